@@ -0,0 +1,114 @@
+package email
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"time"
+)
+
+// Mailer sends emails over SMTP.
+type Mailer struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// Message represents a rendered email.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+	// Unsubscribe is the URL to opt out
+	// of similar emails, if any.
+	Unsubscribe string
+}
+
+// Configured returns true if the mailer has
+// enough configuration to send emails.
+func (m Mailer) Configured() bool {
+	return m.Host != "" && m.From != ""
+}
+
+// Send sends the message over SMTP. STARTTLS is used
+// if the server supports it. Credentials are only sent
+// if a username is configured.
+func (m Mailer) Send(msg Message) (err error) {
+	data, err := msg.bytes(m.From, time.Now())
+	if err != nil {
+		return
+	}
+
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	addr := net.JoinHostPort(m.Host, strconv.Itoa(m.Port))
+
+	return smtp.SendMail(addr, auth, m.From, []string{msg.To}, data)
+}
+
+// bytes encodes the message as a multipart
+// MIME message with text & html alternatives.
+func (msg Message) bytes(from string, date time.Time) (data []byte, err error) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	}
+
+	for _, part := range parts {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		qw := quotedprintable.NewWriter(w)
+		if _, err := qw.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qw.Close(); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = mw.Close(); err != nil {
+		return
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	if msg.Unsubscribe != "" {
+		fmt.Fprintf(&buf, "List-Unsubscribe: <%s>\r\n", msg.Unsubscribe)
+		fmt.Fprintf(&buf, "List-Unsubscribe-Post: List-Unsubscribe=One-Click\r\n")
+	}
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n", mw.Boundary())
+	fmt.Fprintf(&buf, "\r\n")
+	buf.Write(body.Bytes())
+
+	data = buf.Bytes()
+
+	return
+}
@@ -0,0 +1,152 @@
+package email
+
+import (
+	"bufio"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpStandIn accepts a single SMTP session &
+// sends the received envelope & data.
+func smtpStandIn(t *testing.T) (host string, port int, received chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { ln.Close() })
+
+	received = make(chan string, 1)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		tp := textproto.NewConn(conn)
+		var session strings.Builder
+
+		tp.PrintfLine("220 localhost ESMTP")
+
+		for {
+			line, err := tp.ReadLine()
+			if err != nil {
+				return
+			}
+
+			switch verb := strings.ToUpper(strings.Fields(line)[0]); verb {
+			case "EHLO", "HELO":
+				tp.PrintfLine("250 localhost")
+			case "MAIL", "RCPT":
+				session.WriteString(line + "\n")
+				tp.PrintfLine("250 OK")
+			case "DATA":
+				tp.PrintfLine("354 Go ahead")
+				data, err := tp.ReadDotBytes()
+				if err != nil {
+					return
+				}
+				session.Write(data)
+				tp.PrintfLine("250 OK")
+			case "QUIT":
+				tp.PrintfLine("221 Bye")
+				received <- session.String()
+				return
+			default:
+				tp.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+
+	addr := ln.Addr().(*net.TCPAddr)
+
+	return addr.IP.String(), addr.Port, received
+}
+
+func TestMailerSend(t *testing.T) {
+	host, port, received := smtpStandIn(t)
+
+	mailer := Mailer{
+		Host: host,
+		Port: port,
+		From: "alerts@measure.sh",
+	}
+
+	msg := Message{
+		To:          "dev@example.com",
+		Subject:     "Crash rate spiked",
+		Text:        "Crash rate spiked to 3.00%",
+		HTML:        "<h1>Crash rate spiked to 3.00%</h1>",
+		Unsubscribe: "https://api.measure.sh/emails/unsubscribe?token=abc",
+	}
+
+	if err := mailer.Send(msg); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	var session string
+	select {
+	case session = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected SMTP stand-in to receive the email")
+	}
+
+	expected := []string{
+		"MAIL FROM:<alerts@measure.sh>",
+		"RCPT TO:<dev@example.com>",
+		"Subject: Crash rate spiked",
+		"List-Unsubscribe: <https://api.measure.sh/emails/unsubscribe?token=abc>",
+		"List-Unsubscribe-Post: List-Unsubscribe=One-Click",
+		"Content-Type: text/plain; charset=utf-8",
+		"Content-Type: text/html; charset=utf-8",
+		"Crash rate spiked to 3.00%",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(session, e) {
+			t.Errorf("Expected session to contain %q, but got %s", e, session)
+		}
+	}
+}
+
+func TestMailerConfigured(t *testing.T) {
+	if (Mailer{Host: "localhost"}).Configured() {
+		t.Errorf("Expected mailer without sender to not be configured")
+	}
+
+	if !(Mailer{Host: "localhost", Port: 25, From: "alerts@measure.sh"}).Configured() {
+		t.Errorf("Expected mailer to be configured")
+	}
+}
+
+func TestMessageBytesEncodesSubject(t *testing.T) {
+	msg := Message{
+		To:      "dev@example.com",
+		Subject: "Crash rate spiked for Café",
+	}
+
+	data, err := msg.bytes("alerts@measure.sh", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := textproto.NewReader(bufio.NewReader(strings.NewReader(string(data))))
+	header, err := r.ReadMIMEHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if subject := header.Get("Subject"); !strings.HasPrefix(subject, "=?utf-8?q?") {
+		t.Errorf("Expected encoded subject, but got %q", subject)
+	}
+
+	if _, err := strconv.Unquote(strings.TrimPrefix(header.Get("Content-Type"), "multipart/alternative; boundary=")); err != nil {
+		t.Errorf("Expected quoted boundary, but got %q", header.Get("Content-Type"))
+	}
+}
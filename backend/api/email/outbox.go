@@ -0,0 +1,354 @@
+package email
+
+import (
+	"backend/api/server"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+const (
+	StatusPending = "pending"
+	StatusSending = "sending"
+	StatusSent    = "sent"
+	StatusFailed  = "failed"
+)
+
+// maxAttempts is the maximum count of delivery
+// attempts after which an email is marked failed.
+const maxAttempts = 8
+
+// maxBackoff is the maximum delay between
+// delivery attempts.
+const maxBackoff = 6 * time.Hour
+
+// dispatchBatchSize is the maximum count of emails
+// delivered in a single dispatch.
+const dispatchBatchSize = 50
+
+// rateLimit is the maximum count of emails delivered
+// to a single recipient within rateLimitWindow.
+const rateLimit = 10
+
+// rateLimitWindow is the window of time over which
+// the rate limit of a recipient applies.
+const rateLimitWindow = time.Hour
+
+// rateLimitDelay is the delay after which delivery
+// of a rate limited email is attempted again.
+const rateLimitDelay = 15 * time.Minute
+
+// sendingTimeout is the duration after which emails
+// stuck sending, like when the dispatcher crashed
+// mid-delivery, are claimed again.
+const sendingTimeout = 10 * time.Minute
+
+// Email represents an email queued in the outbox.
+type Email struct {
+	ID        uuid.UUID
+	Kind      string
+	Recipient string
+	UserID    *uuid.UUID
+	AppID     *uuid.UUID
+	Payload   any
+	Attempts  int
+}
+
+// Enqueue adds emails to the outbox for delivery.
+func Enqueue(ctx context.Context, emails ...Email) (err error) {
+	tx, err := server.Server.PgPool.Begin(ctx)
+	if err != nil {
+		return
+	}
+
+	defer tx.Rollback(ctx)
+
+	if err = EnqueueTx(ctx, &tx, emails...); err != nil {
+		return
+	}
+
+	return tx.Commit(ctx)
+}
+
+// EnqueueTx adds emails to the outbox for delivery
+// within the transaction, so that they are only
+// delivered if the transaction commits.
+func EnqueueTx(ctx context.Context, tx *pgx.Tx, emails ...Email) (err error) {
+	now := time.Now()
+
+	for _, e := range emails {
+		if e.ID == uuid.Nil {
+			e.ID = uuid.New()
+		}
+
+		stmt := sqlf.PostgreSQL.InsertInto("public.email_outbox").
+			Set("id", e.ID).
+			Set("kind", e.Kind).
+			Set("recipient", e.Recipient).
+			Set("user_id", e.UserID).
+			Set("app_id", e.AppID).
+			Set("payload", e.Payload).
+			Set("status", StatusPending).
+			Set("next_attempt_at", now).
+			Set("created_at", now).
+			Set("updated_at", now)
+
+		_, err = (*tx).Exec(ctx, stmt.String(), stmt.Args()...)
+		stmt.Close()
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// RunDispatcher delivers emails from the outbox
+// every interval until the context is done.
+func RunDispatcher(ctx context.Context, interval time.Duration) {
+	config := server.Server.Config
+	mailer := Mailer{
+		Host:     config.SMTPHost,
+		Port:     config.SMTPPort,
+		Username: config.SMTPUsername,
+		Password: config.SMTPPassword,
+		From:     config.EmailFrom,
+	}
+
+	if !mailer.Configured() {
+		fmt.Println("SMTP_HOST or EMAIL_FROM env var not set, emails won't be sent")
+		return
+	}
+
+	r := renderer{
+		siteOrigin: config.SiteOrigin,
+		apiOrigin:  config.APIOrigin,
+		secret:     config.AccessTokenSecret,
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := dispatch(ctx, mailer, r); err != nil {
+				fmt.Println("failed to dispatch emails", err)
+			}
+		}
+	}
+}
+
+// dispatch claims due emails from the outbox
+// and delivers them. If delivery can't proceed, the
+// emails left are released back to pending, so they
+// don't stay stuck sending until the timeout.
+func dispatch(ctx context.Context, mailer Mailer, r renderer) (err error) {
+	emails, err := claim(ctx)
+	if err != nil {
+		return
+	}
+
+	for i, e := range emails {
+		if err = deliver(ctx, mailer, r, e); err != nil {
+			// release even when the context is done
+			if releaseErr := release(context.WithoutCancel(ctx), emails[i:]); releaseErr != nil {
+				fmt.Println("failed to release claimed emails", releaseErr)
+			}
+			return
+		}
+	}
+
+	return
+}
+
+// deliver sends a claimed email & records the
+// outcome. The returned error is only about
+// recording the outcome, failures to send are
+// recorded on the email.
+func deliver(ctx context.Context, mailer Mailer, r renderer, e Email) (err error) {
+	sent, err := countSent(ctx, e.Recipient, time.Now().Add(-rateLimitWindow))
+	if err != nil {
+		return
+	}
+
+	if sent >= rateLimit {
+		return e.postpone(ctx, time.Now().Add(rateLimitDelay))
+	}
+
+	payload, _ := e.Payload.([]byte)
+
+	msg, err := r.render(e.Kind, payload, e.UserID)
+	if err != nil {
+		// rendering won't succeed on retries
+		return e.fail(ctx, err, true)
+	}
+
+	msg.To = e.Recipient
+
+	if err := mailer.Send(msg); err != nil {
+		return e.fail(ctx, err, false)
+	}
+
+	return e.markSent(ctx)
+}
+
+// claim marks due emails as sending & returns them.
+// Rows locked by other dispatchers are skipped.
+func claim(ctx context.Context) (emails []Email, err error) {
+	now := time.Now()
+
+	stmt := sqlf.PostgreSQL.Update("public.email_outbox").
+		Set("status", StatusSending).
+		Set("updated_at", now).
+		Where("id in (select id from public.email_outbox where (status = ? and next_attempt_at <= ?) or (status = ? and updated_at < ?) order by next_attempt_at limit ? for update skip locked)", StatusPending, now, StatusSending, now.Add(-sendingTimeout), dispatchBatchSize).
+		Returning("id").
+		Returning("kind").
+		Returning("recipient").
+		Returning("user_id").
+		Returning("app_id").
+		Returning("payload").
+		Returning("attempts")
+
+	defer stmt.Close()
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e Email
+		var payload []byte
+		if err = rows.Scan(&e.ID, &e.Kind, &e.Recipient, &e.UserID, &e.AppID, &payload, &e.Attempts); err != nil {
+			return
+		}
+		e.Payload = payload
+		emails = append(emails, e)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// countSent counts emails delivered to the
+// recipient since the given time.
+func countSent(ctx context.Context, recipient string, since time.Time) (count int, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.email_outbox").
+		Select("count(*)").
+		Where("recipient = ?", recipient).
+		Where("sent_at >= ?", since)
+
+	defer stmt.Close()
+
+	err = server.Server.PgPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&count)
+
+	return
+}
+
+// backoff computes the delay before the next
+// delivery attempt, doubling with every attempt.
+func backoff(attempts int) time.Duration {
+	delay := time.Minute
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxBackoff)
+}
+
+// releaseStmt builds the statement moving emails
+// still sending back to pending, without counting
+// it as a delivery attempt.
+func releaseStmt(ids []uuid.UUID) *sqlf.Stmt {
+	return sqlf.PostgreSQL.Update("public.email_outbox").
+		Set("status", StatusPending).
+		Set("updated_at", time.Now()).
+		Where("id = any(?)", ids).
+		Where("status = ?", StatusSending)
+}
+
+// release moves claimed emails back to pending.
+func release(ctx context.Context, emails []Email) (err error) {
+	ids := make([]uuid.UUID, len(emails))
+	for i, e := range emails {
+		ids[i] = e.ID
+	}
+
+	stmt := releaseStmt(ids)
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// postpone moves the email back to pending without
+// counting it as a delivery attempt.
+func (e Email) postpone(ctx context.Context, at time.Time) (err error) {
+	stmt := sqlf.PostgreSQL.Update("public.email_outbox").
+		Set("status", StatusPending).
+		Set("next_attempt_at", at).
+		Set("updated_at", time.Now()).
+		Where("id = ?", e.ID)
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// fail records a failed delivery attempt. The email is
+// retried with backoff unless it has run out of attempts
+// or the failure is permanent.
+func (e Email) fail(ctx context.Context, cause error, permanent bool) (err error) {
+	attempts := e.Attempts + 1
+	status := StatusPending
+	if permanent || attempts >= maxAttempts {
+		status = StatusFailed
+	}
+
+	now := time.Now()
+
+	stmt := sqlf.PostgreSQL.Update("public.email_outbox").
+		Set("status", status).
+		Set("attempts", attempts).
+		Set("last_error", cause.Error()).
+		Set("next_attempt_at", now.Add(backoff(attempts))).
+		Set("updated_at", now).
+		Where("id = ?", e.ID)
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// markSent records a successful delivery.
+func (e Email) markSent(ctx context.Context) (err error) {
+	now := time.Now()
+
+	stmt := sqlf.PostgreSQL.Update("public.email_outbox").
+		Set("status", StatusSent).
+		Set("sent_at", now).
+		Set("updated_at", now).
+		Where("id = ?", e.ID)
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
@@ -0,0 +1,44 @@
+package email
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestBackoff(t *testing.T) {
+	expected := map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		5:  16 * time.Minute,
+		20: maxBackoff,
+	}
+
+	for attempts, delay := range expected {
+		if actual := backoff(attempts); actual != delay {
+			t.Errorf("Expected backoff of %v after %d attempts, but got %v", delay, attempts, actual)
+		}
+	}
+}
+
+func TestReleaseStmt(t *testing.T) {
+	ids := []uuid.UUID{uuid.New(), uuid.New()}
+
+	stmt := releaseStmt(ids)
+	defer stmt.Close()
+
+	expected := "UPDATE public.email_outbox SET status=$1, updated_at=$2 WHERE id = any($3) AND status = $4"
+	if stmt.String() != expected {
+		t.Errorf("Expected %q, but got %q", expected, stmt.String())
+	}
+
+	args := stmt.Args()
+	if args[0] != StatusPending || args[3] != StatusSending {
+		t.Errorf("Expected only emails sending to be released as pending, but got %v", args)
+	}
+
+	if actual, ok := args[2].([]uuid.UUID); !ok || len(actual) != len(ids) {
+		t.Errorf("Expected ids %v, but got %v", ids, args[2])
+	}
+}
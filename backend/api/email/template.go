@@ -0,0 +1,204 @@
+package email
+
+import (
+	"backend/api/server"
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"net/url"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	KindAlert  = "alert"
+	KindInvite = "invite"
+	KindDigest = "digest"
+	KindIssue  = "issue"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+var htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html"))
+
+var textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt"))
+
+// AlertPayload is the data an alert
+// email is rendered from.
+type AlertPayload struct {
+	TeamID      uuid.UUID `json:"team_id"`
+	AppID       uuid.UUID `json:"app_id"`
+	AppName     string    `json:"app_name"`
+	Type        string    `json:"type"`
+	Message     string    `json:"message"`
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+}
+
+// IssuePayload is the data a new issue
+// or regression email is rendered from.
+type IssuePayload struct {
+	TeamID    uuid.UUID `json:"team_id"`
+	AppID     uuid.UUID `json:"app_id"`
+	AppName   string    `json:"app_name"`
+	Type      string    `json:"type"`
+	Message   string    `json:"message"`
+	GroupType string    `json:"group_type"`
+	GroupID   uuid.UUID `json:"group_id"`
+	Title     string    `json:"title"`
+	Frame     string    `json:"frame"`
+	Version   string    `json:"version"`
+	SeenAt    time.Time `json:"seen_at"`
+	// Path is the path of the issue's dashboard
+	// page, relative to the team.
+	Path string `json:"path"`
+}
+
+// InvitePayload is the data a team
+// invite email is rendered from.
+type InvitePayload struct {
+	TeamID    uuid.UUID `json:"team_id"`
+	TeamName  string    `json:"team_name"`
+	InvitedBy string    `json:"invited_by"`
+	Role      string    `json:"role"`
+}
+
+// DigestPayload is the data a digest
+// email is rendered from.
+type DigestPayload struct {
+	TeamID   uuid.UUID       `json:"team_id"`
+	AppID    uuid.UUID       `json:"app_id"`
+	AppName  string          `json:"app_name"`
+	Period   string          `json:"period"`
+	From     time.Time       `json:"from"`
+	To       time.Time       `json:"to"`
+	Sections []DigestSection `json:"sections"`
+}
+
+// DigestSection is a titled list of
+// highlights in a digest email.
+type DigestSection struct {
+	Title string   `json:"title"`
+	Items []string `json:"items"`
+}
+
+// templateData is the data passed to
+// email templates.
+type templateData struct {
+	Payload     any
+	Dashboard   string
+	Unsubscribe string
+}
+
+// renderer renders emails from their
+// kind & payload.
+type renderer struct {
+	siteOrigin string
+	apiOrigin  string
+	secret     []byte
+}
+
+// render renders the subject, text & html of an email.
+// Unsubscribe links are only rendered for emails to users.
+func (r renderer) render(kind string, payload []byte, userID *uuid.UUID) (msg Message, err error) {
+	var data templateData
+
+	switch kind {
+	case KindAlert:
+		var p AlertPayload
+		if err = json.Unmarshal(payload, &p); err != nil {
+			return
+		}
+		data.Payload = p
+		data.Dashboard = fmt.Sprintf("%s/%s/overview", r.siteOrigin, p.TeamID)
+		if userID != nil {
+			data.Unsubscribe = r.unsubscribeURL(*userID, p.AppID, p.Type)
+		}
+		msg.Subject = p.Message
+	case KindIssue:
+		var p IssuePayload
+		if err = json.Unmarshal(payload, &p); err != nil {
+			return
+		}
+		data.Payload = p
+		data.Dashboard = fmt.Sprintf("%s/%s/%s", r.siteOrigin, p.TeamID, p.Path)
+		if userID != nil {
+			data.Unsubscribe = r.unsubscribeURL(*userID, p.AppID, p.Type)
+		}
+		msg.Subject = p.Message
+	case KindInvite:
+		var p InvitePayload
+		if err = json.Unmarshal(payload, &p); err != nil {
+			return
+		}
+		data.Payload = p
+		data.Dashboard = fmt.Sprintf("%s/auth/login", r.siteOrigin)
+		msg.Subject = fmt.Sprintf("You've been invited to join %s on Measure", p.TeamName)
+	case KindDigest:
+		var p DigestPayload
+		if err = json.Unmarshal(payload, &p); err != nil {
+			return
+		}
+		data.Payload = p
+		data.Dashboard = fmt.Sprintf("%s/%s/overview", r.siteOrigin, p.TeamID)
+		if userID != nil {
+			data.Unsubscribe = r.unsubscribeURL(*userID, p.AppID, KindDigest)
+		}
+		msg.Subject = fmt.Sprintf("Your %s digest for %s", p.Period, p.AppName)
+	default:
+		err = fmt.Errorf("unknown email kind %q", kind)
+		return
+	}
+
+	var text, html bytes.Buffer
+
+	if err = textTemplates.ExecuteTemplate(&text, kind+".txt", data); err != nil {
+		return
+	}
+
+	if err = htmlTemplates.ExecuteTemplate(&html, kind+".html", data); err != nil {
+		return
+	}
+
+	msg.Text = text.String()
+	msg.HTML = html.String()
+	msg.Unsubscribe = data.Unsubscribe
+
+	return
+}
+
+// Preview renders an email of the kind from its
+// payload, without an unsubscribe link, to show
+// what the email looks like.
+func Preview(kind string, payload any) (msg Message, err error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	config := server.Server.Config
+	r := renderer{
+		siteOrigin: config.SiteOrigin,
+		apiOrigin:  config.APIOrigin,
+		secret:     config.AccessTokenSecret,
+	}
+
+	return r.render(kind, data, nil)
+}
+
+// unsubscribeURL creates the link to unsubscribe
+// a user from emails of a topic for an app.
+func (r renderer) unsubscribeURL(userID, appID uuid.UUID, topic string) string {
+	token := UnsubscribeToken(r.secret, Unsubscribe{
+		UserID: userID,
+		AppID:  appID,
+		Topic:  topic,
+	})
+
+	return fmt.Sprintf("%s/emails/unsubscribe?token=%s", r.apiOrigin, url.QueryEscape(token))
+}
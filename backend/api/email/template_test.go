@@ -0,0 +1,214 @@
+package email
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRenderAlert(t *testing.T) {
+	r := renderer{
+		siteOrigin: "https://measure.sh",
+		apiOrigin:  "https://api.measure.sh",
+		secret:     []byte("secret"),
+	}
+
+	teamID := uuid.New()
+	userID := uuid.New()
+
+	payload, _ := json.Marshal(AlertPayload{
+		TeamID:      teamID,
+		AppID:       uuid.New(),
+		AppName:     "Shop <Android>",
+		Type:        "crash_rate_spike",
+		Message:     "Crash rate spiked to 3.00% from a baseline of 1.00% for Shop <Android>",
+		WindowStart: time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC),
+		WindowEnd:   time.Date(2024, 12, 23, 11, 0, 0, 0, time.UTC),
+	})
+
+	msg, err := r.render(KindAlert, payload, &userID)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if msg.Subject != "Crash rate spiked to 3.00% from a baseline of 1.00% for Shop <Android>" {
+		t.Errorf("Unexpected subject %q", msg.Subject)
+	}
+
+	if !strings.HasPrefix(msg.Unsubscribe, "https://api.measure.sh/emails/unsubscribe?token=") {
+		t.Errorf("Unexpected unsubscribe link %q", msg.Unsubscribe)
+	}
+
+	dashboard := "https://measure.sh/" + teamID.String() + "/overview"
+	for _, body := range []string{msg.Text, msg.HTML} {
+		if !strings.Contains(body, dashboard) {
+			t.Errorf("Expected body to link to %q, but got %s", dashboard, body)
+		}
+	}
+
+	if !strings.Contains(msg.Text, "Shop <Android>") {
+		t.Errorf("Expected text body to contain app name as is, but got %s", msg.Text)
+	}
+
+	if strings.Contains(msg.HTML, "Shop <Android>") || !strings.Contains(msg.HTML, "Shop &lt;Android&gt;") {
+		t.Errorf("Expected html body to escape app name, but got %s", msg.HTML)
+	}
+}
+
+func TestRenderAlertWithoutUser(t *testing.T) {
+	payload, _ := json.Marshal(AlertPayload{AppName: "Shop"})
+
+	msg, err := renderer{}.render(KindAlert, payload, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if msg.Unsubscribe != "" || strings.Contains(msg.Text, "Unsubscribe") {
+		t.Errorf("Expected no unsubscribe link, but got %q", msg.Unsubscribe)
+	}
+}
+
+func TestRenderIssue(t *testing.T) {
+	r := renderer{
+		siteOrigin: "https://measure.sh",
+		apiOrigin:  "https://api.measure.sh",
+		secret:     []byte("secret"),
+	}
+
+	teamID := uuid.New()
+	appID := uuid.New()
+	groupID := uuid.New()
+	userID := uuid.New()
+
+	payload, _ := json.Marshal(IssuePayload{
+		TeamID:    teamID,
+		AppID:     appID,
+		AppName:   "Shop",
+		Type:      "new_issue",
+		Message:   "New crash in Shop",
+		GroupType: "exception",
+		GroupID:   groupID,
+		Title:     "java.lang.NullPointerException: cart is null",
+		Frame:     "com.shop.CheckoutActivity.onCreate(CheckoutActivity.kt:42)",
+		Version:   "1.2.0 (120)",
+		SeenAt:    time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC),
+		Path:      "crashes/" + appID.String() + "/" + groupID.String() + "/NullPointerException",
+	})
+
+	msg, err := r.render(KindIssue, payload, &userID)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if msg.Subject != "New crash in Shop" {
+		t.Errorf("Unexpected subject %q", msg.Subject)
+	}
+
+	if msg.Unsubscribe == "" {
+		t.Errorf("Expected unsubscribe link")
+	}
+
+	dashboard := "https://measure.sh/" + teamID.String() + "/crashes/" + appID.String() + "/" + groupID.String() + "/NullPointerException"
+	for _, body := range []string{msg.Text, msg.HTML} {
+		if !strings.Contains(body, dashboard) {
+			t.Errorf("Expected body to link to %q, but got %s", dashboard, body)
+		}
+		if !strings.Contains(body, "CheckoutActivity.kt:42") {
+			t.Errorf("Expected body to contain the top in-app frame, but got %s", body)
+		}
+		if !strings.Contains(body, "Seen in 1.2.0 (120) on Dec 23, 10:00 UTC") {
+			t.Errorf("Expected body to contain version & time, but got %s", body)
+		}
+	}
+}
+
+func TestRenderInvite(t *testing.T) {
+	r := renderer{siteOrigin: "https://measure.sh"}
+
+	payload, _ := json.Marshal(InvitePayload{
+		TeamName:  "Acme",
+		InvitedBy: "Jane",
+		Role:      "developer",
+	})
+
+	msg, err := r.render(KindInvite, payload, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if msg.Subject != "You've been invited to join Acme on Measure" {
+		t.Errorf("Unexpected subject %q", msg.Subject)
+	}
+
+	if !strings.Contains(msg.Text, "Jane invited you to join the Acme team on Measure as developer") {
+		t.Errorf("Unexpected text body %s", msg.Text)
+	}
+
+	if !strings.Contains(msg.HTML, "https://measure.sh/auth/login") {
+		t.Errorf("Expected html body to link to sign in, but got %s", msg.HTML)
+	}
+}
+
+func TestRenderDigest(t *testing.T) {
+	payload, _ := json.Marshal(DigestPayload{
+		AppName: "Shop",
+		Period:  "weekly",
+		From:    time.Date(2024, 12, 16, 0, 0, 0, 0, time.UTC),
+		To:      time.Date(2024, 12, 22, 0, 0, 0, 0, time.UTC),
+		Sections: []DigestSection{
+			{Title: "New issues", Items: []string{"NullPointerException in CheckoutActivity"}},
+		},
+	})
+
+	msg, err := renderer{}.render(KindDigest, payload, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if msg.Subject != "Your weekly digest for Shop" {
+		t.Errorf("Unexpected subject %q", msg.Subject)
+	}
+
+	if !strings.Contains(msg.Text, "- NullPointerException in CheckoutActivity") {
+		t.Errorf("Unexpected text body %s", msg.Text)
+	}
+
+	if msg.Unsubscribe != "" {
+		t.Errorf("Expected no unsubscribe link without user, but got %q", msg.Unsubscribe)
+	}
+
+	userID := uuid.New()
+	r := renderer{
+		apiOrigin: "https://api.measure.sh",
+		secret:    []byte("secret"),
+	}
+
+	msg, err = r.render(KindDigest, payload, &userID)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	token, err := url.QueryUnescape(strings.TrimPrefix(msg.Unsubscribe, "https://api.measure.sh/emails/unsubscribe?token="))
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	unsubscribe, err := ParseUnsubscribeToken(r.secret, token)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if unsubscribe.UserID != userID || unsubscribe.Topic != KindDigest {
+		t.Errorf("Unexpected unsubscribe %+v", unsubscribe)
+	}
+}
+
+func TestRenderUnknownKind(t *testing.T) {
+	if _, err := (renderer{}).render("unknown", []byte("{}"), nil); err == nil {
+		t.Errorf("Expected error for unknown kind")
+	}
+}
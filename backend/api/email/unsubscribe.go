@@ -0,0 +1,89 @@
+package email
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Unsubscribe represents a user's request to
+// stop receiving emails of a topic for an app.
+type Unsubscribe struct {
+	UserID uuid.UUID `json:"user_id"`
+	AppID  uuid.UUID `json:"app_id"`
+	Topic  string    `json:"topic"`
+}
+
+// UnsubscribeToken encodes & signs an unsubscribe
+// request so that unsubscribe links can't be forged.
+func UnsubscribeToken(secret []byte, u Unsubscribe) string {
+	data, _ := json.Marshal(u)
+	payload := base64.RawURLEncoding.EncodeToString(data)
+
+	return payload + "." + sign(secret, payload)
+}
+
+// ParseUnsubscribeToken verifies & decodes an
+// unsubscribe token.
+func ParseUnsubscribeToken(secret []byte, token string) (u Unsubscribe, err error) {
+	payload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		err = errors.New("malformed unsubscribe token")
+		return
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(sign(secret, payload))) {
+		err = errors.New("invalid unsubscribe token signature")
+		return
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(data, &u)
+
+	return
+}
+
+// sign computes the signature of an unsubscribe
+// token's payload.
+func sign(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte("unsubscribe:" + payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// unsubscribePage is the data the unsubscribe
+// confirmation page is rendered from.
+type unsubscribePage struct {
+	Token  string
+	Topic  string
+	Digest bool
+	Done   bool
+	// Unsubscribe is always empty, the page's
+	// footer must not link to itself.
+	Unsubscribe string
+}
+
+// RenderUnsubscribePage renders the page asking a user
+// to confirm unsubscribing, or telling them they have
+// been unsubscribed once done.
+func RenderUnsubscribePage(w io.Writer, token string, u Unsubscribe, done bool) error {
+	topic := strings.ReplaceAll(u.Topic, "_", " ")
+
+	return htmlTemplates.ExecuteTemplate(w, "unsubscribe.html", unsubscribePage{
+		Token:  token,
+		Topic:  topic,
+		Digest: u.Topic == KindDigest,
+		Done:   done,
+	})
+}
@@ -0,0 +1,71 @@
+package email
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestUnsubscribeToken(t *testing.T) {
+	secret := []byte("secret")
+	expected := Unsubscribe{
+		UserID: uuid.New(),
+		AppID:  uuid.New(),
+		Topic:  "crash_rate_spike",
+	}
+
+	token := UnsubscribeToken(secret, expected)
+
+	actual, err := ParseUnsubscribeToken(secret, token)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if actual != expected {
+		t.Errorf("Expected %+v, but got %+v", expected, actual)
+	}
+
+	if _, err := ParseUnsubscribeToken([]byte("other"), token); err == nil {
+		t.Errorf("Expected error for token signed with another secret")
+	}
+
+	forged := UnsubscribeToken(secret, Unsubscribe{UserID: uuid.New(), AppID: expected.AppID, Topic: expected.Topic})
+	forgedPayload, _, _ := strings.Cut(forged, ".")
+	_, signature, _ := strings.Cut(token, ".")
+	if _, err := ParseUnsubscribeToken(secret, forgedPayload+"."+signature); err == nil {
+		t.Errorf("Expected error for tampered token")
+	}
+
+	if _, err := ParseUnsubscribeToken(secret, "malformed"); err == nil {
+		t.Errorf("Expected error for malformed token")
+	}
+}
+
+func TestRenderUnsubscribePage(t *testing.T) {
+	u := Unsubscribe{UserID: uuid.New(), AppID: uuid.New(), Topic: "crash_rate_spike"}
+
+	var confirm strings.Builder
+	if err := RenderUnsubscribePage(&confirm, "payload.signature", u, false); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	for _, e := range []string{`<form method="post">`, `value="payload.signature"`, "crash rate spike emails", "alert preferences"} {
+		if !strings.Contains(confirm.String(), e) {
+			t.Errorf("Expected confirmation page to contain %q, but got %s", e, confirm.String())
+		}
+	}
+
+	var done strings.Builder
+	if err := RenderUnsubscribePage(&done, "payload.signature", Unsubscribe{Topic: KindDigest}, true); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if strings.Contains(done.String(), "<form") {
+		t.Errorf("Expected no form once unsubscribed, but got %s", done.String())
+	}
+
+	if !strings.Contains(done.String(), "digest preferences") {
+		t.Errorf("Expected page to point to digest preferences, but got %s", done.String())
+	}
+}
@@ -0,0 +1,335 @@
+package event
+
+import (
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ANRCauseDeadlock is the cause of an ANR where
+// the main thread is part of, or waits on, a cycle
+// of threads waiting on each other's locks.
+const ANRCauseDeadlock = "deadlock"
+
+// ANRCauseLockContention is the cause of an ANR
+// where the main thread waits to acquire a lock or
+// waits on another thread.
+const ANRCauseLockContention = "lock_contention"
+
+// ANRCauseIOOnMain is the cause of an ANR where
+// the main thread performs disk, database or
+// network I/O.
+const ANRCauseIOOnMain = "io_on_main"
+
+// ANRCauseBinderCall is the cause of an ANR where
+// the main thread waits on a binder call to
+// another process.
+const ANRCauseBinderCall = "binder_call"
+
+// ANRCauseCPU is the cause of an ANR where the
+// main thread is busy executing code.
+const ANRCauseCPU = "cpu"
+
+// ANRCauseUnknown is the cause of an ANR that
+// could not be determined, like when the main
+// thread was already idle at the time of capture.
+const ANRCauseUnknown = "unknown"
+
+// ANRCauses is the list of all
+// causes of ANRs.
+var ANRCauses = []string{
+	ANRCauseDeadlock,
+	ANRCauseLockContention,
+	ANRCauseIOOnMain,
+	ANRCauseBinderCall,
+	ANRCauseCPU,
+	ANRCauseUnknown,
+}
+
+// mainThreadName is the name of the
+// main thread in thread dumps.
+const mainThreadName = "main"
+
+// lockFramePrefixes is the list of frame prefixes
+// denoting a thread waiting on a lock or on another
+// thread.
+var lockFramePrefixes = []string{
+	"java.lang.Object.wait",
+	"java.lang.Thread.join",
+	"java.lang.Thread.parkFor",
+	"sun.misc.Unsafe.park",
+	"jdk.internal.misc.Unsafe.park",
+	"java.util.concurrent.locks.",
+	"java.util.concurrent.CountDownLatch.await",
+	"java.util.concurrent.CyclicBarrier.await",
+	"java.util.concurrent.Semaphore.acquire",
+	"java.util.concurrent.FutureTask.get",
+	"java.util.concurrent.FutureTask.awaitDone",
+	"kotlinx.coroutines.BlockingCoroutine.joinBlocking",
+}
+
+// binderFramePrefixes is the list of frame prefixes
+// denoting a thread making a binder call.
+var binderFramePrefixes = []string{
+	"android.os.BinderProxy.transact",
+	"android.os.BinderProxy.transactNative",
+}
+
+// ioFramePrefixes is the list of frame prefixes
+// denoting a thread performing I/O.
+var ioFramePrefixes = []string{
+	"java.io.",
+	"java.nio.",
+	"java.net.",
+	"javax.net.ssl.",
+	"sun.nio.",
+	"libcore.io.",
+	"dalvik.system.BlockGuard",
+	"android.os.StrictMode$AndroidBlockGuardPolicy",
+	"android.database.sqlite.",
+	"android.app.SharedPreferencesImpl",
+	"android.app.QueuedWork",
+	"com.android.org.conscrypt.",
+	"okhttp3.",
+	"okio.",
+}
+
+// idleFramePrefixes is the list of frame prefixes
+// denoting an idle main thread waiting for the
+// next message.
+var idleFramePrefixes = []string{
+	"android.os.MessageQueue.nativePollOnce",
+}
+
+// blockedThreadStates is the list of thread states
+// denoting a thread waiting on a lock.
+var blockedThreadStates = []string{
+	"BLOCKED",
+	"WAITING",
+	"TIMED_WAITING",
+}
+
+// ANRLock represents a lock with its owning
+// thread & the threads waiting to acquire it.
+type ANRLock struct {
+	// Lock is the identity of the lock.
+	Lock string `json:"lock"`
+	// Owner is the name of the thread holding
+	// the lock. Empty if unknown.
+	Owner string `json:"owner"`
+	// Waiters is the list of names of threads
+	// waiting to acquire the lock.
+	Waiters []string `json:"waiters"`
+}
+
+// ANRAnalysis represents the outcome of analysing
+// an ANR's main thread stack & thread dump.
+type ANRAnalysis struct {
+	// Cause is the likely cause of the ANR.
+	Cause string `json:"cause"`
+	// BlockingFrame is the main thread's frame that
+	// determined the cause, or the top frame if no
+	// such frame was found.
+	BlockingFrame string `json:"blocking_frame"`
+	// CulpritFrame is the main thread's top most
+	// frame belonging to the app.
+	CulpritFrame string `json:"culprit_frame"`
+	// Locks is the list of contended locks. Only
+	// available when threads report their locks.
+	Locks []ANRLock `json:"locks"`
+	// Deadlocks is the list of cycles of threads
+	// waiting on each other's locks.
+	Deadlocks [][]string `json:"deadlocks"`
+}
+
+// Analyze analyses the ANR's main thread stack &
+// thread dump to find the main thread's blocking
+// frame, contended locks, deadlocks and to
+// classify the cause of the ANR.
+//
+// Locks & deadlocks are only detected when
+// threads report their state & locks.
+func (a ANR) Analyze() (analysis ANRAnalysis) {
+	analysis.Cause = ANRCauseUnknown
+	analysis.Locks = []ANRLock{}
+	analysis.Deadlocks = [][]string{}
+
+	var frames Frames
+	if len(a.Exceptions) > 0 {
+		frames = a.Exceptions[len(a.Exceptions)-1].Frames
+	}
+
+	if len(frames) > 0 {
+		analysis.Cause, analysis.BlockingFrame = classifyMainFrames(frames)
+	}
+
+	for _, f := range frames {
+		if f.IsInApp() {
+			analysis.CulpritFrame = f.String()
+			break
+		}
+	}
+
+	var mainDeadlocked bool
+	analysis.Locks, analysis.Deadlocks, mainDeadlocked = analyzeLocks(a.Threads)
+
+	main := slices.IndexFunc(a.Threads, func(t Thread) bool {
+		return t.Name == mainThreadName
+	})
+
+	switch {
+	case mainDeadlocked:
+		analysis.Cause = ANRCauseDeadlock
+	case main >= 0 && analysis.Cause == ANRCauseCPU && a.Threads[main].isWaitingOnLock():
+		analysis.Cause = ANRCauseLockContention
+	}
+
+	return
+}
+
+// isWaitingOnLock returns true if the thread
+// reports waiting to acquire a lock.
+func (t Thread) isWaitingOnLock() bool {
+	return t.WaitingOn != "" && slices.Contains(blockedThreadStates, strings.ToUpper(t.State))
+}
+
+// classifyMainFrames classifies the cause of an ANR
+// by walking the main thread's frames from the top
+// until the first frame of the app that does not match
+// any known blocking pattern.
+func classifyMainFrames(frames Frames) (cause, blockingFrame string) {
+	if hasFramePrefix(frames[0], idleFramePrefixes) {
+		return ANRCauseUnknown, frames[0].String()
+	}
+
+	for _, f := range frames {
+		switch {
+		case hasFramePrefix(f, lockFramePrefixes):
+			return ANRCauseLockContention, f.String()
+		case hasFramePrefix(f, binderFramePrefixes):
+			return ANRCauseBinderCall, f.String()
+		case hasFramePrefix(f, ioFramePrefixes):
+			return ANRCauseIOOnMain, f.String()
+		}
+
+		if f.IsInApp() {
+			break
+		}
+	}
+
+	return ANRCauseCPU, frames[0].String()
+}
+
+// hasFramePrefix returns true if the frame's
+// code info starts with any of the prefixes.
+func hasFramePrefix(f Frame, prefixes []string) bool {
+	codeInfo := f.CodeInfo()
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(codeInfo, prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// analyzeLocks computes contended locks and cycles of
+// threads waiting on each other's locks from the locks
+// reported by threads. Also reports if the main thread
+// is part of, or waits on, any such cycle.
+func analyzeLocks(threads Threads) (locks []ANRLock, deadlocks [][]string, mainDeadlocked bool) {
+	locks = []ANRLock{}
+	deadlocks = [][]string{}
+
+	owners := make(map[string]int)
+	for i := range threads {
+		for _, lock := range threads[i].HeldLocks {
+			if _, ok := owners[lock]; !ok {
+				owners[lock] = i
+			}
+		}
+	}
+
+	waiters := make(map[string][]string)
+	for i := range threads {
+		if threads[i].WaitingOn == "" {
+			continue
+		}
+		waiters[threads[i].WaitingOn] = append(waiters[threads[i].WaitingOn], threads[i].Name)
+	}
+
+	for lock, names := range waiters {
+		l := ANRLock{
+			Lock:    lock,
+			Waiters: names,
+		}
+		if owner, ok := owners[lock]; ok {
+			l.Owner = threads[owner].Name
+		}
+		locks = append(locks, l)
+	}
+
+	sort.Slice(locks, func(i, j int) bool {
+		return locks[i].Lock < locks[j].Lock
+	})
+
+	// each thread waits on at most one lock, so
+	// each thread has at most one thread it
+	// waits for.
+	waitsFor := make([]int, len(threads))
+	for i := range threads {
+		waitsFor[i] = -1
+		if owner, ok := owners[threads[i].WaitingOn]; ok && threads[i].WaitingOn != "" && owner != i {
+			waitsFor[i] = owner
+		}
+	}
+
+	inCycle := make([]bool, len(threads))
+	visited := make([]bool, len(threads))
+
+	for start := range threads {
+		if visited[start] {
+			continue
+		}
+
+		// follow the chain of waits marking threads
+		// of this walk until reaching a thread
+		// without waits or a visited thread
+		position := make(map[int]int)
+		var path []int
+		for current := start; current != -1 && !visited[current]; current = waitsFor[current] {
+			visited[current] = true
+			position[current] = len(path)
+			path = append(path, current)
+
+			next := waitsFor[current]
+			if index, ok := position[next]; ok && next != -1 {
+				cycle := path[index:]
+				var names []string
+				for _, t := range cycle {
+					inCycle[t] = true
+					names = append(names, threads[t].Name)
+				}
+				deadlocks = append(deadlocks, names)
+				break
+			}
+		}
+	}
+
+	main := slices.IndexFunc(threads, func(t Thread) bool {
+		return t.Name == mainThreadName
+	})
+
+	// main is deadlocked if it is part of a cycle
+	// or transitively waits on a thread of a cycle
+	seen := make(map[int]bool)
+	for current := main; current != -1 && !seen[current]; current = waitsFor[current] {
+		seen[current] = true
+		if inCycle[current] {
+			mainDeadlocked = true
+			break
+		}
+	}
+
+	return
+}
@@ -0,0 +1,194 @@
+package event
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeANROne(t *testing.T) {
+	anr, err := readANR("./anr_one.json")
+	if err != nil {
+		panic(err)
+	}
+
+	analysis := anr.Analyze()
+
+	expectedCause := ANRCauseCPU
+	expectedCulprit := "sh.measure.sample.ExceptionDemoActivity.deadLock$lambda$10(ExceptionDemoActivity.kt:66)"
+
+	if analysis.Cause != expectedCause {
+		t.Errorf("Expected %q cause, but got %q", expectedCause, analysis.Cause)
+	}
+
+	if analysis.CulpritFrame != expectedCulprit {
+		t.Errorf("Expected %q culprit frame, but got %q", expectedCulprit, analysis.CulpritFrame)
+	}
+}
+
+func TestAnalyzeANRCauses(t *testing.T) {
+	appFrame := Frame{ClassName: "com.example.app.MainActivity", MethodName: "onCreate", FileName: "MainActivity.kt", LineNum: 42}
+
+	cases := []struct {
+		name   string
+		frames Frames
+		cause  string
+	}{
+		{
+			name: "lock contention",
+			frames: Frames{
+				{ClassName: "sun.misc.Unsafe", MethodName: "park"},
+				{ClassName: "java.util.concurrent.locks.LockSupport", MethodName: "park"},
+				{ClassName: "java.util.concurrent.locks.ReentrantLock", MethodName: "lock"},
+				appFrame,
+			},
+			cause: ANRCauseLockContention,
+		},
+		{
+			name: "binder call",
+			frames: Frames{
+				{ClassName: "android.os.BinderProxy", MethodName: "transactNative"},
+				{ClassName: "android.os.BinderProxy", MethodName: "transact"},
+				{ClassName: "android.content.pm.IPackageManager$Stub$Proxy", MethodName: "getPackageInfo"},
+				appFrame,
+			},
+			cause: ANRCauseBinderCall,
+		},
+		{
+			name: "io on main",
+			frames: Frames{
+				{ClassName: "libcore.io.Linux", MethodName: "read"},
+				{ClassName: "java.io.FileInputStream", MethodName: "read"},
+				appFrame,
+			},
+			cause: ANRCauseIOOnMain,
+		},
+		{
+			name: "cpu",
+			frames: Frames{
+				appFrame,
+				{ClassName: "java.io.FileInputStream", MethodName: "read"},
+			},
+			cause: ANRCauseCPU,
+		},
+		{
+			name: "idle",
+			frames: Frames{
+				{ClassName: "android.os.MessageQueue", MethodName: "nativePollOnce"},
+				{ClassName: "android.os.MessageQueue", MethodName: "next"},
+				{ClassName: "android.os.Looper", MethodName: "loop"},
+			},
+			cause: ANRCauseUnknown,
+		},
+		{
+			name:   "no frames",
+			frames: Frames{},
+			cause:  ANRCauseUnknown,
+		},
+	}
+
+	for _, c := range cases {
+		anr := ANR{
+			Exceptions: ExceptionUnits{{Type: "sh.measure.android.anr.AnrError", Frames: c.frames}},
+		}
+
+		if got := anr.Analyze().Cause; got != c.cause {
+			t.Errorf("%s: Expected %q cause, but got %q", c.name, c.cause, got)
+		}
+	}
+}
+
+func TestAnalyzeANRDeadlock(t *testing.T) {
+	anr := ANR{
+		Exceptions: ExceptionUnits{
+			{
+				Type: "sh.measure.android.anr.AnrError",
+				Frames: Frames{
+					{ClassName: "com.example.app.Repository", MethodName: "load"},
+				},
+			},
+		},
+		Threads: Threads{
+			{Name: "main", State: "BLOCKED", WaitingOn: "0x1", HeldLocks: []string{"0x2"}},
+			{Name: "worker", State: "BLOCKED", WaitingOn: "0x2", HeldLocks: []string{"0x1"}},
+			{Name: "io", State: "BLOCKED", WaitingOn: "0x1"},
+			{Name: "idle", State: "RUNNABLE"},
+		},
+	}
+
+	analysis := anr.Analyze()
+
+	if analysis.Cause != ANRCauseDeadlock {
+		t.Errorf("Expected %q cause, but got %q", ANRCauseDeadlock, analysis.Cause)
+	}
+
+	expectedDeadlocks := [][]string{{"main", "worker"}}
+	if !reflect.DeepEqual(expectedDeadlocks, analysis.Deadlocks) {
+		t.Errorf("Expected %v deadlocks, but got %v", expectedDeadlocks, analysis.Deadlocks)
+	}
+
+	expectedLocks := []ANRLock{
+		{Lock: "0x1", Owner: "worker", Waiters: []string{"main", "io"}},
+		{Lock: "0x2", Owner: "main", Waiters: []string{"worker"}},
+	}
+	if !reflect.DeepEqual(expectedLocks, analysis.Locks) {
+		t.Errorf("Expected %v locks, but got %v", expectedLocks, analysis.Locks)
+	}
+}
+
+func TestAnalyzeANRLockContention(t *testing.T) {
+	anr := ANR{
+		Exceptions: ExceptionUnits{
+			{
+				Type: "sh.measure.android.anr.AnrError",
+				Frames: Frames{
+					{ClassName: "com.example.app.Repository", MethodName: "load"},
+				},
+			},
+		},
+		Threads: Threads{
+			{Name: "main", State: "BLOCKED", WaitingOn: "0x1"},
+			{Name: "worker", State: "RUNNABLE", HeldLocks: []string{"0x1"}},
+		},
+	}
+
+	analysis := anr.Analyze()
+
+	if analysis.Cause != ANRCauseLockContention {
+		t.Errorf("Expected %q cause, but got %q", ANRCauseLockContention, analysis.Cause)
+	}
+
+	if len(analysis.Deadlocks) != 0 {
+		t.Errorf("Expected no deadlocks, but got %v", analysis.Deadlocks)
+	}
+}
+
+func TestAnalyzeANRWithoutThreadStates(t *testing.T) {
+	anr := ANR{
+		Exceptions: ExceptionUnits{
+			{
+				Type: "sh.measure.android.anr.AnrError",
+				Frames: Frames{
+					{ClassName: "com.example.app.Repository", MethodName: "load"},
+				},
+			},
+		},
+		Threads: Threads{
+			{Name: "main"},
+			{Name: "worker"},
+		},
+	}
+
+	analysis := anr.Analyze()
+
+	if analysis.Cause != ANRCauseCPU {
+		t.Errorf("Expected %q cause, but got %q", ANRCauseCPU, analysis.Cause)
+	}
+
+	if len(analysis.Locks) != 0 || analysis.Locks == nil {
+		t.Errorf("Expected empty locks, but got %v", analysis.Locks)
+	}
+
+	if len(analysis.Deadlocks) != 0 || analysis.Deadlocks == nil {
+		t.Errorf("Expected empty deadlocks, but got %v", analysis.Deadlocks)
+	}
+}
@@ -90,6 +90,19 @@ const TypeCPUUsage = "cpu_usage"
 const TypeNavigation = "navigation"
 const TypeScreenView = "screen_view"
 
+// FingerprintVersion is the version of the fingerprint
+// algorithm new exceptions & ANRs are fingerprinted with.
+//
+// Add new algorithms to fingerprintExceptionUnits and
+// bump LatestFingerprintVersion instead of changing this,
+// so that existing groups are not split silently. Apps
+// are moved to a newer version by fingerprint jobs.
+const FingerprintVersion uint8 = 1
+
+// LatestFingerprintVersion is the most recent version
+// of the fingerprint algorithm for exceptions & ANRs.
+const LatestFingerprintVersion uint8 = 2
+
 const NetworkGeneration2G = "2g"
 const NetworkGeneration3G = "3g"
 const NetworkGeneration4G = "4g"
@@ -129,6 +142,13 @@ const LifecycleSwiftUITypeOnDisappear = "on_disappear"
 const LifecycleAppTypeBackground = "background"
 const LifecycleAppTypeForeground = "foreground"
 
+const AppExitReasonANR = "ANR"
+const AppExitReasonCrash = "CRASH"
+const AppExitReasonCrashNative = "CRASH_NATIVE"
+const AppExitReasonLowMemory = "LOW_MEMORY"
+const AppExitReasonUserRequested = "USER_REQUESTED"
+const AppExitReasonExcessiveResourceUsage = "EXCESSIVE_RESOURCE_USAGE"
+
 // NominalColdLaunchThreshold defines the upper bound
 // of a nominal cold launch duration.
 const NominalColdLaunchThreshold = 30 * time.Second
@@ -236,6 +256,12 @@ var ValidNetworkGenerations = []string{
 	NetworkGenerationUnknown,
 }
 
+// IsValidType returns true if the event type
+// is valid for any platform.
+func IsValidType(t string) bool {
+	return slices.Contains(androidValidTypes, t) || slices.Contains(iOSValidTypes, t)
+}
+
 // makeTitle appends the message to the type
 // if message is present.
 func makeTitle(t, m string) (typeMessage string) {
@@ -257,24 +283,36 @@ type ExceptionUnits []ExceptionUnit
 type Thread struct {
 	Name   string `json:"name" binding:"required"`
 	Frames Frames `json:"frames" binding:"required"`
+	// State is the optional state of the thread,
+	// like RUNNABLE, BLOCKED or WAITING.
+	State string `json:"state,omitempty"`
+	// WaitingOn is the optional identity of the
+	// lock the thread is waiting to acquire.
+	WaitingOn string `json:"waiting_on,omitempty"`
+	// HeldLocks is the optional list of identities
+	// of locks held by the thread.
+	HeldLocks []string `json:"held_locks,omitempty"`
 }
 
 type Threads []Thread
 
 type ANR struct {
-	Handled     bool           `json:"handled" binding:"required"`
-	Exceptions  ExceptionUnits `json:"exceptions" binding:"required"`
-	Threads     Threads        `json:"threads" binding:"required"`
-	Fingerprint string         `json:"fingerprint"`
-	Foreground  bool           `json:"foreground" binding:"required"`
+	Handled            bool           `json:"handled" binding:"required"`
+	Exceptions         ExceptionUnits `json:"exceptions" binding:"required"`
+	Threads            Threads        `json:"threads" binding:"required"`
+	Fingerprint        string         `json:"fingerprint"`
+	FingerprintVersion uint8          `json:"-"`
+	Cause              string         `json:"-"`
+	Foreground         bool           `json:"foreground" binding:"required"`
 }
 
 type Exception struct {
-	Handled     bool           `json:"handled" binding:"required"`
-	Exceptions  ExceptionUnits `json:"exceptions" binding:"required"`
-	Threads     Threads        `json:"threads" binding:"required"`
-	Fingerprint string         `json:"fingerprint"`
-	Foreground  bool           `json:"foreground" binding:"required"`
+	Handled            bool           `json:"handled" binding:"required"`
+	Exceptions         ExceptionUnits `json:"exceptions" binding:"required"`
+	Threads            Threads        `json:"threads" binding:"required"`
+	Fingerprint        string         `json:"fingerprint"`
+	FingerprintVersion uint8          `json:"-"`
+	Foreground         bool           `json:"foreground" binding:"required"`
 }
 
 type AppExit struct {
@@ -562,6 +600,12 @@ func (e EventField) IsUnhandledException() bool {
 	return e.Type == TypeException && !e.Exception.Handled
 }
 
+// IsHandledException returns true
+// for handled exception event.
+func (e EventField) IsHandledException() bool {
+	return e.Type == TypeException && e.Exception.Handled
+}
+
 // IsCustom returns true for custom
 // event.
 func (e EventField) IsCustom() bool {
@@ -1168,6 +1212,15 @@ func (e Exception) GetDisplayTitle() string {
 	return e.GetType() + "@" + e.GetFileName()
 }
 
+// GetTopInAppFrame provides the topmost frame
+// of the exception that belongs to the app.
+func (e Exception) GetTopInAppFrame() string {
+	if len(e.Exceptions) == 0 {
+		return ""
+	}
+	return e.Exceptions[len(e.Exceptions)-1].Frames.topInApp()
+}
+
 // Stacktrace writes a formatted stacktrace
 // from the exception.
 func (e Exception) Stacktrace() string {
@@ -1209,39 +1262,34 @@ func (e Exception) Stacktrace() string {
 }
 
 // ComputeExceptionFingerprint computes a fingerprint
-// from the exception data.
+// from the exception data using the current version
+// of the fingerprint algorithm.
 func (e *Exception) ComputeExceptionFingerprint() (err error) {
 	if len(e.Exceptions) == 0 {
 		return fmt.Errorf("error computing exception fingerprint: no exceptions found")
 	}
 
-	// Get the innermost exception
-	innermostException := e.Exceptions[len(e.Exceptions)-1]
+	fingerprint, err := e.FingerprintWithVersion(FingerprintVersion)
+	if err != nil {
+		return
+	}
 
-	// Get the exception type
-	exceptionType := innermostException.Type
+	e.Fingerprint = fingerprint
+	e.FingerprintVersion = FingerprintVersion
 
-	// Initialize fingerprint data with the exception type
-	fingerprintData := exceptionType
-
-	// Get the method name and file name from the first frame of the innermost exception
-	if len(innermostException.Frames) > 0 {
-		methodName := innermostException.Frames[0].MethodName
-		fileName := innermostException.Frames[0].FileName
+	return nil
+}
 
-		// Include any non-empty information
-		if methodName != "" {
-			fingerprintData += ":" + methodName
-		}
-		if fileName != "" {
-			fingerprintData += ":" + fileName
-		}
+// FingerprintWithVersion computes a fingerprint from the
+// exception data using the requested version of the
+// fingerprint algorithm without modifying the exception.
+func (e Exception) FingerprintWithVersion(version uint8) (fingerprint string, err error) {
+	if len(e.Exceptions) == 0 {
+		err = fmt.Errorf("error computing exception fingerprint: no exceptions found")
+		return
 	}
 
-	// Compute the fingerprint
-	e.Fingerprint = computeFingerprint(fingerprintData)
-
-	return nil
+	return fingerprintExceptionUnits(e.Exceptions, version)
 }
 
 // IsNested returns true in case of
@@ -1312,6 +1360,15 @@ func (a ANR) GetDisplayTitle() string {
 	return a.GetType() + "@" + a.GetFileName()
 }
 
+// GetTopInAppFrame provides the topmost frame
+// of the ANR that belongs to the app.
+func (a ANR) GetTopInAppFrame() string {
+	if len(a.Exceptions) == 0 {
+		return ""
+	}
+	return a.Exceptions[len(a.Exceptions)-1].Frames.topInApp()
+}
+
 // Stacktrace writes a formatted stacktrace
 // from the ANR.
 func (a ANR) Stacktrace() string {
@@ -1353,14 +1410,64 @@ func (a ANR) Stacktrace() string {
 }
 
 // ComputeANRFingerprint computes a fingerprint
-// from the ANR data.
+// from the ANR data using the current version
+// of the fingerprint algorithm.
 func (a *ANR) ComputeANRFingerprint() (err error) {
 	if len(a.Exceptions) == 0 {
 		return fmt.Errorf("error computing ANR fingerprint: no exceptions found")
 	}
 
+	fingerprint, err := a.FingerprintWithVersion(FingerprintVersion)
+	if err != nil {
+		return
+	}
+
+	a.Fingerprint = fingerprint
+	a.FingerprintVersion = FingerprintVersion
+
+	return nil
+}
+
+// FingerprintWithVersion computes a fingerprint from the
+// ANR data using the requested version of the fingerprint
+// algorithm without modifying the ANR.
+func (a ANR) FingerprintWithVersion(version uint8) (fingerprint string, err error) {
+	if len(a.Exceptions) == 0 {
+		err = fmt.Errorf("error computing ANR fingerprint: no exceptions found")
+		return
+	}
+
+	return fingerprintExceptionUnits(a.Exceptions, version)
+}
+
+// IsValidFingerprintVersion returns true if the
+// version of the fingerprint algorithm is known.
+func IsValidFingerprintVersion(version uint8) bool {
+	return version >= 1 && version <= LatestFingerprintVersion
+}
+
+// fingerprintExceptionUnits computes a fingerprint
+// of exception units using the requested version
+// of the fingerprint algorithm.
+func fingerprintExceptionUnits(units ExceptionUnits, version uint8) (fingerprint string, err error) {
+	switch version {
+	case 1:
+		fingerprint = fingerprintV1(units)
+	case 2:
+		fingerprint = fingerprintV2(units)
+	default:
+		err = fmt.Errorf("error computing fingerprint: unknown fingerprint version %d", version)
+	}
+
+	return
+}
+
+// fingerprintV1 computes a fingerprint from the
+// type of the innermost exception along with
+// method & file name of its first frame.
+func fingerprintV1(units ExceptionUnits) string {
 	// Get the innermost exception
-	innermostException := a.Exceptions[len(a.Exceptions)-1]
+	innermostException := units[len(units)-1]
 
 	// Get the exception type
 	exceptionType := innermostException.Type
@@ -1382,10 +1489,42 @@ func (a *ANR) ComputeANRFingerprint() (err error) {
 		}
 	}
 
-	// Compute the fingerprint
-	a.Fingerprint = computeFingerprint(fingerprintData)
+	return computeFingerprint(fingerprintData)
+}
 
-	return nil
+// fingerprintV2 computes a fingerprint from the type
+// of the innermost exception along with class, method
+// & file name of its topmost frame that belongs to the
+// app. Unlike v1, exceptions thrown from platform or
+// library code are grouped by the app code calling it.
+// Falls back to the first frame if no frame belongs to
+// the app.
+func fingerprintV2(units ExceptionUnits) string {
+	// Get the innermost exception
+	innermostException := units[len(units)-1]
+
+	fingerprintData := innermostException.Type
+
+	frames := innermostException.Frames
+	if len(frames) == 0 {
+		return computeFingerprint(fingerprintData)
+	}
+
+	frame := frames[0]
+	for _, f := range frames {
+		if f.IsInApp() {
+			frame = f
+			break
+		}
+	}
+
+	for _, part := range []string{frame.ClassName, frame.MethodName, frame.FileName} {
+		if part != "" {
+			fingerprintData += ":" + part
+		}
+	}
+
+	return computeFingerprint(fingerprintData)
 }
 
 func computeFingerprint(data string) string {
@@ -83,3 +83,144 @@ func TestANRStacktraceOne(t *testing.T) {
 		t.Errorf("Expected %q stacktrace, but got %q", expected, got)
 	}
 }
+
+func TestExceptionFingerprintVersion(t *testing.T) {
+	exception, err := readException("./exception_one.json")
+	if err != nil {
+		panic(err)
+	}
+
+	if err := exception.ComputeExceptionFingerprint(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if exception.FingerprintVersion != FingerprintVersion {
+		t.Errorf("Expected %d fingerprint version, but got %d", FingerprintVersion, exception.FingerprintVersion)
+	}
+
+	got, err := exception.FingerprintWithVersion(FingerprintVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if exception.Fingerprint != got {
+		t.Errorf("Expected %q fingerprint, but got %q", exception.Fingerprint, got)
+	}
+
+	if _, err := exception.FingerprintWithVersion(LatestFingerprintVersion + 1); err == nil {
+		t.Errorf("Expected error for unknown fingerprint version, but got nil")
+	}
+}
+
+func TestANRFingerprintVersion(t *testing.T) {
+	anr, err := readANR("./anr_one.json")
+	if err != nil {
+		panic(err)
+	}
+
+	if err := anr.ComputeANRFingerprint(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if anr.FingerprintVersion != FingerprintVersion {
+		t.Errorf("Expected %d fingerprint version, but got %d", FingerprintVersion, anr.FingerprintVersion)
+	}
+
+	got, err := anr.FingerprintWithVersion(FingerprintVersion)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if anr.Fingerprint != got {
+		t.Errorf("Expected %q fingerprint, but got %q", anr.Fingerprint, got)
+	}
+}
+
+func TestFingerprintV2(t *testing.T) {
+	// same app call site, different platform frames
+	first := Exception{
+		Exceptions: ExceptionUnits{
+			{
+				Type: "java.lang.IllegalStateException",
+				Frames: Frames{
+					{ClassName: "android.os.Parcel", MethodName: "readException", FileName: "Parcel.java"},
+					{ClassName: "sh.measure.sample.CheckoutActivity", MethodName: "onPay", FileName: "CheckoutActivity.kt"},
+				},
+			},
+		},
+	}
+	second := Exception{
+		Exceptions: ExceptionUnits{
+			{
+				Type: "java.lang.IllegalStateException",
+				Frames: Frames{
+					{ClassName: "android.os.Binder", MethodName: "execTransact", FileName: "Binder.java"},
+					{ClassName: "sh.measure.sample.CheckoutActivity", MethodName: "onPay", FileName: "CheckoutActivity.kt"},
+				},
+			},
+		},
+	}
+
+	firstV1, _ := first.FingerprintWithVersion(1)
+	secondV1, _ := second.FingerprintWithVersion(1)
+
+	if firstV1 == secondV1 {
+		t.Errorf("Expected v1 fingerprints to differ, but both are %q", firstV1)
+	}
+
+	firstV2, err := first.FingerprintWithVersion(2)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	secondV2, _ := second.FingerprintWithVersion(2)
+
+	if firstV2 != secondV2 {
+		t.Errorf("Expected v2 fingerprints to match, but got %q and %q", firstV2, secondV2)
+	}
+
+	if firstV2 == firstV1 {
+		t.Errorf("Expected v2 fingerprint to differ from v1, but both are %q", firstV2)
+	}
+
+	// without in-app frames, the first frame is used
+	platform := Exception{
+		Exceptions: ExceptionUnits{
+			{
+				Type: "java.lang.IllegalStateException",
+				Frames: Frames{
+					{ClassName: "android.os.Binder", MethodName: "execTransact", FileName: "Binder.java"},
+				},
+			},
+		},
+	}
+
+	platformV2, _ := platform.FingerprintWithVersion(2)
+	if platformV2 == secondV2 {
+		t.Errorf("Expected fingerprint from first frame, but got %q", platformV2)
+	}
+}
+
+func TestExceptionTopInAppFrame(t *testing.T) {
+	exception := Exception{
+		Exceptions: ExceptionUnits{
+			{
+				Type: "java.lang.IllegalStateException",
+				Frames: Frames{
+					{ClassName: "android.app.Activity", MethodName: "performCreate", FileName: "Activity.java", LineNum: 8051},
+					{ClassName: "com.shop.CheckoutActivity", MethodName: "onCreate", FileName: "CheckoutActivity.kt", LineNum: 42},
+				},
+			},
+		},
+	}
+
+	expected := "com.shop.CheckoutActivity.onCreate(CheckoutActivity.kt:42)"
+	if got := exception.GetTopInAppFrame(); got != expected {
+		t.Errorf("Expected %q top in-app frame, but got %q", expected, got)
+	}
+
+	exception.Exceptions[0].Frames = exception.Exceptions[0].Frames[:1]
+	if got := exception.GetTopInAppFrame(); got != "" {
+		t.Errorf("Expected no top in-app frame, but got %q", got)
+	}
+}
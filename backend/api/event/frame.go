@@ -4,6 +4,7 @@ import (
 	"backend/api/text"
 	"fmt"
 	"strconv"
+	"strings"
 )
 
 // FramePrefix is the prefix string that
@@ -14,6 +15,24 @@ const FramePrefix = "\tat "
 // that appears in Android stacktraces.
 const GenericPrefix = ": "
 
+// frameworkPrefixes is the list of class name prefixes
+// of frames that belong to the platform, language
+// runtime or common libraries and not to the app.
+var frameworkPrefixes = []string{
+	"android.",
+	"androidx.",
+	"com.android.",
+	"com.google.android.",
+	"dalvik.",
+	"java.",
+	"javax.",
+	"jdk.internal.",
+	"kotlin.",
+	"kotlinx.",
+	"libcore.",
+	"sun.",
+}
+
 type Frame struct {
 	LineNum    int    `json:"line_num"`
 	ColNum     int    `json:"col_num"`
@@ -34,6 +53,31 @@ func (f Frame) CodeInfo() string {
 	return text.JoinNonEmptyStrings(".", className, methodName)
 }
 
+// IsInApp returns true if the frame does
+// not belong to the platform or common
+// libraries.
+func (f Frame) IsInApp() bool {
+	for _, prefix := range frameworkPrefixes {
+		if strings.HasPrefix(f.ClassName, prefix) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// topInApp provides the serialized topmost
+// frame that belongs to the app, if any.
+func (fs Frames) topInApp() string {
+	for _, f := range fs {
+		if f.IsInApp() {
+			return f.String()
+		}
+	}
+
+	return ""
+}
+
 // FileInfo provides a serialized
 // version of the frame's file information.
 func (f Frame) FileInfo() string {
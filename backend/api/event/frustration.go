@@ -0,0 +1,206 @@
+package event
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RageClickThreshold is the minimum count of clicks
+// on the same spot within the rage click window to
+// be considered rage clicks.
+const RageClickThreshold = 3
+
+// RageClickWindow is the duration within which
+// repeated clicks on the same spot are considered
+// rage clicks.
+const RageClickWindow = time.Second
+
+// RageClickArea is the size in pixels of the square
+// areas clicks without a target id are bucketed into
+// to be considered on the same spot.
+const RageClickArea = 48
+
+// DeadClickWindow is the duration after a click
+// within which some navigation, lifecycle or http
+// activity is expected for the click to not be
+// considered a dead click.
+const DeadClickWindow = time.Second
+
+// FrustratedClick represents a click gesture that
+// was detected as a rage click, a dead click or
+// both.
+type FrustratedClick struct {
+	ID        uuid.UUID
+	SessionID uuid.UUID
+	Timestamp time.Time
+	Target    string
+	TargetID  string
+	// Screen is the most recently viewed screen
+	// at the time of the click.
+	Screen string
+	Rage   bool
+	Dead   bool
+}
+
+// isClickActivity returns true if the event shows
+// the app responded to a click.
+func (e EventField) isClickActivity() bool {
+	switch e.Type {
+	case TypeNavigation, TypeScreenView, TypeHttp,
+		TypeLifecycleActivity, TypeLifecycleFragment,
+		TypeLifecycleViewController, TypeLifecycleSwiftUI,
+		TypeLifecycleApp:
+		return true
+	}
+
+	return false
+}
+
+// viewedScreen returns the name of the screen the
+// event shows becoming visible, if any.
+func (e EventField) viewedScreen() string {
+	switch {
+	case e.IsScreenView() && e.ScreenView != nil:
+		return e.ScreenView.Name
+	case e.IsNavigation() && e.Navigation != nil:
+		return e.Navigation.To
+	case e.IsLifecycleActivity() && e.LifecycleActivity != nil && e.LifecycleActivity.Type == LifecycleActivityTypeResumed:
+		return e.LifecycleActivity.ClassName
+	case e.IsLifecycleFragment() && e.LifecycleFragment != nil && e.LifecycleFragment.Type == LifecycleFragmentTypeResumed:
+		return e.LifecycleFragment.ClassName
+	case e.IsLifecycleViewController() && e.LifecycleViewController != nil && e.LifecycleViewController.Type == LifecycleViewControllerTypeViewDidAppear:
+		return e.LifecycleViewController.ClassName
+	case e.IsLifecycleSwiftUI() && e.LifecycleSwiftUI != nil && e.LifecycleSwiftUI.Type == LifecycleSwiftUITypeOnAppear:
+		return e.LifecycleSwiftUI.ClassName
+	}
+
+	return ""
+}
+
+// clickSpot identifies the spot of a click. Clicks
+// on a target with an id share the spot, otherwise
+// clicks on the same target within the same area
+// share the spot.
+func clickSpot(gc *GestureClick) string {
+	if gc.TargetID != "" {
+		return fmt.Sprintf("%s#%s", gc.Target, gc.TargetID)
+	}
+
+	x := int(math.Floor(float64(gc.X) / RageClickArea))
+	y := int(math.Floor(float64(gc.Y) / RageClickArea))
+
+	return fmt.Sprintf("%s@%d,%d", gc.Target, x, y)
+}
+
+// DetectFrustratedClicks detects rage clicks & dead
+// clicks among click gestures of one or more sessions.
+//
+// A click is a rage click when at least RageClickThreshold
+// clicks on the same spot happen within RageClickWindow. A
+// click is a dead click when no navigation, lifecycle or
+// http event of the session follows within DeadClickWindow.
+func DetectFrustratedClicks(events []EventField) (clicks []FrustratedClick) {
+	sessions := make(map[uuid.UUID][]EventField)
+	var order []uuid.UUID
+
+	for _, ev := range events {
+		if _, ok := sessions[ev.SessionID]; !ok {
+			order = append(order, ev.SessionID)
+		}
+		sessions[ev.SessionID] = append(sessions[ev.SessionID], ev)
+	}
+
+	for _, sessionID := range order {
+		clicks = append(clicks, detectSessionFrustratedClicks(sessions[sessionID])...)
+	}
+
+	return
+}
+
+// detectSessionFrustratedClicks detects rage clicks &
+// dead clicks among click gestures of a single session.
+func detectSessionFrustratedClicks(events []EventField) (clicks []FrustratedClick) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].Timestamp.Before(events[j].Timestamp)
+	})
+
+	type click struct {
+		FrustratedClick
+		spot string
+	}
+
+	var all []click
+	var activities []time.Time
+	screen := ""
+
+	for _, ev := range events {
+		if name := ev.viewedScreen(); name != "" {
+			screen = name
+		}
+
+		if ev.isClickActivity() {
+			activities = append(activities, ev.Timestamp)
+			continue
+		}
+
+		if !ev.IsGestureClick() || ev.GestureClick == nil {
+			continue
+		}
+
+		all = append(all, click{
+			FrustratedClick: FrustratedClick{
+				ID:        ev.ID,
+				SessionID: ev.SessionID,
+				Timestamp: ev.Timestamp,
+				Target:    ev.GestureClick.Target,
+				TargetID:  ev.GestureClick.TargetID,
+				Screen:    screen,
+			},
+			spot: clickSpot(ev.GestureClick),
+		})
+	}
+
+	// rage clicks
+	spots := make(map[string][]int)
+	for i := range all {
+		spots[all[i].spot] = append(spots[all[i].spot], i)
+	}
+
+	for _, indices := range spots {
+		start := 0
+		for end := range indices {
+			for all[indices[end]].Timestamp.Sub(all[indices[start]].Timestamp) > RageClickWindow {
+				start++
+			}
+
+			if end-start+1 >= RageClickThreshold {
+				for _, i := range indices[start : end+1] {
+					all[i].Rage = true
+				}
+			}
+		}
+	}
+
+	// dead clicks
+	for i := range all {
+		ndx := sort.Search(len(activities), func(j int) bool {
+			return activities[j].After(all[i].Timestamp)
+		})
+
+		if ndx == len(activities) || activities[ndx].Sub(all[i].Timestamp) > DeadClickWindow {
+			all[i].Dead = true
+		}
+	}
+
+	for _, c := range all {
+		if c.Rage || c.Dead {
+			clicks = append(clicks, c.FrustratedClick)
+		}
+	}
+
+	return
+}
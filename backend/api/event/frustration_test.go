@@ -0,0 +1,124 @@
+package event
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newClick(sessionID uuid.UUID, ts time.Time, target, targetID string, x, y float32) EventField {
+	return EventField{
+		ID:        uuid.New(),
+		SessionID: sessionID,
+		Timestamp: ts,
+		Type:      TypeGestureClick,
+		GestureClick: &GestureClick{
+			Target:   target,
+			TargetID: targetID,
+			X:        x,
+			Y:        y,
+		},
+	}
+}
+
+func TestDetectFrustratedClicksRage(t *testing.T) {
+	sessionID := uuid.New()
+	start := time.Date(2024, 12, 22, 10, 0, 0, 0, time.UTC)
+
+	events := []EventField{
+		{
+			SessionID:  sessionID,
+			Timestamp:  start,
+			Type:       TypeScreenView,
+			ScreenView: &ScreenView{Name: "checkout"},
+		},
+	}
+
+	// 3 clicks on the same button within a second,
+	// each followed by an http request
+	for i := 0; i < 3; i++ {
+		ts := start.Add(time.Duration(i*300+100) * time.Millisecond)
+		events = append(events, newClick(sessionID, ts, "android.widget.Button", "pay", 10, 10))
+		events = append(events, EventField{
+			SessionID: sessionID,
+			Timestamp: ts.Add(50 * time.Millisecond),
+			Type:      TypeHttp,
+		})
+	}
+
+	clicks := DetectFrustratedClicks(events)
+
+	if len(clicks) != 3 {
+		t.Fatalf("Expected %d frustrated clicks, but got %d", 3, len(clicks))
+	}
+
+	for _, click := range clicks {
+		if !click.Rage || click.Dead {
+			t.Errorf("Expected rage click which is not dead, but got %+v", click)
+		}
+
+		if click.Screen != "checkout" {
+			t.Errorf("Expected screen %q, but got %q", "checkout", click.Screen)
+		}
+	}
+}
+
+func TestDetectFrustratedClicksRageArea(t *testing.T) {
+	sessionID := uuid.New()
+	start := time.Date(2024, 12, 22, 10, 0, 0, 0, time.UTC)
+
+	events := []EventField{
+		newClick(sessionID, start, "android.view.View", "", 10, 10),
+		newClick(sessionID, start.Add(200*time.Millisecond), "android.view.View", "", 20, 30),
+		newClick(sessionID, start.Add(400*time.Millisecond), "android.view.View", "", 300, 300),
+		newClick(sessionID, start.Add(1500*time.Millisecond), "android.view.View", "", 15, 15),
+	}
+
+	for _, click := range DetectFrustratedClicks(events) {
+		if click.Rage {
+			t.Errorf("Expected no rage clicks, but got %+v", click)
+		}
+	}
+}
+
+func TestDetectFrustratedClicksDead(t *testing.T) {
+	sessionID := uuid.New()
+	otherSessionID := uuid.New()
+	start := time.Date(2024, 12, 22, 10, 0, 0, 0, time.UTC)
+
+	live := newClick(sessionID, start, "android.widget.Button", "next", 10, 10)
+	dead := newClick(sessionID, start.Add(5*time.Second), "android.widget.Button", "help", 10, 10)
+	late := newClick(otherSessionID, start, "android.widget.Button", "next", 10, 10)
+
+	events := []EventField{
+		live,
+		{
+			SessionID:  sessionID,
+			Timestamp:  start.Add(200 * time.Millisecond),
+			Type:       TypeNavigation,
+			Navigation: &Navigation{To: "details"},
+		},
+		dead,
+		late,
+		{
+			SessionID: otherSessionID,
+			Timestamp: start.Add(3 * time.Second),
+			Type:      TypeHttp,
+		},
+	}
+
+	clicks := DetectFrustratedClicks(events)
+
+	if len(clicks) != 2 {
+		t.Fatalf("Expected %d frustrated clicks, but got %d", 2, len(clicks))
+	}
+
+	if clicks[0].ID != dead.ID || !clicks[0].Dead || clicks[0].Screen != "details" {
+		t.Errorf("Expected dead click %v on %q, but got %+v", dead.ID, "details", clicks[0])
+	}
+
+	if clicks[1].ID != late.ID || !clicks[1].Dead {
+		t.Errorf("Expected dead click %v, but got %+v", late.ID, clicks[1])
+	}
+}
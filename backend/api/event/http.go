@@ -0,0 +1,63 @@
+package event
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// URLPlaceholderID is the placeholder that replaces
+// numeric & hexadecimal identifiers in url templates.
+const URLPlaceholderID = "{id}"
+
+// URLPlaceholderUUID is the placeholder that replaces
+// UUIDs in url templates.
+const URLPlaceholderUUID = "{uuid}"
+
+// minHexIDLength is the minimum length of a path
+// segment of hexadecimal characters to be treated
+// as an identifier, like object ids & hashes.
+const minHexIDLength = 16
+
+// uuidRegex matches a UUID path segment.
+var uuidRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// numericRegex matches a numeric path segment.
+var numericRegex = regexp.MustCompile(`^[0-9]+$`)
+
+// hexRegex matches a hexadecimal path segment
+// containing at least one digit.
+var hexRegex = regexp.MustCompile(`^[0-9a-fA-F]*[0-9][0-9a-fA-F]*$`)
+
+// URLTemplate normalizes the http url to a template
+// identifying the endpoint. Scheme, port, query string
+// & fragment are dropped and path segments that look
+// like identifiers are collapsed to placeholders.
+//
+// Must be kept in sync with the default expression of
+// the `http.url_template` column of the events table.
+func (h Http) URLTemplate() string {
+	host := ""
+	path := h.URL
+
+	if u, err := url.Parse(h.URL); err == nil {
+		host = u.Hostname()
+		path = u.EscapedPath()
+	} else if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		switch {
+		case uuidRegex.MatchString(segment):
+			segments[i] = URLPlaceholderUUID
+		case numericRegex.MatchString(segment):
+			segments[i] = URLPlaceholderID
+		case len(segment) >= minHexIDLength && hexRegex.MatchString(segment):
+			segments[i] = URLPlaceholderID
+		}
+	}
+
+	return host + strings.Join(segments, "/")
+}
@@ -0,0 +1,22 @@
+package event
+
+import "testing"
+
+func TestURLTemplate(t *testing.T) {
+	cases := map[string]string{
+		"https://api.example.com/users/42/posts?page=2":                            "api.example.com/users/{id}/posts",
+		"https://api.example.com:8443/orders/3f2b8c1e-9a4d-4c6b-8e2f-1a2b3c4d5e6f": "api.example.com/orders/{uuid}",
+		"http://example.com/objects/5f8d0d55b54764421b7156c3/":                     "example.com/objects/{id}/",
+		"https://example.com/v1/feed#top":                                          "example.com/v1/feed",
+		"https://example.com/images/deadbeefcafe":                                  "example.com/images/deadbeefcafe",
+		"https://example.com":                                                      "example.com",
+		"/relative/12?x=%zz":                                                       "/relative/{id}",
+	}
+
+	for input, expected := range cases {
+		got := Http{URL: input}.URLTemplate()
+		if got != expected {
+			t.Errorf("Expected %q for %q, but got %q", expected, input, got)
+		}
+	}
+}
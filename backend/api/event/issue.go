@@ -7,8 +7,11 @@ import (
 )
 
 type ThreadView struct {
-	Name   string   `json:"name"`
-	Frames []string `json:"frames"`
+	Name      string   `json:"name"`
+	Frames    []string `json:"frames"`
+	State     string   `json:"state,omitempty"`
+	WaitingOn string   `json:"waiting_on,omitempty"`
+	HeldLocks []string `json:"held_locks,omitempty"`
 }
 
 type EventANR struct {
@@ -24,9 +27,10 @@ type EventANR struct {
 }
 
 type ANRView struct {
-	Title      string `json:"title"`
-	Stacktrace string `json:"stacktrace"`
-	Message    string `json:"message"`
+	Title      string      `json:"title"`
+	Stacktrace string      `json:"stacktrace"`
+	Message    string      `json:"message"`
+	Analysis   ANRAnalysis `json:"analysis"`
 }
 
 type EventException struct {
@@ -54,11 +58,15 @@ func (e *EventANR) ComputeView() {
 		Title:      e.ANR.GetDisplayTitle(),
 		Stacktrace: e.ANR.Stacktrace(),
 		Message:    e.ANR.GetMessage(),
+		Analysis:   e.ANR.Analyze(),
 	}
 
 	for i := range e.ANR.Threads {
 		var tv ThreadView
 		tv.Name = e.ANR.Threads[i].Name
+		tv.State = e.ANR.Threads[i].State
+		tv.WaitingOn = e.ANR.Threads[i].WaitingOn
+		tv.HeldLocks = e.ANR.Threads[i].HeldLocks
 		for j := range e.ANR.Threads[i].Frames {
 			tv.Frames = append(tv.Frames, e.ANR.Threads[i].Frames[j].String())
 		}
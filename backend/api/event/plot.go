@@ -7,4 +7,7 @@ type IssueInstance struct {
 	Version           string   `json:"version"`
 	Instances         *uint64  `json:"instances"`
 	IssueFreeSessions *float64 `json:"issue_free_sessions"`
+	// Sessions is the count of sessions
+	// issue free sessions are computed from.
+	Sessions uint64 `json:"sessions"`
 }
@@ -119,6 +119,22 @@ type AppFilter struct {
 	// consider ANR events.
 	ANR bool `form:"anr"`
 
+	// ANRCauses represents the list of causes
+	// of ANRs to be filtered on.
+	ANRCauses []string `form:"anr_causes"`
+
+	// NonFatal indicates the filtering should
+	// only consider handled exception events.
+	NonFatal bool `form:"non_fatal"`
+
+	// RageClick indicates the filtering should
+	// only consider sessions having rage clicks.
+	RageClick bool `form:"rage_click"`
+
+	// DeadClick indicates the filtering should
+	// only consider sessions having dead clicks.
+	DeadClick bool `form:"dead_click"`
+
 	// UDAttrKeys indicates a request to receive
 	// list of user defined attribute key &
 	// types.
@@ -238,6 +254,12 @@ func (af *AppFilter) Validate() error {
 		}
 	}
 
+	for _, cause := range af.ANRCauses {
+		if !slices.Contains(event.ANRCauses, cause) {
+			return fmt.Errorf("`anr_causes` value %q is not a known cause", cause)
+		}
+	}
+
 	for _, status := range af.SpanStatuses {
 		if status < 0 || status > 2 {
 			return fmt.Errorf("`span_statuses` values must be 0 (Unset), 1 (Ok) or 2 (Error)")
@@ -302,6 +324,10 @@ func (af *AppFilter) Expand(ctx context.Context) (err error) {
 		err = nil
 	}
 
+	if len(af.ANRCauses) > 0 {
+		af.ANRCauses = text.SplitTrimEmpty(af.ANRCauses[0], ",")
+	}
+
 	if filters != nil {
 		if len(filters.Versions) > 0 {
 			af.Versions = filters.Versions
@@ -615,8 +641,8 @@ func (af *AppFilter) hasKeyTimestamp() bool {
 // getAppVersions finds distinct pairs of app versions &
 // app build no from available events.
 //
-// Additionally, filters for `exception` and `anr` event
-// types.
+// Additionally, filters for `exception`, `anr` and
+// `non_fatal` event types.
 func (af *AppFilter) getAppVersions(ctx context.Context) (versions, versionCodes []string, err error) {
 	var table_name string
 	if af.Span {
@@ -641,6 +667,10 @@ func (af *AppFilter) getAppVersions(ctx context.Context) (versions, versionCodes
 		stmt.Where("anr=true")
 	}
 
+	if af.NonFatal && !af.Span {
+		stmt.Where("non_fatal=true")
+	}
+
 	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
 	if err != nil {
 		return
@@ -664,7 +694,8 @@ func (af *AppFilter) getAppVersions(ctx context.Context) (versions, versionCodes
 // getOsVersions finds distinct values of os versions
 // from available events.
 //
-// Additionally, filters `exception` and `anr` event types.
+// Additionally, filters `exception`, `anr` and `non_fatal`
+// event types.
 func (af *AppFilter) getOsVersions(ctx context.Context) (osNames, osVersions []string, err error) {
 	var table_name string
 	if af.Span {
@@ -690,6 +721,10 @@ func (af *AppFilter) getOsVersions(ctx context.Context) (osNames, osVersions []s
 		stmt.Where("anr=true")
 	}
 
+	if af.NonFatal && !af.Span {
+		stmt.Where("non_fatal=true")
+	}
+
 	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
 	if err != nil {
 		return
@@ -713,7 +748,8 @@ func (af *AppFilter) getOsVersions(ctx context.Context) (osNames, osVersions []s
 // getCountries finds distinct values of country codes
 // from available events.
 //
-// Additionally, filters `exception` and `anr` event types.
+// Additionally, filters `exception`, `anr` and `non_fatal`
+// event types.
 func (af *AppFilter) getCountries(ctx context.Context) (countries []string, err error) {
 	var table_name string
 	if af.Span {
@@ -738,6 +774,10 @@ func (af *AppFilter) getCountries(ctx context.Context) (countries []string, err
 		stmt.Where("anr=true")
 	}
 
+	if af.NonFatal && !af.Span {
+		stmt.Where("non_fatal=true")
+	}
+
 	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
 	if err != nil {
 		return
@@ -759,7 +799,8 @@ func (af *AppFilter) getCountries(ctx context.Context) (countries []string, err
 // getNetworkProviders finds distinct values of app network
 // providers from available events.
 //
-// Additionally, filters `exception` and `anr` event types.
+// Additionally, filters `exception`, `anr` and `non_fatal`
+// event types.
 func (af *AppFilter) getNetworkProviders(ctx context.Context) (networkProviders []string, err error) {
 	var table_name string
 	if af.Span {
@@ -784,6 +825,10 @@ func (af *AppFilter) getNetworkProviders(ctx context.Context) (networkProviders
 		stmt.Where("anr=true")
 	}
 
+	if af.NonFatal && !af.Span {
+		stmt.Where("non_fatal=true")
+	}
+
 	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
 	if err != nil {
 		return
@@ -805,7 +850,8 @@ func (af *AppFilter) getNetworkProviders(ctx context.Context) (networkProviders
 // getNetworkTypes finds distinct values of app network
 // types from available events.
 //
-// Additionally, filters `exception` and `anr` event types.
+// Additionally, filters `exception`, `anr` and `non_fatal`
+// event types.
 func (af *AppFilter) getNetworkTypes(ctx context.Context) (networkTypes []string, err error) {
 	var table_name string
 	if af.Span {
@@ -830,6 +876,10 @@ func (af *AppFilter) getNetworkTypes(ctx context.Context) (networkTypes []string
 		stmt.Where("anr=true")
 	}
 
+	if af.NonFatal && !af.Span {
+		stmt.Where("non_fatal=true")
+	}
+
 	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
 	if err != nil {
 		return
@@ -851,7 +901,8 @@ func (af *AppFilter) getNetworkTypes(ctx context.Context) (networkTypes []string
 // getNetworkGenerations finds distinct values of app network
 // generations from available events.
 //
-// Additionally, filters `exception` and `anr` event types.
+// Additionally, filters `exception`, `anr` and `non_fatal`
+// event types.
 func (af *AppFilter) getNetworkGenerations(ctx context.Context) (networkGenerations []string, err error) {
 	var table_name string
 	if af.Span {
@@ -876,6 +927,10 @@ func (af *AppFilter) getNetworkGenerations(ctx context.Context) (networkGenerati
 		stmt.Where("anr=true")
 	}
 
+	if af.NonFatal && !af.Span {
+		stmt.Where("non_fatal=true")
+	}
+
 	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
 	if err != nil {
 		return
@@ -900,7 +955,8 @@ func (af *AppFilter) getNetworkGenerations(ctx context.Context) (networkGenerati
 // getDeviceLocales finds distinct values of app device
 // locales from available events.
 //
-// Additionally, filters `exception` and `anr` event types.
+// Additionally, filters `exception`, `anr` and `non_fatal`
+// event types.
 func (af *AppFilter) getDeviceLocales(ctx context.Context) (deviceLocales []string, err error) {
 	var table_name string
 	if af.Span {
@@ -925,6 +981,10 @@ func (af *AppFilter) getDeviceLocales(ctx context.Context) (deviceLocales []stri
 		stmt.Where("anr=true")
 	}
 
+	if af.NonFatal && !af.Span {
+		stmt.Where("non_fatal=true")
+	}
+
 	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
 	if err != nil {
 		return
@@ -946,7 +1006,8 @@ func (af *AppFilter) getDeviceLocales(ctx context.Context) (deviceLocales []stri
 // getDeviceManufacturers finds distinct values of app device
 // manufacturers from available events.
 //
-// Additionally, filters `exception` and `anr` event types.
+// Additionally, filters `exception`, `anr` and `non_fatal`
+// event types.
 func (af *AppFilter) getDeviceManufacturers(ctx context.Context) (deviceManufacturers []string, err error) {
 	var table_name string
 	if af.Span {
@@ -971,6 +1032,10 @@ func (af *AppFilter) getDeviceManufacturers(ctx context.Context) (deviceManufact
 		stmt.Where("anr=true")
 	}
 
+	if af.NonFatal && !af.Span {
+		stmt.Where("non_fatal=true")
+	}
+
 	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
 	if err != nil {
 		return
@@ -992,7 +1057,8 @@ func (af *AppFilter) getDeviceManufacturers(ctx context.Context) (deviceManufact
 // getDeviceNames finds distinct values of app device
 // names from available events.
 //
-// Additionally, filters `exception` and `anr` event types.
+// Additionally, filters `exception`, `anr` and `non_fatal`
+// event types.
 func (af *AppFilter) getDeviceNames(ctx context.Context) (deviceNames []string, err error) {
 	var table_name string
 	if af.Span {
@@ -1017,6 +1083,10 @@ func (af *AppFilter) getDeviceNames(ctx context.Context) (deviceNames []string,
 		stmt.Where("anr=true")
 	}
 
+	if af.NonFatal && !af.Span {
+		stmt.Where("non_fatal=true")
+	}
+
 	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
 	if err != nil {
 		return
@@ -1036,9 +1106,17 @@ func (af *AppFilter) getDeviceNames(ctx context.Context) (deviceNames []string,
 }
 
 // getUDAttrKeys finds distinct user defined attribute
-// key and its types.
+// key and its types of events, or spans if the filter
+// considers spans.
 func (af *AppFilter) getUDAttrKeys(ctx context.Context) (keytypes []event.UDKeyType, err error) {
-	stmt := sqlf.From("user_def_attrs").
+	var table_name string
+	if af.Span {
+		table_name = "span_user_def_attrs"
+	} else {
+		table_name = "user_def_attrs"
+	}
+
+	stmt := sqlf.From(table_name).
 		Select("distinct key").
 		Select("toString(type) type").
 		Clause("prewhere app_id = toUUID(?) and end_of_month <= ?", af.AppID, af.To).
@@ -1054,6 +1132,10 @@ func (af *AppFilter) getUDAttrKeys(ctx context.Context) (keytypes []event.UDKeyT
 		stmt.Where("anr = true")
 	}
 
+	if af.NonFatal {
+		stmt.Where("non_fatal = true")
+	}
+
 	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
 	if err != nil {
 		return
@@ -3,6 +3,8 @@ package filter
 import (
 	"backend/api/event"
 	"testing"
+
+	"github.com/google/uuid"
 )
 
 func TestParseRawUDExpression(t *testing.T) {
@@ -85,3 +87,21 @@ func TestParseRawUDExpression(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateANRCauses(t *testing.T) {
+	af := &AppFilter{
+		AppID:     uuid.New(),
+		Limit:     DefaultPaginationLimit,
+		ANRCauses: []string{event.ANRCauseDeadlock, event.ANRCauseIOOnMain},
+	}
+
+	if err := af.Validate(); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+
+	af.ANRCauses = append(af.ANRCauses, "gc")
+
+	if err := af.Validate(); err == nil {
+		t.Error("Expected error for unknown cause, got nil")
+	}
+}
@@ -8,6 +8,10 @@ type JourneyOpts struct {
 	// exception events.
 	Exceptions bool
 
+	// NonFatals denotes to query
+	// handled exception events.
+	NonFatals bool
+
 	// ANRs denotes to query
 	// ANR events.
 	ANRs bool
@@ -21,42 +21,55 @@ import (
 // types like ExceptionGroup & ANRGroup.
 type IssueGroup interface {
 	GetFingerprint() string
+	GetFingerprints() []string
 }
 
 type ExceptionGroup struct {
-	ID              uuid.UUID              `json:"id" db:"id"`
-	AppID           uuid.UUID              `json:"app_id" db:"app_id"`
-	Type            string                 `json:"type" db:"type"`
-	Message         string                 `json:"message" db:"message"`
-	MethodName      string                 `json:"method_name" db:"method_name"`
-	FileName        string                 `json:"file_name" db:"file_name"`
-	LineNumber      int                    `json:"line_number" db:"line_number"`
-	Fingerprint     string                 `json:"fingerprint" db:"fingerprint"`
-	Count           int                    `json:"count"`
-	EventIDs        []uuid.UUID            `json:"event_ids,omitempty"`
-	EventExceptions []event.EventException `json:"exception_events,omitempty"`
-	Percentage      float32                `json:"percentage_contribution"`
-	FirstEventTime  time.Time              `json:"-" db:"first_event_timestamp"`
-	CreatedAt       chrono.ISOTime         `json:"created_at" db:"created_at"`
-	UpdatedAt       chrono.ISOTime         `json:"updated_at" db:"updated_at"`
+	ID                 uuid.UUID              `json:"id" db:"id"`
+	AppID              uuid.UUID              `json:"app_id" db:"app_id"`
+	Type               string                 `json:"type" db:"type"`
+	Message            string                 `json:"message" db:"message"`
+	MethodName         string                 `json:"method_name" db:"method_name"`
+	FileName           string                 `json:"file_name" db:"file_name"`
+	LineNumber         int                    `json:"line_number" db:"line_number"`
+	Fingerprint        string                 `json:"fingerprint" db:"fingerprint"`
+	FingerprintVersion uint8                  `json:"fingerprint_version" db:"fingerprint_version"`
+	Handled            bool                   `json:"handled" db:"handled"`
+	MergedInto         *uuid.UUID             `json:"merged_into,omitempty" db:"merged_into"`
+	MergedFingerprints []string               `json:"merged_fingerprints,omitempty" db:"merged_fingerprints"`
+	Count              int                    `json:"count"`
+	AffectedUsers      int                    `json:"affected_users"`
+	EventIDs           []uuid.UUID            `json:"event_ids,omitempty"`
+	EventExceptions    []event.EventException `json:"exception_events,omitempty"`
+	Percentage         float32                `json:"percentage_contribution"`
+	FirstEventTime     time.Time              `json:"-" db:"first_event_timestamp"`
+	LastEventTime      time.Time              `json:"-" db:"last_event_timestamp"`
+	CreatedAt          chrono.ISOTime         `json:"created_at" db:"created_at"`
+	UpdatedAt          chrono.ISOTime         `json:"updated_at" db:"updated_at"`
 }
 
 type ANRGroup struct {
-	ID             uuid.UUID        `json:"id" db:"id"`
-	AppID          uuid.UUID        `json:"app_id" db:"app_id"`
-	Type           string           `json:"type" db:"type"`
-	Message        string           `json:"message" db:"message"`
-	MethodName     string           `json:"method_name" db:"method_name"`
-	FileName       string           `json:"file_name" db:"file_name"`
-	LineNumber     int              `json:"line_number" db:"line_number"`
-	Fingerprint    string           `json:"fingerprint" db:"fingerprint"`
-	Count          int              `json:"count"`
-	EventIDs       []uuid.UUID      `json:"event_ids,omitempty"`
-	EventANRs      []event.EventANR `json:"anr_events,omitempty"`
-	Percentage     float32          `json:"percentage_contribution"`
-	FirstEventTime time.Time        `json:"-" db:"first_event_timestamp"`
-	CreatedAt      chrono.ISOTime   `json:"created_at" db:"created_at"`
-	UpdatedAt      chrono.ISOTime   `json:"updated_at" db:"updated_at"`
+	ID                 uuid.UUID        `json:"id" db:"id"`
+	AppID              uuid.UUID        `json:"app_id" db:"app_id"`
+	Type               string           `json:"type" db:"type"`
+	Message            string           `json:"message" db:"message"`
+	MethodName         string           `json:"method_name" db:"method_name"`
+	FileName           string           `json:"file_name" db:"file_name"`
+	LineNumber         int              `json:"line_number" db:"line_number"`
+	Fingerprint        string           `json:"fingerprint" db:"fingerprint"`
+	FingerprintVersion uint8            `json:"fingerprint_version" db:"fingerprint_version"`
+	Cause              string           `json:"cause" db:"cause"`
+	MergedInto         *uuid.UUID       `json:"merged_into,omitempty" db:"merged_into"`
+	MergedFingerprints []string         `json:"merged_fingerprints,omitempty" db:"merged_fingerprints"`
+	Count              int              `json:"count"`
+	AffectedUsers      int              `json:"affected_users"`
+	EventIDs           []uuid.UUID      `json:"event_ids,omitempty"`
+	EventANRs          []event.EventANR `json:"anr_events,omitempty"`
+	Percentage         float32          `json:"percentage_contribution"`
+	FirstEventTime     time.Time        `json:"-" db:"first_event_timestamp"`
+	LastEventTime      time.Time        `json:"-" db:"last_event_timestamp"`
+	CreatedAt          chrono.ISOTime   `json:"created_at" db:"created_at"`
+	UpdatedAt          chrono.ISOTime   `json:"updated_at" db:"updated_at"`
 }
 
 func (e ExceptionGroup) GetID() uuid.UUID {
@@ -69,6 +82,13 @@ func (e ExceptionGroup) GetFingerprint() string {
 	return e.Fingerprint
 }
 
+// GetFingerprints provides the exception group's
+// own fingerprint along with fingerprints of all
+// groups merged into it.
+func (e ExceptionGroup) GetFingerprints() []string {
+	return append([]string{e.Fingerprint}, e.MergedFingerprints...)
+}
+
 // GetDisplayTitle provides a user friendly display
 // name for the Exception Group.
 func (e ExceptionGroup) GetDisplayTitle() string {
@@ -86,11 +106,13 @@ func (e ExceptionGroup) EventExists(id uuid.UUID) bool {
 // UpdateTimeStamps updates the updated_at timestamp of the
 // ExceptionGroup. Additionally, if the event's timestamp is
 // older than the group's timestamp, then update the group's
-// timestamp.
+// timestamp. The group's last event timestamp moves forward
+// to the event's timestamp.
 func (e ExceptionGroup) UpdateTimeStamps(ctx context.Context, event *event.EventField, tx *pgx.Tx) (err error) {
 	stmt := sqlf.PostgreSQL.
 		Update("public.unhandled_exception_groups").
 		Set("updated_at", time.Now()).
+		SetExpr("last_event_timestamp", "greatest(last_event_timestamp, ?)", event.Timestamp).
 		Where("id = ?", e.ID)
 
 	if event.Timestamp.Before(e.FirstEventTime) {
@@ -116,6 +138,8 @@ func (e *ExceptionGroup) Insert(ctx context.Context, tx *pgx.Tx) (err error) {
 		return
 	}
 
+	e.ID = id
+
 	stmt := sqlf.PostgreSQL.
 		InsertInto("public.unhandled_exception_groups").
 		Set("id", id).
@@ -126,7 +150,44 @@ func (e *ExceptionGroup) Insert(ctx context.Context, tx *pgx.Tx) (err error) {
 		Set("file_name", e.FileName).
 		Set("line_number", e.LineNumber).
 		Set("fingerprint", e.Fingerprint).
-		Set("first_event_timestamp", e.FirstEventTime)
+		Set("fingerprint_version", e.FingerprintVersion).
+		Set("handled", e.Handled).
+		Set("first_event_timestamp", e.FirstEventTime).
+		Set("last_event_timestamp", e.LastEventTime)
+
+	defer stmt.Close()
+
+	if tx != nil {
+		_, err = (*tx).Exec(ctx, stmt.String(), stmt.Args()...)
+		return
+	}
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// Merge merges the exception groups matching ids into the
+// ExceptionGroup. Groups that were previously merged into any
+// of the merging groups are pointed to the ExceptionGroup as
+// well. Only groups of the same app and of the same kind,
+// handled or unhandled, are merged.
+func (e ExceptionGroup) Merge(ctx context.Context, ids []uuid.UUID, tx *pgx.Tx) (err error) {
+	ids = slices.DeleteFunc(slices.Clone(ids), func(id uuid.UUID) bool {
+		return id == e.ID
+	})
+
+	if len(ids) == 0 {
+		return
+	}
+
+	stmt := sqlf.PostgreSQL.
+		Update("public.unhandled_exception_groups").
+		Set("merged_into", e.ID).
+		Set("updated_at", time.Now()).
+		Where("app_id = ?", e.AppID).
+		Where("handled = ?", e.Handled).
+		Where("(id = any(?) or merged_into = any(?))", ids, ids)
 
 	defer stmt.Close()
 
@@ -150,6 +211,13 @@ func (a ANRGroup) GetFingerprint() string {
 	return a.Fingerprint
 }
 
+// GetFingerprints provides the ANR group's own
+// fingerprint along with fingerprints of all
+// groups merged into it.
+func (a ANRGroup) GetFingerprints() []string {
+	return append([]string{a.Fingerprint}, a.MergedFingerprints...)
+}
+
 // GetDisplayTitle provides a user friendly display
 // name for the ANR Group.
 func (a ANRGroup) GetDisplayTitle() string {
@@ -167,15 +235,22 @@ func (a ANRGroup) EventExists(id uuid.UUID) bool {
 // UpdateTimeStamps updates the updated_at timestamp of the
 // ANRGroup. Additionally, if the event's timestamp is
 // older than the group's timestamp, then update the group's
-// timestamp.
-func (e ANRGroup) UpdateTimeStamps(ctx context.Context, event *event.EventField, tx *pgx.Tx) (err error) {
+// timestamp. The group's last event timestamp moves forward
+// to the event's timestamp. If the group's cause is unknown,
+// then the event's cause is adopted.
+func (e ANRGroup) UpdateTimeStamps(ctx context.Context, ev *event.EventField, tx *pgx.Tx) (err error) {
 	stmt := sqlf.PostgreSQL.
 		Update("public.anr_groups").
 		Set("updated_at", time.Now()).
+		SetExpr("last_event_timestamp", "greatest(last_event_timestamp, ?)", ev.Timestamp).
 		Where("id = ?", e.ID)
 
-	if event.Timestamp.Before(e.FirstEventTime) {
-		stmt.Set("first_event_timestamp", event.Timestamp)
+	if ev.Timestamp.Before(e.FirstEventTime) {
+		stmt.Set("first_event_timestamp", ev.Timestamp)
+	}
+
+	if e.Cause == event.ANRCauseUnknown && ev.ANR != nil && ev.ANR.Cause != "" && ev.ANR.Cause != event.ANRCauseUnknown {
+		stmt.Set("cause", ev.ANR.Cause)
 	}
 
 	defer stmt.Close()
@@ -197,6 +272,8 @@ func (a *ANRGroup) Insert(ctx context.Context, tx *pgx.Tx) (err error) {
 		return err
 	}
 
+	a.ID = id
+
 	stmt := sqlf.PostgreSQL.
 		InsertInto("public.anr_groups").
 		Set("id", id).
@@ -207,7 +284,42 @@ func (a *ANRGroup) Insert(ctx context.Context, tx *pgx.Tx) (err error) {
 		Set("file_name", a.FileName).
 		Set("line_number", a.LineNumber).
 		Set("fingerprint", a.Fingerprint).
-		Set("first_event_timestamp", a.FirstEventTime)
+		Set("fingerprint_version", a.FingerprintVersion).
+		Set("cause", a.Cause).
+		Set("first_event_timestamp", a.FirstEventTime).
+		Set("last_event_timestamp", a.LastEventTime)
+
+	defer stmt.Close()
+
+	if tx != nil {
+		_, err = (*tx).Exec(ctx, stmt.String(), stmt.Args()...)
+		return
+	}
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// Merge merges the ANR groups matching ids into the ANRGroup.
+// Groups that were previously merged into any of the merging
+// groups are pointed to the ANRGroup as well. Only groups of
+// the same app are merged.
+func (a ANRGroup) Merge(ctx context.Context, ids []uuid.UUID, tx *pgx.Tx) (err error) {
+	ids = slices.DeleteFunc(slices.Clone(ids), func(id uuid.UUID) bool {
+		return id == a.ID
+	})
+
+	if len(ids) == 0 {
+		return
+	}
+
+	stmt := sqlf.PostgreSQL.
+		Update("public.anr_groups").
+		Set("merged_into", a.ID).
+		Set("updated_at", time.Now()).
+		Where("app_id = ?", a.AppID).
+		Where("(id = any(?) or merged_into = any(?))", ids, ids)
 
 	defer stmt.Close()
 
@@ -251,6 +363,42 @@ func ComputeANRContribution(groups []ANRGroup) {
 	}
 }
 
+// ANRCauseCount represents the count of ANR
+// groups & ANR instances of a cause.
+type ANRCauseCount struct {
+	Cause  string `json:"cause"`
+	Groups int    `json:"groups"`
+	Count  int    `json:"count"`
+}
+
+// CountANRCauses counts ANR groups & instances by
+// cause from given slice of ANRGroup, sorted by
+// descending count and then ascending cause.
+func CountANRCauses(groups []ANRGroup) (causes []ANRCauseCount) {
+	causes = []ANRCauseCount{}
+	index := make(map[string]int)
+
+	for _, group := range groups {
+		i, ok := index[group.Cause]
+		if !ok {
+			i = len(causes)
+			index[group.Cause] = i
+			causes = append(causes, ANRCauseCount{Cause: group.Cause})
+		}
+		causes[i].Groups++
+		causes[i].Count += group.Count
+	}
+
+	sort.SliceStable(causes, func(i, j int) bool {
+		if causes[i].Count != causes[j].Count {
+			return causes[i].Count > causes[j].Count
+		}
+		return causes[i].Cause < causes[j].Cause
+	})
+
+	return
+}
+
 // SortExceptionGroups first sorts a slice of ExceptionGroup
 // with descending count and then ascending ID.
 func SortExceptionGroups(groups []ExceptionGroup) {
@@ -279,8 +427,8 @@ func GetExceptionGroupsFromExceptionIds(ctx context.Context, af *filter.AppFilte
 	// Get list of fingerprints and event IDs
 	eventDataStmt := sqlf.From(`default.events`).
 		Select(`id, exception.fingerprint`).
-		Where("app_id = toUUID(?)", af.AppID).
-		Where("id in ?", eventIds)
+		Clause("prewhere app_id = toUUID(?) and id in ?", af.AppID, eventIds).
+		Where("type = ?", event.TypeException)
 
 	defer eventDataStmt.Close()
 
@@ -312,8 +460,9 @@ func GetExceptionGroupsFromExceptionIds(ctx context.Context, af *filter.AppFilte
 	}
 
 	// Query groups that match the obtained fingerprints
+	// either directly or via groups merged into them
 	stmt := sqlf.PostgreSQL.
-		From(`public.unhandled_exception_groups`).
+		From(`public.unhandled_exception_groups g`).
 		Select(`id`).
 		Select(`type`).
 		Select(`message`).
@@ -321,7 +470,12 @@ func GetExceptionGroupsFromExceptionIds(ctx context.Context, af *filter.AppFilte
 		Select(`file_name`).
 		Select(`line_number`).
 		Select(`fingerprint`).
-		Where(`fingerprint = ANY(?)`, fingerprints)
+		Select(`handled`).
+		Select(`array(select m.fingerprint from public.unhandled_exception_groups m where m.merged_into = g.id) as merged_fingerprints`).
+		Where(`app_id = ?`, af.AppID).
+		Where(`handled = ?`, false).
+		Where(`merged_into is null`).
+		Where(`(fingerprint = ANY(?) or id in (select merged_into from public.unhandled_exception_groups where app_id = ? and fingerprint = ANY(?)))`, fingerprints, af.AppID, fingerprints)
 
 	defer stmt.Close()
 
@@ -338,7 +492,9 @@ func GetExceptionGroupsFromExceptionIds(ctx context.Context, af *filter.AppFilte
 	// Add event ids to obtained exception groups
 	fingerprintToGroup := make(map[string]*ExceptionGroup)
 	for i := range exceptionGroups {
-		fingerprintToGroup[exceptionGroups[i].Fingerprint] = &exceptionGroups[i]
+		for _, fingerprint := range exceptionGroups[i].GetFingerprints() {
+			fingerprintToGroup[fingerprint] = &exceptionGroups[i]
+		}
 	}
 
 	for eventID, fingerprint := range eventIdToFingerprint {
@@ -356,8 +512,10 @@ func GetANRGroupsFromANRIds(ctx context.Context, af *filter.AppFilter, eventIds
 	// Get list of fingerprints and event IDs
 	eventDataStmt := sqlf.From(`default.events`).
 		Select(`id, anr.fingerprint`).
-		Where("app_id = toUUID(?)", af.AppID).
-		Where(`id in ?`, eventIds)
+		Clause("prewhere app_id = toUUID(?) and id in ?", af.AppID, eventIds).
+		Where("type = ?", event.TypeANR)
+
+	defer eventDataStmt.Close()
 
 	eventDataRows, err := server.Server.ChPool.Query(ctx, eventDataStmt.String(), eventDataStmt.Args()...)
 	if err != nil {
@@ -387,8 +545,9 @@ func GetANRGroupsFromANRIds(ctx context.Context, af *filter.AppFilter, eventIds
 	}
 
 	// Query groups that match the obtained fingerprints
+	// either directly or via groups merged into them
 	stmt := sqlf.PostgreSQL.
-		From(`public.anr_groups`).
+		From(`public.anr_groups g`).
 		Select(`id`).
 		Select(`type`).
 		Select(`message`).
@@ -396,7 +555,10 @@ func GetANRGroupsFromANRIds(ctx context.Context, af *filter.AppFilter, eventIds
 		Select(`file_name`).
 		Select(`line_number`).
 		Select(`fingerprint`).
-		Where(`fingerprint = ANY(?)`, fingerprints)
+		Select(`array(select m.fingerprint from public.anr_groups m where m.merged_into = g.id) as merged_fingerprints`).
+		Where(`app_id = ?`, af.AppID).
+		Where(`merged_into is null`).
+		Where(`(fingerprint = ANY(?) or id in (select merged_into from public.anr_groups where app_id = ? and fingerprint = ANY(?)))`, fingerprints, af.AppID, fingerprints)
 
 	defer stmt.Close()
 
@@ -413,7 +575,9 @@ func GetANRGroupsFromANRIds(ctx context.Context, af *filter.AppFilter, eventIds
 	// Add event ids to obtained ANR groups
 	fingerprintToGroup := make(map[string]*ANRGroup)
 	for i := range anrGroups {
-		fingerprintToGroup[anrGroups[i].Fingerprint] = &anrGroups[i]
+		for _, fingerprint := range anrGroups[i].GetFingerprints() {
+			fingerprintToGroup[fingerprint] = &anrGroups[i]
+		}
 	}
 
 	for eventID, fingerprint := range eventIdToFingerprint {
@@ -426,29 +590,36 @@ func GetANRGroupsFromANRIds(ctx context.Context, af *filter.AppFilter, eventIds
 }
 
 // NewExceptionGroup constructs a new ExceptionGroup and returns a pointer to it.
-func NewExceptionGroup(appId uuid.UUID, exceptionType, message, methodName, fileName string, lineNumber int, fingerprint string, firstTime time.Time) *ExceptionGroup {
+// Handled denotes whether the group is for handled (non-fatal) exceptions.
+func NewExceptionGroup(appId uuid.UUID, exceptionType, message, methodName, fileName string, lineNumber int, fingerprint string, firstTime time.Time, handled bool) *ExceptionGroup {
 	return &ExceptionGroup{
-		AppID:          appId,
-		Type:           exceptionType,
-		Message:        message,
-		MethodName:     methodName,
-		FileName:       fileName,
-		LineNumber:     lineNumber,
-		Fingerprint:    fingerprint,
-		FirstEventTime: firstTime,
+		AppID:              appId,
+		Type:               exceptionType,
+		Message:            message,
+		MethodName:         methodName,
+		FileName:           fileName,
+		LineNumber:         lineNumber,
+		Fingerprint:        fingerprint,
+		FingerprintVersion: event.FingerprintVersion,
+		Handled:            handled,
+		FirstEventTime:     firstTime,
+		LastEventTime:      firstTime,
 	}
 }
 
 // NewANRGroup constructs a new ANRGroup and returns a pointer to it.
-func NewANRGroup(appId uuid.UUID, anrType, message, methodName, fileName string, lineNumber int, fingerprint string, firstTime time.Time) *ANRGroup {
+func NewANRGroup(appId uuid.UUID, anrType, message, methodName, fileName string, lineNumber int, fingerprint, cause string, firstTime time.Time) *ANRGroup {
 	return &ANRGroup{
-		AppID:          appId,
-		Type:           anrType,
-		Message:        message,
-		MethodName:     methodName,
-		FileName:       fileName,
-		LineNumber:     lineNumber,
-		Fingerprint:    fingerprint,
-		FirstEventTime: firstTime,
+		AppID:              appId,
+		Type:               anrType,
+		Message:            message,
+		MethodName:         methodName,
+		FileName:           fileName,
+		LineNumber:         lineNumber,
+		Fingerprint:        fingerprint,
+		FingerprintVersion: event.FingerprintVersion,
+		Cause:              cause,
+		FirstEventTime:     firstTime,
+		LastEventTime:      firstTime,
 	}
 }
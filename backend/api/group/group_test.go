@@ -255,3 +255,27 @@ func TestBackwardLimitWithID(t *testing.T) {
 		}
 	}
 }
+
+func TestCountANRCauses(t *testing.T) {
+	anrGroups := []ANRGroup{
+		{Cause: "io_on_main", Count: 4},
+		{Cause: "deadlock", Count: 2},
+		{Cause: "io_on_main", Count: 3},
+		{Cause: "cpu", Count: 2},
+	}
+
+	expected := []ANRCauseCount{
+		{Cause: "io_on_main", Groups: 2, Count: 7},
+		{Cause: "cpu", Groups: 1, Count: 2},
+		{Cause: "deadlock", Groups: 1, Count: 2},
+	}
+
+	got := CountANRCauses(anrGroups)
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected %v but got %v", expected, got)
+	}
+
+	if got := CountANRCauses(nil); len(got) != 0 || got == nil {
+		t.Errorf("Expected empty causes but got %v", got)
+	}
+}
@@ -0,0 +1,182 @@
+package group
+
+import (
+	"backend/api/event"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// shingleSize is the count of consecutive frames
+// that make up a single shingle when comparing
+// stack traces.
+const shingleSize = 3
+
+// MinSimilarityScore is the minimum score an issue
+// group must achieve to be suggested as similar.
+const MinSimilarityScore = 0.5
+
+// MaxSimilarGroups is the maximum count of similar
+// issue groups suggested for an issue group.
+const MaxSimilarGroups = 10
+
+// SimilarGroup represents an issue group that is
+// similar to another issue group along with the
+// similarity scores.
+type SimilarGroup struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	Type        string    `json:"type" db:"type"`
+	Message     string    `json:"message" db:"message"`
+	MethodName  string    `json:"method_name" db:"method_name"`
+	FileName    string    `json:"file_name" db:"file_name"`
+	LineNumber  int       `json:"line_number" db:"line_number"`
+	Fingerprint string    `json:"fingerprint" db:"fingerprint"`
+	// Score is the combined similarity score
+	// in the range of 0 to 1.
+	Score float64 `json:"score"`
+	// Jaccard is the jaccard index of shingled
+	// frames in the range of 0 to 1.
+	Jaccard float64 `json:"jaccard"`
+	// EditSimilarity is the normalized edit
+	// distance similarity of frames in the range
+	// of 0 to 1.
+	EditSimilarity float64 `json:"edit_similarity"`
+}
+
+// NormalizeFrames converts exception units to a sequence
+// of normalized frame signatures suitable for comparison.
+// Line & column numbers are dropped as they drift across
+// versions. Only in-app frames are kept, unless there are
+// none, in which case all frames are kept.
+func NormalizeFrames(units event.ExceptionUnits) (frames []string) {
+	var all []string
+
+	for _, unit := range units {
+		for _, f := range unit.Frames {
+			signature := f.CodeInfo()
+			if signature == "" {
+				continue
+			}
+
+			all = append(all, signature)
+
+			if f.IsInApp() {
+				frames = append(frames, signature)
+			}
+		}
+	}
+
+	if len(frames) == 0 {
+		frames = all
+	}
+
+	return
+}
+
+// shingles computes the set of k-frame shingles
+// for a sequence of frames. Sequences shorter than
+// k produce a single shingle.
+func shingles(frames []string, k int) map[string]struct{} {
+	set := make(map[string]struct{})
+
+	if len(frames) == 0 {
+		return set
+	}
+
+	if len(frames) < k {
+		set[strings.Join(frames, "\n")] = struct{}{}
+		return set
+	}
+
+	for i := 0; i+k <= len(frames); i++ {
+		set[strings.Join(frames[i:i+k], "\n")] = struct{}{}
+	}
+
+	return set
+}
+
+// jaccard computes the jaccard index of
+// two sets.
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 0
+	}
+
+	intersection := 0
+	for k := range a {
+		if _, ok := b[k]; ok {
+			intersection++
+		}
+	}
+
+	union := len(a) + len(b) - intersection
+
+	return float64(intersection) / float64(union)
+}
+
+// editDistance computes the levenshtein distance
+// between two sequences of frames.
+func editDistance(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(b)]
+}
+
+// ComputeSimilarity computes similarity between two
+// sequences of normalized frames. Returns the combined
+// score along with the jaccard index of shingled frames
+// & the normalized edit similarity.
+func ComputeSimilarity(a, b []string) (score, jaccardIndex, editSimilarity float64) {
+	if len(a) == 0 || len(b) == 0 {
+		return
+	}
+
+	jaccardIndex = jaccard(shingles(a, shingleSize), shingles(b, shingleSize))
+
+	longest := max(len(a), len(b))
+	editSimilarity = 1 - float64(editDistance(a, b))/float64(longest)
+
+	score = (jaccardIndex + editSimilarity) / 2
+
+	jaccardIndex = round(jaccardIndex)
+	editSimilarity = round(editSimilarity)
+	score = round(score)
+
+	return
+}
+
+// SortSimilarGroups sorts a slice of SimilarGroup
+// by score in descending order.
+func SortSimilarGroups(groups []SimilarGroup) {
+	sort.SliceStable(groups, func(i, j int) bool {
+		if groups[i].Score != groups[j].Score {
+			return groups[i].Score > groups[j].Score
+		}
+		return groups[i].ID.String() < groups[j].ID.String()
+	})
+}
+
+// round rounds a float to 4 decimal
+// places.
+func round(f float64) float64 {
+	return math.Round(f*10000) / 10000
+}
@@ -0,0 +1,133 @@
+package group
+
+import (
+	"backend/api/event"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeFrames(t *testing.T) {
+	units := event.ExceptionUnits{
+		{
+			Type: "java.lang.IllegalStateException",
+			Frames: event.Frames{
+				{ClassName: "android.os.Handler", MethodName: "dispatchMessage", LineNum: 102},
+				{ClassName: "com.example.app.MainActivity", MethodName: "onCreate", LineNum: 42},
+				{ClassName: "com.example.app.Repository", MethodName: "load", LineNum: 7},
+				{ClassName: "java.lang.reflect.Method", MethodName: "invoke"},
+			},
+		},
+	}
+
+	expected := []string{
+		"com.example.app.MainActivity.onCreate",
+		"com.example.app.Repository.load",
+	}
+	got := NormalizeFrames(units)
+
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected %v but got %v", expected, got)
+	}
+}
+
+func TestNormalizeFramesNoInAppFrames(t *testing.T) {
+	units := event.ExceptionUnits{
+		{
+			Type: "java.lang.OutOfMemoryError",
+			Frames: event.Frames{
+				{ClassName: "java.util.ArrayList", MethodName: "grow"},
+				{ClassName: "android.os.Looper", MethodName: "loop"},
+			},
+		},
+	}
+
+	expected := []string{
+		"java.util.ArrayList.grow",
+		"android.os.Looper.loop",
+	}
+	got := NormalizeFrames(units)
+
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected %v but got %v", expected, got)
+	}
+}
+
+func TestComputeSimilarity(t *testing.T) {
+	a := []string{"A.a", "B.b", "C.c", "D.d", "E.e"}
+
+	// identical
+	{
+		score, jaccard, edit := ComputeSimilarity(a, a)
+		if score != 1 || jaccard != 1 || edit != 1 {
+			t.Errorf("Expected identical frames to score 1, but got %v %v %v", score, jaccard, edit)
+		}
+	}
+
+	// one frame differs
+	{
+		b := []string{"A.a", "B.b", "C.c", "D.d", "X.x"}
+		score, jaccard, edit := ComputeSimilarity(a, b)
+
+		expectedJaccard := 0.5
+		expectedEdit := 0.8
+		expectedScore := 0.65
+
+		if jaccard != expectedJaccard {
+			t.Errorf("Expected %v jaccard, but got %v", expectedJaccard, jaccard)
+		}
+		if edit != expectedEdit {
+			t.Errorf("Expected %v edit similarity, but got %v", expectedEdit, edit)
+		}
+		if score != expectedScore {
+			t.Errorf("Expected %v score, but got %v", expectedScore, score)
+		}
+	}
+
+	// completely different
+	{
+		b := []string{"V.v", "W.w", "X.x"}
+		score, _, _ := ComputeSimilarity(a, b)
+
+		if score != 0 {
+			t.Errorf("Expected 0 score, but got %v", score)
+		}
+	}
+
+	// empty
+	{
+		score, _, _ := ComputeSimilarity(a, nil)
+
+		if score != 0 {
+			t.Errorf("Expected 0 score, but got %v", score)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	a := []string{"A", "B", "C"}
+	b := []string{"A", "C", "D", "E"}
+
+	expected := 3
+	got := editDistance(a, b)
+
+	if expected != got {
+		t.Errorf("Expected %d but got %d", expected, got)
+	}
+}
+
+func TestSortSimilarGroups(t *testing.T) {
+	similar := []SimilarGroup{
+		{ID: groups[0].ID, Score: 0.6},
+		{ID: groups[1].ID, Score: 0.9},
+		{ID: groups[2].ID, Score: 0.75},
+	}
+
+	SortSimilarGroups(similar)
+
+	expected := []float64{0.9, 0.75, 0.6}
+	got := []float64{similar[0].Score, similar[1].Score, similar[2].Score}
+
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected %v but got %v", expected, got)
+	}
+}
@@ -85,6 +85,18 @@ type Options struct {
 	ANRGroup *group.ANRGroup
 }
 
+// isExceptionIssue determines if the exception event
+// should be considered an issue for the journey. When
+// bound to a non-fatal exception group, only handled
+// exceptions qualify, otherwise only unhandled ones.
+func (o *Options) isExceptionIssue(ev event.EventField) bool {
+	if o.ExceptionGroup != nil && o.ExceptionGroup.Handled {
+		return ev.IsHandledException()
+	}
+
+	return ev.IsUnhandledException()
+}
+
 // NodeAndroid represents each
 // node of the journey graph
 // for android.
@@ -197,7 +209,7 @@ func (j *JourneyAndroid) computeIssues() {
 			if !ok {
 				continue
 			}
-			if issueEvent.IsUnhandledException() {
+			if j.options.isExceptionIssue(issueEvent) {
 				bag.exceptionIds.Add(issueEvent.ID)
 			} else if issueEvent.IsANR() {
 				bag.anrIds.Add(issueEvent.ID)
@@ -609,7 +621,7 @@ func NewJourneyAndroid(events []event.EventField, opts *Options) (journey Journe
 		node.ID = i
 		activity := events[i].IsLifecycleActivity()
 		fragment := events[i].IsLifecycleFragment()
-		issue := i > 0 && events[i].IsUnhandledException() || events[i].IsANR()
+		issue := i > 0 && opts.isExceptionIssue(events[i]) || events[i].IsANR()
 
 		if activity {
 			node.Name = events[i].LifecycleActivity.ClassName
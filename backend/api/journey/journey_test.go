@@ -1332,6 +1332,39 @@ func TestNewJourneyAndroidExceptionsOne(t *testing.T) {
 	}
 }
 
+func TestNewJourneyAndroidNonFatalsOne(t *testing.T) {
+	events, err := readEvents("events_one.json")
+	if err != nil {
+		panic(err)
+	}
+
+	nonFatalGroup := exceptionGroupOne
+	nonFatalGroup.Handled = true
+
+	journey := NewJourneyAndroid(events, &Options{
+		BiGraph:        true,
+		ExceptionGroup: &nonFatalGroup,
+	})
+
+	if err := journey.SetNodeExceptionGroups(func(eventIds []uuid.UUID) (exceptionGroups []group.ExceptionGroup, err error) {
+		exceptionGroups = []group.ExceptionGroup{nonFatalGroup}
+		return
+	}); err != nil {
+		panic(err)
+	}
+
+	// unhandled exceptions must not be attached
+	// to nodes of a non-fatal journey
+	for _, v := range journey.GetNodeVertices() {
+		expected := 0
+		got := journey.GetNodeExceptionCount(v, nonFatalGroup.ID)
+
+		if expected != got {
+			t.Errorf("Expected %d node exceptions, but got %d", expected, got)
+		}
+	}
+}
+
 func TestNewJourneyAndroidExceptionsTwo(t *testing.T) {
 	events, err := readEvents("events_two.json")
 	if err != nil {
@@ -6,8 +6,10 @@ import (
 	"net/http"
 	"time"
 
+	"backend/api/email"
 	"backend/api/inet"
 	"backend/api/measure"
+	"backend/api/notify"
 	"backend/api/server"
 
 	"github.com/gin-contrib/cors"
@@ -36,6 +38,16 @@ func main() {
 		}
 	}()
 
+	// deliver emails from the outbox in the background
+	dispatchCtx, stopDispatcher := context.WithCancel(context.Background())
+	defer stopDispatcher()
+	go email.RunDispatcher(dispatchCtx, time.Minute)
+	go notify.RunDispatcher(dispatchCtx, time.Minute)
+	go measure.RunAlertRules(dispatchCtx, time.Minute)
+	go measure.RunIssueAlerts(dispatchCtx, time.Minute)
+	go measure.RunDigests(dispatchCtx, time.Minute)
+	go measure.RunSpanRegressions(dispatchCtx, time.Hour)
+
 	r := gin.Default()
 
 	closeTracer := config.InitTracer()
@@ -60,6 +72,13 @@ func main() {
 	r.PUT("/events", measure.ValidateAPIKey(), measure.PutEvents)
 	r.PUT("/builds", measure.ValidateAPIKey(), measure.PutBuild)
 
+	// Email routes
+	// Unsubscribe requests come from email clients &
+	// the confirmation page, not the dashboard, so
+	// these are registered before CORS
+	r.GET("/emails/unsubscribe", measure.ConfirmUnsubscribeEmails)
+	r.POST("/emails/unsubscribe", measure.UnsubscribeEmails)
+
 	cors := cors.New(cors.Config{
 		AllowOrigins:     []string{config.SiteOrigin},
 		AllowMethods:     []string{"GET", "OPTIONS", "PATCH", "DELETE", "PUT"},
@@ -88,24 +107,72 @@ func main() {
 	{
 		apps.GET(":id/journey", measure.GetAppJourney)
 		apps.GET(":id/metrics", measure.GetAppMetrics)
+		apps.GET(":id/exits", measure.GetAppExits)
+		apps.GET(":id/launches/plot", measure.GetLaunchPlot)
+		apps.GET(":id/http/endpoints", measure.GetHttpEndpoints)
+		apps.GET(":id/http/breakdowns", measure.GetHttpBreakdowns)
+		apps.GET(":id/http/plot", measure.GetHttpPlot)
+		apps.GET(":id/screens", measure.GetScreens)
+		apps.GET(":id/releases/compare", measure.GetReleaseComparison)
+		apps.POST(":id/funnels", measure.GetFunnelAnalysis)
+		apps.GET(":id/retention", measure.GetRetention)
+		apps.GET(":id/clicks/frustrations", measure.GetClickFrustrations)
 		apps.GET(":id/filters", measure.GetAppFilters)
 		apps.GET(":id/crashGroups", measure.GetCrashOverview)
 		apps.GET(":id/crashGroups/plots/instances", measure.GetCrashOverviewPlotInstances)
 		apps.GET(":id/crashGroups/:crashGroupId/crashes", measure.GetCrashDetailCrashes)
 		apps.GET(":id/crashGroups/:crashGroupId/plots/instances", measure.GetCrashDetailPlotInstances)
 		apps.GET(":id/crashGroups/:crashGroupId/plots/distribution", measure.GetCrashDetailAttributeDistribution)
+		apps.GET(":id/crashGroups/:crashGroupId/correlations", measure.GetCrashDetailAttributeCorrelations)
 		apps.GET(":id/crashGroups/:crashGroupId/plots/journey", measure.GetCrashDetailPlotJourney)
+		apps.GET(":id/crashGroups/:crashGroupId/similar", measure.GetCrashDetailSimilar)
+		apps.POST(":id/crashGroups/:crashGroupId/merge", measure.MergeCrashGroups)
 		apps.GET(":id/anrGroups", measure.GetANROverview)
 		apps.GET(":id/anrGroups/plots/instances", measure.GetANROverviewPlotInstances)
 		apps.GET(":id/anrGroups/:anrGroupId/anrs", measure.GetANRDetailANRs)
 		apps.GET(":id/anrGroups/:anrGroupId/plots/instances", measure.GetANRDetailPlotInstances)
 		apps.GET(":id/anrGroups/:anrGroupId/plots/distribution", measure.GetANRDetailAttributeDistribution)
+		apps.GET(":id/anrGroups/:anrGroupId/correlations", measure.GetANRDetailAttributeCorrelations)
 		apps.GET(":id/anrGroups/:anrGroupId/plots/journey", measure.GetANRDetailPlotJourney)
+		apps.GET(":id/anrGroups/:anrGroupId/similar", measure.GetANRDetailSimilar)
+		apps.POST(":id/anrGroups/:anrGroupId/merge", measure.MergeANRGroups)
+		apps.GET(":id/nonFatalGroups", measure.GetNonFatalOverview)
+		apps.GET(":id/nonFatalGroups/plots/instances", measure.GetNonFatalOverviewPlotInstances)
+		apps.GET(":id/nonFatalGroups/:nonFatalGroupId/nonFatals", measure.GetNonFatalDetailNonFatals)
+		apps.GET(":id/nonFatalGroups/:nonFatalGroupId/plots/instances", measure.GetNonFatalDetailPlotInstances)
+		apps.GET(":id/nonFatalGroups/:nonFatalGroupId/plots/distribution", measure.GetNonFatalDetailAttributeDistribution)
+		apps.GET(":id/nonFatalGroups/:nonFatalGroupId/correlations", measure.GetNonFatalDetailAttributeCorrelations)
+		apps.GET(":id/nonFatalGroups/:nonFatalGroupId/plots/journey", measure.GetNonFatalDetailPlotJourney)
+		apps.GET(":id/nonFatalGroups/:nonFatalGroupId/similar", measure.GetNonFatalDetailSimilar)
+		apps.POST(":id/nonFatalGroups/:nonFatalGroupId/merge", measure.MergeNonFatalGroups)
+		apps.POST(":id/fingerprintJobs", measure.CreateFingerprintJob)
+		apps.GET(":id/fingerprintJobs", measure.GetFingerprintJobs)
+		apps.GET(":id/fingerprintJobs/:jobId", measure.GetFingerprintJob)
 		apps.GET(":id/sessions", measure.GetSessionsOverview)
 		apps.GET(":id/sessions/:sessionId", measure.GetSession)
 		apps.GET(":id/sessions/plots/instances", measure.GetSessionsOverviewPlotInstances)
 		apps.GET(":id/alertPrefs", measure.GetAlertPrefs)
 		apps.PATCH(":id/alertPrefs", measure.UpdateAlertPrefs)
+		apps.GET(":id/digestPrefs", measure.GetDigestPrefs)
+		apps.PATCH(":id/digestPrefs", measure.UpdateDigestPrefs)
+		apps.GET(":id/digestPreview", measure.GetDigestPreview)
+		apps.GET(":id/channels", measure.GetNotificationChannels)
+		apps.POST(":id/channels", measure.CreateNotificationChannel)
+		apps.PATCH(":id/channels/:channelId", measure.UpdateNotificationChannel)
+		apps.DELETE(":id/channels/:channelId", measure.DeleteNotificationChannel)
+		apps.POST(":id/channels/:channelId/test", measure.TestNotificationChannel)
+		apps.GET(":id/channels/:channelId/deliveries", measure.GetNotificationDeliveries)
+		apps.GET(":id/alertRules", measure.GetAlertRules)
+		apps.POST(":id/alertRules", measure.CreateAlertRule)
+		apps.GET(":id/alertRules/:ruleId", measure.GetAlertRule)
+		apps.PATCH(":id/alertRules/:ruleId", measure.UpdateAlertRule)
+		apps.DELETE(":id/alertRules/:ruleId", measure.DeleteAlertRule)
+		apps.GET(":id/alertIncidents", measure.GetAlertIncidents)
+		apps.GET(":id/alertIncidents/:incidentId", measure.GetAlertIncident)
+		apps.PATCH(":id/alertIncidents/:incidentId", measure.UpdateAlertIncident)
+		apps.GET(":id/alertMutes", measure.GetAlertMutes)
+		apps.POST(":id/alertMutes", measure.CreateAlertMute)
+		apps.DELETE(":id/alertMutes/:muteId", measure.DeleteAlertMute)
 		apps.GET(":id/settings", measure.GetAppSettings)
 		apps.PATCH(":id/settings", measure.UpdateAppSettings)
 		apps.PATCH(":id/rename", measure.RenameApp)
@@ -113,6 +180,8 @@ func main() {
 		apps.GET(":id/spans/roots/names", measure.GetRootSpanNames)
 		apps.GET(":id/spans/instances", measure.GetSpanInstances)
 		apps.GET(":id/spans/plot", measure.GetSpanMetricsPlot)
+		apps.GET(":id/spans/plot/breakdown", measure.GetSpanMetricsBreakdown)
+		apps.GET(":id/spans/regressions", measure.GetSpanRegressions)
 		apps.GET(":id/traces/:traceId", measure.GetTrace)
 	}
 
@@ -0,0 +1,859 @@
+package measure
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"backend/api/chrono"
+	"backend/api/filter"
+	"backend/api/server"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+const (
+	IncidentStatusOpen         = "open"
+	IncidentStatusAcknowledged = "acknowledged"
+	IncidentStatusResolved     = "resolved"
+)
+
+// defaultIncidentsLimit is the count of incidents
+// returned unless a limit is passed.
+const defaultIncidentsLimit = 20
+
+// maxIncidentsLimit is the maximum count of
+// incidents returned at once.
+const maxIncidentsLimit = 100
+
+// maxMuteDuration is the maximum duration
+// in minutes alerts can be muted for.
+const maxMuteDuration = 30 * 24 * 60
+
+// AlertIncident represents an alert that fired &
+// stays open until its metric recovers or a team
+// member resolves it.
+type AlertIncident struct {
+	ID      uuid.UUID  `json:"id" db:"id"`
+	AppID   uuid.UUID  `json:"app_id" db:"app_id"`
+	RuleID  *uuid.UUID `json:"rule_id" db:"rule_id"`
+	Type    string     `json:"type" db:"type"`
+	Status  string     `json:"status" db:"status"`
+	Message string     `json:"message" db:"message"`
+	// Filters is the snapshot of the filters the
+	// metric was computed with. Nil for alerts
+	// computed over all of the app's data.
+	Filters *filter.FilterList `json:"filters" db:"filters"`
+	// Snapshot is the snapshot of the metric
+	// values that opened the incident.
+	Snapshot json.RawMessage `json:"snapshot" db:"snapshot"`
+	// Muted is true if the incident opened while
+	// its alerts were muted & no one was notified.
+	Muted          bool            `json:"muted" db:"muted"`
+	OpenedAt       chrono.ISOTime  `json:"opened_at" db:"opened_at"`
+	AcknowledgedAt *chrono.ISOTime `json:"acknowledged_at" db:"acknowledged_at"`
+	AcknowledgedBy *uuid.UUID      `json:"acknowledged_by" db:"acknowledged_by"`
+	ResolvedAt     *chrono.ISOTime `json:"resolved_at" db:"resolved_at"`
+	// ResolvedBy is the user who resolved the
+	// incident. Nil for incidents that resolved
+	// on their own after the metric recovered.
+	ResolvedBy *uuid.UUID     `json:"resolved_by" db:"resolved_by"`
+	UpdatedAt  chrono.ISOTime `json:"updated_at" db:"updated_at"`
+}
+
+// AlertIncidentPayload represents the
+// request to update an incident's status.
+type AlertIncidentPayload struct {
+	Status string `json:"status" binding:"required"`
+}
+
+// AlertMute represents alerts of an app muted
+// until a point in time. Mutes without an alert
+// type & rule mute all of the app's alerts.
+type AlertMute struct {
+	ID         uuid.UUID      `json:"id" db:"id"`
+	AppID      uuid.UUID      `json:"app_id" db:"app_id"`
+	AlertType  *string        `json:"alert_type" db:"alert_type"`
+	RuleID     *uuid.UUID     `json:"rule_id" db:"rule_id"`
+	MutedUntil chrono.ISOTime `json:"muted_until" db:"muted_until"`
+	CreatedBy  *uuid.UUID     `json:"created_by" db:"created_by"`
+	CreatedAt  chrono.ISOTime `json:"created_at" db:"created_at"`
+}
+
+// AlertMutePayload represents the
+// request to mute alerts.
+type AlertMutePayload struct {
+	AlertType *string    `json:"alert_type"`
+	RuleID    *uuid.UUID `json:"rule_id"`
+	// Duration is the duration in minutes
+	// the alerts stay muted for.
+	Duration int `json:"duration"`
+}
+
+// transition moves the incident to the status on
+// behalf of the user. Incidents can be acknowledged
+// while open & resolved until resolved.
+func (inc *AlertIncident) transition(status string, userId uuid.UUID, at time.Time) error {
+	now := chrono.ISOTime(at)
+
+	switch status {
+	case IncidentStatusAcknowledged:
+		if inc.Status != IncidentStatusOpen {
+			return fmt.Errorf("only open incidents can be acknowledged, incident is %s", inc.Status)
+		}
+		inc.AcknowledgedAt = &now
+		inc.AcknowledgedBy = &userId
+	case IncidentStatusResolved:
+		if inc.Status == IncidentStatusResolved {
+			return errors.New("incident is already resolved")
+		}
+		inc.ResolvedAt = &now
+		inc.ResolvedBy = &userId
+	default:
+		return fmt.Errorf("status must be one of %s, %s", IncidentStatusAcknowledged, IncidentStatusResolved)
+	}
+
+	inc.Status = status
+	inc.UpdatedAt = now
+
+	return nil
+}
+
+// Validate validates the request to mute alerts.
+func (p AlertMutePayload) Validate() error {
+	if p.Duration < 1 || p.Duration > maxMuteDuration {
+		return fmt.Errorf("duration must be between 1 and %d minutes", maxMuteDuration)
+	}
+
+	if p.AlertType != nil && !slices.Contains(alertTypes, *p.AlertType) {
+		return fmt.Errorf("unknown alert type %q", *p.AlertType)
+	}
+
+	if p.RuleID != nil && p.AlertType != nil && *p.AlertType != AlertTypeAlertRule {
+		return fmt.Errorf("alert_type must be %q when muting a rule", AlertTypeAlertRule)
+	}
+
+	return nil
+}
+
+// alertIncidentColumns lists the
+// columns of alert incidents.
+var alertIncidentColumns = []string{
+	"id",
+	"app_id",
+	"rule_id",
+	"type",
+	"status",
+	"message",
+	"filters",
+	"snapshot",
+	"muted",
+	"opened_at",
+	"acknowledged_at",
+	"acknowledged_by",
+	"resolved_at",
+	"resolved_by",
+	"updated_at",
+}
+
+// alertMuteColumns lists the
+// columns of alert mutes.
+var alertMuteColumns = []string{
+	"id",
+	"app_id",
+	"alert_type",
+	"rule_id",
+	"muted_until",
+	"created_by",
+	"created_at",
+}
+
+// insert opens the incident with the snapshot of
+// metric values. Returns false if an incident of
+// the same alert is not resolved yet.
+func (inc *AlertIncident) insert(ctx context.Context, tx *pgx.Tx, snapshot any) (opened bool, err error) {
+	stmt := sqlf.PostgreSQL.InsertInto("public.alert_incidents").
+		Set("id", inc.ID).
+		Set("app_id", inc.AppID).
+		Set("rule_id", inc.RuleID).
+		Set("type", inc.Type).
+		Set("status", inc.Status).
+		Set("message", inc.Message).
+		Set("filters", inc.Filters).
+		Set("snapshot", snapshot).
+		Set("muted", inc.Muted).
+		Set("opened_at", time.Time(inc.OpenedAt)).
+		Set("updated_at", time.Time(inc.OpenedAt)).
+		Clause("on conflict do nothing")
+
+	defer stmt.Close()
+
+	result, err := (*tx).Exec(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// update saves the incident's status, unless
+// it changed since the incident was fetched.
+func (inc *AlertIncident) update(ctx context.Context, previousStatus string) (updated bool, err error) {
+	stmt := sqlf.PostgreSQL.Update("public.alert_incidents").
+		Set("status", inc.Status).
+		Set("acknowledged_at", (*time.Time)(inc.AcknowledgedAt)).
+		Set("acknowledged_by", inc.AcknowledgedBy).
+		Set("resolved_at", (*time.Time)(inc.ResolvedAt)).
+		Set("resolved_by", inc.ResolvedBy).
+		Set("updated_at", time.Time(inc.UpdatedAt)).
+		Where("id = ?", inc.ID).
+		Where("status = ?", previousStatus)
+
+	defer stmt.Close()
+
+	result, err := server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// resolveRuleIncidents resolves the open &
+// acknowledged incidents of the alert rule
+// after its metric recovered.
+func resolveRuleIncidents(ctx context.Context, ruleId uuid.UUID, at time.Time) (err error) {
+	stmt := resolveRuleIncidentsStmt(ruleId, at)
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// resolveRuleIncidentsStmt builds the statement
+// resolving the open & acknowledged incidents of
+// the alert rule.
+func resolveRuleIncidentsStmt(ruleId uuid.UUID, at time.Time) *sqlf.Stmt {
+	return sqlf.PostgreSQL.Update("public.alert_incidents").
+		Set("status", IncidentStatusResolved).
+		Set("resolved_at", at).
+		Set("updated_at", at).
+		Where("rule_id = ?", ruleId).
+		Where("status in (?, ?)", IncidentStatusOpen, IncidentStatusAcknowledged)
+}
+
+// getAlertIncidents fetches the app's incidents,
+// most recent first, optionally of a status.
+func getAlertIncidents(ctx context.Context, appId uuid.UUID, status string, limit, offset int) (incidents []AlertIncident, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.alert_incidents").
+		Where("app_id = ?", appId).
+		OrderBy("opened_at desc", "id").
+		Limit(limit).
+		Offset(offset)
+
+	defer stmt.Close()
+
+	for _, col := range alertIncidentColumns {
+		stmt.Select(col)
+	}
+
+	if status != "" {
+		stmt.Where("status = ?", status)
+	}
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[AlertIncident])
+}
+
+// getAlertIncident fetches an incident of an app.
+// Returns nil if the incident does not exist.
+func getAlertIncident(ctx context.Context, appId, id uuid.UUID) (incident *AlertIncident, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.alert_incidents").
+		Where("app_id = ?", appId).
+		Where("id = ?", id)
+
+	defer stmt.Close()
+
+	for _, col := range alertIncidentColumns {
+		stmt.Select(col)
+	}
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	found, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[AlertIncident])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+
+	return &found, nil
+}
+
+// isAlertMuted checks if alerts of the type, & of the
+// rule if any, are muted for the app at the time.
+func isAlertMuted(ctx context.Context, appId uuid.UUID, alertType string, ruleId *uuid.UUID, at time.Time) (muted bool, err error) {
+	stmt := sqlf.PostgreSQL.
+		Select("exists(select 1 from public.alert_mutes where app_id = ? and muted_until > ? and (alert_type is null or alert_type = ?) and (rule_id is null or rule_id = ?))", appId, at, alertType, ruleId)
+
+	defer stmt.Close()
+
+	err = server.Server.PgPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&muted)
+
+	return
+}
+
+// getAlertMutes fetches the app's mutes
+// that haven't expired yet.
+func getAlertMutes(ctx context.Context, appId uuid.UUID) (mutes []AlertMute, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.alert_mutes").
+		Where("app_id = ?", appId).
+		Where("muted_until > ?", time.Now()).
+		OrderBy("muted_until")
+
+	defer stmt.Close()
+
+	for _, col := range alertMuteColumns {
+		stmt.Select(col)
+	}
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[AlertMute])
+}
+
+// insert creates the mute.
+func (m *AlertMute) insert(ctx context.Context) (err error) {
+	stmt := sqlf.PostgreSQL.InsertInto("public.alert_mutes").
+		Set("id", m.ID).
+		Set("app_id", m.AppID).
+		Set("alert_type", m.AlertType).
+		Set("rule_id", m.RuleID).
+		Set("muted_until", time.Time(m.MutedUntil)).
+		Set("created_by", m.CreatedBy).
+		Set("created_at", time.Time(m.CreatedAt))
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// deleteAlertMute deletes a mute of an app, unmuting
+// its alerts. Returns false if the mute does not exist.
+func deleteAlertMute(ctx context.Context, appId, id uuid.UUID) (deleted bool, err error) {
+	stmt := sqlf.PostgreSQL.DeleteFrom("public.alert_mutes").
+		Where("app_id = ?", appId).
+		Where("id = ?", id)
+
+	defer stmt.Close()
+
+	result, err := server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+func GetAlertIncidents(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	status := c.Query("status")
+	if status != "" && !slices.Contains([]string{IncidentStatusOpen, IncidentStatusAcknowledged, IncidentStatusResolved}, status) {
+		msg := fmt.Sprintf(`status must be one of %s, %s, %s`, IncidentStatusOpen, IncidentStatusAcknowledged, IncidentStatusResolved)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultIncidentsLimit)))
+	if err != nil || limit < 1 || limit > maxIncidentsLimit {
+		msg := fmt.Sprintf(`limit must be between 1 and %d`, maxIncidentsLimit)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	offset, err := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	if err != nil || offset < 0 {
+		msg := `offset must not be negative`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAlertRead)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to read alert incidents in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	// fetch one more than the limit to
+	// know if there's a next page
+	incidents, err := getAlertIncidents(ctx, appId, status, limit+1, offset)
+	if err != nil {
+		msg := `failed to fetch alert incidents`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	next := len(incidents) > limit
+	if next {
+		incidents = incidents[:limit]
+	}
+
+	if incidents == nil {
+		incidents = []AlertIncident{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": incidents,
+		"meta": gin.H{
+			"next":     next,
+			"previous": offset > 0,
+		},
+	})
+}
+
+func GetAlertIncident(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	incidentId, err := uuid.Parse(c.Param("incidentId"))
+	if err != nil {
+		msg := `incident id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAlertRead)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to read alert incidents in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	incident, err := getAlertIncident(ctx, appId, incidentId)
+	if err != nil {
+		msg := `failed to fetch alert incident`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if incident == nil {
+		msg := fmt.Sprintf(`alert incident [%s] does not exist`, incidentId)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, incident)
+}
+
+func UpdateAlertIncident(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId, err := uuid.Parse(c.GetString("userId"))
+	if err != nil {
+		msg := `user id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	incidentId, err := uuid.Parse(c.Param("incidentId"))
+	if err != nil {
+		msg := `incident id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId.String(), team.ID.String(), *ScopeAlertAll)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to modify alert incidents in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var payload AlertIncidentPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		msg := `failed to parse alert incident json payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	incident, err := getAlertIncident(ctx, appId, incidentId)
+	if err != nil {
+		msg := `failed to fetch alert incident`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if incident == nil {
+		msg := fmt.Sprintf(`alert incident [%s] does not exist`, incidentId)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	previousStatus := incident.Status
+
+	if err := incident.transition(payload.Status, userId, time.Now()); err != nil {
+		msg := `alert incident status is invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	updated, err := incident.update(ctx, previousStatus)
+	if err != nil {
+		msg := `failed to update alert incident`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !updated {
+		msg := fmt.Sprintf(`alert incident [%s] was updated in the meantime, fetch it & try again`, incidentId)
+		c.JSON(http.StatusConflict, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, incident)
+}
+
+func GetAlertMutes(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAlertRead)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to read alert mutes in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	mutes, err := getAlertMutes(ctx, appId)
+	if err != nil {
+		msg := `failed to fetch alert mutes`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if mutes == nil {
+		mutes = []AlertMute{}
+	}
+
+	c.JSON(http.StatusOK, mutes)
+}
+
+func CreateAlertMute(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId, err := uuid.Parse(c.GetString("userId"))
+	if err != nil {
+		msg := `user id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId.String(), team.ID.String(), *ScopeAlertAll)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to mute alerts in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var payload AlertMutePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		msg := `failed to parse alert mute json payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := payload.Validate(); err != nil {
+		msg := `alert mute is invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	if payload.RuleID != nil {
+		rule, err := getAlertRule(ctx, appId, *payload.RuleID)
+		if err != nil {
+			msg := `failed to fetch alert rule`
+			fmt.Println(msg, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+		if rule == nil {
+			msg := fmt.Sprintf(`alert rule [%s] does not exist`, payload.RuleID)
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+	}
+
+	now := time.Now()
+	mute := AlertMute{
+		ID:         uuid.New(),
+		AppID:      appId,
+		AlertType:  payload.AlertType,
+		RuleID:     payload.RuleID,
+		MutedUntil: chrono.ISOTime(now.Add(time.Duration(payload.Duration) * time.Minute)),
+		CreatedBy:  &userId,
+		CreatedAt:  chrono.ISOTime(now),
+	}
+
+	if err := mute.insert(ctx); err != nil {
+		msg := `failed to mute alerts`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusCreated, &mute)
+}
+
+func DeleteAlertMute(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	muteId, err := uuid.Parse(c.Param("muteId"))
+	if err != nil {
+		msg := `mute id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAlertAll)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to unmute alerts in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	deleted, err := deleteAlertMute(ctx, appId, muteId)
+	if err != nil {
+		msg := `failed to unmute alerts`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !deleted {
+		msg := fmt.Sprintf(`alert mute [%s] does not exist`, muteId)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": "done"})
+}
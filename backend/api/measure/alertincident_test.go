@@ -0,0 +1,77 @@
+package measure
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestAlertIncidentTransition(t *testing.T) {
+	userId := uuid.New()
+	now := time.Date(2024, 12, 24, 12, 0, 0, 0, time.UTC)
+
+	incident := AlertIncident{Status: IncidentStatusOpen}
+
+	if err := incident.transition(IncidentStatusAcknowledged, userId, now); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if incident.Status != IncidentStatusAcknowledged || incident.AcknowledgedBy == nil || *incident.AcknowledgedBy != userId {
+		t.Errorf("Expected incident to be acknowledged by user, but got %+v", incident)
+	}
+
+	if err := incident.transition(IncidentStatusAcknowledged, userId, now); err == nil {
+		t.Errorf("Expected error acknowledging acknowledged incident")
+	}
+
+	if err := incident.transition(IncidentStatusResolved, userId, now); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if incident.Status != IncidentStatusResolved || incident.ResolvedAt == nil || !time.Time(*incident.ResolvedAt).Equal(now) {
+		t.Errorf("Expected incident to be resolved at %v, but got %+v", now, incident)
+	}
+
+	if err := incident.transition(IncidentStatusResolved, userId, now); err == nil {
+		t.Errorf("Expected error resolving resolved incident")
+	}
+
+	reopened := AlertIncident{Status: IncidentStatusResolved}
+	if err := reopened.transition(IncidentStatusOpen, userId, now); err == nil {
+		t.Errorf("Expected error reopening incident")
+	}
+}
+
+func TestAlertMutePayloadValidate(t *testing.T) {
+	ruleId := uuid.New()
+	crash := AlertTypeCrashRateSpike
+	rule := AlertTypeAlertRule
+	unknown := "meteor_strike"
+
+	valid := []AlertMutePayload{
+		{Duration: 60},
+		{AlertType: &crash, Duration: maxMuteDuration},
+		{RuleID: &ruleId, Duration: 60},
+		{AlertType: &rule, RuleID: &ruleId, Duration: 60},
+	}
+
+	for _, p := range valid {
+		if err := p.Validate(); err != nil {
+			t.Errorf("Expected no error for %+v, but got %v", p, err)
+		}
+	}
+
+	invalid := map[string]AlertMutePayload{
+		"no duration":        {},
+		"too long":           {Duration: maxMuteDuration + 1},
+		"unknown alert type": {AlertType: &unknown, Duration: 60},
+		"rule of other type": {AlertType: &crash, RuleID: &ruleId, Duration: 60},
+	}
+
+	for name, p := range invalid {
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+}
@@ -4,58 +4,164 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"time"
 
 	"backend/api/chrono"
+	"backend/api/email"
 	"backend/api/server"
 
+	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/leporo/sqlf"
 )
 
+const (
+	AlertTypeCrashRateSpike  = "crash_rate_spike"
+	AlertTypeAnrRateSpike    = "anr_rate_spike"
+	AlertTypeLaunchTimeSpike = "launch_time_spike"
+	AlertTypeNewIssue        = "new_issue"
+	AlertTypeRegression      = "regression"
+	AlertTypeAlertRule       = "alert_rule"
+	AlertTypeSpanRegression  = "span_regression"
+)
+
 type AlertPref struct {
 	AppId                uuid.UUID
 	UserId               uuid.UUID
 	CrashRateSpikeEmail  bool
 	AnrRateSpikeEmail    bool
 	LaunchTimeSpikeEmail bool
+	NewIssueEmail        bool
+	RegressionEmail      bool
+	AlertRuleEmail       bool
+	SpanRegressionEmail  bool
 	UpdatedAt            time.Time
 	CreatedAt            time.Time
+	// Channels maps alert types to the ids of the
+	// app's notification channels subscribed to them.
+	// Channels are shared by all members of the team.
+	Channels map[string][]uuid.UUID
 }
 
 type AlertPrefPayload struct {
 	CrashRateSpike struct {
-		Email bool `json:"email"`
+		Email    bool         `json:"email"`
+		Channels *[]uuid.UUID `json:"channels"`
 	} `json:"crash_rate_spike"`
 	AnrRateSpike struct {
-		Email bool `json:"email"`
+		Email    bool         `json:"email"`
+		Channels *[]uuid.UUID `json:"channels"`
 	} `json:"anr_rate_spike"`
 	LaunchTimeSpike struct {
-		Email bool `json:"email"`
+		Email    bool         `json:"email"`
+		Channels *[]uuid.UUID `json:"channels"`
 	} `json:"launch_time_spike"`
+	NewIssue struct {
+		Email    bool         `json:"email"`
+		Channels *[]uuid.UUID `json:"channels"`
+	} `json:"new_issue"`
+	Regression struct {
+		Email    bool         `json:"email"`
+		Channels *[]uuid.UUID `json:"channels"`
+	} `json:"regression"`
+	AlertRule struct {
+		Email    bool         `json:"email"`
+		Channels *[]uuid.UUID `json:"channels"`
+	} `json:"alert_rule"`
+	SpanRegression struct {
+		Email    bool         `json:"email"`
+		Channels *[]uuid.UUID `json:"channels"`
+	} `json:"span_regression"`
+}
+
+// channels returns the channel subscriptions
+// present in the payload by alert type.
+func (p AlertPrefPayload) channels() map[string][]uuid.UUID {
+	channels := make(map[string][]uuid.UUID)
+
+	if p.CrashRateSpike.Channels != nil {
+		channels[AlertTypeCrashRateSpike] = *p.CrashRateSpike.Channels
+	}
+	if p.AnrRateSpike.Channels != nil {
+		channels[AlertTypeAnrRateSpike] = *p.AnrRateSpike.Channels
+	}
+	if p.LaunchTimeSpike.Channels != nil {
+		channels[AlertTypeLaunchTimeSpike] = *p.LaunchTimeSpike.Channels
+	}
+	if p.NewIssue.Channels != nil {
+		channels[AlertTypeNewIssue] = *p.NewIssue.Channels
+	}
+	if p.Regression.Channels != nil {
+		channels[AlertTypeRegression] = *p.Regression.Channels
+	}
+	if p.AlertRule.Channels != nil {
+		channels[AlertTypeAlertRule] = *p.AlertRule.Channels
+	}
+	if p.SpanRegression.Channels != nil {
+		channels[AlertTypeSpanRegression] = *p.SpanRegression.Channels
+	}
+
+	return channels
 }
 
 func (pref *AlertPref) MarshalJSON() ([]byte, error) {
 	apiMap := make(map[string]any)
 
-	crashRateSpikeMap := make(map[string]bool)
+	crashRateSpikeMap := make(map[string]any)
 	crashRateSpikeMap["email"] = pref.CrashRateSpikeEmail
 
-	anrRateSpikeMap := make(map[string]bool)
+	anrRateSpikeMap := make(map[string]any)
 	anrRateSpikeMap["email"] = pref.AnrRateSpikeEmail
 
-	launchTimeSpikeMap := make(map[string]bool)
+	launchTimeSpikeMap := make(map[string]any)
 	launchTimeSpikeMap["email"] = pref.LaunchTimeSpikeEmail
 
+	newIssueMap := make(map[string]any)
+	newIssueMap["email"] = pref.NewIssueEmail
+
+	regressionMap := make(map[string]any)
+	regressionMap["email"] = pref.RegressionEmail
+
+	alertRuleMap := make(map[string]any)
+	alertRuleMap["email"] = pref.AlertRuleEmail
+
+	spanRegressionMap := make(map[string]any)
+	spanRegressionMap["email"] = pref.SpanRegressionEmail
+
+	if pref.Channels != nil {
+		crashRateSpikeMap["channels"] = channelIds(pref.Channels[AlertTypeCrashRateSpike])
+		anrRateSpikeMap["channels"] = channelIds(pref.Channels[AlertTypeAnrRateSpike])
+		launchTimeSpikeMap["channels"] = channelIds(pref.Channels[AlertTypeLaunchTimeSpike])
+		newIssueMap["channels"] = channelIds(pref.Channels[AlertTypeNewIssue])
+		regressionMap["channels"] = channelIds(pref.Channels[AlertTypeRegression])
+		alertRuleMap["channels"] = channelIds(pref.Channels[AlertTypeAlertRule])
+		spanRegressionMap["channels"] = channelIds(pref.Channels[AlertTypeSpanRegression])
+	}
+
 	apiMap["crash_rate_spike"] = crashRateSpikeMap
 	apiMap["anr_rate_spike"] = anrRateSpikeMap
 	apiMap["launch_time_spike"] = launchTimeSpikeMap
+	apiMap["new_issue"] = newIssueMap
+	apiMap["regression"] = regressionMap
+	apiMap["alert_rule"] = alertRuleMap
+	apiMap["span_regression"] = spanRegressionMap
 	apiMap["created_at"] = pref.CreatedAt.Format(chrono.ISOFormatJS)
 	apiMap["updated_at"] = pref.UpdatedAt.Format(chrono.ISOFormatJS)
 	return json.Marshal(apiMap)
 }
 
+// channelIds returns the channel ids, or an
+// empty list if there are none.
+func channelIds(ids []uuid.UUID) []uuid.UUID {
+	if ids == nil {
+		return []uuid.UUID{}
+	}
+
+	return ids
+}
+
 func newAlertPref(appId uuid.UUID, userId uuid.UUID) *AlertPref {
 	return &AlertPref{
 		AppId:                appId,
@@ -63,6 +169,10 @@ func newAlertPref(appId uuid.UUID, userId uuid.UUID) *AlertPref {
 		CrashRateSpikeEmail:  true,
 		AnrRateSpikeEmail:    true,
 		LaunchTimeSpikeEmail: true,
+		NewIssueEmail:        true,
+		RegressionEmail:      true,
+		AlertRuleEmail:       true,
+		SpanRegressionEmail:  true,
 		CreatedAt:            time.Now(),
 		UpdatedAt:            time.Now(),
 	}
@@ -73,8 +183,13 @@ func (pref *AlertPref) update() error {
 		Set("crash_rate_spike_email", pref.CrashRateSpikeEmail).
 		Set("anr_rate_spike_email", pref.AnrRateSpikeEmail).
 		Set("launch_time_spike_email", pref.LaunchTimeSpikeEmail).
+		Set("new_issue_email", pref.NewIssueEmail).
+		Set("regression_email", pref.RegressionEmail).
+		Set("alert_rule_email", pref.AlertRuleEmail).
+		Set("span_regression_email", pref.SpanRegressionEmail).
 		Set("updated_at", pref.UpdatedAt).
-		Where("app_id = ?", pref.AppId)
+		Where("app_id = ?", pref.AppId).
+		Where("user_id = ?", pref.UserId)
 	defer stmt.Close()
 
 	_, err := server.Server.PgPool.Exec(context.Background(), stmt.String(), stmt.Args()...)
@@ -96,6 +211,10 @@ func getAlertPref(appId uuid.UUID, userId uuid.UUID) (*AlertPref, error) {
 		Select("crash_rate_spike_email").
 		Select("anr_rate_spike_email").
 		Select("launch_time_spike_email").
+		Select("new_issue_email").
+		Select("regression_email").
+		Select("alert_rule_email").
+		Select("span_regression_email").
 		Select("created_at").
 		Select("updated_at").
 		From("public.alert_prefs").
@@ -103,7 +222,7 @@ func getAlertPref(appId uuid.UUID, userId uuid.UUID) (*AlertPref, error) {
 		Where("user_id = ?", userId)
 	defer stmt.Close()
 
-	err := server.Server.PgPool.QueryRow(context.Background(), stmt.String(), appId, userId).Scan(&pref.AppId, &pref.UserId, &pref.CrashRateSpikeEmail, &pref.AnrRateSpikeEmail, &pref.LaunchTimeSpikeEmail, &pref.CreatedAt, &pref.UpdatedAt)
+	err := server.Server.PgPool.QueryRow(context.Background(), stmt.String(), appId, userId).Scan(&pref.AppId, &pref.UserId, &pref.CrashRateSpikeEmail, &pref.AnrRateSpikeEmail, &pref.LaunchTimeSpikeEmail, &pref.NewIssueEmail, &pref.RegressionEmail, &pref.AlertRuleEmail, &pref.SpanRegressionEmail, &pref.CreatedAt, &pref.UpdatedAt)
 
 	// If there is no record for given appId and userId combo, we create one
 	if err != nil && err == pgx.ErrNoRows {
@@ -115,6 +234,10 @@ func getAlertPref(appId uuid.UUID, userId uuid.UUID) (*AlertPref, error) {
 			Set("crash_rate_spike_email", pref.CrashRateSpikeEmail).
 			Set("anr_rate_spike_email", pref.AnrRateSpikeEmail).
 			Set("launch_time_spike_email", pref.LaunchTimeSpikeEmail).
+			Set("new_issue_email", pref.NewIssueEmail).
+			Set("regression_email", pref.RegressionEmail).
+			Set("alert_rule_email", pref.AlertRuleEmail).
+			Set("span_regression_email", pref.SpanRegressionEmail).
 			Set("created_at", pref.CreatedAt).
 			Set("updated_at", pref.UpdatedAt)
 		defer stmt.Close()
@@ -137,3 +260,121 @@ func getAlertPref(appId uuid.UUID, userId uuid.UUID) (*AlertPref, error) {
 func (pref *AlertPref) String() string {
 	return fmt.Sprintf("AlertPref - appId: %s, userId: %s, crash_rate_spike_email: %v, anr_rate_spike_email: %v, launch_time_spike_email: %v, created_at: %v, updated_at: %v ", pref.AppId, pref.UserId, pref.CrashRateSpikeEmail, pref.AnrRateSpikeEmail, pref.LaunchTimeSpikeEmail, pref.CreatedAt, pref.UpdatedAt)
 }
+
+// unsubscribe turns off emails of an alert type.
+func (pref *AlertPref) unsubscribe(alertType string) error {
+	switch alertType {
+	case AlertTypeCrashRateSpike:
+		pref.CrashRateSpikeEmail = false
+	case AlertTypeAnrRateSpike:
+		pref.AnrRateSpikeEmail = false
+	case AlertTypeLaunchTimeSpike:
+		pref.LaunchTimeSpikeEmail = false
+	case AlertTypeNewIssue:
+		pref.NewIssueEmail = false
+	case AlertTypeRegression:
+		pref.RegressionEmail = false
+	case AlertTypeAlertRule:
+		pref.AlertRuleEmail = false
+	case AlertTypeSpanRegression:
+		pref.SpanRegressionEmail = false
+	default:
+		return fmt.Errorf("unknown alert type %q", alertType)
+	}
+
+	pref.UpdatedAt = time.Now()
+
+	return nil
+}
+
+// unsubscribeToken reads the unsubscribe token from the
+// query string, where links & one-click unsubscribe
+// requests carry it, or from the confirmation form.
+func unsubscribeToken(c *gin.Context) string {
+	if token := c.Query("token"); token != "" {
+		return token
+	}
+
+	return c.PostForm("token")
+}
+
+// ConfirmUnsubscribeEmails renders a page asking the user
+// to confirm unsubscribing. Following the link doesn't
+// unsubscribe, as link scanners of mail providers fetch
+// links in emails.
+func ConfirmUnsubscribeEmails(c *gin.Context) {
+	token := unsubscribeToken(c)
+	unsubscribe, err := email.ParseUnsubscribeToken(server.Server.Config.AccessTokenSecret, token)
+	if err != nil {
+		msg := `unsubscribe link is invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := email.RenderUnsubscribePage(c.Writer, token, unsubscribe, false); err != nil {
+		fmt.Println(`failed to render unsubscribe page`, err)
+	}
+}
+
+// UnsubscribeEmails unsubscribes the user from emails
+// of the token's topic. It serves both the confirmation
+// page's form & one-click unsubscribe requests of mail
+// clients as per RFC 8058.
+func UnsubscribeEmails(c *gin.Context) {
+	token := unsubscribeToken(c)
+	unsubscribe, err := email.ParseUnsubscribeToken(server.Server.Config.AccessTokenSecret, token)
+	if err != nil {
+		msg := `unsubscribe link is invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if unsubscribe.Topic == email.KindDigest {
+		pref, err := getDigestPref(c.Request.Context(), unsubscribe.AppID, unsubscribe.UserID)
+		if err != nil {
+			msg := `unable to fetch digest prefs`
+			fmt.Println(msg, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		if err := pref.unsubscribe(c.Request.Context()); err != nil {
+			msg := `failed to unsubscribe`
+			fmt.Println(msg, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+	} else {
+		pref, err := getAlertPref(unsubscribe.AppID, unsubscribe.UserID)
+		if err != nil {
+			msg := `unable to fetch alert prefs`
+			fmt.Println(msg, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		if err := pref.unsubscribe(unsubscribe.Topic); err != nil {
+			msg := `unsubscribe link is invalid`
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		if err := pref.update(); err != nil {
+			msg := `failed to unsubscribe`
+			fmt.Println(msg, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	if err := email.RenderUnsubscribePage(c.Writer, token, unsubscribe, true); err != nil {
+		fmt.Println(`failed to render unsubscribe page`, err)
+	}
+}
@@ -36,6 +36,18 @@ func TestNewAlertPref(t *testing.T) {
 	if !pref.LaunchTimeSpikeEmail {
 		t.Errorf("launchTimeSpikeEmail should be true")
 	}
+	if !pref.NewIssueEmail {
+		t.Errorf("newIssueEmail should be true")
+	}
+	if !pref.RegressionEmail {
+		t.Errorf("regressionEmail should be true")
+	}
+	if !pref.AlertRuleEmail {
+		t.Errorf("alertRuleEmail should be true")
+	}
+	if !pref.SpanRegressionEmail {
+		t.Errorf("spanRegressionEmail should be true")
+	}
 	if pref.CreatedAt.Sub(now) > time.Second {
 		t.Errorf("createdAt should be around current time")
 	}
@@ -57,6 +69,10 @@ func TestAlertPrefMarshalJSON(t *testing.T) {
 		CrashRateSpikeEmail:  true,
 		AnrRateSpikeEmail:    false,
 		LaunchTimeSpikeEmail: false,
+		NewIssueEmail:        true,
+		RegressionEmail:      false,
+		AlertRuleEmail:       true,
+		SpanRegressionEmail:  false,
 		CreatedAt:            createdAt,
 		UpdatedAt:            updatedAt,
 	}
@@ -71,6 +87,18 @@ func TestAlertPrefMarshalJSON(t *testing.T) {
         "launch_time_spike": {
             "email": false
         },
+        "new_issue": {
+            "email": true
+        },
+        "regression": {
+            "email": false
+        },
+        "alert_rule": {
+            "email": true
+        },
+        "span_regression": {
+            "email": false
+        },
         "created_at": "2023-04-04T12:00:00Z",
         "updated_at": "2023-04-05T12:00:00Z"
     }`
@@ -99,6 +127,40 @@ func TestAlertPrefMarshalJSON(t *testing.T) {
 	}
 }
 
+func TestAlertPrefMarshalJSONChannels(t *testing.T) {
+	channelId := uuid.New()
+
+	pref := newAlertPref(uuid.New(), uuid.New())
+	pref.Channels = map[string][]uuid.UUID{
+		AlertTypeCrashRateSpike: {channelId},
+	}
+
+	jsonBytes, err := pref.MarshalJSON()
+	if err != nil {
+		t.Fatalf("MarshalJSON failed: %v", err)
+	}
+
+	type setting struct {
+		Email    bool        `json:"email"`
+		Channels []uuid.UUID `json:"channels"`
+	}
+	var actual struct {
+		CrashRateSpike setting `json:"crash_rate_spike"`
+		AnrRateSpike   setting `json:"anr_rate_spike"`
+	}
+	if err := json.Unmarshal(jsonBytes, &actual); err != nil {
+		t.Fatalf("Failed to unmarshal actual JSON: %v", err)
+	}
+
+	if channels := actual.CrashRateSpike.Channels; len(channels) != 1 || channels[0] != channelId {
+		t.Errorf("Expected crash rate spike channels [%v], but got %v", channelId, channels)
+	}
+
+	if channels := actual.AnrRateSpike.Channels; channels == nil || len(channels) != 0 {
+		t.Errorf("Expected empty anr rate spike channels, but got %v", channels)
+	}
+}
+
 func TestAlertPrefString(t *testing.T) {
 	// Setup
 	appId := uuid.New()
@@ -129,3 +191,31 @@ func TestAlertPrefString(t *testing.T) {
 		t.Errorf("String() output mismatch:\nExpected: %s\nActual: %s", expectedString, actualString)
 	}
 }
+
+func TestAlertPrefUnsubscribe(t *testing.T) {
+	pref := newAlertPref(uuid.New(), uuid.New())
+
+	if err := pref.unsubscribe(AlertTypeAnrRateSpike); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if pref.AnrRateSpikeEmail {
+		t.Errorf("Expected ANR rate spike emails to be turned off")
+	}
+
+	if !pref.CrashRateSpikeEmail || !pref.LaunchTimeSpikeEmail || !pref.NewIssueEmail || !pref.RegressionEmail || !pref.AlertRuleEmail || !pref.SpanRegressionEmail {
+		t.Errorf("Expected other alert emails to stay on")
+	}
+
+	if err := pref.unsubscribe(AlertTypeRegression); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if pref.RegressionEmail {
+		t.Errorf("Expected regression emails to be turned off")
+	}
+
+	if err := pref.unsubscribe("unknown"); err == nil {
+		t.Errorf("Expected error for unknown alert type")
+	}
+}
@@ -0,0 +1,1636 @@
+package measure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"backend/api/chrono"
+	"backend/api/email"
+	"backend/api/event"
+	"backend/api/filter"
+	"backend/api/metrics"
+	"backend/api/notify"
+	"backend/api/server"
+	"backend/api/span"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+const (
+	RuleConditionAbove   = "above"
+	RuleConditionBelow   = "below"
+	RuleConditionAnomaly = "anomaly"
+	RuleConditionOutlier = "outlier"
+)
+
+const (
+	ruleSourceEvents = "events"
+	ruleSourceSpans  = "spans"
+)
+
+// maxAlertRulesPerApp is the maximum count
+// of alert rules of an app.
+const maxAlertRulesPerApp = 50
+
+// maxAlertRuleNameLen is the maximum length
+// of an alert rule's name.
+const maxAlertRuleNameLen = 256
+
+// maxSpanNameLen is the maximum length
+// of a span's name.
+const maxSpanNameLen = 64
+
+// minRuleWindow & maxRuleWindow bound the length
+// of an alert rule's evaluation window in minutes.
+const (
+	minRuleWindow = 5
+	maxRuleWindow = 24 * 60
+)
+
+// ruleBaselineWindow is the window of activity right
+// before the evaluation window that anomaly conditions
+// compare against.
+const ruleBaselineWindow = 7 * 24 * time.Hour
+
+// ruleOutlierDays is the count of days before the
+// evaluation window outlier conditions compare the
+// same window of time against.
+const ruleOutlierDays = 14
+
+// minRuleOutlierHistory is the minimum count of days
+// having enough samples for outlier conditions to be
+// evaluated.
+const minRuleOutlierHistory = 7
+
+// ruleCooldown is the duration after a rule fires
+// within which the rule does not fire again.
+const ruleCooldown = 24 * time.Hour
+
+// ruleEvaluationInterval is the minimum duration
+// between evaluations of the same rule.
+const ruleEvaluationInterval = 5 * time.Minute
+
+// ruleBatchSize is the maximum count of alert
+// rules evaluated at once.
+const ruleBatchSize = 50
+
+// ruleMetric describes how a metric alert
+// rules evaluate is computed.
+type ruleMetric struct {
+	source string
+	// value is the expression computing
+	// the metric.
+	value string
+	// samples is the expression counting the
+	// samples the metric is computed from.
+	samples string
+	// where narrows down the events the
+	// metric is computed from.
+	where string
+	// sessions is true if user defined attribute
+	// expressions select whole sessions instead
+	// of individual events.
+	sessions bool
+	// healthy is true if the metric is a percentage
+	// of healthy sessions or users, where lower
+	// values are worse.
+	healthy bool
+	// additive is true if the metric grows with
+	// the length of the window.
+	additive bool
+}
+
+// ruleMetrics lists the metrics alert rules
+// can evaluate.
+var ruleMetrics = map[string]ruleMetric{
+	"crash_free_sessions": {
+		source:   ruleSourceEvents,
+		value:    "100 * (1 - uniqIf(session_id, type = 'exception' and exception.handled = false) / uniq(session_id))",
+		samples:  "uniq(session_id)",
+		sessions: true,
+		healthy:  true,
+	},
+	"anr_free_sessions": {
+		source:   ruleSourceEvents,
+		value:    "100 * (1 - uniqIf(session_id, type = 'anr') / uniq(session_id))",
+		samples:  "uniq(session_id)",
+		sessions: true,
+		healthy:  true,
+	},
+	"crash_free_users": {
+		source:   ruleSourceEvents,
+		value:    fmt.Sprintf("100 * (1 - uniqIf(%s, type = 'exception' and exception.handled = false) / uniq(%s))", userIdentity, userIdentity),
+		samples:  fmt.Sprintf("uniq(%s)", userIdentity),
+		sessions: true,
+		healthy:  true,
+	},
+	"anr_free_users": {
+		source:   ruleSourceEvents,
+		value:    fmt.Sprintf("100 * (1 - uniqIf(%s, type = 'anr') / uniq(%s))", userIdentity, userIdentity),
+		samples:  fmt.Sprintf("uniq(%s)", userIdentity),
+		sessions: true,
+		healthy:  true,
+	},
+	"cold_launch_p95": {
+		source:  ruleSourceEvents,
+		value:   "quantile(0.95)(cold_launch.duration)",
+		samples: "count()",
+		where:   "type = 'cold_launch'",
+	},
+	"warm_launch_p95": {
+		source:  ruleSourceEvents,
+		value:   "quantile(0.95)(warm_launch.duration)",
+		samples: "count()",
+		where:   "type = 'warm_launch'",
+	},
+	"hot_launch_p95": {
+		source:  ruleSourceEvents,
+		value:   "quantile(0.95)(hot_launch.duration)",
+		samples: "count()",
+		where:   "type = 'hot_launch'",
+	},
+	"event_count": {
+		source:   ruleSourceEvents,
+		value:    "toFloat64(count())",
+		samples:  "count()",
+		additive: true,
+	},
+	"http_error_rate": {
+		source:  ruleSourceEvents,
+		value:   "100 * countIf(http.status_code >= 400 or http.failure_reason != '') / count()",
+		samples: "count()",
+		where:   "type = 'http'",
+	},
+	"span_p50": {
+		source:  ruleSourceSpans,
+		value:   "quantile(0.50)(dateDiff('millisecond', start_time, end_time))",
+		samples: "count()",
+	},
+	"span_p90": {
+		source:  ruleSourceSpans,
+		value:   "quantile(0.90)(dateDiff('millisecond', start_time, end_time))",
+		samples: "count()",
+	},
+	"span_p95": {
+		source:  ruleSourceSpans,
+		value:   "quantile(0.95)(dateDiff('millisecond', start_time, end_time))",
+		samples: "count()",
+	},
+	"span_p99": {
+		source:  ruleSourceSpans,
+		value:   "quantile(0.99)(dateDiff('millisecond', start_time, end_time))",
+		samples: "count()",
+	},
+	"span_error_rate": {
+		source:  ruleSourceSpans,
+		value:   "100 * countIf(status = 2) / count()",
+		samples: "count()",
+	},
+}
+
+// AlertRule represents a user defined alert rule
+// evaluating a metric of an app over a window of
+// time.
+type AlertRule struct {
+	ID     uuid.UUID `json:"id" db:"id"`
+	AppID  uuid.UUID `json:"app_id" db:"app_id"`
+	Name   string    `json:"name" db:"name"`
+	Metric string    `json:"metric" db:"metric"`
+	// SpanName is the name of the span
+	// span metrics are computed for.
+	SpanName string `json:"span_name" db:"span_name"`
+	// EventType is the type of events
+	// event counts are computed for.
+	EventType string `json:"event_type" db:"event_type"`
+	// HTTPURL narrows down http metrics to
+	// urls containing it.
+	HTTPURL   string            `json:"http_url" db:"http_url"`
+	Filters   filter.FilterList `json:"filters" db:"filters"`
+	Condition string            `json:"condition" db:"condition"`
+	// Threshold is the value of the metric for above
+	// & below conditions, the minimum percentage of
+	// deviation from the baseline for anomaly conditions,
+	// or the minimum robust z-score for outlier conditions.
+	Threshold float64 `json:"threshold" db:"threshold"`
+	// Window is the length of the evaluation
+	// window in minutes.
+	Window          int             `json:"window" db:"window_minutes"`
+	MinSamples      int             `json:"min_samples" db:"min_samples"`
+	Enabled         bool            `json:"enabled" db:"enabled"`
+	CreatedBy       *uuid.UUID      `json:"created_by" db:"created_by"`
+	LastEvaluatedAt *chrono.ISOTime `json:"last_evaluated_at" db:"last_evaluated_at"`
+	LastFiredAt     *chrono.ISOTime `json:"last_fired_at" db:"last_fired_at"`
+	CreatedAt       chrono.ISOTime  `json:"created_at" db:"created_at"`
+	UpdatedAt       chrono.ISOTime  `json:"updated_at" db:"updated_at"`
+}
+
+// AlertRulePayload represents the request body
+// to create or update an alert rule.
+type AlertRulePayload struct {
+	Name      *string            `json:"name"`
+	Metric    *string            `json:"metric"`
+	SpanName  *string            `json:"span_name"`
+	EventType *string            `json:"event_type"`
+	HTTPURL   *string            `json:"http_url"`
+	Filters   *filter.FilterList `json:"filters"`
+	// FilterShortCode copies the filters
+	// stored under the short code.
+	FilterShortCode *string  `json:"filter_short_code"`
+	Condition       *string  `json:"condition"`
+	Threshold       *float64 `json:"threshold"`
+	Window          *int     `json:"window"`
+	MinSamples      *int     `json:"min_samples"`
+	Enabled         *bool    `json:"enabled"`
+}
+
+// RuleEvidence represents the computed
+// values that fired an alert rule.
+type RuleEvidence struct {
+	RuleID    uuid.UUID `json:"rule_id"`
+	Metric    string    `json:"metric"`
+	SpanName  string    `json:"span_name,omitempty"`
+	EventType string    `json:"event_type,omitempty"`
+	HTTPURL   string    `json:"http_url,omitempty"`
+	Condition string    `json:"condition"`
+	Threshold float64   `json:"threshold"`
+	Value     float64   `json:"value"`
+	Samples   uint64    `json:"samples"`
+	// Baseline is only set for anomaly &
+	// outlier conditions, Deviation for
+	// anomaly & Score for outlier conditions.
+	Baseline      *float64   `json:"baseline,omitempty"`
+	Deviation     *float64   `json:"deviation,omitempty"`
+	Score         *float64   `json:"score,omitempty"`
+	BaselineStart *time.Time `json:"baseline_start,omitempty"`
+	BaselineEnd   *time.Time `json:"baseline_end,omitempty"`
+}
+
+// ruleOutcome is the outcome of
+// evaluating an alert rule.
+type ruleOutcome int
+
+const (
+	// ruleUnknown means there was too little
+	// data to evaluate the rule's condition.
+	ruleUnknown ruleOutcome = iota
+	ruleHealthy
+	ruleBreached
+)
+
+// ruleValue is a metric value computed
+// over a window of time.
+type ruleValue struct {
+	value   float64
+	samples uint64
+	// spread is the robust spread of a
+	// seasonal baseline's values.
+	spread float64
+}
+
+// apply applies the fields present in the
+// payload to the alert rule.
+func (p AlertRulePayload) apply(rule *AlertRule) {
+	if p.Name != nil {
+		rule.Name = strings.TrimSpace(*p.Name)
+	}
+	if p.Metric != nil {
+		rule.Metric = *p.Metric
+	}
+	if p.SpanName != nil {
+		rule.SpanName = strings.TrimSpace(*p.SpanName)
+	}
+	if p.EventType != nil {
+		rule.EventType = *p.EventType
+	}
+	if p.HTTPURL != nil {
+		rule.HTTPURL = strings.TrimSpace(*p.HTTPURL)
+	}
+	if p.Filters != nil {
+		rule.Filters = *p.Filters
+	}
+	if p.Condition != nil {
+		rule.Condition = *p.Condition
+	}
+	if p.Threshold != nil {
+		rule.Threshold = *p.Threshold
+	}
+	if p.Window != nil {
+		rule.Window = *p.Window
+	}
+	if p.MinSamples != nil {
+		rule.MinSamples = *p.MinSamples
+	}
+	if p.Enabled != nil {
+		rule.Enabled = *p.Enabled
+	}
+}
+
+// Validate validates the alert rule.
+func (r AlertRule) Validate() error {
+	if r.Name == "" {
+		return errors.New("name must not be empty")
+	}
+
+	if len(r.Name) > maxAlertRuleNameLen {
+		return fmt.Errorf("name must not be longer than %d characters", maxAlertRuleNameLen)
+	}
+
+	metric, ok := ruleMetrics[r.Metric]
+	if !ok {
+		return fmt.Errorf("unknown metric %q", r.Metric)
+	}
+
+	if metric.source == ruleSourceSpans {
+		if r.SpanName == "" {
+			return fmt.Errorf("span_name is required for %q metric", r.Metric)
+		}
+
+		if len(r.SpanName) > maxSpanNameLen {
+			return fmt.Errorf("span_name must not be longer than %d characters", maxSpanNameLen)
+		}
+	}
+
+	if r.Metric == "event_count" && !event.IsValidType(r.EventType) {
+		return fmt.Errorf("event_type must be a valid event type for %q metric", r.Metric)
+	}
+
+	switch r.Condition {
+	case RuleConditionAbove, RuleConditionBelow:
+	case RuleConditionAnomaly, RuleConditionOutlier:
+		if r.Threshold <= 0 {
+			return fmt.Errorf("threshold must be greater than 0 for %s condition", r.Condition)
+		}
+	default:
+		return fmt.Errorf("condition must be one of %q, %q, %q or %q", RuleConditionAbove, RuleConditionBelow, RuleConditionAnomaly, RuleConditionOutlier)
+	}
+
+	if math.IsNaN(r.Threshold) || math.IsInf(r.Threshold, 0) {
+		return errors.New("threshold must be a finite number")
+	}
+
+	if r.Window < minRuleWindow || r.Window > maxRuleWindow {
+		return fmt.Errorf("window must be between %d and %d minutes", minRuleWindow, maxRuleWindow)
+	}
+
+	if r.MinSamples < 0 {
+		return errors.New("min_samples must not be negative")
+	}
+
+	now := time.Now()
+	if _, err := r.appFilter(now.Add(-time.Duration(r.Window)*time.Minute), now); err != nil {
+		return fmt.Errorf("filters are invalid. %s", err.Error())
+	}
+
+	return nil
+}
+
+// appFilter creates the app filter the rule's
+// metric is computed with over a window of time.
+func (r AlertRule) appFilter(from, to time.Time) (af *filter.AppFilter, err error) {
+	af = &filter.AppFilter{
+		AppID:               r.AppID,
+		From:                from,
+		To:                  to,
+		Limit:               filter.DefaultPaginationLimit,
+		Versions:            r.Filters.Versions,
+		VersionCodes:        r.Filters.VersionCodes,
+		OsNames:             r.Filters.OsNames,
+		OsVersions:          r.Filters.OsVersions,
+		Countries:           r.Filters.Countries,
+		DeviceNames:         r.Filters.DeviceNames,
+		DeviceManufacturers: r.Filters.DeviceManufacturers,
+		Locales:             r.Filters.DeviceLocales,
+		NetworkProviders:    r.Filters.NetworkProviders,
+		NetworkTypes:        r.Filters.NetworkTypes,
+		NetworkGenerations:  r.Filters.NetworkGenerations,
+		UDExpressionRaw:     strings.TrimSpace(r.Filters.UDExpressionRaw),
+	}
+
+	if err = af.Validate(); err != nil {
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err = af.ValidateVersions(); err != nil {
+			return
+		}
+	}
+
+	if len(af.OsNames) > 0 || len(af.OsVersions) > 0 {
+		if _, err = af.OSVersionPairs(); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// evaluable checks if the metric's values suffice
+// to evaluate the rule's condition.
+func (r AlertRule) evaluable(current, baseline ruleValue) bool {
+	if math.IsNaN(current.value) || current.samples < uint64(r.MinSamples) {
+		return false
+	}
+
+	// no history to compare against
+	if (r.Condition == RuleConditionAnomaly || r.Condition == RuleConditionOutlier) && (baseline.samples == 0 || math.IsNaN(baseline.value)) {
+		return false
+	}
+
+	return true
+}
+
+// breached checks if the metric's value over the
+// evaluation window meets the rule's condition.
+// Anomaly conditions compare against the baseline
+// & return the percentage of deviation, outlier
+// conditions return the robust z-score.
+func (r AlertRule) breached(current, baseline ruleValue) (ok bool, deviation float64) {
+	if !r.evaluable(current, baseline) {
+		return
+	}
+
+	switch r.Condition {
+	case RuleConditionAbove:
+		ok = current.value > r.Threshold
+	case RuleConditionBelow:
+		ok = current.value < r.Threshold
+	case RuleConditionAnomaly:
+		metric := ruleMetrics[r.Metric]
+
+		value, base := current.value, baseline.value
+
+		// for percentages of healthy sessions or
+		// users, compare the unhealthy percentages
+		// so that drops show up as deviations
+		if metric.healthy {
+			value, base = 100-value, 100-base
+		}
+
+		if base == 0 {
+			if value > 0 {
+				return true, math.Inf(1)
+			}
+			return
+		}
+
+		deviation = (value - base) / base * 100
+
+		// counts of events are anomalous in
+		// either direction, everything else
+		// only when it gets worse
+		if metric.additive {
+			ok = math.Abs(deviation) >= r.Threshold
+		} else {
+			ok = deviation >= r.Threshold
+		}
+	case RuleConditionOutlier:
+		metric := ruleMetrics[r.Metric]
+
+		deviation = metrics.Score(current.value, baseline.value, baseline.spread)
+
+		switch {
+		case metric.additive:
+			ok = math.Abs(deviation) >= r.Threshold
+		case metric.healthy:
+			ok = -deviation >= r.Threshold
+		default:
+			ok = deviation >= r.Threshold
+		}
+	}
+
+	return
+}
+
+// message describes the fired alert rule.
+func (r AlertRule) message(appName string, evidence RuleEvidence) string {
+	subject := r.Metric
+	switch {
+	case r.SpanName != "":
+		subject = fmt.Sprintf("%s of %s", r.Metric, r.SpanName)
+	case r.EventType != "":
+		subject = fmt.Sprintf("%s of %s", r.Metric, r.EventType)
+	}
+
+	if r.Condition == RuleConditionAnomaly && evidence.Baseline != nil {
+		return fmt.Sprintf("%s for %s: %s is %.2f, deviating from a baseline of %.2f", r.Name, appName, subject, evidence.Value, *evidence.Baseline)
+	}
+
+	if r.Condition == RuleConditionOutlier && evidence.Baseline != nil && evidence.Score != nil {
+		return fmt.Sprintf("%s for %s: %s is %.2f, %.1f deviations from a baseline of %.2f", r.Name, appName, subject, evidence.Value, math.Abs(*evidence.Score), *evidence.Baseline)
+	}
+
+	return fmt.Sprintf("%s for %s: %s is %.2f, %s the threshold of %.2f", r.Name, appName, subject, evidence.Value, r.Condition, r.Threshold)
+}
+
+// metricStmt creates the statement computing the rule's
+// metric & its samples with the app filter.
+func (r AlertRule) metricStmt(af *filter.AppFilter) (stmt *sqlf.Stmt, err error) {
+	metric := ruleMetrics[r.Metric]
+
+	if metric.source == ruleSourceSpans {
+		stmt = sqlf.From("default.spans").
+			Select(fmt.Sprintf("toFloat64(%s) as value", metric.value)).
+			Select(fmt.Sprintf("%s as samples", metric.samples)).
+			Clause("prewhere app_id = toUUID(?) and span_name = ? and start_time >= ? and start_time < ?", af.AppID, r.SpanName, af.From, af.To)
+
+		if af.HasVersions() {
+			selectedVersions, err := af.VersionPairs()
+			if err != nil {
+				return nil, err
+			}
+			stmt.Where("attribute.app_version in (?)", selectedVersions.Parameterize())
+		}
+
+		if af.HasOSVersions() {
+			selectedOSVersions, err := af.OSVersionPairs()
+			if err != nil {
+				return nil, err
+			}
+			stmt.Where("attribute.os_version in (?)", selectedOSVersions.Parameterize())
+		}
+
+		if af.HasCountries() {
+			stmt.Where("attribute.country_code in ?", af.Countries)
+		}
+	} else {
+		stmt = sqlf.From("default.events").
+			Select(fmt.Sprintf("toFloat64(%s) as value", metric.value)).
+			Select(fmt.Sprintf("%s as samples", metric.samples)).
+			Clause("prewhere app_id = toUUID(?) and timestamp >= ? and timestamp < ?", af.AppID, af.From, af.To)
+
+		if metric.where != "" {
+			stmt.Where(metric.where)
+		}
+
+		if r.Metric == "event_count" {
+			stmt.Where("type = ?", r.EventType)
+		}
+
+		if r.Metric == "http_error_rate" && r.HTTPURL != "" {
+			stmt.Where("position(http.url, ?) > 0", r.HTTPURL)
+		}
+
+		if af.HasVersions() {
+			selectedVersions, err := af.VersionPairs()
+			if err != nil {
+				return nil, err
+			}
+			stmt.Where("(attribute.app_version, attribute.app_build) in (?)", selectedVersions.Parameterize())
+		}
+
+		if af.HasOSVersions() {
+			selectedOSVersions, err := af.OSVersionPairs()
+			if err != nil {
+				return nil, err
+			}
+			stmt.Where("(attribute.os_name, attribute.os_version) in (?)", selectedOSVersions.Parameterize())
+		}
+
+		if af.HasCountries() {
+			stmt.Where("inet.country_code in ?", af.Countries)
+		}
+	}
+
+	if af.HasNetworkProviders() {
+		stmt.Where("attribute.network_provider in ?", af.NetworkProviders)
+	}
+
+	if af.HasNetworkTypes() {
+		stmt.Where("attribute.network_type in ?", af.NetworkTypes)
+	}
+
+	if af.HasNetworkGenerations() {
+		stmt.Where("attribute.network_generation in ?", af.NetworkGenerations)
+	}
+
+	if af.HasDeviceLocales() {
+		stmt.Where("attribute.device_locale in ?", af.Locales)
+	}
+
+	if af.HasDeviceManufacturers() {
+		stmt.Where("attribute.device_manufacturer in ?", af.DeviceManufacturers)
+	}
+
+	if af.HasDeviceNames() {
+		stmt.Where("attribute.device_name in ?", af.DeviceNames)
+	}
+
+	if metric.source == ruleSourceSpans {
+		span.FilterUDExpression(stmt, af)
+	}
+
+	if metric.source == ruleSourceEvents && af.HasUDExpression() && !af.UDExpression.Empty() {
+		column := "event_id"
+		if metric.sessions {
+			column = "session_id"
+		}
+
+		subQuery := sqlf.From("user_def_attrs").
+			Select(column).
+			Where("app_id = toUUID(?)", af.AppID)
+		af.UDExpression.Augment(subQuery)
+
+		defer subQuery.Close()
+
+		if metric.sessions {
+			stmt.Where(fmt.Sprintf("session_id in (%s)", subQuery.String()), subQuery.Args()...)
+		} else {
+			stmt.Where(fmt.Sprintf("id in (%s)", subQuery.String()), subQuery.Args()...)
+		}
+	}
+
+	return
+}
+
+// compute computes the rule's metric & its
+// samples over a window of time.
+func (r AlertRule) compute(ctx context.Context, from, to time.Time) (v ruleValue, err error) {
+	af, err := r.appFilter(from, to)
+	if err != nil {
+		return
+	}
+
+	stmt, err := r.metricStmt(af)
+	if err != nil {
+		return
+	}
+
+	defer stmt.Close()
+
+	err = server.Server.ChPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&v.value, &v.samples)
+
+	return
+}
+
+// seasonalBaseline computes the rule's metric over the
+// same window of time on each of the days before the
+// evaluation window ending at the given time & reduces
+// the days having enough samples to their median &
+// robust spread. Samples of the baseline are 0 when
+// too few days have enough samples.
+func (r AlertRule) seasonalBaseline(ctx context.Context, end time.Time) (v ruleValue, err error) {
+	day := 24 * time.Hour
+	window := time.Duration(r.Window) * time.Minute
+
+	af, err := r.appFilter(end.Add(-ruleOutlierDays*day-window), end.Add(-day))
+	if err != nil {
+		return
+	}
+
+	stmt, err := r.metricStmt(af)
+	if err != nil {
+		return
+	}
+
+	defer stmt.Close()
+
+	column := "timestamp"
+	if ruleMetrics[r.Metric].source == ruleSourceSpans {
+		column = "start_time"
+	}
+
+	// seconds between the end of the evaluation
+	// window & the event or span
+	offset := fmt.Sprintf("dateDiff('second', %s, ?)", column)
+
+	stmt.Select(fmt.Sprintf("intDiv(%s, 86400) as day", offset), end).
+		Where(fmt.Sprintf("%s %% 86400 < ?", offset), end, r.Window*60).
+		GroupBy("day")
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	var values []float64
+	var samples uint64
+	for rows.Next() {
+		var value float64
+		var count, ignore uint64
+		if err = rows.Scan(&value, &count, &ignore); err != nil {
+			return
+		}
+
+		if math.IsNaN(value) || count == 0 || count < uint64(r.MinSamples) {
+			continue
+		}
+
+		values = append(values, value)
+		samples += count
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	if len(values) < minRuleOutlierHistory {
+		return
+	}
+
+	v.value, v.spread = metrics.RobustBaseline(values)
+	v.samples = samples
+
+	return
+}
+
+// evaluate computes the rule's metric over the evaluation
+// window ending at the given time & checks if the rule's
+// condition is met. Evidence is only set for breached
+// rules.
+func (r AlertRule) evaluate(ctx context.Context, end time.Time) (evidence RuleEvidence, outcome ruleOutcome, err error) {
+	start := end.Add(-time.Duration(r.Window) * time.Minute)
+
+	current, err := r.compute(ctx, start, end)
+	if err != nil {
+		return
+	}
+
+	var baseline ruleValue
+	baselineStart := start.Add(-ruleBaselineWindow)
+
+	if r.Condition == RuleConditionOutlier {
+		baselineStart = start.Add(-ruleOutlierDays * 24 * time.Hour)
+		baseline, err = r.seasonalBaseline(ctx, end)
+		if err != nil {
+			return
+		}
+	}
+
+	if r.Condition == RuleConditionAnomaly {
+		baseline, err = r.compute(ctx, baselineStart, start)
+		if err != nil {
+			return
+		}
+
+		// scale counts of the baseline down
+		// to the length of the window
+		if ruleMetrics[r.Metric].additive {
+			baseline.value = baseline.value * float64(r.Window) * float64(time.Minute) / float64(ruleBaselineWindow)
+		}
+	}
+
+	if !r.evaluable(current, baseline) {
+		return
+	}
+
+	ok, deviation := r.breached(current, baseline)
+	if !ok {
+		outcome = ruleHealthy
+		return
+	}
+
+	outcome = ruleBreached
+	evidence = RuleEvidence{
+		RuleID:    r.ID,
+		Metric:    r.Metric,
+		SpanName:  r.SpanName,
+		EventType: r.EventType,
+		HTTPURL:   r.HTTPURL,
+		Condition: r.Condition,
+		Threshold: r.Threshold,
+		Value:     current.value,
+		Samples:   current.samples,
+	}
+
+	if r.Condition == RuleConditionAnomaly {
+		evidence.Baseline = &baseline.value
+		evidence.BaselineStart = &baselineStart
+		evidence.BaselineEnd = &start
+		if !math.IsInf(deviation, 0) {
+			evidence.Deviation = &deviation
+		}
+	}
+
+	if r.Condition == RuleConditionOutlier {
+		// the last day's window ends a day
+		// before the evaluation window
+		baselineEnd := end.Add(-24 * time.Hour)
+		evidence.Baseline = &baseline.value
+		evidence.BaselineStart = &baselineStart
+		evidence.BaselineEnd = &baselineEnd
+		evidence.Score = &deviation
+	}
+
+	return
+}
+
+// fire records an alert of the rule along with the
+// incident it opens & queues its emails & notifications
+// unless the rule is muted. Nothing is recorded if an
+// incident of the rule is not resolved yet.
+func (r AlertRule) fire(ctx context.Context, app *App, evidence RuleEvidence, windowStart, windowEnd time.Time) (err error) {
+	message := r.message(app.AppName, evidence)
+	now := time.Now()
+
+	muted, err := isAlertMuted(ctx, r.AppID, AlertTypeAlertRule, &r.ID, now)
+	if err != nil {
+		return
+	}
+
+	tx, err := server.Server.PgPool.Begin(ctx)
+	if err != nil {
+		return
+	}
+
+	defer tx.Rollback(ctx)
+
+	// skip rules fired by other evaluations
+	// while this one was computing
+	fired := sqlf.PostgreSQL.Update("public.alert_rules").
+		Set("last_fired_at", now).
+		Where("id = ?", r.ID).
+		Where("(last_fired_at is null or last_fired_at < ?)", now.Add(-ruleCooldown))
+
+	defer fired.Close()
+
+	result, err := tx.Exec(ctx, fired.String(), fired.Args()...)
+	if err != nil || result.RowsAffected() == 0 {
+		return
+	}
+
+	incident := AlertIncident{
+		ID:       uuid.New(),
+		AppID:    r.AppID,
+		RuleID:   &r.ID,
+		Type:     AlertTypeAlertRule,
+		Status:   IncidentStatusOpen,
+		Message:  message,
+		Filters:  &r.Filters,
+		Muted:    muted,
+		OpenedAt: chrono.ISOTime(now),
+	}
+
+	opened, err := incident.insert(ctx, &tx, evidence)
+	if err != nil || !opened {
+		return
+	}
+
+	alert := sqlf.PostgreSQL.InsertInto("public.alerts").
+		Set("id", uuid.New()).
+		Set("app_id", r.AppID).
+		Set("incident_id", incident.ID).
+		Set("type", AlertTypeAlertRule).
+		Set("rule_id", r.ID).
+		Set("message", message).
+		Set("evidence", evidence).
+		Set("window_start", windowStart).
+		Set("window_end", windowEnd).
+		Set("created_at", now)
+
+	defer alert.Close()
+
+	if _, err = tx.Exec(ctx, alert.String(), alert.Args()...); err != nil {
+		return
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return
+	}
+
+	if muted {
+		return
+	}
+
+	payload := email.AlertPayload{
+		TeamID:      app.TeamId,
+		AppID:       r.AppID,
+		AppName:     app.AppName,
+		Type:        AlertTypeAlertRule,
+		Message:     message,
+		WindowStart: windowStart,
+		WindowEnd:   windowEnd,
+	}
+
+	recipients, err := getAlertRecipients(ctx, r.AppID, "alert_rule_email")
+	if err != nil {
+		return
+	}
+
+	var emails []email.Email
+	for _, recipient := range recipients {
+		emails = append(emails, email.Email{
+			Kind:      email.KindAlert,
+			Recipient: recipient.email,
+			UserID:    &recipient.userId,
+			AppID:     &r.AppID,
+			Payload:   payload,
+		})
+	}
+
+	if len(emails) > 0 {
+		if err = email.Enqueue(ctx, emails...); err != nil {
+			return
+		}
+	}
+
+	return notify.Enqueue(ctx, r.AppID, AlertTypeAlertRule, payload)
+}
+
+// RunAlertRules evaluates due alert rules at
+// every interval until the context is done.
+func RunAlertRules(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := evaluateAlertRules(ctx); err != nil {
+				fmt.Println("failed to evaluate alert rules", err)
+			}
+		}
+	}
+}
+
+// evaluateAlertRules claims alert rules due for
+// evaluation, fires the ones whose condition is
+// met & resolves incidents of the ones that
+// recovered. Failing rules don't stop evaluation
+// of the rest.
+func evaluateAlertRules(ctx context.Context) (err error) {
+	rules, err := claimAlertRules(ctx)
+	if err != nil {
+		return
+	}
+
+	end := time.Now().UTC().Truncate(time.Minute)
+	apps := make(map[uuid.UUID]*App)
+
+	for _, rule := range rules {
+		evidence, outcome, err := rule.evaluate(ctx, end)
+		if err != nil {
+			fmt.Printf("failed to evaluate alert rule %s: %v\n", rule.ID, err)
+			continue
+		}
+
+		if outcome == ruleHealthy {
+			if err := resolveRuleIncidents(ctx, rule.ID, end); err != nil {
+				fmt.Printf("failed to resolve incidents of alert rule %s: %v\n", rule.ID, err)
+			}
+			continue
+		}
+
+		if outcome != ruleBreached {
+			continue
+		}
+
+		// still cooling down
+		if rule.LastFiredAt != nil && end.Sub(time.Time(*rule.LastFiredAt)) < ruleCooldown {
+			continue
+		}
+
+		app, found := apps[rule.AppID]
+		if !found {
+			app = &App{
+				ID: &rule.AppID,
+			}
+			if err := app.Populate(ctx); err != nil {
+				fmt.Printf("failed to fetch app of alert rule %s: %v\n", rule.ID, err)
+				continue
+			}
+			apps[rule.AppID] = app
+		}
+
+		start := end.Add(-time.Duration(rule.Window) * time.Minute)
+		if err := rule.fire(ctx, app, evidence, start, end); err != nil {
+			fmt.Printf("failed to fire alert rule %s: %v\n", rule.ID, err)
+		}
+	}
+
+	return
+}
+
+// alertRuleColumns lists the columns
+// of alert rules.
+var alertRuleColumns = []string{
+	"id",
+	"app_id",
+	"name",
+	"metric",
+	"span_name",
+	"event_type",
+	"http_url",
+	"filters",
+	"condition",
+	"threshold",
+	"window_minutes",
+	"min_samples",
+	"enabled",
+	"created_by",
+	"last_evaluated_at",
+	"last_fired_at",
+	"created_at",
+	"updated_at",
+}
+
+// claimAlertRules marks enabled alert rules due for
+// evaluation as evaluated & returns them. Rules locked
+// by other evaluations are skipped.
+func claimAlertRules(ctx context.Context) (rules []AlertRule, err error) {
+	now := time.Now()
+
+	stmt := sqlf.PostgreSQL.Update("public.alert_rules").
+		Set("last_evaluated_at", now).
+		Where("id in (select id from public.alert_rules where enabled and (last_evaluated_at is null or last_evaluated_at < ?) order by last_evaluated_at nulls first limit ? for update skip locked)", now.Add(-ruleEvaluationInterval), ruleBatchSize)
+
+	defer stmt.Close()
+
+	for _, col := range alertRuleColumns {
+		stmt.Returning(col)
+	}
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[AlertRule])
+}
+
+// getAlertRules fetches the alert rules of an app.
+func getAlertRules(ctx context.Context, appId uuid.UUID) (rules []AlertRule, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.alert_rules").
+		Where("app_id = ?", appId).
+		OrderBy("created_at")
+
+	defer stmt.Close()
+
+	for _, col := range alertRuleColumns {
+		stmt.Select(col)
+	}
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[AlertRule])
+}
+
+// getAlertRule fetches an alert rule of an app.
+// Returns nil if the rule does not exist.
+func getAlertRule(ctx context.Context, appId, id uuid.UUID) (rule *AlertRule, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.alert_rules").
+		Where("app_id = ?", appId).
+		Where("id = ?", id)
+
+	defer stmt.Close()
+
+	for _, col := range alertRuleColumns {
+		stmt.Select(col)
+	}
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	r, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[AlertRule])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+
+	return &r, nil
+}
+
+// countAlertRules counts the alert rules of an app.
+func countAlertRules(ctx context.Context, appId uuid.UUID) (count int, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.alert_rules").
+		Select("count(*)").
+		Where("app_id = ?", appId)
+
+	defer stmt.Close()
+
+	err = server.Server.PgPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&count)
+
+	return
+}
+
+// insert creates the alert rule.
+func (r *AlertRule) insert(ctx context.Context) (err error) {
+	stmt := sqlf.PostgreSQL.InsertInto("public.alert_rules").
+		Set("id", r.ID).
+		Set("app_id", r.AppID).
+		Set("name", r.Name).
+		Set("metric", r.Metric).
+		Set("span_name", r.SpanName).
+		Set("event_type", r.EventType).
+		Set("http_url", r.HTTPURL).
+		Set("filters", r.Filters).
+		Set("condition", r.Condition).
+		Set("threshold", r.Threshold).
+		Set("window_minutes", r.Window).
+		Set("min_samples", r.MinSamples).
+		Set("enabled", r.Enabled).
+		Set("created_by", r.CreatedBy).
+		Set("created_at", time.Time(r.CreatedAt)).
+		Set("updated_at", time.Time(r.UpdatedAt))
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// update updates the definition of the alert rule.
+func (r *AlertRule) update(ctx context.Context) (err error) {
+	stmt := sqlf.PostgreSQL.Update("public.alert_rules").
+		Set("name", r.Name).
+		Set("metric", r.Metric).
+		Set("span_name", r.SpanName).
+		Set("event_type", r.EventType).
+		Set("http_url", r.HTTPURL).
+		Set("filters", r.Filters).
+		Set("condition", r.Condition).
+		Set("threshold", r.Threshold).
+		Set("window_minutes", r.Window).
+		Set("min_samples", r.MinSamples).
+		Set("enabled", r.Enabled).
+		Set("updated_at", time.Time(r.UpdatedAt)).
+		Where("id = ?", r.ID)
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// deleteStmts builds the statements deleting the
+// alert rule. Unresolved incidents of the rule are
+// resolved first, as deleting the rule unsets their
+// rule id & unresolved incidents of the same app &
+// type without a rule must be unique.
+func (r *AlertRule) deleteStmts(at time.Time) []*sqlf.Stmt {
+	return []*sqlf.Stmt{
+		resolveRuleIncidentsStmt(r.ID, at),
+		sqlf.PostgreSQL.DeleteFrom("public.alert_rules").
+			Where("id = ?", r.ID),
+	}
+}
+
+// delete deletes the alert rule & resolves its
+// unresolved incidents. Alerts & incidents of
+// the rule are kept.
+func (r *AlertRule) delete(ctx context.Context) (err error) {
+	tx, err := server.Server.PgPool.Begin(ctx)
+	if err != nil {
+		return
+	}
+
+	defer tx.Rollback(ctx)
+
+	for _, stmt := range r.deleteStmts(time.Now()) {
+		_, err = tx.Exec(ctx, stmt.String(), stmt.Args()...)
+		stmt.Close()
+		if err != nil {
+			return
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// resolveFilters copies the filters stored under the
+// payload's short code into the payload. Returns false
+// if no filters are stored under the short code.
+func (p *AlertRulePayload) resolveFilters(ctx context.Context, appId uuid.UUID) (ok bool, err error) {
+	if p.FilterShortCode == nil {
+		return true, nil
+	}
+
+	filters, err := filter.GetFiltersFromCode(ctx, *p.FilterShortCode, appId)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return
+	}
+
+	p.Filters = filters
+
+	return true, nil
+}
+
+func GetAlertRules(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAlertRead)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to read alert rules in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	rules, err := getAlertRules(ctx, appId)
+	if err != nil {
+		msg := `failed to fetch alert rules`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+func GetAlertRule(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ruleId, err := uuid.Parse(c.Param("ruleId"))
+	if err != nil {
+		msg := `rule id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAlertRead)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to read alert rules in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	rule, err := getAlertRule(ctx, appId, ruleId)
+	if err != nil {
+		msg := `failed to fetch alert rule`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if rule == nil {
+		msg := fmt.Sprintf(`alert rule [%s] does not exist`, ruleId)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func CreateAlertRule(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAlertAll)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to create alert rules in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var payload AlertRulePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		msg := `failed to parse alert rule json payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	found, err := payload.resolveFilters(ctx, appId)
+	if err != nil {
+		msg := `failed to fetch filters from short code`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !found {
+		msg := fmt.Sprintf(`filter short code [%s] does not exist`, *payload.FilterShortCode)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	createdBy, err := uuid.Parse(userId)
+	if err != nil {
+		msg := `user id invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	now := time.Now()
+	rule := AlertRule{
+		ID:        uuid.New(),
+		AppID:     appId,
+		Enabled:   true,
+		CreatedBy: &createdBy,
+		CreatedAt: chrono.ISOTime(now),
+		UpdatedAt: chrono.ISOTime(now),
+	}
+
+	payload.apply(&rule)
+
+	if err := rule.Validate(); err != nil {
+		msg := `alert rule is invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	count, err := countAlertRules(ctx, appId)
+	if err != nil {
+		msg := `failed to count alert rules`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if count >= maxAlertRulesPerApp {
+		msg := fmt.Sprintf(`app cannot have more than %d alert rules`, maxAlertRulesPerApp)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := rule.insert(ctx); err != nil {
+		msg := `failed to create alert rule`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusCreated, &rule)
+}
+
+func UpdateAlertRule(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ruleId, err := uuid.Parse(c.Param("ruleId"))
+	if err != nil {
+		msg := `rule id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAlertAll)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to modify alert rules in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var payload AlertRulePayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		msg := `failed to parse alert rule json payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	found, err := payload.resolveFilters(ctx, appId)
+	if err != nil {
+		msg := `failed to fetch filters from short code`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !found {
+		msg := fmt.Sprintf(`filter short code [%s] does not exist`, *payload.FilterShortCode)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	rule, err := getAlertRule(ctx, appId, ruleId)
+	if err != nil {
+		msg := `failed to fetch alert rule`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if rule == nil {
+		msg := fmt.Sprintf(`alert rule [%s] does not exist`, ruleId)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	payload.apply(rule)
+	rule.UpdatedAt = chrono.ISOTime(time.Now())
+
+	if err := rule.Validate(); err != nil {
+		msg := `alert rule is invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	if err := rule.update(ctx); err != nil {
+		msg := `failed to update alert rule`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+func DeleteAlertRule(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ruleId, err := uuid.Parse(c.Param("ruleId"))
+	if err != nil {
+		msg := `rule id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAlertAll)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to delete alert rules in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	rule, err := getAlertRule(ctx, appId, ruleId)
+	if err != nil {
+		msg := `failed to fetch alert rule`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if rule == nil {
+		msg := fmt.Sprintf(`alert rule [%s] does not exist`, ruleId)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	if err := rule.delete(ctx); err != nil {
+		msg := `failed to delete alert rule`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": "done"})
+}
@@ -0,0 +1,360 @@
+package measure
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/api/filter"
+
+	"github.com/google/uuid"
+)
+
+func TestAlertRuleValidate(t *testing.T) {
+	valid := AlertRule{
+		ID:        uuid.New(),
+		AppID:     uuid.New(),
+		Name:      "Crash free sessions on v5",
+		Metric:    "crash_free_sessions",
+		Condition: RuleConditionBelow,
+		Threshold: 99.5,
+		Window:    60,
+		Filters: filter.FilterList{
+			Versions:            []string{"5.0.0"},
+			VersionCodes:        []string{"500"},
+			DeviceManufacturers: []string{"samsung"},
+			UDExpressionRaw:     `{"cmp":{"key":"premium","type":"bool","op":"eq","value":"true"}}`,
+		},
+	}
+
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+
+	span := valid
+	span.Metric = "span_p95"
+	span.SpanName = "checkout_flow"
+	span.Condition = RuleConditionAbove
+	span.Threshold = 3000
+	span.Filters = filter.FilterList{}
+	if err := span.Validate(); err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+
+	invalid := map[string]func(r *AlertRule){
+		"empty name":           func(r *AlertRule) { r.Name = "" },
+		"long name":            func(r *AlertRule) { r.Name = strings.Repeat("a", maxAlertRuleNameLen+1) },
+		"unknown metric":       func(r *AlertRule) { r.Metric = "unknown" },
+		"missing span name":    func(r *AlertRule) { r.Metric = "span_p95" },
+		"invalid event type":   func(r *AlertRule) { r.Metric = "event_count"; r.EventType = "unknown" },
+		"unknown condition":    func(r *AlertRule) { r.Condition = "equal" },
+		"zero anomaly":         func(r *AlertRule) { r.Condition = RuleConditionAnomaly; r.Threshold = 0 },
+		"zero outlier":         func(r *AlertRule) { r.Condition = RuleConditionOutlier; r.Threshold = 0 },
+		"infinite threshold":   func(r *AlertRule) { r.Threshold = math.Inf(1) },
+		"short window":         func(r *AlertRule) { r.Window = minRuleWindow - 1 },
+		"long window":          func(r *AlertRule) { r.Window = maxRuleWindow + 1 },
+		"negative min samples": func(r *AlertRule) { r.MinSamples = -1 },
+		"unpaired versions":    func(r *AlertRule) { r.Filters.VersionCodes = nil },
+		"invalid expression":   func(r *AlertRule) { r.Filters.UDExpressionRaw = "{" },
+	}
+
+	for name, mutate := range invalid {
+		r := valid
+		mutate(&r)
+		if err := r.Validate(); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+}
+
+func TestAlertRulePayloadApply(t *testing.T) {
+	name := "  Slow checkout  "
+	threshold := 3000.0
+	enabled := false
+
+	rule := AlertRule{
+		Name:      "Old",
+		Metric:    "span_p95",
+		SpanName:  "checkout_flow",
+		Condition: RuleConditionAbove,
+		Threshold: 2000,
+		Window:    30,
+		Enabled:   true,
+	}
+
+	AlertRulePayload{
+		Name:      &name,
+		Threshold: &threshold,
+		Enabled:   &enabled,
+	}.apply(&rule)
+
+	if rule.Name != "Slow checkout" {
+		t.Errorf("Expected trimmed name, but got %q", rule.Name)
+	}
+
+	if rule.Threshold != threshold {
+		t.Errorf("Expected threshold %v, but got %v", threshold, rule.Threshold)
+	}
+
+	if rule.Enabled {
+		t.Errorf("Expected rule to be disabled")
+	}
+
+	if rule.Metric != "span_p95" || rule.SpanName != "checkout_flow" || rule.Window != 30 {
+		t.Errorf("Expected fields absent in payload to be unchanged")
+	}
+}
+
+func TestAlertRuleBreached(t *testing.T) {
+	above := AlertRule{Metric: "span_p95", Condition: RuleConditionAbove, Threshold: 3000}
+
+	if ok, _ := above.breached(ruleValue{value: 3500, samples: 10}, ruleValue{}); !ok {
+		t.Errorf("Expected value above threshold to breach")
+	}
+
+	if ok, _ := above.breached(ruleValue{value: 2500, samples: 10}, ruleValue{}); ok {
+		t.Errorf("Expected value below threshold to not breach")
+	}
+
+	if ok, _ := above.breached(ruleValue{value: math.NaN()}, ruleValue{}); ok {
+		t.Errorf("Expected missing value to not breach")
+	}
+
+	above.MinSamples = 100
+	if ok, _ := above.breached(ruleValue{value: 3500, samples: 10}, ruleValue{}); ok {
+		t.Errorf("Expected too few samples to not breach")
+	}
+
+	below := AlertRule{Metric: "crash_free_sessions", Condition: RuleConditionBelow, Threshold: 99.5}
+
+	if ok, _ := below.breached(ruleValue{value: 99.1, samples: 1000}, ruleValue{}); !ok {
+		t.Errorf("Expected value below threshold to breach")
+	}
+
+	// crash free sessions dropping from 99.8% to 99.5%
+	// is a 150% increase in crashing sessions
+	anomaly := AlertRule{Metric: "crash_free_sessions", Condition: RuleConditionAnomaly, Threshold: 100}
+
+	ok, deviation := anomaly.breached(ruleValue{value: 99.5, samples: 1000}, ruleValue{value: 99.8, samples: 10000})
+	if !ok {
+		t.Errorf("Expected drop of healthy sessions to breach")
+	}
+
+	if math.Abs(deviation-150) > 0.01 {
+		t.Errorf("Expected deviation of 150%%, but got %v", deviation)
+	}
+
+	if ok, _ := anomaly.breached(ruleValue{value: 99.9, samples: 1000}, ruleValue{value: 99.8, samples: 10000}); ok {
+		t.Errorf("Expected improvement to not breach")
+	}
+
+	if ok, _ := anomaly.breached(ruleValue{value: 99.5, samples: 1000}, ruleValue{}); ok {
+		t.Errorf("Expected missing baseline to not breach")
+	}
+
+	// 99.1% is 4 spreads below the median of 99.5%
+	outlier := AlertRule{Metric: "crash_free_sessions", Condition: RuleConditionOutlier, Threshold: 3}
+
+	ok, score := outlier.breached(ruleValue{value: 99.1, samples: 1000}, ruleValue{value: 99.5, spread: 0.1, samples: 10000})
+	if !ok {
+		t.Errorf("Expected drop of healthy sessions to breach")
+	}
+
+	if math.Abs(score+4) > 0.01 {
+		t.Errorf("Expected score of -4, but got %v", score)
+	}
+
+	if ok, _ := outlier.breached(ruleValue{value: 99.9, samples: 1000}, ruleValue{value: 99.5, spread: 0.1, samples: 10000}); ok {
+		t.Errorf("Expected rise of healthy sessions to not breach")
+	}
+
+	if ok, _ := outlier.breached(ruleValue{value: 99.1, samples: 1000}, ruleValue{}); ok {
+		t.Errorf("Expected missing seasonal baseline to not breach")
+	}
+
+	latency := AlertRule{Metric: "span_p95", SpanName: "checkout_flow", Condition: RuleConditionOutlier, Threshold: 3}
+
+	if ok, _ := latency.breached(ruleValue{value: 2400, samples: 500}, ruleValue{value: 1800, spread: 100, samples: 10000}); !ok {
+		t.Errorf("Expected slower spans to breach")
+	}
+
+	if ok, _ := latency.breached(ruleValue{value: 1200, samples: 500}, ruleValue{value: 1800, spread: 100, samples: 10000}); ok {
+		t.Errorf("Expected faster spans to not breach")
+	}
+
+	counts := AlertRule{Metric: "event_count", Condition: RuleConditionAnomaly, Threshold: 50}
+
+	if ok, _ := counts.breached(ruleValue{value: 40, samples: 40}, ruleValue{value: 100, samples: 1000}); !ok {
+		t.Errorf("Expected drop of event count to breach")
+	}
+
+	if ok, _ := counts.breached(ruleValue{value: 120, samples: 120}, ruleValue{value: 100, samples: 1000}); ok {
+		t.Errorf("Expected small rise of event count to not breach")
+	}
+}
+
+func TestAlertRuleEvaluable(t *testing.T) {
+	rule := AlertRule{Metric: "crash_free_sessions", Condition: RuleConditionBelow, Threshold: 99.5, MinSamples: 100}
+
+	if !rule.evaluable(ruleValue{value: 99.9, samples: 1000}, ruleValue{}) {
+		t.Errorf("Expected healthy value with enough samples to be evaluable")
+	}
+
+	if rule.evaluable(ruleValue{value: 99.9, samples: 10}, ruleValue{}) {
+		t.Errorf("Expected too few samples to not be evaluable")
+	}
+
+	rule.Condition = RuleConditionAnomaly
+	if rule.evaluable(ruleValue{value: 99.9, samples: 1000}, ruleValue{value: math.NaN()}) {
+		t.Errorf("Expected missing baseline to not be evaluable")
+	}
+}
+
+func TestAlertRuleMetricStmt(t *testing.T) {
+	rule := AlertRule{
+		AppID:     uuid.New(),
+		Metric:    "http_error_rate",
+		HTTPURL:   "/api/pay",
+		Condition: RuleConditionAbove,
+		Threshold: 2,
+		Window:    60,
+		Filters: filter.FilterList{
+			Countries:       []string{"IN"},
+			UDExpressionRaw: `{"cmp":{"key":"premium","type":"bool","op":"eq","value":"true"}}`,
+		},
+	}
+
+	af, err := rule.appFilter(time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	stmt, err := rule.metricStmt(af)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	defer stmt.Close()
+
+	sql := stmt.String()
+
+	for _, want := range []string{"from default.events", "type = 'http'", "position(http.url, ?) > 0", "inet.country_code in", "AND id in (SELECT event_id"} {
+		if !strings.Contains(strings.ToLower(sql), strings.ToLower(want)) {
+			t.Errorf("Expected statement to contain %q, got %s", want, sql)
+		}
+	}
+
+	// user defined attribute expressions of session
+	// metrics select whole sessions
+	rule.Metric = "crash_free_sessions"
+	rule.Filters.Countries = nil
+
+	af, err = rule.appFilter(time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	stmt, err = rule.metricStmt(af)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	defer stmt.Close()
+
+	sql = stmt.String()
+
+	if !strings.Contains(sql, "WHERE session_id in (SELECT session_id") {
+		t.Errorf("Expected statement to filter sessions, got %s", sql)
+	}
+
+	// user defined attribute expressions of span
+	// metrics select spans
+	rule.Metric = "span_p95"
+	rule.SpanName = "checkout_flow"
+
+	af, err = rule.appFilter(time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	stmt, err = rule.metricStmt(af)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	defer stmt.Close()
+
+	sql = stmt.String()
+
+	if !strings.Contains(sql, "span_id in (SELECT span_id FROM span_user_def_attrs") {
+		t.Errorf("Expected statement to filter spans, got %s", sql)
+	}
+}
+
+func TestAlertRuleDeleteStmts(t *testing.T) {
+	// incident mirrors the columns of the unresolved
+	// incidents index, all of the same app & type
+	type incident struct {
+		ruleId *uuid.UUID
+		status string
+	}
+
+	first, second := uuid.New(), uuid.New()
+	incidents := []incident{
+		{ruleId: &first, status: IncidentStatusOpen},
+		{ruleId: &second, status: IncidentStatusAcknowledged},
+	}
+
+	at := time.Now()
+
+	for _, rule := range []AlertRule{{ID: first}, {ID: second}} {
+		stmts := rule.deleteStmts(at)
+		if len(stmts) != 2 {
+			t.Fatalf("Expected 2 statements, but got %d", len(stmts))
+		}
+
+		resolve, remove := stmts[0], stmts[1]
+
+		if !strings.HasPrefix(resolve.String(), "UPDATE public.alert_incidents") {
+			t.Fatalf("Expected incidents to be resolved first, but got %s", resolve.String())
+		}
+
+		if !strings.HasPrefix(remove.String(), "DELETE FROM public.alert_rules") {
+			t.Fatalf("Expected rule to be deleted last, but got %s", remove.String())
+		}
+
+		// resolve the rule's open & acknowledged incidents
+		args := resolve.Args()
+		for i := range incidents {
+			if incidents[i].ruleId != nil && *incidents[i].ruleId == args[3] && (incidents[i].status == args[4] || incidents[i].status == args[5]) {
+				incidents[i].status = args[0].(string)
+			}
+		}
+
+		// deleting the rule sets null on its incidents
+		for i := range incidents {
+			if incidents[i].ruleId != nil && *incidents[i].ruleId == remove.Args()[0] {
+				incidents[i].ruleId = nil
+			}
+		}
+
+		unresolved := 0
+		for _, incident := range incidents {
+			if incident.ruleId == nil && incident.status != IncidentStatusResolved {
+				unresolved++
+			}
+		}
+
+		if unresolved > 1 {
+			t.Errorf("Expected at most 1 unresolved incident without a rule after deleting rule %s, but got %d", rule.ID, unresolved)
+		}
+
+		for _, stmt := range stmts {
+			stmt.Close()
+		}
+	}
+
+	for _, incident := range incidents {
+		if incident.status != IncidentStatusResolved {
+			t.Errorf("Expected incidents of deleted rules to be resolved, but got %q", incident.status)
+		}
+	}
+}
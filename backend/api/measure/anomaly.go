@@ -0,0 +1,124 @@
+package measure
+
+import (
+	"math"
+	"time"
+
+	"backend/api/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// plotAnomalyThreshold is the minimum absolute
+// score of anomalies annotated on plots.
+const plotAnomalyThreshold = 3
+
+// plotAnomalyMinHistory is the minimum count of
+// earlier days a plot's day is compared against.
+const plotAnomalyMinHistory = 5
+
+// plotAnomalyMinVolume is the minimum count of
+// sessions or spans of a plot's day for it to
+// be considered.
+const plotAnomalyMinVolume = 100
+
+// plotAnomalyAlpha is the smoothing factor of
+// EWMA anomaly detection on plots.
+const plotAnomalyAlpha = 0.3
+
+// PlotAnomaly represents an anomalous
+// point of a plot's metric.
+type PlotAnomaly struct {
+	DateTime string `json:"datetime"`
+	Metric   string `json:"metric"`
+	metrics.Anomaly
+}
+
+// plotPoint represents a day, or an hour
+// for hourly plots, of a plot's metric.
+type plotPoint struct {
+	datetime string
+	value    *float64
+	volume   uint64
+}
+
+// plotHourLayouts lists the layouts of
+// datetimes of hourly plots.
+var plotHourLayouts = []string{
+	time.DateTime,
+	"2006-01-02 15:04",
+	time.RFC3339,
+}
+
+// plotPhase computes the seasonal phase of a plot's
+// datetime based on the plot's granularity. Days of
+// daily plots are compared against the same weekday
+// & hours of hourly plots against the same hour of
+// the day.
+func plotPhase(datetime string) int {
+	if date, err := time.Parse(time.DateOnly, datetime); err == nil {
+		return int(date.Weekday())
+	}
+
+	for _, layout := range plotHourLayouts {
+		if hour, err := time.Parse(layout, datetime); err == nil {
+			return hour.Hour()
+		}
+	}
+
+	return 0
+}
+
+// plotAnomalyOptions parses the anomaly detection method
+// requested for a plot. Returns nil if anomalies were
+// not requested.
+func plotAnomalyOptions(c *gin.Context) (opts *metrics.AnomalyOptions, err error) {
+	method := c.Query("anomalies")
+	if method == "" {
+		return
+	}
+
+	opts = &metrics.AnomalyOptions{
+		Method:     method,
+		Threshold:  plotAnomalyThreshold,
+		MinHistory: plotAnomalyMinHistory,
+		MinVolume:  plotAnomalyMinVolume,
+		Seasonal:   true,
+		Alpha:      plotAnomalyAlpha,
+	}
+
+	if err = opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// detectPlotAnomalies detects anomalous points of a
+// plot's metric, comparing points against points of
+// the same phase once there are enough of them.
+func detectPlotAnomalies(opts metrics.AnomalyOptions, metric string, points []plotPoint) (anomalies []PlotAnomaly) {
+	series := make([]metrics.SeriesPoint, len(points))
+	for i, p := range points {
+		series[i] = metrics.SeriesPoint{
+			Value:  math.NaN(),
+			Volume: p.volume,
+		}
+
+		if p.value != nil {
+			series[i].Value = *p.value
+		}
+
+		series[i].Phase = plotPhase(p.datetime)
+	}
+
+	for _, anomaly := range metrics.DetectAnomalies(series, opts) {
+		anomalies = append(anomalies, PlotAnomaly{
+			DateTime: points[anomaly.Index].datetime,
+			Metric:   metric,
+			Anomaly:  anomaly,
+		})
+	}
+
+	return
+}
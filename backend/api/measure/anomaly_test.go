@@ -0,0 +1,107 @@
+package measure
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"backend/api/metrics"
+)
+
+func TestDetectPlotAnomalies(t *testing.T) {
+	values := []float64{99.6, 99.5, 99.7, 99.6, 99.5, 99.6, 97.1}
+	datetimes := []string{"2024-12-16", "2024-12-17", "2024-12-18", "2024-12-19", "2024-12-20", "2024-12-21", "2024-12-22"}
+
+	var points []plotPoint
+	for i := range values {
+		points = append(points, plotPoint{datetime: datetimes[i], value: &values[i], volume: 1000})
+	}
+
+	opts := metrics.AnomalyOptions{
+		Method:     metrics.AnomalyMethodMAD,
+		Threshold:  plotAnomalyThreshold,
+		MinHistory: plotAnomalyMinHistory,
+		MinVolume:  plotAnomalyMinVolume,
+		Seasonal:   true,
+	}
+
+	anomalies := detectPlotAnomalies(opts, "crash_free_sessions", points)
+	if len(anomalies) != 1 {
+		t.Fatalf("Expected 1 anomaly, but got %+v", anomalies)
+	}
+
+	if anomalies[0].DateTime != "2024-12-22" || anomalies[0].Metric != "crash_free_sessions" || anomalies[0].Score >= 0 {
+		t.Errorf("Expected drop on 2024-12-22 to be annotated, but got %+v", anomalies[0])
+	}
+
+	// missing values are skipped
+	points[6].value = nil
+	if anomalies := detectPlotAnomalies(opts, "crash_free_sessions", points); len(anomalies) != 0 {
+		t.Errorf("Expected no anomalies, but got %+v", anomalies)
+	}
+}
+
+func TestPlotPhase(t *testing.T) {
+	expected := map[string]int{
+		"2024-12-22":                0,
+		"2024-12-23":                1,
+		"2024-12-22 00:00:00":       0,
+		"2024-12-23 14:00:00":       14,
+		"2024-12-23 14:00":          14,
+		"2024-12-23T23:00:00+05:30": 23,
+		"unknown":                   0,
+	}
+
+	for datetime, phase := range expected {
+		if actual := plotPhase(datetime); actual != phase {
+			t.Errorf("Expected phase %d of %q, but got %d", phase, datetime, actual)
+		}
+	}
+}
+
+func TestDetectPlotAnomaliesHourly(t *testing.T) {
+	// the 14:00 hour dips daily, so on the last
+	// day only the drop at 09:00 is anomalous
+	var points []plotPoint
+	for day := 16; day <= 22; day++ {
+		for _, hour := range []int{9, 14} {
+			value := 99.6
+			if hour == 14 {
+				value = 97.0
+			}
+			if day == 22 && hour == 9 {
+				value = 97.0
+			}
+			points = append(points, plotPoint{
+				datetime: fmt.Sprintf("2024-12-%02d %02d:00:00", day, hour),
+				value:    &value,
+				volume:   1000,
+			})
+		}
+	}
+
+	opts := metrics.AnomalyOptions{
+		Method:     metrics.AnomalyMethodMAD,
+		Threshold:  plotAnomalyThreshold,
+		MinHistory: plotAnomalyMinHistory,
+		MinVolume:  plotAnomalyMinVolume,
+		Seasonal:   true,
+	}
+
+	// hours without enough history of the same
+	// hour are compared against all earlier hours
+	var last []PlotAnomaly
+	for _, anomaly := range detectPlotAnomalies(opts, "crash_free_sessions", points) {
+		if strings.HasPrefix(anomaly.DateTime, "2024-12-22") {
+			last = append(last, anomaly)
+		}
+	}
+
+	if len(last) != 1 {
+		t.Fatalf("Expected 1 anomaly on the last day, but got %+v", last)
+	}
+
+	if last[0].DateTime != "2024-12-22 09:00:00" || last[0].Score >= 0 {
+		t.Errorf("Expected drop at 2024-12-22 09:00:00 to be annotated, but got %+v", last[0])
+	}
+}
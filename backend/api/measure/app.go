@@ -8,6 +8,7 @@ import (
 	"math"
 	"net/http"
 	"net/url"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -101,7 +102,12 @@ func (a App) GetExceptionGroup(ctx context.Context, id uuid.UUID) (exceptionGrou
 		Select(`file_name`).
 		Select(`line_number`).
 		Select("fingerprint").
+		Select("fingerprint_version").
+		Select("merged_into").
+		Select("array(select m.fingerprint from public.unhandled_exception_groups m where m.merged_into = unhandled_exception_groups.id) as merged_fingerprints").
+		Select("handled").
 		Select("first_event_timestamp").
+		Select("last_event_timestamp").
 		Select("created_at").
 		Select("updated_at").
 		Where("app_id = ?", a.ID).
@@ -124,45 +130,34 @@ func (a App) GetExceptionGroup(ctx context.Context, id uuid.UUID) (exceptionGrou
 
 	exceptionGroup = &row
 
-	// Get list of event IDs
-	eventDataStmt := sqlf.From(`events`).
-		Select(`distinct id`).
-		Clause("prewhere app_id = toUUID(?) and exception.fingerprint = ?", a.ID, exceptionGroup.Fingerprint).
-		Where("type = 'exception'").
-		Where("exception.handled = false").
-		GroupBy("id")
-
-	defer eventDataStmt.Close()
-
-	eventDataRows, err := server.Server.ChPool.Query(ctx, eventDataStmt.String(), eventDataStmt.Args()...)
-	if err != nil {
-		return nil, err
-	}
-	defer eventDataRows.Close()
-
-	var eventIds = []uuid.UUID{}
-	var eventID uuid.UUID
-	for eventDataRows.Next() {
-		if err := eventDataRows.Scan(&eventID); err != nil {
-			return nil, err
-		}
+	return
+}
 
-		eventIds = append(eventIds, eventID)
-	}
+// GetExceptionGroupByFingerprint queries a single exception group by its fingerprint.
+// Handled chooses between handled (non-fatal) and unhandled exception groups.
+func (a App) GetExceptionGroupByFingerprint(ctx context.Context, fingerprint string, handled bool) (exceptionGroup *group.ExceptionGroup, err error) {
+	stmt := a.exceptionGroupByFingerprintStmt(fingerprint, handled)
+	defer stmt.Close()
 
-	if eventDataRows.Err() != nil {
+	rows, _ := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if rows.Err() != nil {
 		return
 	}
 
-	exceptionGroup.EventIDs = eventIds
-	exceptionGroup.Count = len(eventIds)
+	row, err := pgx.CollectOneRow(rows, pgx.RowToStructByNameLax[group.ExceptionGroup])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
 
-	return
+	return &row, nil
 }
 
-// GetExceptionGroupByFingerprint queries a single exception group by its fingerprint.
-func (a App) GetExceptionGroupByFingerprint(ctx context.Context, fingerprint string) (exceptionGroup *group.ExceptionGroup, err error) {
-	stmt := sqlf.PostgreSQL.
+// exceptionGroupByFingerprintStmt builds the statement looking
+// up an app's exception group by its fingerprint.
+func (a App) exceptionGroupByFingerprintStmt(fingerprint string, handled bool) *sqlf.Stmt {
+	return sqlf.PostgreSQL.
 		From("public.unhandled_exception_groups").
 		Select("id").
 		Select("app_id").
@@ -172,62 +167,23 @@ func (a App) GetExceptionGroupByFingerprint(ctx context.Context, fingerprint str
 		Select(`file_name`).
 		Select(`line_number`).
 		Select("fingerprint").
+		Select("fingerprint_version").
+		Select("merged_into").
+		Select("array(select m.fingerprint from public.unhandled_exception_groups m where m.merged_into = unhandled_exception_groups.id) as merged_fingerprints").
+		Select("handled").
 		Select("first_event_timestamp").
+		Select("last_event_timestamp").
 		Select("created_at").
 		Select("updated_at").
 		Where("app_id = ?", a.ID).
-		Where("fingerprint = ?", fingerprint)
-
-	defer stmt.Close()
-
-	rows, _ := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
-	if rows.Err() != nil {
-		return
-	}
-
-	row, err := pgx.CollectOneRow(rows, pgx.RowToStructByNameLax[group.ExceptionGroup])
-	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, nil
-	} else if err != nil {
-		return nil, err
-	}
-
-	exceptionGroup = &row
-
-	// Get list of event IDs
-	eventDataStmt := sqlf.From(`default.events`).
-		Select(`id`).
-		Where(`exception.fingerprint = ?`, exceptionGroup.Fingerprint)
-
-	eventDataRows, err := server.Server.ChPool.Query(ctx, eventDataStmt.String(), eventDataStmt.Args()...)
-	if err != nil {
-		return nil, err
-	}
-	defer eventDataRows.Close()
-
-	var eventIds = []uuid.UUID{}
-	var eventID uuid.UUID
-	for eventDataRows.Next() {
-		if err := eventDataRows.Scan(&eventID); err != nil {
-			return nil, err
-		}
-
-		eventIds = append(eventIds, eventID)
-	}
-
-	if eventDataRows.Err() != nil {
-		return nil, eventDataRows.Err()
-	}
-
-	exceptionGroup.EventIDs = eventIds
-	exceptionGroup.Count = len(eventIds)
-
-	return exceptionGroup, nil
+		Where("fingerprint = ?", fingerprint).
+		Where("handled = ?", handled)
 }
 
 // GetExceptionGroups returns slice of ExceptionGroup
-// of an app.
-func (a App) GetExceptionGroupsWithFilter(ctx context.Context, af *filter.AppFilter) (groups []group.ExceptionGroup, err error) {
+// of an app. Handled chooses between handled (non-fatal)
+// and unhandled exception groups.
+func (a App) GetExceptionGroupsWithFilter(ctx context.Context, af *filter.AppFilter, handled bool) (groups []group.ExceptionGroup, err error) {
 	stmt := sqlf.PostgreSQL.
 		From("public.unhandled_exception_groups").
 		Select("id").
@@ -238,10 +194,17 @@ func (a App) GetExceptionGroupsWithFilter(ctx context.Context, af *filter.AppFil
 		Select(`file_name`).
 		Select(`line_number`).
 		Select("fingerprint").
+		Select("fingerprint_version").
+		Select("merged_into").
+		Select("array(select m.fingerprint from public.unhandled_exception_groups m where m.merged_into = unhandled_exception_groups.id) as merged_fingerprints").
+		Select("handled").
 		Select("first_event_timestamp").
+		Select("last_event_timestamp").
 		Select("created_at").
 		Select("updated_at").
-		Where("app_id = ?", a.ID)
+		Where("app_id = ?", a.ID).
+		Where("handled = ?", handled).
+		Where("merged_into is null")
 
 	defer stmt.Close()
 
@@ -251,101 +214,48 @@ func (a App) GetExceptionGroupsWithFilter(ctx context.Context, af *filter.AppFil
 		return
 	}
 
-	var exceptionGroup *group.ExceptionGroup
+	groupFingerprints := make(map[string][]string)
 	for i := range groups {
-		exceptionGroup = &groups[i]
-
-		eventDataStmt := sqlf.
-			From("events").
-			Select("distinct id").
-			Clause("prewhere app_id = toUUID(?) and exception.fingerprint = ?", af.AppID, exceptionGroup.Fingerprint).
-			Where("type = ?", event.TypeException).
-			Where("exception.handled = ?", false)
-
-		defer eventDataStmt.Close()
-
-		if af.HasUDExpression() && !af.UDExpression.Empty() {
-			subQuery := sqlf.From("user_def_attrs").
-				Select("event_id id").
-				Where("app_id = toUUID(?)", af.AppID).
-				Where("exception = true")
-			af.UDExpression.Augment(subQuery)
-			eventDataStmt.Clause("AND id in").SubQuery("(", ")", subQuery)
-		}
-
-		eventDataStmt.GroupBy("id")
-
-		if len(af.Versions) > 0 {
-			eventDataStmt.Where("attribute.app_version").In(af.Versions)
-		}
-
-		if len(af.VersionCodes) > 0 {
-			eventDataStmt.Where("attribute.app_build").In(af.VersionCodes)
-		}
-
-		if len(af.OsNames) > 0 {
-			eventDataStmt.Where("attribute.os_name").In(af.OsNames)
-		}
-
-		if len(af.OsVersions) > 0 {
-			eventDataStmt.Where("attribute.os_version").In(af.OsVersions)
-		}
-
-		if len(af.Countries) > 0 {
-			eventDataStmt.Where("inet.country_code").In(af.Countries)
-		}
-
-		if len(af.DeviceNames) > 0 {
-			eventDataStmt.Where("attribute.device_name").In(af.DeviceNames)
-		}
-
-		if len(af.DeviceManufacturers) > 0 {
-			eventDataStmt.Where("attribute.device_manufacturer").In(af.DeviceManufacturers)
-		}
-
-		if len(af.Locales) > 0 {
-			eventDataStmt.Where("attribute.device_locale").In(af.Locales)
-		}
-
-		if len(af.NetworkProviders) > 0 {
-			eventDataStmt.Where("attribute.network_provider").In(af.NetworkProviders)
-		}
-
-		if len(af.NetworkTypes) > 0 {
-			eventDataStmt.Where("attribute.network_type").In(af.NetworkTypes)
-		}
+		groupFingerprints[groups[i].ID.String()] = groups[i].GetFingerprints()
+	}
 
-		if len(af.NetworkGenerations) > 0 {
-			eventDataStmt.Where("attribute.network_generation").In(af.NetworkGenerations)
+	// count from pre-aggregated issue metrics unless
+	// user defined attributes need to be matched
+	// against individual events
+	if !af.HasUDExpression() || af.UDExpression.Empty() {
+		var fingerprints []string
+		for i := range groups {
+			fingerprints = append(fingerprints, groups[i].GetFingerprints()...)
 		}
 
-		if af.HasTimeRange() {
-			eventDataStmt.Where("timestamp >= ? and timestamp <= ?", af.From, af.To)
+		counts, err := GetIssueMetricsCounts(ctx, af, event.TypeException, handled, fingerprints)
+		if err != nil {
+			return nil, err
 		}
 
-		rows, err := server.Server.ChPool.Query(ctx, eventDataStmt.String(), eventDataStmt.Args()...)
+		users, err := GetIssueMetricsUsers(ctx, af, event.TypeException, handled, groupFingerprints)
 		if err != nil {
 			return nil, err
 		}
 
-		defer rows.Close()
-
-		var ids []uuid.UUID
-		for rows.Next() {
-			var id uuid.UUID
-			if err := rows.Scan(&id); err != nil {
-				return nil, err
+		for i := range groups {
+			for _, fingerprint := range groups[i].GetFingerprints() {
+				groups[i].Count += int(counts[fingerprint])
 			}
-
-			ids = append(ids, id)
+			groups[i].AffectedUsers = int(users[groups[i].ID.String()])
 		}
 
-		if rows.Err() != nil {
-			return nil, err
-		}
+		return groups, nil
+	}
+
+	counts, users, err := GetIssueEventCounts(ctx, af, event.TypeException, handled, groupFingerprints)
+	if err != nil {
+		return nil, err
+	}
 
-		exceptionGroup.EventIDs = ids
-		exceptionGroup.Count = len(ids)
+	for i := range groups {
+		groups[i].Count = int(counts[groups[i].ID.String()])
+		groups[i].AffectedUsers = int(users[groups[i].ID.String()])
 	}
 
 	return
@@ -363,7 +273,12 @@ func (a App) GetANRGroup(ctx context.Context, id uuid.UUID) (anrGroup *group.ANR
 		Select(`file_name`).
 		Select(`line_number`).
 		Select("fingerprint").
+		Select("fingerprint_version").
+		Select("cause").
+		Select("merged_into").
+		Select("array(select m.fingerprint from public.anr_groups m where m.merged_into = anr_groups.id) as merged_fingerprints").
 		Select("first_event_timestamp").
+		Select("last_event_timestamp").
 		Select("created_at").
 		Select("updated_at").
 		Where("app_id = ?", a.ID).
@@ -386,41 +301,56 @@ func (a App) GetANRGroup(ctx context.Context, id uuid.UUID) (anrGroup *group.ANR
 
 	anrGroup = &row
 
-	// Get list of event IDs
-	eventDataStmt := sqlf.From(`events`).
-		Select(`distinct id`).
-		Clause("prewhere app_id = toUUID(?) and anr.fingerprint = ?", a.ID, anrGroup.Fingerprint).
-		Where("type = ?", event.TypeANR).
-		GroupBy("id")
-
-	eventDataRows, err := server.Server.ChPool.Query(ctx, eventDataStmt.String(), eventDataStmt.Args()...)
-	if err != nil {
-		return nil, err
-	}
-	defer eventDataRows.Close()
+	return
+}
 
-	var eventIds = []uuid.UUID{}
-	var eventID uuid.UUID
-	for eventDataRows.Next() {
-		if err := eventDataRows.Scan(&eventID); err != nil {
-			return nil, err
-		}
+// GetANRGroupByFingerprint queries a single ANR group by its fingerprint.
+func (a App) GetANRGroupByFingerprint(ctx context.Context, fingerprint string) (anrGroup *group.ANRGroup, err error) {
+	stmt := a.anrGroupByFingerprintStmt(fingerprint)
+	defer stmt.Close()
 
-		eventIds = append(eventIds, eventID)
+	rows, _ := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if rows.Err() != nil {
+		return
 	}
 
-	if eventDataRows.Err() != nil {
-		return nil, eventDataRows.Err()
+	row, err := pgx.CollectOneRow(rows, pgx.RowToStructByNameLax[group.ANRGroup])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
 	}
 
-	anrGroup.EventIDs = eventIds
-	anrGroup.Count = len(eventIds)
+	return &row, nil
+}
 
-	return anrGroup, nil
+// anrGroupByFingerprintStmt builds the statement looking
+// up an app's ANR group by its fingerprint.
+func (a App) anrGroupByFingerprintStmt(fingerprint string) *sqlf.Stmt {
+	return sqlf.PostgreSQL.
+		From("public.anr_groups").
+		Select("id").
+		Select("app_id").
+		Select(`type`).
+		Select(`message`).
+		Select(`method_name`).
+		Select(`file_name`).
+		Select(`line_number`).
+		Select("fingerprint").
+		Select("fingerprint_version").
+		Select("cause").
+		Select("merged_into").
+		Select("array(select m.fingerprint from public.anr_groups m where m.merged_into = anr_groups.id) as merged_fingerprints").
+		Select("first_event_timestamp").
+		Select("last_event_timestamp").
+		Select("created_at").
+		Select("updated_at").
+		Where("app_id = ?", a.ID).
+		Where("fingerprint = ?", fingerprint)
 }
 
-// GetANRGroupByFingerprint queries a single ANR group by its fingerprint.
-func (a App) GetANRGroupByFingerprint(ctx context.Context, fingerprint string) (anrGroup *group.ANRGroup, err error) {
+// GetANRGroups returns slice of ANRGroup of an app.
+func (a App) GetANRGroupsWithFilter(ctx context.Context, af *filter.AppFilter) (groups []group.ANRGroup, err error) {
 	stmt := sqlf.PostgreSQL.
 		From("public.anr_groups").
 		Select("id").
@@ -431,177 +361,197 @@ func (a App) GetANRGroupByFingerprint(ctx context.Context, fingerprint string) (
 		Select(`file_name`).
 		Select(`line_number`).
 		Select("fingerprint").
+		Select("fingerprint_version").
+		Select("cause").
+		Select("merged_into").
+		Select("array(select m.fingerprint from public.anr_groups m where m.merged_into = anr_groups.id) as merged_fingerprints").
 		Select("first_event_timestamp").
+		Select("last_event_timestamp").
 		Select("created_at").
 		Select("updated_at").
 		Where("app_id = ?", a.ID).
-		Where("fingerprint = ?", fingerprint)
+		Where("merged_into is null")
 
 	defer stmt.Close()
 
+	if len(af.ANRCauses) > 0 {
+		stmt.Where("cause = any(?)", af.ANRCauses)
+	}
+
 	rows, _ := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
-	if rows.Err() != nil {
+	groups, err = pgx.CollectRows(rows, pgx.RowToStructByNameLax[group.ANRGroup])
+	if err != nil {
 		return
 	}
 
-	row, err := pgx.CollectOneRow(rows, pgx.RowToStructByNameLax[group.ANRGroup])
-	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, nil
-	} else if err != nil {
-		return nil, err
+	groupFingerprints := make(map[string][]string)
+	for i := range groups {
+		groupFingerprints[groups[i].ID.String()] = groups[i].GetFingerprints()
 	}
 
-	anrGroup = &row
-
-	// Get list of event IDs
-	eventDataStmt := sqlf.From(`default.events`).
-		Select(`id`).
-		Where(`anr.fingerprint = ?`, anrGroup.Fingerprint)
+	// count from pre-aggregated issue metrics unless
+	// user defined attributes need to be matched
+	// against individual events
+	if !af.HasUDExpression() || af.UDExpression.Empty() {
+		var fingerprints []string
+		for i := range groups {
+			fingerprints = append(fingerprints, groups[i].GetFingerprints()...)
+		}
 
-	eventDataRows, err := server.Server.ChPool.Query(ctx, eventDataStmt.String(), eventDataStmt.Args()...)
-	if err != nil {
-		return nil, err
-	}
-	defer eventDataRows.Close()
+		counts, err := GetIssueMetricsCounts(ctx, af, event.TypeANR, false, fingerprints)
+		if err != nil {
+			return nil, err
+		}
 
-	var eventIds = []uuid.UUID{}
-	var eventID uuid.UUID
-	for eventDataRows.Next() {
-		if err := eventDataRows.Scan(&eventID); err != nil {
+		users, err := GetIssueMetricsUsers(ctx, af, event.TypeANR, false, groupFingerprints)
+		if err != nil {
 			return nil, err
 		}
 
-		eventIds = append(eventIds, eventID)
+		for i := range groups {
+			for _, fingerprint := range groups[i].GetFingerprints() {
+				groups[i].Count += int(counts[fingerprint])
+			}
+			groups[i].AffectedUsers = int(users[groups[i].ID.String()])
+		}
+
+		return groups, nil
 	}
 
-	if eventDataRows.Err() != nil {
-		return nil, eventDataRows.Err()
+	counts, users, err := GetIssueEventCounts(ctx, af, event.TypeANR, false, groupFingerprints)
+	if err != nil {
+		return nil, err
 	}
 
-	anrGroup.EventIDs = eventIds
-	anrGroup.Count = len(eventIds)
+	for i := range groups {
+		groups[i].Count = int(counts[groups[i].ID.String()])
+		groups[i].AffectedUsers = int(users[groups[i].ID.String()])
+	}
 
-	return anrGroup, nil
+	return
 }
 
-// GetANRGroups returns slice of ANRGroup of an app.
-func (a App) GetANRGroupsWithFilter(ctx context.Context, af *filter.AppFilter) (groups []group.ANRGroup, err error) {
+// GetSimilarExceptionGroups finds exception groups of the app
+// similar to the exception group by comparing normalized stack
+// frames of each group's most recent exception event. Only
+// unmerged groups of the same kind are considered.
+func (a App) GetSimilarExceptionGroups(ctx context.Context, exceptionGroup *group.ExceptionGroup) (similar []group.SimilarGroup, err error) {
 	stmt := sqlf.PostgreSQL.
-		From("public.anr_groups").
+		From("public.unhandled_exception_groups").
 		Select("id").
-		Select("app_id").
 		Select(`type`).
 		Select(`message`).
 		Select(`method_name`).
 		Select(`file_name`).
 		Select(`line_number`).
 		Select("fingerprint").
-		Select("first_event_timestamp").
-		Select("created_at").
-		Select("updated_at").
-		Where("app_id = ?", a.ID)
+		Where("app_id = ?", a.ID).
+		Where("handled = ?", exceptionGroup.Handled).
+		Where("merged_into is null").
+		Where("id != ?", exceptionGroup.ID)
 
 	defer stmt.Close()
 
 	rows, _ := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
-	groups, err = pgx.CollectRows(rows, pgx.RowToStructByNameLax[group.ANRGroup])
+	candidates, err := pgx.CollectRows(rows, pgx.RowToStructByNameLax[group.SimilarGroup])
 	if err != nil {
 		return
 	}
 
-	var anrGroup *group.ANRGroup
-	for i := range groups {
-		anrGroup = &groups[i]
-
-		eventDataStmt := sqlf.
-			From("events").
-			Select("distinct id").
-			Clause("prewhere app_id = toUUID(?) and anr.fingerprint = ?", af.AppID, anrGroup.Fingerprint).
-			Where("type = ?", event.TypeANR)
-
-		defer eventDataStmt.Close()
+	if len(candidates) == 0 {
+		return
+	}
 
-		if af.HasUDExpression() && !af.UDExpression.Empty() {
-			subQuery := sqlf.From("user_def_attrs").
-				Select("event_id id").
-				Where("app_id = toUUID(?)", af.AppID)
-			af.UDExpression.Augment(subQuery)
-			eventDataStmt.Clause("AND id in").SubQuery("(", ")", subQuery)
-		}
+	fingerprints := []string{exceptionGroup.Fingerprint}
+	for i := range candidates {
+		fingerprints = append(fingerprints, candidates[i].Fingerprint)
+	}
 
-		eventDataStmt.GroupBy("id")
+	units, err := GetIssueExceptionUnits(ctx, a.ID, event.TypeException, exceptionGroup.Handled, fingerprints)
+	if err != nil {
+		return
+	}
 
-		if len(af.Versions) > 0 {
-			eventDataStmt.Where("attribute.app_version").In(af.Versions)
-		}
+	similar = computeSimilarGroups(units, exceptionGroup.Fingerprint, candidates)
 
-		if len(af.VersionCodes) > 0 {
-			eventDataStmt.Where("attribute.app_build").In(af.VersionCodes)
-		}
+	return
+}
 
-		if len(af.OsNames) > 0 {
-			eventDataStmt.Where("attribute.os_name").In(af.OsNames)
-		}
+// GetSimilarANRGroups finds ANR groups of the app similar
+// to the ANR group by comparing normalized stack frames of
+// each group's most recent ANR event. Only unmerged groups
+// are considered.
+func (a App) GetSimilarANRGroups(ctx context.Context, anrGroup *group.ANRGroup) (similar []group.SimilarGroup, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.anr_groups").
+		Select("id").
+		Select(`type`).
+		Select(`message`).
+		Select(`method_name`).
+		Select(`file_name`).
+		Select(`line_number`).
+		Select("fingerprint").
+		Where("app_id = ?", a.ID).
+		Where("merged_into is null").
+		Where("id != ?", anrGroup.ID)
 
-		if len(af.OsVersions) > 0 {
-			eventDataStmt.Where("attribute.os_version").In(af.OsVersions)
-		}
+	defer stmt.Close()
 
-		if len(af.Countries) > 0 {
-			eventDataStmt.Where("inet.country_code").In(af.Countries)
-		}
+	rows, _ := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	candidates, err := pgx.CollectRows(rows, pgx.RowToStructByNameLax[group.SimilarGroup])
+	if err != nil {
+		return
+	}
 
-		if len(af.DeviceNames) > 0 {
-			eventDataStmt.Where("attribute.device_name").In(af.DeviceNames)
-		}
+	if len(candidates) == 0 {
+		return
+	}
 
-		if len(af.DeviceManufacturers) > 0 {
-			eventDataStmt.Where("attribute.device_manufacturer").In(af.DeviceManufacturers)
-		}
+	fingerprints := []string{anrGroup.Fingerprint}
+	for i := range candidates {
+		fingerprints = append(fingerprints, candidates[i].Fingerprint)
+	}
 
-		if len(af.Locales) > 0 {
-			eventDataStmt.Where("attribute.device_locale").In(af.Locales)
-		}
+	units, err := GetIssueExceptionUnits(ctx, a.ID, event.TypeANR, false, fingerprints)
+	if err != nil {
+		return
+	}
 
-		if len(af.NetworkProviders) > 0 {
-			eventDataStmt.Where("attribute.network_provider").In(af.NetworkProviders)
-		}
+	similar = computeSimilarGroups(units, anrGroup.Fingerprint, candidates)
 
-		if len(af.NetworkTypes) > 0 {
-			eventDataStmt.Where("attribute.network_type").In(af.NetworkTypes)
-		}
+	return
+}
 
-		if len(af.NetworkGenerations) > 0 {
-			eventDataStmt.Where("attribute.network_generation").In(af.NetworkGenerations)
-		}
+// computeSimilarGroups scores each candidate against the
+// issue group's frames and returns the most similar
+// candidates in descending order of their score.
+func computeSimilarGroups(units map[string]event.ExceptionUnits, fingerprint string, candidates []group.SimilarGroup) (similar []group.SimilarGroup) {
+	frames := group.NormalizeFrames(units[fingerprint])
+	if len(frames) == 0 {
+		return
+	}
 
-		if af.HasTimeRange() {
-			eventDataStmt.Where("timestamp >= ? and timestamp <= ?", af.From, af.To)
+	for i := range candidates {
+		candidateUnits, ok := units[candidates[i].Fingerprint]
+		if !ok {
+			continue
 		}
 
-		rows, err := server.Server.ChPool.Query(ctx, eventDataStmt.String(), eventDataStmt.Args()...)
-		if err != nil {
-			return nil, err
+		score, jaccard, edit := group.ComputeSimilarity(frames, group.NormalizeFrames(candidateUnits))
+		if score < group.MinSimilarityScore {
+			continue
 		}
 
-		defer rows.Close()
-
-		var ids []uuid.UUID
-		for rows.Next() {
-			var id uuid.UUID
-			if err := rows.Scan(&id); err != nil {
-				return nil, err
-			}
-
-			ids = append(ids, id)
-		}
+		candidates[i].Score = score
+		candidates[i].Jaccard = jaccard
+		candidates[i].EditSimilarity = edit
+		similar = append(similar, candidates[i])
+	}
 
-		if rows.Err() != nil {
-			return nil, err
-		}
+	group.SortSimilarGroups(similar)
 
-		anrGroup.EventIDs = ids
-		anrGroup.Count = len(ids)
+	if len(similar) > group.MaxSimilarGroups {
+		similar = similar[:group.MaxSimilarGroups]
 	}
 
 	return
@@ -833,31 +783,92 @@ func (a App) GetIssueFreeMetrics(
 	return
 }
 
-// GetAdoptionMetrics computes adoption by computing sessions
-// for selected versions and sessions of all versions for an app.
-func (a App) GetAdoptionMetrics(ctx context.Context, af *filter.AppFilter) (adoption *metrics.SessionAdoption, err error) {
-	adoption = &metrics.SessionAdoption{}
+// GetIssueFreeUserMetrics computes crash and ANR free users
+// percentage of selected app versions and its deltas. Users
+// are identified by user id, falling back to installation id.
+// Deltas are computed against the previous period of the same
+// length immediately before the selected time range.
+func (a App) GetIssueFreeUserMetrics(ctx context.Context, af *filter.AppFilter) (crashFree *metrics.CrashFreeUser, anrFree *metrics.ANRFreeUser, err error) {
+	crashFree = &metrics.CrashFreeUser{}
+	anrFree = &metrics.ANRFreeUser{}
+
 	selectedVersions, err := af.VersionPairs()
 	if err != nil {
 		return
 	}
 
+	previousFrom := af.From.Add(-af.To.Sub(af.From))
+
 	stmt := sqlf.From("app_metrics").
-		Select("uniqMergeIf(unique_sessions, app_version in (?)) as selected_sessions", selectedVersions.Parameterize()).
-		Select("uniqMerge(unique_sessions) as all_sessions").
-		Select("round((selected_sessions / all_sessions) * 100, 2) as adoption").
+		Select("uniqMergeIf(unique_users, timestamp >= ?) as users", af.From).
+		Select("uniqMergeIf(crash_users, timestamp >= ?) as crash_users", af.From).
+		Select("uniqMergeIf(anr_users, timestamp >= ?) as anr_users", af.From).
+		Select("uniqMergeIf(unique_users, timestamp < ?) as previous_users", af.From).
+		Select("uniqMergeIf(crash_users, timestamp < ?) as previous_crash_users", af.From).
+		Select("uniqMergeIf(anr_users, timestamp < ?) as previous_anr_users", af.From).
 		Where("app_id = toUUID(?)", af.AppID).
-		Where("timestamp >= ? and timestamp <= ?", af.From, af.To)
+		Where("timestamp >= ? and timestamp <= ?", previousFrom, af.To)
 
 	defer stmt.Close()
 
-	if err = server.Server.ChPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&adoption.SelectedVersion, &adoption.AllVersions, &adoption.Adoption); err != nil {
+	if len(af.Versions) > 0 {
+		stmt.Where("app_version in (?)", selectedVersions.Parameterize())
+	}
+
+	var (
+		users, previousUsers           uint64
+		crashUsers, previousCrashUsers uint64
+		anrUsers, previousANRUsers     uint64
+	)
+
+	if err = server.Server.ChPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(
+		&users,
+		&crashUsers,
+		&anrUsers,
+		&previousUsers,
+		&previousCrashUsers,
+		&previousANRUsers,
+	); err != nil {
 		return
 	}
 
-	adoption.SetNaNs()
+	crashFree.CrashFreeUsers = metrics.IssueFree(users, crashUsers)
+	crashFree.Delta = metrics.Delta(crashFree.CrashFreeUsers, metrics.IssueFree(previousUsers, previousCrashUsers))
 
-	return
+	anrFree.ANRFreeUsers = metrics.IssueFree(users, anrUsers)
+	anrFree.Delta = metrics.Delta(anrFree.ANRFreeUsers, metrics.IssueFree(previousUsers, previousANRUsers))
+
+	crashFree.SetNaNs()
+	anrFree.SetNaNs()
+
+	return
+}
+
+// GetAdoptionMetrics computes adoption by computing sessions
+// for selected versions and sessions of all versions for an app.
+func (a App) GetAdoptionMetrics(ctx context.Context, af *filter.AppFilter) (adoption *metrics.SessionAdoption, err error) {
+	adoption = &metrics.SessionAdoption{}
+	selectedVersions, err := af.VersionPairs()
+	if err != nil {
+		return
+	}
+
+	stmt := sqlf.From("app_metrics").
+		Select("uniqMergeIf(unique_sessions, app_version in (?)) as selected_sessions", selectedVersions.Parameterize()).
+		Select("uniqMerge(unique_sessions) as all_sessions").
+		Select("round((selected_sessions / all_sessions) * 100, 2) as adoption").
+		Where("app_id = toUUID(?)", af.AppID).
+		Where("timestamp >= ? and timestamp <= ?", af.From, af.To)
+
+	defer stmt.Close()
+
+	if err = server.Server.ChPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&adoption.SelectedVersion, &adoption.AllVersions, &adoption.Adoption); err != nil {
+		return
+	}
+
+	adoption.SetNaNs()
+
+	return
 }
 
 // GetLaunchMetrics computes cold, warm and hot launch quantiles
@@ -928,6 +939,8 @@ func (a App) getJourneyEvents(ctx context.Context, af *filter.AppFilter, opts fi
 		whereVals = append(whereVals, event.TypeException, false, event.TypeANR)
 	} else if opts.Exceptions {
 		whereVals = append(whereVals, event.TypeException, false)
+	} else if opts.NonFatals {
+		whereVals = append(whereVals, event.TypeException, true)
 	} else if opts.ANRs {
 		whereVals = append(whereVals, event.TypeANR)
 	}
@@ -944,6 +957,8 @@ func (a App) getJourneyEvents(ctx context.Context, af *filter.AppFilter, opts fi
 		Select(`toString(lifecycle_fragment.class_name)`).
 		Select(`toString(lifecycle_fragment.parent_activity)`).
 		Select(`toString(lifecycle_fragment.parent_fragment)`).
+		Select(`toString(exception.fingerprint)`).
+		Select(`toString(anr.fingerprint)`).
 		Where(`app_id = ?`, a.ID).
 		Where("`timestamp` >= ? and `timestamp` <= ?", af.From, af.To)
 
@@ -957,7 +972,7 @@ func (a App) getJourneyEvents(ctx context.Context, af *filter.AppFilter, opts fi
 
 	if opts.All {
 		stmt.Where("((type = ? and `lifecycle_activity.type` in ?) or (type = ? and `lifecycle_fragment.type` in ?) or ((type = ? and `exception.handled` = ?) or type = ?))", whereVals...)
-	} else if opts.Exceptions {
+	} else if opts.Exceptions || opts.NonFatals {
 		stmt.Where("((type = ? and `lifecycle_activity.type` in ?) or (type = ? and `lifecycle_fragment.type` in ?) or (type = ? and `exception.handled` = ?))", whereVals...)
 	} else if opts.ANRs {
 		stmt.Where("((type = ? and `lifecycle_activity.type` in ?) or (type = ? and `lifecycle_fragment.type` in ?) or (type = ?))", whereVals...)
@@ -1016,6 +1031,8 @@ func (a App) getJourneyEvents(ctx context.Context, af *filter.AppFilter, opts fi
 		var lifecycleFragmentClassName string
 		var lifecycleFragmentParentActivity string
 		var lifecycleFragmentParentFragment string
+		var exceptionFingerprint string
+		var anrFingerprint string
 
 		dest := []any{
 			&ev.ID,
@@ -1028,6 +1045,8 @@ func (a App) getJourneyEvents(ctx context.Context, af *filter.AppFilter, opts fi
 			&lifecycleFragmentClassName,
 			&lifecycleFragmentParentActivity,
 			&lifecycleFragmentParentFragment,
+			&exceptionFingerprint,
+			&anrFingerprint,
 		}
 
 		if err := rows.Scan(dest...); err != nil {
@@ -1047,9 +1066,14 @@ func (a App) getJourneyEvents(ctx context.Context, af *filter.AppFilter, opts fi
 				ParentFragment: lifecycleFragmentParentFragment,
 			}
 		} else if ev.IsException() {
-			ev.Exception = &event.Exception{}
+			ev.Exception = &event.Exception{
+				Handled:     opts.NonFatals,
+				Fingerprint: exceptionFingerprint,
+			}
 		} else if ev.IsANR() {
-			ev.ANR = &event.ANR{}
+			ev.ANR = &event.ANR{
+				Fingerprint: anrFingerprint,
+			}
 		}
 
 		events = append(events, ev)
@@ -1062,6 +1086,28 @@ func (a App) getJourneyEvents(ctx context.Context, af *filter.AppFilter, opts fi
 	return
 }
 
+// issueEventIDs picks ids of exception & ANR
+// events matching any of the fingerprints.
+func issueEventIDs(events []event.EventField, fingerprints []string) (ids []uuid.UUID) {
+	for i := range events {
+		var fingerprint string
+		switch {
+		case events[i].IsException():
+			fingerprint = events[i].Exception.Fingerprint
+		case events[i].IsANR():
+			fingerprint = events[i].ANR.Fingerprint
+		default:
+			continue
+		}
+
+		if slices.Contains(fingerprints, fingerprint) {
+			ids = append(ids, events[i].ID)
+		}
+	}
+
+	return
+}
+
 func (a *App) add() (*APIKey, error) {
 	id := uuid.New()
 	a.ID = &id
@@ -2314,6 +2360,16 @@ func GetAppMetrics(c *gin.Context) {
 		return
 	}
 
+	crashFreeUsers, anrFreeUsers, err := app.GetIssueFreeUserMetrics(ctx, &af)
+	if err != nil {
+		msg := `failed to fetch issue free user metrics`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
 	launch, err := app.GetLaunchMetrics(ctx, &af)
 	if err != nil {
 		msg := `failed to fetch launch metrics`
@@ -2324,6 +2380,36 @@ func GetAppMetrics(c *gin.Context) {
 		return
 	}
 
+	coldDistribution, err := app.GetLaunchDistribution(ctx, &af, event.TypeColdLaunch)
+	if err != nil {
+		msg := `failed to fetch cold launch distribution`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	warmDistribution, err := app.GetLaunchDistribution(ctx, &af, event.TypeWarmLaunch)
+	if err != nil {
+		msg := `failed to fetch warm launch distribution`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	hotDistribution, err := app.GetLaunchDistribution(ctx, &af, event.TypeHotLaunch)
+	if err != nil {
+		msg := `failed to fetch hot launch distribution`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
 	var sizes *metrics.SizeMetric = nil
 	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 && !af.HasMultiVersions() {
 		sizes, err = app.GetSizeMetrics(ctx, &af, excludedVersions)
@@ -2339,19 +2425,22 @@ func GetAppMetrics(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"cold_launch": gin.H{
-			"p95":   launch.ColdLaunchP95,
-			"delta": launch.ColdDelta,
-			"nan":   launch.ColdNaN,
+			"p95":          launch.ColdLaunchP95,
+			"delta":        launch.ColdDelta,
+			"nan":          launch.ColdNaN,
+			"distribution": coldDistribution,
 		},
 		"warm_launch": gin.H{
-			"p95":   launch.WarmLaunchP95,
-			"delta": launch.WarmDelta,
-			"nan":   launch.WarmNaN,
+			"p95":          launch.WarmLaunchP95,
+			"delta":        launch.WarmDelta,
+			"nan":          launch.WarmNaN,
+			"distribution": warmDistribution,
 		},
 		"hot_launch": gin.H{
-			"p95":   launch.HotLaunchP95,
-			"delta": launch.HotDelta,
-			"nan":   launch.HotNaN,
+			"p95":          launch.HotLaunchP95,
+			"delta":        launch.HotDelta,
+			"nan":          launch.HotNaN,
+			"distribution": hotDistribution,
 		},
 		"adoption":                      adoption,
 		"sizes":                         sizes,
@@ -2359,6 +2448,8 @@ func GetAppMetrics(c *gin.Context) {
 		"anr_free_sessions":             anrFree,
 		"perceived_crash_free_sessions": perceivedCrashFree,
 		"perceived_anr_free_sessions":   perceivedANRFree,
+		"crash_free_users":              crashFreeUsers,
+		"anr_free_users":                anrFreeUsers,
 	})
 }
 
@@ -2493,21 +2584,1202 @@ func GetAppFilters(c *gin.Context) {
 		udAttrs["key_types"] = fl.UDKeyTypes
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"versions":             versions,
-		"os_versions":          osVersions,
-		"countries":            fl.Countries,
-		"network_providers":    fl.NetworkProviders,
-		"network_types":        fl.NetworkTypes,
-		"network_generations":  fl.NetworkGenerations,
-		"locales":              fl.DeviceLocales,
-		"device_manufacturers": fl.DeviceManufacturers,
-		"device_names":         fl.DeviceNames,
-		"ud_attrs":             udAttrs,
-	})
+	c.JSON(http.StatusOK, gin.H{
+		"versions":             versions,
+		"os_versions":          osVersions,
+		"countries":            fl.Countries,
+		"network_providers":    fl.NetworkProviders,
+		"network_types":        fl.NetworkTypes,
+		"network_generations":  fl.NetworkGenerations,
+		"locales":              fl.DeviceLocales,
+		"device_manufacturers": fl.DeviceManufacturers,
+		"device_names":         fl.DeviceNames,
+		"ud_attrs":             udAttrs,
+	})
+}
+
+func GetCrashOverview(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse query parameters`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := "crash overview request validation failed"
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	groups, err := app.GetExceptionGroupsWithFilter(ctx, &af, false)
+	if err != nil {
+		msg := "failed to get app's exception groups with filter"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	var crashGroups []group.ExceptionGroup
+	for i := range groups {
+		// only consider those groups that have at least 1 exception
+		// event
+		if groups[i].Count > 0 {
+			crashGroups = append(crashGroups, groups[i])
+		}
+	}
+
+	group.ComputeCrashContribution(crashGroups)
+	group.SortExceptionGroups(crashGroups)
+	crashGroups, next, previous := paginate.Paginate(crashGroups, &af)
+	meta := gin.H{"next": next, "previous": previous}
+
+	crashFreeUsers, _, err := app.GetIssueFreeUserMetrics(ctx, &af)
+	if err != nil {
+		msg := "failed to get app's crash free users"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results":          crashGroups,
+		"meta":             meta,
+		"crash_free_users": crashFreeUsers,
+	})
+}
+
+func GetCrashOverviewPlotInstances(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse query parameters`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	anomalyOpts, err := plotAnomalyOptions(c)
+	if err != nil {
+		msg := `failed to parse anomaly detection method`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := `crash overview request validation failed`
+
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	crashInstances, err := GetExceptionPlotInstances(ctx, &af, false)
+	if err != nil {
+		msg := `failed to query exception instances`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	type instance struct {
+		ID        string        `json:"id"`
+		Data      []gin.H       `json:"data"`
+		Anomalies []PlotAnomaly `json:"anomalies,omitempty"`
+	}
+
+	lut := make(map[string]int)
+	var instances []instance
+
+	for i := range crashInstances {
+		instance := instance{
+			ID: crashInstances[i].Version,
+			Data: []gin.H{{
+				"datetime":            crashInstances[i].DateTime,
+				"instances":           crashInstances[i].Instances,
+				"crash_free_sessions": crashInstances[i].IssueFreeSessions,
+			}},
+		}
+
+		ndx, ok := lut[crashInstances[i].Version]
+
+		if ok {
+			instances[ndx].Data = append(instances[ndx].Data, instance.Data...)
+		} else {
+			instances = append(instances, instance)
+			lut[crashInstances[i].Version] = len(instances) - 1
+		}
+	}
+
+	if anomalyOpts != nil {
+		points := make(map[string][]plotPoint)
+		for i := range crashInstances {
+			points[crashInstances[i].Version] = append(points[crashInstances[i].Version], plotPoint{
+				datetime: crashInstances[i].DateTime,
+				value:    crashInstances[i].IssueFreeSessions,
+				volume:   crashInstances[i].Sessions,
+			})
+		}
+
+		for i := range instances {
+			instances[i].Anomalies = detectPlotAnomalies(*anomalyOpts, "crash_free_sessions", points[instances[i].ID])
+		}
+	}
+
+	c.JSON(http.StatusOK, instances)
+}
+
+func GetCrashDetailCrashes(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	crashGroupId, err := uuid.Parse(c.Param("crashGroupId"))
+	if err != nil {
+		msg := `crash group id is invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse query parameters`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := "app filters request validation failed"
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	app := App{
+		ID: &id,
+	}
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	group, err := app.GetExceptionGroup(ctx, crashGroupId)
+	if err != nil {
+		msg := fmt.Sprintf("failed to get exception group with id %q", crashGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if group == nil || group.Handled {
+		msg := fmt.Sprintf("no crash group found with id %q", crashGroupId)
+		fmt.Println(msg)
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	eventExceptions, next, previous, err := GetExceptionsWithFilter(ctx, group, &af)
+	if err != nil {
+		msg := `failed to get exception group's exception events`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	// set appropriate attachment URLs
+	for i := range eventExceptions {
+		if len(eventExceptions[i].Attachments) > 0 {
+			for j := range eventExceptions[i].Attachments {
+				if err := eventExceptions[i].Attachments[j].PreSignURL(); err != nil {
+					msg := `failed to generate URLs for attachment`
+					fmt.Println(msg, err)
+					c.JSON(http.StatusInternalServerError, gin.H{
+						"error": msg,
+					})
+					return
+				}
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": eventExceptions,
+		"meta": gin.H{
+			"next":     next,
+			"previous": previous,
+		},
+	})
+}
+
+func GetCrashDetailPlotInstances(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	crashGroupId, err := uuid.Parse(c.Param("crashGroupId"))
+	if err != nil {
+		msg := `crash group id is invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse query parameters`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := "app filters request validation failed"
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	app := App{
+		ID: &id,
+	}
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	group, err := app.GetExceptionGroup(ctx, crashGroupId)
+	if err != nil {
+		msg := fmt.Sprintf("failed to get exception group with id %q", crashGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if group == nil || group.Handled {
+		msg := fmt.Sprintf("no crash group found with id %q", crashGroupId)
+		fmt.Println(msg)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	crashInstances, err := GetIssuesPlot(ctx, group, &af)
+	if err != nil {
+		msg := `failed to query data for crash instances plot`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	type instance struct {
+		ID   string  `json:"id"`
+		Data []gin.H `json:"data"`
+	}
+
+	lut := make(map[string]int)
+	var instances []instance
+
+	for i := range crashInstances {
+		instance := instance{
+			ID: crashInstances[i].Version,
+			Data: []gin.H{{
+				"datetime":  crashInstances[i].DateTime,
+				"instances": crashInstances[i].Instances,
+			}},
+		}
+
+		ndx, ok := lut[crashInstances[i].Version]
+
+		if ok {
+			instances[ndx].Data = append(instances[ndx].Data, instance.Data...)
+		} else {
+			instances = append(instances, instance)
+			lut[crashInstances[i].Version] = len(instances) - 1
+		}
+	}
+
+	c.JSON(http.StatusOK, instances)
+}
+
+func GetCrashDetailAttributeDistribution(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	crashGroupId, err := uuid.Parse(c.Param("crashGroupId"))
+	if err != nil {
+		msg := `crash group id is invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse query parameters`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := "app filters request validation failed"
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	app := App{
+		ID: &id,
+	}
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	group, err := app.GetExceptionGroup(ctx, crashGroupId)
+	if err != nil {
+		msg := fmt.Sprintf("failed to get exception group with id %q", crashGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if group == nil || group.Handled {
+		msg := fmt.Sprintf("no crash group found with id %q", crashGroupId)
+		fmt.Println(msg)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	distribution, err := GetIssuesAttributeDistribution(ctx, group, &af)
+	if err != nil {
+		msg := `failed to query data for crash distribution plot`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, distribution)
+}
+
+func GetCrashDetailPlotJourney(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	crashGroupId, err := uuid.Parse(c.Param("crashGroupId"))
+	if err != nil {
+		msg := `crash group id is invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse query parameters`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := `crash detail journey plot request validation failed`
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	exceptionGroup, err := app.GetExceptionGroup(ctx, crashGroupId)
+	if err != nil {
+		msg := fmt.Sprintf("failed to get exception group with id %q", crashGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if exceptionGroup == nil || exceptionGroup.Handled {
+		msg := fmt.Sprintf("no crash group found with id %q", crashGroupId)
+		fmt.Println(msg)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	journeyEvents, err := app.getJourneyEvents(ctx, &af, filter.JourneyOpts{
+		Exceptions: true,
+	})
+	if err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	// bind the group to its events
+	// within the journey
+	exceptionGroup.EventIDs = issueEventIDs(journeyEvents, exceptionGroup.GetFingerprints())
+
+	journeyAndroid := journey.NewJourneyAndroid(journeyEvents, &journey.Options{
+		BiGraph:        af.BiGraph,
+		ExceptionGroup: exceptionGroup,
+	})
+
+	if err := journeyAndroid.SetNodeExceptionGroups(func(eventIds []uuid.UUID) (exceptionGroups []group.ExceptionGroup, err error) {
+		exceptionGroups = []group.ExceptionGroup{*exceptionGroup}
+		return
+	}); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	type Link struct {
+		Source string `json:"source"`
+		Target string `json:"target"`
+		Value  int    `json:"value"`
+	}
+
+	type Issue struct {
+		ID    uuid.UUID `json:"id"`
+		Title string    `json:"title"`
+		Count int       `json:"count"`
+	}
+
+	type Node struct {
+		ID     string `json:"id"`
+		Issues gin.H  `json:"issues"`
+	}
+
+	var nodes []Node
+	var links []Link
+
+	for v := range journeyAndroid.Graph.Order() {
+		journeyAndroid.Graph.Visit(v, func(w int, c int64) bool {
+			var link Link
+			link.Source = journeyAndroid.GetNodeName(v)
+			link.Target = journeyAndroid.GetNodeName(w)
+			link.Value = journeyAndroid.GetEdgeSessionCount(v, w)
+			links = append(links, link)
+			return false
+		})
+	}
+
+	for _, v := range journeyAndroid.GetNodeVertices() {
+		var node Node
+		name := journeyAndroid.GetNodeName(v)
+		exceptionGroups := journeyAndroid.GetNodeExceptionGroups(name)
+		crashes := []Issue{}
+
+		for i := range exceptionGroups {
+			issue := Issue{
+				ID:    exceptionGroups[i].ID,
+				Title: exceptionGroups[i].GetDisplayTitle(),
+				Count: journeyAndroid.GetNodeExceptionCount(v, exceptionGroups[i].ID),
+			}
+			if issue.Count > 0 {
+				crashes = append(crashes, issue)
+			}
+		}
+
+		sort.Slice(crashes, func(i, j int) bool {
+			return crashes[i].Count > crashes[j].Count
+		})
+
+		node.ID = name
+		node.Issues = gin.H{
+			"crashes": crashes,
+		}
+		nodes = append(nodes, node)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"totalIssues": len(exceptionGroup.EventIDs),
+		"nodes":       nodes,
+		"links":       links,
+	})
+}
+
+func GetCrashDetailSimilar(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	crashGroupId, err := uuid.Parse(c.Param("crashGroupId"))
+	if err != nil {
+		msg := `crash group id is invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &id,
+	}
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	exceptionGroup, err := app.GetExceptionGroup(ctx, crashGroupId)
+	if err != nil {
+		msg := fmt.Sprintf("failed to get crash group with id %q", crashGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if exceptionGroup == nil || exceptionGroup.Handled {
+		msg := fmt.Sprintf("no crash group found with id %q", crashGroupId.String())
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	similar, err := app.GetSimilarExceptionGroups(ctx, exceptionGroup)
+	if err != nil {
+		msg := fmt.Sprintf("failed to get similar crash groups for id %q", crashGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if similar == nil {
+		similar = []group.SimilarGroup{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": similar,
+	})
+}
+
+func MergeCrashGroups(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	crashGroupId, err := uuid.Parse(c.Param("crashGroupId"))
+	if err != nil {
+		msg := `crash group id is invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var payload struct {
+		GroupIDs []uuid.UUID `json:"group_ids" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		msg := `failed to parse crash group merge json payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	if len(payload.GroupIDs) == 0 {
+		msg := `group_ids must contain at least one crash group id`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &id,
+	}
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAppAll)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to modify app in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	exceptionGroup, err := app.GetExceptionGroup(ctx, crashGroupId)
+	if err != nil {
+		msg := fmt.Sprintf("failed to get crash group with id %q", crashGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if exceptionGroup == nil || exceptionGroup.Handled {
+		msg := fmt.Sprintf("no crash group found with id %q", crashGroupId.String())
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	if exceptionGroup.MergedInto != nil {
+		msg := fmt.Sprintf("crash group %q is already merged into %q", crashGroupId.String(), exceptionGroup.MergedInto.String())
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := exceptionGroup.Merge(ctx, payload.GroupIDs, nil); err != nil {
+		msg := fmt.Sprintf("failed to merge crash groups into %q", crashGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": "done"})
 }
 
-func GetCrashOverview(c *gin.Context) {
+func GetNonFatalOverview(c *gin.Context) {
 	ctx := c.Request.Context()
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -2548,7 +3820,7 @@ func GetCrashOverview(c *gin.Context) {
 		return
 	}
 
-	msg := "crash overview request validation failed"
+	msg := "non-fatal overview request validation failed"
 	if err := af.Validate(); err != nil {
 		fmt.Println(msg, err)
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -2622,9 +3894,9 @@ func GetCrashOverview(c *gin.Context) {
 		return
 	}
 
-	groups, err := app.GetExceptionGroupsWithFilter(ctx, &af)
+	groups, err := app.GetExceptionGroupsWithFilter(ctx, &af, true)
 	if err != nil {
-		msg := "failed to get app's exception groups with filter"
+		msg := "failed to get app's non-fatal exception groups with filter"
 		fmt.Println(msg, err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": msg,
@@ -2632,31 +3904,27 @@ func GetCrashOverview(c *gin.Context) {
 		return
 	}
 
-	var crashGroups []group.ExceptionGroup
+	var nonFatalGroups []group.ExceptionGroup
 	for i := range groups {
 		// only consider those groups that have at least 1 exception
 		// event
 		if groups[i].Count > 0 {
-			// omit `event_ids` field from JSON
-			// response, because these can get really huge
-			groups[i].EventIDs = nil
-
-			crashGroups = append(crashGroups, groups[i])
+			nonFatalGroups = append(nonFatalGroups, groups[i])
 		}
 	}
 
-	group.ComputeCrashContribution(crashGroups)
-	group.SortExceptionGroups(crashGroups)
-	crashGroups, next, previous := paginate.Paginate(crashGroups, &af)
+	group.ComputeCrashContribution(nonFatalGroups)
+	group.SortExceptionGroups(nonFatalGroups)
+	nonFatalGroups, next, previous := paginate.Paginate(nonFatalGroups, &af)
 	meta := gin.H{"next": next, "previous": previous}
 
 	c.JSON(http.StatusOK, gin.H{
-		"results": crashGroups,
+		"results": nonFatalGroups,
 		"meta":    meta,
 	})
 }
 
-func GetCrashOverviewPlotInstances(c *gin.Context) {
+func GetNonFatalOverviewPlotInstances(c *gin.Context) {
 	ctx := c.Request.Context()
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -2697,7 +3965,7 @@ func GetCrashOverviewPlotInstances(c *gin.Context) {
 		return
 	}
 
-	msg := `crash overview request validation failed`
+	msg := `non-fatal overview request validation failed`
 
 	if err := af.Validate(); err != nil {
 		fmt.Println(msg, err)
@@ -2762,9 +4030,9 @@ func GetCrashOverviewPlotInstances(c *gin.Context) {
 		return
 	}
 
-	crashInstances, err := GetExceptionPlotInstances(ctx, &af)
+	nonFatalInstances, err := GetExceptionPlotInstances(ctx, &af, true)
 	if err != nil {
-		msg := `failed to query exception instances`
+		msg := `failed to query non-fatal exception instances`
 		fmt.Println(msg, err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": msg,
@@ -2780,30 +4048,30 @@ func GetCrashOverviewPlotInstances(c *gin.Context) {
 	lut := make(map[string]int)
 	var instances []instance
 
-	for i := range crashInstances {
+	for i := range nonFatalInstances {
 		instance := instance{
-			ID: crashInstances[i].Version,
+			ID: nonFatalInstances[i].Version,
 			Data: []gin.H{{
-				"datetime":            crashInstances[i].DateTime,
-				"instances":           crashInstances[i].Instances,
-				"crash_free_sessions": crashInstances[i].IssueFreeSessions,
+				"datetime":                nonFatalInstances[i].DateTime,
+				"instances":               nonFatalInstances[i].Instances,
+				"non_fatal_free_sessions": nonFatalInstances[i].IssueFreeSessions,
 			}},
 		}
 
-		ndx, ok := lut[crashInstances[i].Version]
+		ndx, ok := lut[nonFatalInstances[i].Version]
 
 		if ok {
 			instances[ndx].Data = append(instances[ndx].Data, instance.Data...)
 		} else {
 			instances = append(instances, instance)
-			lut[crashInstances[i].Version] = len(instances) - 1
+			lut[nonFatalInstances[i].Version] = len(instances) - 1
 		}
 	}
 
 	c.JSON(http.StatusOK, instances)
 }
 
-func GetCrashDetailCrashes(c *gin.Context) {
+func GetNonFatalDetailNonFatals(c *gin.Context) {
 	ctx := c.Request.Context()
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -2813,9 +4081,9 @@ func GetCrashDetailCrashes(c *gin.Context) {
 		return
 	}
 
-	crashGroupId, err := uuid.Parse(c.Param("crashGroupId"))
+	nonFatalGroupId, err := uuid.Parse(c.Param("nonFatalGroupId"))
 	if err != nil {
-		msg := `crash group id is invalid or missing`
+		msg := `non-fatal group id is invalid or missing`
 		fmt.Println(msg, err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 		return
@@ -2904,16 +4172,16 @@ func GetCrashDetailCrashes(c *gin.Context) {
 		return
 	}
 
-	group, err := app.GetExceptionGroup(ctx, crashGroupId)
+	group, err := app.GetExceptionGroup(ctx, nonFatalGroupId)
 	if err != nil {
-		msg := fmt.Sprintf("failed to get exception group with id %q", crashGroupId.String())
+		msg := fmt.Sprintf("failed to get exception group with id %q", nonFatalGroupId.String())
 		fmt.Println(msg, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 		return
 	}
 
-	if group == nil {
-		msg := fmt.Sprintf("no exception group found with id %q", crashGroupId)
+	if group == nil || !group.Handled {
+		msg := fmt.Sprintf("no non-fatal exception group found with id %q", nonFatalGroupId)
 		fmt.Println(msg, err)
 		c.JSON(http.StatusNotFound, gin.H{
 			"error": msg,
@@ -2954,7 +4222,7 @@ func GetCrashDetailCrashes(c *gin.Context) {
 	})
 }
 
-func GetCrashDetailPlotInstances(c *gin.Context) {
+func GetNonFatalDetailPlotInstances(c *gin.Context) {
 	ctx := c.Request.Context()
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -2964,9 +4232,9 @@ func GetCrashDetailPlotInstances(c *gin.Context) {
 		return
 	}
 
-	crashGroupId, err := uuid.Parse(c.Param("crashGroupId"))
+	nonFatalGroupId, err := uuid.Parse(c.Param("nonFatalGroupId"))
 	if err != nil {
-		msg := `crash group id is invalid or missing`
+		msg := `non-fatal group id is invalid or missing`
 		fmt.Println(msg, err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 		return
@@ -3061,17 +4329,24 @@ func GetCrashDetailPlotInstances(c *gin.Context) {
 		return
 	}
 
-	group, err := app.GetExceptionGroup(ctx, crashGroupId)
+	group, err := app.GetExceptionGroup(ctx, nonFatalGroupId)
 	if err != nil {
-		msg := fmt.Sprintf("failed to get exception group with id %q", crashGroupId.String())
+		msg := fmt.Sprintf("failed to get exception group with id %q", nonFatalGroupId.String())
 		fmt.Println(msg, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 		return
 	}
 
-	crashInstances, err := GetIssuesPlot(ctx, group, &af)
+	if group == nil || !group.Handled {
+		msg := fmt.Sprintf("no non-fatal exception group found with id %q", nonFatalGroupId)
+		fmt.Println(msg)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	nonFatalInstances, err := GetIssuesPlot(ctx, group, &af)
 	if err != nil {
-		msg := `failed to query data for crash instances plot`
+		msg := `failed to query data for non-fatal instances plot`
 		fmt.Println(msg, err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": msg,
@@ -3087,29 +4362,29 @@ func GetCrashDetailPlotInstances(c *gin.Context) {
 	lut := make(map[string]int)
 	var instances []instance
 
-	for i := range crashInstances {
+	for i := range nonFatalInstances {
 		instance := instance{
-			ID: crashInstances[i].Version,
+			ID: nonFatalInstances[i].Version,
 			Data: []gin.H{{
-				"datetime":  crashInstances[i].DateTime,
-				"instances": crashInstances[i].Instances,
+				"datetime":  nonFatalInstances[i].DateTime,
+				"instances": nonFatalInstances[i].Instances,
 			}},
 		}
 
-		ndx, ok := lut[crashInstances[i].Version]
+		ndx, ok := lut[nonFatalInstances[i].Version]
 
 		if ok {
 			instances[ndx].Data = append(instances[ndx].Data, instance.Data...)
 		} else {
 			instances = append(instances, instance)
-			lut[crashInstances[i].Version] = len(instances) - 1
+			lut[nonFatalInstances[i].Version] = len(instances) - 1
 		}
 	}
 
 	c.JSON(http.StatusOK, instances)
 }
 
-func GetCrashDetailAttributeDistribution(c *gin.Context) {
+func GetNonFatalDetailAttributeDistribution(c *gin.Context) {
 	ctx := c.Request.Context()
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -3119,9 +4394,9 @@ func GetCrashDetailAttributeDistribution(c *gin.Context) {
 		return
 	}
 
-	crashGroupId, err := uuid.Parse(c.Param("crashGroupId"))
+	nonFatalGroupId, err := uuid.Parse(c.Param("nonFatalGroupId"))
 	if err != nil {
-		msg := `crash group id is invalid or missing`
+		msg := `non-fatal group id is invalid or missing`
 		fmt.Println(msg, err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 		return
@@ -3216,17 +4491,24 @@ func GetCrashDetailAttributeDistribution(c *gin.Context) {
 		return
 	}
 
-	group, err := app.GetExceptionGroup(ctx, crashGroupId)
+	group, err := app.GetExceptionGroup(ctx, nonFatalGroupId)
 	if err != nil {
-		msg := fmt.Sprintf("failed to get exception group with id %q", crashGroupId.String())
+		msg := fmt.Sprintf("failed to get exception group with id %q", nonFatalGroupId.String())
 		fmt.Println(msg, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 		return
 	}
 
+	if group == nil || !group.Handled {
+		msg := fmt.Sprintf("no non-fatal exception group found with id %q", nonFatalGroupId)
+		fmt.Println(msg)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
 	distribution, err := GetIssuesAttributeDistribution(ctx, group, &af)
 	if err != nil {
-		msg := `failed to query data for crash distribution plot`
+		msg := `failed to query data for non-fatal distribution plot`
 		fmt.Println(msg, err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": msg,
@@ -3237,7 +4519,7 @@ func GetCrashDetailAttributeDistribution(c *gin.Context) {
 	c.JSON(http.StatusOK, distribution)
 }
 
-func GetCrashDetailPlotJourney(c *gin.Context) {
+func GetNonFatalDetailPlotJourney(c *gin.Context) {
 	ctx := c.Request.Context()
 	id, err := uuid.Parse(c.Param("id"))
 	if err != nil {
@@ -3247,9 +4529,9 @@ func GetCrashDetailPlotJourney(c *gin.Context) {
 		return
 	}
 
-	crashGroupId, err := uuid.Parse(c.Param("crashGroupId"))
+	nonFatalGroupId, err := uuid.Parse(c.Param("nonFatalGroupId"))
 	if err != nil {
-		msg := `crash group id is invalid or missing`
+		msg := `non-fatal group id is invalid or missing`
 		fmt.Println(msg, err)
 		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
 		return
@@ -3281,7 +4563,7 @@ func GetCrashDetailPlotJourney(c *gin.Context) {
 		return
 	}
 
-	msg := `crash detail journey plot request validation failed`
+	msg := `non-fatal detail journey plot request validation failed`
 	if err := af.Validate(); err != nil {
 		fmt.Println(msg, err)
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -3345,16 +4627,23 @@ func GetCrashDetailPlotJourney(c *gin.Context) {
 		return
 	}
 
-	exceptionGroup, err := app.GetExceptionGroup(ctx, crashGroupId)
+	exceptionGroup, err := app.GetExceptionGroup(ctx, nonFatalGroupId)
 	if err != nil {
-		msg := fmt.Sprintf("failed to get exception group with id %q", crashGroupId.String())
+		msg := fmt.Sprintf("failed to get exception group with id %q", nonFatalGroupId.String())
 		fmt.Println(msg, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 		return
 	}
 
+	if exceptionGroup == nil || !exceptionGroup.Handled {
+		msg := fmt.Sprintf("no non-fatal exception group found with id %q", nonFatalGroupId)
+		fmt.Println(msg)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
 	journeyEvents, err := app.getJourneyEvents(ctx, &af, filter.JourneyOpts{
-		Exceptions: true,
+		NonFatals: true,
 	})
 	if err != nil {
 		fmt.Println(msg, err)
@@ -3364,6 +4653,10 @@ func GetCrashDetailPlotJourney(c *gin.Context) {
 		return
 	}
 
+	// bind the group to its events
+	// within the journey
+	exceptionGroup.EventIDs = issueEventIDs(journeyEvents, exceptionGroup.GetFingerprints())
+
 	journeyAndroid := journey.NewJourneyAndroid(journeyEvents, &journey.Options{
 		BiGraph:        af.BiGraph,
 		ExceptionGroup: exceptionGroup,
@@ -3392,58 +4685,241 @@ func GetCrashDetailPlotJourney(c *gin.Context) {
 		Count int       `json:"count"`
 	}
 
-	type Node struct {
-		ID     string `json:"id"`
-		Issues gin.H  `json:"issues"`
+	type Node struct {
+		ID     string `json:"id"`
+		Issues gin.H  `json:"issues"`
+	}
+
+	var nodes []Node
+	var links []Link
+
+	for v := range journeyAndroid.Graph.Order() {
+		journeyAndroid.Graph.Visit(v, func(w int, c int64) bool {
+			var link Link
+			link.Source = journeyAndroid.GetNodeName(v)
+			link.Target = journeyAndroid.GetNodeName(w)
+			link.Value = journeyAndroid.GetEdgeSessionCount(v, w)
+			links = append(links, link)
+			return false
+		})
+	}
+
+	for _, v := range journeyAndroid.GetNodeVertices() {
+		var node Node
+		name := journeyAndroid.GetNodeName(v)
+		exceptionGroups := journeyAndroid.GetNodeExceptionGroups(name)
+		nonFatals := []Issue{}
+
+		for i := range exceptionGroups {
+			issue := Issue{
+				ID:    exceptionGroups[i].ID,
+				Title: exceptionGroups[i].GetDisplayTitle(),
+				Count: journeyAndroid.GetNodeExceptionCount(v, exceptionGroups[i].ID),
+			}
+			if issue.Count > 0 {
+				nonFatals = append(nonFatals, issue)
+			}
+		}
+
+		sort.Slice(nonFatals, func(i, j int) bool {
+			return nonFatals[i].Count > nonFatals[j].Count
+		})
+
+		node.ID = name
+		node.Issues = gin.H{
+			"non_fatals": nonFatals,
+		}
+		nodes = append(nodes, node)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"totalIssues": len(exceptionGroup.EventIDs),
+		"nodes":       nodes,
+		"links":       links,
+	})
+}
+
+func GetNonFatalDetailSimilar(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	nonFatalGroupId, err := uuid.Parse(c.Param("nonFatalGroupId"))
+	if err != nil {
+		msg := `non-fatal group id is invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &id,
+	}
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	exceptionGroup, err := app.GetExceptionGroup(ctx, nonFatalGroupId)
+	if err != nil {
+		msg := fmt.Sprintf("failed to get non-fatal group with id %q", nonFatalGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if exceptionGroup == nil || !exceptionGroup.Handled {
+		msg := fmt.Sprintf("no non-fatal group found with id %q", nonFatalGroupId.String())
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	similar, err := app.GetSimilarExceptionGroups(ctx, exceptionGroup)
+	if err != nil {
+		msg := fmt.Sprintf("failed to get similar non-fatal groups for id %q", nonFatalGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if similar == nil {
+		similar = []group.SimilarGroup{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": similar,
+	})
+}
+
+func MergeNonFatalGroups(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	nonFatalGroupId, err := uuid.Parse(c.Param("nonFatalGroupId"))
+	if err != nil {
+		msg := `non-fatal group id is invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var payload struct {
+		GroupIDs []uuid.UUID `json:"group_ids" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		msg := `failed to parse non-fatal group merge json payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	if len(payload.GroupIDs) == 0 {
+		msg := `group_ids must contain at least one non-fatal group id`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &id,
+	}
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAppAll)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to modify app in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	exceptionGroup, err := app.GetExceptionGroup(ctx, nonFatalGroupId)
+	if err != nil {
+		msg := fmt.Sprintf("failed to get non-fatal group with id %q", nonFatalGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
 	}
 
-	var nodes []Node
-	var links []Link
-
-	for v := range journeyAndroid.Graph.Order() {
-		journeyAndroid.Graph.Visit(v, func(w int, c int64) bool {
-			var link Link
-			link.Source = journeyAndroid.GetNodeName(v)
-			link.Target = journeyAndroid.GetNodeName(w)
-			link.Value = journeyAndroid.GetEdgeSessionCount(v, w)
-			links = append(links, link)
-			return false
-		})
+	if exceptionGroup == nil || !exceptionGroup.Handled {
+		msg := fmt.Sprintf("no non-fatal group found with id %q", nonFatalGroupId.String())
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
 	}
 
-	for _, v := range journeyAndroid.GetNodeVertices() {
-		var node Node
-		name := journeyAndroid.GetNodeName(v)
-		exceptionGroups := journeyAndroid.GetNodeExceptionGroups(name)
-		crashes := []Issue{}
-
-		for i := range exceptionGroups {
-			issue := Issue{
-				ID:    exceptionGroups[i].ID,
-				Title: exceptionGroups[i].GetDisplayTitle(),
-				Count: journeyAndroid.GetNodeExceptionCount(v, exceptionGroups[i].ID),
-			}
-			if issue.Count > 0 {
-				crashes = append(crashes, issue)
-			}
-		}
-
-		sort.Slice(crashes, func(i, j int) bool {
-			return crashes[i].Count > crashes[j].Count
-		})
+	if exceptionGroup.MergedInto != nil {
+		msg := fmt.Sprintf("non-fatal group %q is already merged into %q", nonFatalGroupId.String(), exceptionGroup.MergedInto.String())
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
 
-		node.ID = name
-		node.Issues = gin.H{
-			"crashes": crashes,
-		}
-		nodes = append(nodes, node)
+	if err := exceptionGroup.Merge(ctx, payload.GroupIDs, nil); err != nil {
+		msg := fmt.Sprintf("failed to merge non-fatal groups into %q", nonFatalGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"totalIssues": len(exceptionGroup.EventIDs),
-		"nodes":       nodes,
-		"links":       links,
-	})
+	c.JSON(http.StatusOK, gin.H{"ok": "done"})
 }
 
 func GetANROverview(c *gin.Context) {
@@ -3562,20 +5038,27 @@ func GetANROverview(c *gin.Context) {
 		// only consider those groups that have at least 1 anr
 		// event
 		if groups[i].Count > 0 {
-			// omit `event_ids` field from JSON
-			// response, because these can get really huge
-			groups[i].EventIDs = nil
-
 			anrGroups = append(anrGroups, groups[i])
 		}
 	}
 
 	group.ComputeANRContribution(anrGroups)
 	group.SortANRGroups(anrGroups)
+	causes := group.CountANRCauses(anrGroups)
 	anrGroups, next, previous := paginate.Paginate(anrGroups, &af)
 	meta := gin.H{"next": next, "previous": previous}
 
-	c.JSON(http.StatusOK, gin.H{"results": anrGroups, "meta": meta})
+	_, anrFreeUsers, err := app.GetIssueFreeUserMetrics(ctx, &af)
+	if err != nil {
+		msg := "failed to get app's ANR free users"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": anrGroups, "meta": meta, "anr_free_users": anrFreeUsers, "anr_causes": causes})
 }
 
 func GetANROverviewPlotInstances(c *gin.Context) {
@@ -3605,6 +5088,17 @@ func GetANROverviewPlotInstances(c *gin.Context) {
 		return
 	}
 
+	anomalyOpts, err := plotAnomalyOptions(c)
+	if err != nil {
+		msg := `failed to parse anomaly detection method`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
 	if err := af.Expand(ctx); err != nil {
 		msg := `failed to expand filters`
 		fmt.Println(msg, err)
@@ -3693,8 +5187,9 @@ func GetANROverviewPlotInstances(c *gin.Context) {
 		return
 	}
 	type instance struct {
-		ID   string  `json:"id"`
-		Data []gin.H `json:"data"`
+		ID        string        `json:"id"`
+		Data      []gin.H       `json:"data"`
+		Anomalies []PlotAnomaly `json:"anomalies,omitempty"`
 	}
 
 	lut := make(map[string]int)
@@ -3720,6 +5215,21 @@ func GetANROverviewPlotInstances(c *gin.Context) {
 		}
 	}
 
+	if anomalyOpts != nil {
+		points := make(map[string][]plotPoint)
+		for i := range anrInstances {
+			points[anrInstances[i].Version] = append(points[anrInstances[i].Version], plotPoint{
+				datetime: anrInstances[i].DateTime,
+				value:    anrInstances[i].IssueFreeSessions,
+				volume:   anrInstances[i].Sessions,
+			})
+		}
+
+		for i := range instances {
+			instances[i].Anomalies = detectPlotAnomalies(*anomalyOpts, "anr_free_sessions", points[instances[i].ID])
+		}
+	}
+
 	c.JSON(http.StatusOK, instances)
 }
 
@@ -4283,6 +5793,10 @@ func GetANRDetailPlotJourney(c *gin.Context) {
 		return
 	}
 
+	// bind the group to its events
+	// within the journey
+	anrGroup.EventIDs = issueEventIDs(journeyEvents, anrGroup.GetFingerprints())
+
 	journeyAndroid := journey.NewJourneyAndroid(journeyEvents, &journey.Options{
 		BiGraph:  af.BiGraph,
 		ANRGroup: anrGroup,
@@ -4336,33 +5850,216 @@ func GetANRDetailPlotJourney(c *gin.Context) {
 		anrGroups := journeyAndroid.GetNodeANRGroups(name)
 		anrs := []Issue{}
 
-		for i := range anrGroups {
-			issue := Issue{
-				ID:    anrGroups[i].ID,
-				Title: anrGroups[i].GetDisplayTitle(),
-				Count: journeyAndroid.GetNodeANRCount(v, anrGroups[i].ID),
-			}
-			if issue.Count > 0 {
-				anrs = append(anrs, issue)
-			}
-		}
+		for i := range anrGroups {
+			issue := Issue{
+				ID:    anrGroups[i].ID,
+				Title: anrGroups[i].GetDisplayTitle(),
+				Count: journeyAndroid.GetNodeANRCount(v, anrGroups[i].ID),
+			}
+			if issue.Count > 0 {
+				anrs = append(anrs, issue)
+			}
+		}
+
+		sort.Slice(anrs, func(i, j int) bool {
+			return anrs[i].Count > anrs[j].Count
+		})
+
+		node.ID = name
+		node.Issues = gin.H{
+			"anrs": anrs,
+		}
+		nodes = append(nodes, node)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"totalIssues": len(anrGroup.EventIDs),
+		"nodes":       nodes,
+		"links":       links,
+	})
+}
+
+func GetANRDetailSimilar(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	anrGroupId, err := uuid.Parse(c.Param("anrGroupId"))
+	if err != nil {
+		msg := `anr group id is invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &id,
+	}
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	anrGroup, err := app.GetANRGroup(ctx, anrGroupId)
+	if err != nil {
+		msg := fmt.Sprintf("failed to get anr group with id %q", anrGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if anrGroup == nil {
+		msg := fmt.Sprintf("no anr group found with id %q", anrGroupId.String())
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	similar, err := app.GetSimilarANRGroups(ctx, anrGroup)
+	if err != nil {
+		msg := fmt.Sprintf("failed to get similar anr groups for id %q", anrGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if similar == nil {
+		similar = []group.SimilarGroup{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": similar,
+	})
+}
+
+func MergeANRGroups(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	anrGroupId, err := uuid.Parse(c.Param("anrGroupId"))
+	if err != nil {
+		msg := `anr group id is invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var payload struct {
+		GroupIDs []uuid.UUID `json:"group_ids" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		msg := `failed to parse anr group merge json payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	if len(payload.GroupIDs) == 0 {
+		msg := `group_ids must contain at least one anr group id`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &id,
+	}
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAppAll)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to modify app in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	anrGroup, err := app.GetANRGroup(ctx, anrGroupId)
+	if err != nil {
+		msg := fmt.Sprintf("failed to get anr group with id %q", anrGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if anrGroup == nil {
+		msg := fmt.Sprintf("no anr group found with id %q", anrGroupId.String())
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
 
-		sort.Slice(anrs, func(i, j int) bool {
-			return anrs[i].Count > anrs[j].Count
-		})
+	if anrGroup.MergedInto != nil {
+		msg := fmt.Sprintf("anr group %q is already merged into %q", anrGroupId.String(), anrGroup.MergedInto.String())
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
 
-		node.ID = name
-		node.Issues = gin.H{
-			"anrs": anrs,
-		}
-		nodes = append(nodes, node)
+	if err := anrGroup.Merge(ctx, payload.GroupIDs, nil); err != nil {
+		msg := fmt.Sprintf("failed to merge anr groups into %q", anrGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"totalIssues": len(anrGroup.EventIDs),
-		"nodes":       nodes,
-		"links":       links,
-	})
+	c.JSON(http.StatusOK, gin.H{"ok": "done"})
 }
 
 func CreateApp(c *gin.Context) {
@@ -4557,6 +6254,17 @@ func GetSessionsOverviewPlotInstances(c *gin.Context) {
 		return
 	}
 
+	anomalyOpts, err := plotAnomalyOptions(c)
+	if err != nil {
+		msg := `failed to parse anomaly detection method`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
 	if err := af.Expand(ctx); err != nil {
 		msg := `failed to expand filters`
 		fmt.Println(msg, err)
@@ -4654,8 +6362,9 @@ func GetSessionsOverviewPlotInstances(c *gin.Context) {
 	}
 
 	type instance struct {
-		ID   string  `json:"id"`
-		Data []gin.H `json:"data"`
+		ID        string        `json:"id"`
+		Data      []gin.H       `json:"data"`
+		Anomalies []PlotAnomaly `json:"anomalies,omitempty"`
 	}
 
 	lut := make(map[string]int)
@@ -4680,6 +6389,29 @@ func GetSessionsOverviewPlotInstances(c *gin.Context) {
 		}
 	}
 
+	if anomalyOpts != nil {
+		points := make(map[string][]plotPoint)
+		for i := range sessionInstances {
+			var value *float64
+			var volume uint64
+			if sessionInstances[i].Instances != nil {
+				count := float64(*sessionInstances[i].Instances)
+				value = &count
+				volume = *sessionInstances[i].Instances
+			}
+
+			points[sessionInstances[i].Version] = append(points[sessionInstances[i].Version], plotPoint{
+				datetime: sessionInstances[i].DateTime,
+				value:    value,
+				volume:   volume,
+			})
+		}
+
+		for i := range instances {
+			instances[i].Anomalies = detectPlotAnomalies(*anomalyOpts, "instances", points[instances[i].ID])
+		}
+	}
+
 	c.JSON(http.StatusOK, instances)
 }
 
@@ -4828,7 +6560,11 @@ func GetSession(c *gin.Context) {
 
 	gestureClickEvents := eventMap[event.TypeGestureClick]
 	if len(gestureClickEvents) > 0 {
-		gestureClicks := timeline.ComputeGestureClicks(gestureClickEvents)
+		frustrated := make(map[uuid.UUID]event.FrustratedClick)
+		for _, click := range event.DetectFrustratedClicks(session.Events) {
+			frustrated[click.ID] = click
+		}
+		gestureClicks := timeline.ComputeGestureClicks(gestureClickEvents, frustrated)
 		threadedGestureClicks := timeline.GroupByThreads(gestureClicks)
 		threads.Organize(event.TypeGestureClick, threadedGestureClicks)
 	}
@@ -5037,6 +6773,14 @@ func GetAlertPrefs(c *gin.Context) {
 		return
 	}
 
+	alertPref.Channels, err = getChannelSubscriptions(c.Request.Context(), appId)
+	if err != nil {
+		msg := `unable to fetch notification channel subscriptions`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
 	c.JSON(http.StatusOK, alertPref)
 }
 
@@ -5070,6 +6814,71 @@ func UpdateAlertPrefs(c *gin.Context) {
 	alertPref.CrashRateSpikeEmail = payload.CrashRateSpike.Email
 	alertPref.AnrRateSpikeEmail = payload.AnrRateSpike.Email
 	alertPref.LaunchTimeSpikeEmail = payload.LaunchTimeSpike.Email
+	alertPref.NewIssueEmail = payload.NewIssue.Email
+	alertPref.RegressionEmail = payload.Regression.Email
+	alertPref.AlertRuleEmail = payload.AlertRule.Email
+	alertPref.SpanRegressionEmail = payload.SpanRegression.Email
+
+	// channel subscriptions are shared by the
+	// team, so changing them needs alert write
+	// permissions
+	if channels := payload.channels(); len(channels) > 0 {
+		ctx := c.Request.Context()
+		app := App{
+			ID: &appId,
+		}
+
+		team, err := app.getTeam(ctx)
+		if err != nil {
+			msg := "failed to get team from app id"
+			fmt.Println(msg, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+		if team == nil {
+			msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+
+		ok, err := PerformAuthz(userIdString, team.ID.String(), *ScopeAlertAll)
+		if err != nil {
+			msg := `couldn't perform authorization checks`
+			fmt.Println(msg, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+		if !ok {
+			msg := fmt.Sprintf(`you don't have permissions to modify notification channels in team [%s]`, team.ID.String())
+			c.JSON(http.StatusForbidden, gin.H{"error": msg})
+			return
+		}
+
+		msg := `failed to update notification channel subscriptions`
+
+		tx, err := server.Server.PgPool.Begin(ctx)
+		if err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		defer tx.Rollback(ctx)
+
+		for alertType, channelIds := range channels {
+			if err := setChannelSubscriptions(ctx, &tx, appId, alertType, channelIds); err != nil {
+				fmt.Println(msg, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+				return
+			}
+		}
+
+		if err := tx.Commit(ctx); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+	}
 
 	alertPref.update()
 
@@ -5136,8 +6945,6 @@ func UpdateAppSettings(c *gin.Context) {
 		return
 	}
 
-	appSettings := newAppSettings(appId)
-
 	var payload AppSettingsPayload
 	if err := c.ShouldBindJSON(&payload); err != nil {
 		msg := `failed to parse alert preferences json payload`
@@ -5146,7 +6953,26 @@ func UpdateAppSettings(c *gin.Context) {
 		return
 	}
 
+	appSettings, err := getAppSettings(appId)
+	if err != nil {
+		msg := `unable to fetch app settings`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
 	appSettings.RetentionPeriod = payload.RetentionPeriod
+	appSettings.UpdatedAt = time.Now()
+
+	if payload.NewIssueLatestVersionOnly != nil {
+		appSettings.NewIssueLatestVersionOnly = *payload.NewIssueLatestVersionOnly
+	}
+	if payload.NewIssueMinEvents != nil {
+		appSettings.NewIssueMinEvents = *payload.NewIssueMinEvents
+	}
+	if payload.NewIssueMinUsers != nil {
+		appSettings.NewIssueMinUsers = *payload.NewIssueMinUsers
+	}
 
 	appSettings.update()
 
@@ -5530,6 +7356,17 @@ func GetSpanMetricsPlot(c *gin.Context) {
 		return
 	}
 
+	anomalyOpts, err := plotAnomalyOptions(c)
+	if err != nil {
+		msg := `failed to parse anomaly detection method`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
 	if err := af.Expand(ctx); err != nil {
 		msg := `failed to expand filters`
 		fmt.Println(msg, err)
@@ -5617,8 +7454,9 @@ func GetSpanMetricsPlot(c *gin.Context) {
 	}
 
 	type instance struct {
-		ID   string  `json:"id"`
-		Data []gin.H `json:"data"`
+		ID        string        `json:"id"`
+		Data      []gin.H       `json:"data"`
+		Anomalies []PlotAnomaly `json:"anomalies,omitempty"`
 	}
 
 	lut := make(map[string]int)
@@ -5646,9 +7484,174 @@ func GetSpanMetricsPlot(c *gin.Context) {
 		}
 	}
 
+	if anomalyOpts != nil {
+		quantiles := []struct {
+			metric string
+			value  func(span.SpanMetricsPlotInstance) *float64
+		}{
+			{"p50", func(i span.SpanMetricsPlotInstance) *float64 { return i.P50 }},
+			{"p90", func(i span.SpanMetricsPlotInstance) *float64 { return i.P90 }},
+			{"p95", func(i span.SpanMetricsPlotInstance) *float64 { return i.P95 }},
+			{"p99", func(i span.SpanMetricsPlotInstance) *float64 { return i.P99 }},
+		}
+
+		for _, q := range quantiles {
+			points := make(map[string][]plotPoint)
+			for i := range spanMetricsPlotInstances {
+				points[spanMetricsPlotInstances[i].Version] = append(points[spanMetricsPlotInstances[i].Version], plotPoint{
+					datetime: spanMetricsPlotInstances[i].DateTime,
+					value:    q.value(spanMetricsPlotInstances[i]),
+					volume:   spanMetricsPlotInstances[i].Spans,
+				})
+			}
+
+			for i := range instances {
+				instances[i].Anomalies = append(instances[i].Anomalies, detectPlotAnomalies(*anomalyOpts, q.metric, points[instances[i].ID])...)
+			}
+		}
+	}
+
 	c.JSON(http.StatusOK, instances)
 }
 
+func GetSpanMetricsBreakdown(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	rawSpanName := c.Query("span_name")
+	if rawSpanName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing span_name query param"})
+		return
+	}
+
+	spanName, err := url.QueryUnescape(rawSpanName)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid span_name query param"})
+		return
+	}
+
+	key := c.Query("ud_key")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing ud_key query param"})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse query parameters`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := "span breakdown request validation failed"
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	breakdowns, err := span.GetSpanMetricsBreakdownWithFilter(ctx, spanName, key, &af)
+	if err != nil {
+		msg := "failed to get span's breakdown"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if breakdowns == nil {
+		breakdowns = []span.SpanMetricsBreakdown{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": breakdowns,
+	})
+}
+
 func GetTrace(c *gin.Context) {
 	ctx := c.Request.Context()
 	id, err := uuid.Parse(c.Param("id"))
@@ -0,0 +1,73 @@
+package measure
+
+import (
+	"strings"
+	"testing"
+
+	"backend/api/event"
+
+	"github.com/google/uuid"
+)
+
+func TestExceptionGroupByFingerprintStmt(t *testing.T) {
+	appId := uuid.New()
+	app := App{ID: &appId}
+
+	stmt := app.exceptionGroupByFingerprintStmt("bd10e744da4b4685bd83fe29e4ac6ed9", true)
+	defer stmt.Close()
+
+	sql := stmt.String()
+
+	for _, want := range []string{"from public.unhandled_exception_groups", "app_id = $", "fingerprint = $", "handled = $"} {
+		if !strings.Contains(strings.ToLower(sql), want) {
+			t.Errorf("Expected statement to contain %q, got %s", want, sql)
+		}
+	}
+
+	args := stmt.Args()
+	if len(args) != 3 || args[0] != &appId || args[2] != true {
+		t.Errorf("Expected app id, fingerprint & handled args, got %v", args)
+	}
+}
+
+func TestANRGroupByFingerprintStmt(t *testing.T) {
+	appId := uuid.New()
+	app := App{ID: &appId}
+
+	stmt := app.anrGroupByFingerprintStmt("bd10e744da4b4685bd83fe29e4ac6ed9")
+	defer stmt.Close()
+
+	sql := stmt.String()
+
+	for _, want := range []string{"from public.anr_groups", "app_id = $", "fingerprint = $"} {
+		if !strings.Contains(strings.ToLower(sql), want) {
+			t.Errorf("Expected statement to contain %q, got %s", want, sql)
+		}
+	}
+
+	// group lookups must never touch raw events
+	if strings.Contains(sql, "events") {
+		t.Errorf("Expected statement to not read events, got %s", sql)
+	}
+}
+
+func TestIssueEventIDs(t *testing.T) {
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New(), uuid.New()}
+
+	events := []event.EventField{
+		{ID: ids[0], Type: event.TypeException, Exception: &event.Exception{Fingerprint: "one"}},
+		{ID: ids[1], Type: event.TypeException, Exception: &event.Exception{Fingerprint: "two"}},
+		{ID: ids[2], Type: event.TypeANR, ANR: &event.ANR{Fingerprint: "merged"}},
+		{ID: ids[3], Type: event.TypeLifecycleActivity, LifecycleActivity: &event.LifecycleActivity{}},
+	}
+
+	got := issueEventIDs(events, []string{"one", "merged"})
+
+	if len(got) != 2 || got[0] != ids[0] || got[1] != ids[2] {
+		t.Errorf("Expected ids %v, but got %v", []uuid.UUID{ids[0], ids[2]}, got)
+	}
+
+	if got := issueEventIDs(events, []string{"unknown"}); len(got) != 0 {
+		t.Errorf("Expected no ids, but got %v", got)
+	}
+}
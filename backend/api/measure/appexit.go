@@ -0,0 +1,531 @@
+package measure
+
+import (
+	"backend/api/event"
+	"backend/api/filter"
+	"backend/api/server"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+// maxAppExitBreakdowns is the maximum count of
+// versions, devices or OS versions reported in
+// app exit breakdowns.
+const maxAppExitBreakdowns = 10
+
+// AppExitReason represents the count of app
+// exits for a single exit reason.
+type AppExitReason struct {
+	Reason     string  `json:"reason"`
+	Count      uint64  `json:"count"`
+	Percentage float64 `json:"percentage"`
+}
+
+// AppExitBreakdown represents the distribution
+// of app exit reasons for a single value of an
+// attribute, like an app version.
+type AppExitBreakdown struct {
+	Key     string          `json:"key"`
+	Total   uint64          `json:"total"`
+	Reasons []AppExitReason `json:"reasons"`
+}
+
+// AppExitIssueGroup represents a crash or ANR
+// group linked to app exits of a reason.
+type AppExitIssueGroup struct {
+	Reason      string    `json:"reason"`
+	IssueType   string    `json:"issue_type"`
+	ID          uuid.UUID `json:"id" db:"id"`
+	Type        string    `json:"type" db:"type"`
+	Message     string    `json:"message" db:"message"`
+	MethodName  string    `json:"method_name" db:"method_name"`
+	FileName    string    `json:"file_name" db:"file_name"`
+	LineNumber  int       `json:"line_number" db:"line_number"`
+	Fingerprint string    `json:"-" db:"fingerprint"`
+	Count       uint64    `json:"count"`
+}
+
+// AppExits represents the distribution of an
+// app's exit reasons along with the crash and
+// ANR groups the exits correspond to.
+type AppExits struct {
+	Total       uint64              `json:"total"`
+	Reasons     []AppExitReason     `json:"reasons"`
+	Versions    []AppExitBreakdown  `json:"versions"`
+	Devices     []AppExitBreakdown  `json:"devices"`
+	OSVersions  []AppExitBreakdown  `json:"os_versions"`
+	IssueGroups []AppExitIssueGroup `json:"issue_groups"`
+	// Unlinked is the count of crash and ANR
+	// exits that could not be linked to any
+	// crash or ANR group.
+	Unlinked []AppExitReason `json:"unlinked"`
+}
+
+// appExitBreakdownRow represents a single row
+// of app exit counts by attribute & reason.
+type appExitBreakdownRow struct {
+	key    string
+	reason string
+	count  uint64
+}
+
+// GetAppExits computes the distribution of app exit
+// reasons overall and by app version, device & OS version
+// while respecting all applicable app filters. Crash & ANR
+// exits are linked to the crash & ANR groups of the issues
+// that occurred in the same session.
+func (a App) GetAppExits(ctx context.Context, af *filter.AppFilter) (exits *AppExits, err error) {
+	exits = &AppExits{
+		Reasons:     []AppExitReason{},
+		Versions:    []AppExitBreakdown{},
+		Devices:     []AppExitBreakdown{},
+		OSVersions:  []AppExitBreakdown{},
+		IssueGroups: []AppExitIssueGroup{},
+		Unlinked:    []AppExitReason{},
+	}
+
+	overall, err := a.getAppExitBreakdowns(ctx, af, "''")
+	if err != nil {
+		return
+	}
+
+	if len(overall) > 0 {
+		exits.Total = overall[0].Total
+		exits.Reasons = overall[0].Reasons
+	}
+
+	if exits.Versions, err = a.getAppExitBreakdowns(ctx, af, "concat(toString(attribute.app_version), ' (', toString(attribute.app_build), ')')"); err != nil {
+		return
+	}
+
+	if exits.Devices, err = a.getAppExitBreakdowns(ctx, af, "concat(toString(attribute.device_manufacturer), ' ', toString(attribute.device_name))"); err != nil {
+		return
+	}
+
+	if exits.OSVersions, err = a.getAppExitBreakdowns(ctx, af, "concat(toString(attribute.os_name), ' ', toString(attribute.os_version))"); err != nil {
+		return
+	}
+
+	if exits.IssueGroups, exits.Unlinked, err = a.getAppExitIssueGroups(ctx, af); err != nil {
+		return
+	}
+
+	return
+}
+
+// getAppExitBreakdowns queries app exit counts by reason
+// for each value of the key expression. Only the values
+// having the most exits are kept.
+func (a App) getAppExitBreakdowns(ctx context.Context, af *filter.AppFilter, key string) (breakdowns []AppExitBreakdown, err error) {
+	breakdowns = []AppExitBreakdown{}
+
+	stmt := sqlf.
+		From("events").
+		Select(fmt.Sprintf("%s as key", key)).
+		Select("toString(app_exit.reason) as reason").
+		Select("count() as count").
+		Clause("prewhere app_id = toUUID(?) and type = ?", af.AppID, event.TypeAppExit).
+		GroupBy("key, reason")
+
+	defer stmt.Close()
+
+	applyEventFilters(stmt, af)
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	var results []appExitBreakdownRow
+	for rows.Next() {
+		var row appExitBreakdownRow
+		if err = rows.Scan(&row.key, &row.reason, &row.count); err != nil {
+			return
+		}
+		results = append(results, row)
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	breakdowns = computeAppExitBreakdowns(results)
+
+	if len(breakdowns) > maxAppExitBreakdowns {
+		breakdowns = breakdowns[:maxAppExitBreakdowns]
+	}
+
+	return
+}
+
+// getAppExitIssueGroups links crash & ANR app exits to
+// the crash & ANR groups of unhandled exceptions & ANRs
+// that occurred in the same session as the exit. Exits
+// that could not be linked are reported as unlinked.
+func (a App) getAppExitIssueGroups(ctx context.Context, af *filter.AppFilter) (issueGroups []AppExitIssueGroup, unlinked []AppExitReason, err error) {
+	issueGroups = []AppExitIssueGroup{}
+	unlinked = []AppExitReason{}
+
+	exitsStmt := sqlf.
+		From("events").
+		Select("id").
+		Select("session_id").
+		Select("toString(app_exit.reason) as reason").
+		Select("if(app_exit.reason = ?, ?, ?) as issue_type", event.AppExitReasonANR, event.TypeANR, event.TypeException).
+		Clause("prewhere app_id = toUUID(?) and type = ?", af.AppID, event.TypeAppExit).
+		Where("app_exit.reason in ?", []string{event.AppExitReasonCrash, event.AppExitReasonCrashNative, event.AppExitReasonANR})
+
+	defer exitsStmt.Close()
+
+	applyEventFilters(exitsStmt, af)
+
+	issuesStmt := sqlf.
+		From("events").
+		Select("distinct session_id").
+		Select("toString(type) as issue_type").
+		Select("toString(if(type = ?, anr.fingerprint, exception.fingerprint)) as fingerprint", event.TypeANR).
+		Clause("prewhere app_id = toUUID(?) and ((type = ? and exception.handled = false) or type = ?)", af.AppID, event.TypeException, event.TypeANR).
+		Where(fmt.Sprintf("session_id in (select session_id from (%s))", exitsStmt.String()), exitsStmt.Args()...)
+
+	defer issuesStmt.Close()
+
+	var args []any
+	args = append(args, exitsStmt.Args()...)
+	args = append(args, issuesStmt.Args()...)
+
+	stmt := sqlf.New(fmt.Sprintf("select e.reason, e.issue_type, i.fingerprint, uniq(e.id) from (%s) as e left join (%s) as i on e.session_id = i.session_id and e.issue_type = i.issue_type", exitsStmt.String(), issuesStmt.String()), args...).
+		GroupBy("e.reason, e.issue_type, i.fingerprint")
+
+	defer stmt.Close()
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	type linkKey struct {
+		reason      string
+		issueType   string
+		fingerprint string
+	}
+
+	counts := make(map[linkKey]uint64)
+	unlinkedCounts := make(map[string]uint64)
+	fingerprints := make(map[string][]string)
+
+	for rows.Next() {
+		var key linkKey
+		var count uint64
+		if err = rows.Scan(&key.reason, &key.issueType, &key.fingerprint, &count); err != nil {
+			return
+		}
+
+		if key.fingerprint == "" {
+			unlinkedCounts[key.reason] += count
+			continue
+		}
+
+		counts[key] += count
+		fingerprints[key.issueType] = append(fingerprints[key.issueType], key.fingerprint)
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	var total uint64
+	for _, count := range unlinkedCounts {
+		total += count
+	}
+
+	for reason, count := range unlinkedCounts {
+		unlinked = append(unlinked, AppExitReason{
+			Reason:     reason,
+			Count:      count,
+			Percentage: percentage(count, total),
+		})
+	}
+
+	sortAppExitReasons(unlinked)
+
+	// resolve fingerprints to groups, counting
+	// exits of merged groups towards the group
+	// they were merged into
+	grouped := make(map[uuid.UUID]map[string]*AppExitIssueGroup)
+	for issueType, fps := range fingerprints {
+		groups, err := a.getAppExitGroups(ctx, issueType, fps)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, g := range groups {
+			for key, count := range counts {
+				if key.issueType != issueType || key.fingerprint != g.Fingerprint {
+					continue
+				}
+
+				if grouped[g.ID] == nil {
+					grouped[g.ID] = make(map[string]*AppExitIssueGroup)
+				}
+
+				linked, ok := grouped[g.ID][key.reason]
+				if !ok {
+					linked = &AppExitIssueGroup{}
+					*linked = g
+					linked.Reason = key.reason
+					linked.IssueType = issueType
+					linked.Count = 0
+					grouped[g.ID][key.reason] = linked
+				}
+
+				linked.Count += count
+			}
+		}
+	}
+
+	for _, reasons := range grouped {
+		for _, linked := range reasons {
+			issueGroups = append(issueGroups, *linked)
+		}
+	}
+
+	sort.Slice(issueGroups, func(i, j int) bool {
+		if issueGroups[i].Count != issueGroups[j].Count {
+			return issueGroups[i].Count > issueGroups[j].Count
+		}
+		if issueGroups[i].ID != issueGroups[j].ID {
+			return issueGroups[i].ID.String() < issueGroups[j].ID.String()
+		}
+		return issueGroups[i].Reason < issueGroups[j].Reason
+	})
+
+	return
+}
+
+// getAppExitGroups queries crash or ANR groups matching
+// fingerprints. Groups that were merged are resolved to
+// the group they were merged into. Fingerprint reports the
+// matched fingerprint.
+func (a App) getAppExitGroups(ctx context.Context, issueType string, fingerprints []string) (groups []AppExitIssueGroup, err error) {
+	table := "public.unhandled_exception_groups"
+	if issueType == event.TypeANR {
+		table = "public.anr_groups"
+	}
+
+	stmt := sqlf.PostgreSQL.
+		From(fmt.Sprintf("%s g", table)).
+		Join(fmt.Sprintf("%s r", table), "r.id = coalesce(g.merged_into, g.id)").
+		Select("r.id").
+		Select("r.type").
+		Select("r.message").
+		Select("r.method_name").
+		Select("r.file_name").
+		Select("r.line_number").
+		Select("g.fingerprint").
+		Where("g.app_id = ?", a.ID).
+		Where("g.fingerprint = any(?)", fingerprints)
+
+	if issueType == event.TypeException {
+		stmt.Where("g.handled = false")
+	}
+
+	defer stmt.Close()
+
+	rows, _ := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	groups, err = pgx.CollectRows(rows, pgx.RowToStructByNameLax[AppExitIssueGroup])
+
+	return
+}
+
+// computeAppExitBreakdowns builds breakdowns from rows
+// of exit counts by key & reason. Breakdowns are sorted
+// by total exits in descending order & reasons within a
+// breakdown by count in descending order.
+func computeAppExitBreakdowns(rows []appExitBreakdownRow) (breakdowns []AppExitBreakdown) {
+	breakdowns = []AppExitBreakdown{}
+	indices := make(map[string]int)
+
+	for _, row := range rows {
+		i, ok := indices[row.key]
+		if !ok {
+			i = len(breakdowns)
+			indices[row.key] = i
+			breakdowns = append(breakdowns, AppExitBreakdown{
+				Key:     row.key,
+				Reasons: []AppExitReason{},
+			})
+		}
+
+		breakdowns[i].Total += row.count
+		breakdowns[i].Reasons = append(breakdowns[i].Reasons, AppExitReason{
+			Reason: row.reason,
+			Count:  row.count,
+		})
+	}
+
+	for i := range breakdowns {
+		for j := range breakdowns[i].Reasons {
+			breakdowns[i].Reasons[j].Percentage = percentage(breakdowns[i].Reasons[j].Count, breakdowns[i].Total)
+		}
+		sortAppExitReasons(breakdowns[i].Reasons)
+	}
+
+	sort.SliceStable(breakdowns, func(i, j int) bool {
+		if breakdowns[i].Total != breakdowns[j].Total {
+			return breakdowns[i].Total > breakdowns[j].Total
+		}
+		return breakdowns[i].Key < breakdowns[j].Key
+	})
+
+	return
+}
+
+// sortAppExitReasons sorts app exit reasons by
+// count in descending order.
+func sortAppExitReasons(reasons []AppExitReason) {
+	sort.SliceStable(reasons, func(i, j int) bool {
+		if reasons[i].Count != reasons[j].Count {
+			return reasons[i].Count > reasons[j].Count
+		}
+		return reasons[i].Reason < reasons[j].Reason
+	})
+}
+
+// percentage computes part as a percentage of
+// total rounded to 2 decimal places.
+func percentage(part, total uint64) float64 {
+	if total == 0 {
+		return 0
+	}
+
+	return math.Round(float64(part)/float64(total)*10000) / 100
+}
+
+func GetAppExits(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse app exits request`
+		fmt.Println(msg, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := `app exits request validation failed`
+
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	exits, err := app.GetAppExits(ctx, &af)
+	if err != nil {
+		msg := `failed to fetch app exits`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, exits)
+}
@@ -0,0 +1,60 @@
+package measure
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestComputeAppExitBreakdowns(t *testing.T) {
+	rows := []appExitBreakdownRow{
+		{key: "1.0 (1)", reason: "CRASH", count: 2},
+		{key: "1.1 (2)", reason: "LOW_MEMORY", count: 6},
+		{key: "1.0 (1)", reason: "USER_REQUESTED", count: 6},
+		{key: "1.1 (2)", reason: "ANR", count: 3},
+		{key: "1.1 (2)", reason: "CRASH", count: 3},
+	}
+
+	expected := []AppExitBreakdown{
+		{
+			Key:   "1.1 (2)",
+			Total: 12,
+			Reasons: []AppExitReason{
+				{Reason: "LOW_MEMORY", Count: 6, Percentage: 50},
+				{Reason: "ANR", Count: 3, Percentage: 25},
+				{Reason: "CRASH", Count: 3, Percentage: 25},
+			},
+		},
+		{
+			Key:   "1.0 (1)",
+			Total: 8,
+			Reasons: []AppExitReason{
+				{Reason: "USER_REQUESTED", Count: 6, Percentage: 75},
+				{Reason: "CRASH", Count: 2, Percentage: 25},
+			},
+		},
+	}
+
+	got := computeAppExitBreakdowns(rows)
+
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("Expected %v, but got %v", expected, got)
+	}
+}
+
+func TestComputeAppExitBreakdownsEmpty(t *testing.T) {
+	got := computeAppExitBreakdowns(nil)
+
+	if got == nil || len(got) != 0 {
+		t.Errorf("Expected empty breakdowns, but got %v", got)
+	}
+}
+
+func TestPercentage(t *testing.T) {
+	if got := percentage(1, 3); got != 33.33 {
+		t.Errorf("Expected %v, but got %v", 33.33, got)
+	}
+
+	if got := percentage(1, 0); got != 0 {
+		t.Errorf("Expected %v, but got %v", 0, got)
+	}
+}
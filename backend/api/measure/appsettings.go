@@ -17,18 +17,34 @@ import (
 type AppSettings struct {
 	AppId           uuid.UUID
 	RetentionPeriod uint32
-	UpdatedAt       time.Time
-	CreatedAt       time.Time
+	// NewIssueLatestVersionOnly holds off new issue
+	// alerts until the issue occurs on the latest
+	// version of the app.
+	NewIssueLatestVersionOnly bool
+	// NewIssueMinEvents holds off new issue alerts
+	// until the issue occurs at least as many times.
+	NewIssueMinEvents uint32
+	// NewIssueMinUsers holds off new issue alerts
+	// until the issue affects at least as many users.
+	NewIssueMinUsers uint32
+	UpdatedAt        time.Time
+	CreatedAt        time.Time
 }
 
 type AppSettingsPayload struct {
-	RetentionPeriod uint32 `json:"retention_period"`
+	RetentionPeriod           uint32  `json:"retention_period"`
+	NewIssueLatestVersionOnly *bool   `json:"new_issue_latest_version_only"`
+	NewIssueMinEvents         *uint32 `json:"new_issue_min_events"`
+	NewIssueMinUsers          *uint32 `json:"new_issue_min_users"`
 }
 
 func (pref *AppSettings) MarshalJSON() ([]byte, error) {
 	apiMap := make(map[string]any)
 	apiMap["app_id"] = pref.AppId
 	apiMap["retention_period"] = pref.RetentionPeriod
+	apiMap["new_issue_latest_version_only"] = pref.NewIssueLatestVersionOnly
+	apiMap["new_issue_min_events"] = pref.NewIssueMinEvents
+	apiMap["new_issue_min_users"] = pref.NewIssueMinUsers
 	apiMap["created_at"] = pref.CreatedAt.Format(chrono.ISOFormatJS)
 	apiMap["updated_at"] = pref.UpdatedAt.Format(chrono.ISOFormatJS)
 	return json.Marshal(apiMap)
@@ -46,6 +62,9 @@ func newAppSettings(appId uuid.UUID) *AppSettings {
 func (pref *AppSettings) update() error {
 	stmt := sqlf.PostgreSQL.Update("public.app_settings").
 		Set("retention_period", pref.RetentionPeriod).
+		Set("new_issue_latest_version_only", pref.NewIssueLatestVersionOnly).
+		Set("new_issue_min_events", pref.NewIssueMinEvents).
+		Set("new_issue_min_users", pref.NewIssueMinUsers).
 		Set("updated_at", pref.UpdatedAt).
 		Where("app_id = ?", pref.AppId)
 	defer stmt.Close()
@@ -66,13 +85,16 @@ func getAppSettings(appId uuid.UUID) (*AppSettings, error) {
 	stmt := sqlf.PostgreSQL.
 		Select("app_id").
 		Select("retention_period").
+		Select("new_issue_latest_version_only").
+		Select("new_issue_min_events").
+		Select("new_issue_min_users").
 		Select("created_at").
 		Select("updated_at").
 		From("public.app_settings").
 		Where("app_id = ?", appId)
 	defer stmt.Close()
 
-	err := server.Server.PgPool.QueryRow(context.Background(), stmt.String(), appId).Scan(&pref.AppId, &pref.RetentionPeriod, &pref.CreatedAt, &pref.UpdatedAt)
+	err := server.Server.PgPool.QueryRow(context.Background(), stmt.String(), appId).Scan(&pref.AppId, &pref.RetentionPeriod, &pref.NewIssueLatestVersionOnly, &pref.NewIssueMinEvents, &pref.NewIssueMinUsers, &pref.CreatedAt, &pref.UpdatedAt)
 
 	// If there is no record for given appId and userId combo, we create one
 	if err != nil && err == pgx.ErrNoRows {
@@ -81,6 +103,9 @@ func getAppSettings(appId uuid.UUID) (*AppSettings, error) {
 		stmt := sqlf.PostgreSQL.InsertInto("public.app_settings").
 			Set("app_id", pref.AppId).
 			Set("retention_period", pref.RetentionPeriod).
+			Set("new_issue_latest_version_only", pref.NewIssueLatestVersionOnly).
+			Set("new_issue_min_events", pref.NewIssueMinEvents).
+			Set("new_issue_min_users", pref.NewIssueMinUsers).
 			Set("created_at", pref.CreatedAt).
 			Set("updated_at", pref.UpdatedAt)
 		defer stmt.Close()
@@ -100,6 +125,12 @@ func getAppSettings(appId uuid.UUID) (*AppSettings, error) {
 	return &pref, nil
 }
 
+// needsIssueStats returns true if any new issue
+// alerting threshold is set.
+func (pref *AppSettings) needsIssueStats() bool {
+	return pref.NewIssueLatestVersionOnly || pref.NewIssueMinEvents > 0 || pref.NewIssueMinUsers > 0
+}
+
 func (pref *AppSettings) String() string {
 	return fmt.Sprintf("AppSettings - app_id: %s, retention_period: %v, created_at: %v, updated_at: %v ", pref.AppId, pref.RetentionPeriod, pref.CreatedAt, pref.UpdatedAt)
 }
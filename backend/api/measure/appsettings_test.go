@@ -51,6 +51,9 @@ func TestAppSettingsMarshalJSON(t *testing.T) {
 	expectedJSON := fmt.Sprintf(`{
 		"app_id": "%s",
         "retention_period": %d,
+        "new_issue_latest_version_only": false,
+        "new_issue_min_events": 0,
+        "new_issue_min_users": 0,
         "created_at": "2023-04-04T12:00:00Z",
         "updated_at": "2023-04-05T12:00:00Z"
     }`, appId, retentionPeriod)
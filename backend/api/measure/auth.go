@@ -358,6 +358,14 @@ func SigninGitHub(c *gin.Context) {
 				return
 			}
 
+			if err := msrUser.acceptInvites(ctx, &tx); err != nil {
+				fmt.Println(msg, err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": msg,
+				})
+				return
+			}
+
 			if err := tx.Commit(ctx); err != nil {
 				fmt.Println(msg, err)
 				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
@@ -563,6 +571,14 @@ func SigninGoogle(c *gin.Context) {
 			return
 		}
 
+		if err := msrUser.acceptInvites(ctx, &tx); err != nil {
+			fmt.Println(msg, err)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": msg,
+			})
+			return
+		}
+
 		if err := tx.Commit(ctx); err != nil {
 			fmt.Println(msg, err)
 			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
@@ -196,6 +196,21 @@ func PerformAuthz(uid string, rid string, scope scope) (bool, error) {
 			return true, nil
 		}
 
+		return false, nil
+	case *ScopeAlertAll:
+		if slices.Contains(roleScope, *ScopeAlertAll) {
+			return true, nil
+		}
+
+		return false, nil
+	case *ScopeAlertRead:
+		if slices.Contains(roleScope, *ScopeAlertAll) {
+			return true, nil
+		}
+		if slices.Contains(roleScope, *ScopeAlertRead) {
+			return true, nil
+		}
+
 		return false, nil
 	default:
 		return false, nil
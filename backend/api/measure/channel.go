@@ -0,0 +1,869 @@
+package measure
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"backend/api/chrono"
+	"backend/api/notify"
+	"backend/api/server"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+// maxChannelsPerApp is the maximum count of
+// notification channels of an app.
+const maxChannelsPerApp = 20
+
+// maxChannelNameLen is the maximum length
+// of a notification channel's name.
+const maxChannelNameLen = 256
+
+// maxDeliveries is the maximum count of
+// deliveries returned in a channel's delivery log.
+const maxDeliveries = 50
+
+// slackWebhookHost is the host of Slack
+// incoming webhook urls.
+const slackWebhookHost = "hooks.slack.com"
+
+// alertTypes lists the alert types notification
+// channels can subscribe to.
+var alertTypes = []string{
+	AlertTypeCrashRateSpike,
+	AlertTypeAnrRateSpike,
+	AlertTypeLaunchTimeSpike,
+	AlertTypeNewIssue,
+	AlertTypeRegression,
+	AlertTypeAlertRule,
+	AlertTypeSpanRegression,
+}
+
+// NotificationChannel represents a destination
+// an app's alerts are delivered to.
+type NotificationChannel struct {
+	ID    uuid.UUID `json:"id" db:"id"`
+	AppID uuid.UUID `json:"app_id" db:"app_id"`
+	Type  string    `json:"type" db:"type"`
+	Name  string    `json:"name" db:"name"`
+	URL   string    `json:"url" db:"url"`
+	// Secret signs webhook payloads. It is only
+	// exposed when the channel is created.
+	Secret     *string        `json:"secret,omitempty" db:"secret"`
+	AlertTypes []string       `json:"alert_types" db:"alert_types"`
+	CreatedBy  *uuid.UUID     `json:"created_by" db:"created_by"`
+	CreatedAt  chrono.ISOTime `json:"created_at" db:"created_at"`
+	UpdatedAt  chrono.ISOTime `json:"updated_at" db:"updated_at"`
+}
+
+// NotificationChannelPayload represents the request
+// body to create or update a notification channel.
+type NotificationChannelPayload struct {
+	Type       *string   `json:"type"`
+	Name       *string   `json:"name"`
+	URL        *string   `json:"url"`
+	AlertTypes *[]string `json:"alert_types"`
+}
+
+// apply applies the fields present in the
+// payload to the channel. Type is only set
+// on creation & is never applied.
+func (p NotificationChannelPayload) apply(ch *NotificationChannel) {
+	if p.Name != nil {
+		ch.Name = strings.TrimSpace(*p.Name)
+	}
+	if p.URL != nil {
+		ch.URL = strings.TrimSpace(*p.URL)
+	}
+	if p.AlertTypes != nil {
+		ch.AlertTypes = []string{}
+		for _, alertType := range *p.AlertTypes {
+			if !slices.Contains(ch.AlertTypes, alertType) {
+				ch.AlertTypes = append(ch.AlertTypes, alertType)
+			}
+		}
+	}
+}
+
+// Validate validates the notification channel.
+func (ch NotificationChannel) Validate() error {
+	if ch.Type != notify.ChannelWebhook && ch.Type != notify.ChannelSlack {
+		return fmt.Errorf("type must be one of %q or %q", notify.ChannelWebhook, notify.ChannelSlack)
+	}
+
+	if ch.Name == "" {
+		return errors.New("name must not be empty")
+	}
+
+	if len(ch.Name) > maxChannelNameLen {
+		return fmt.Errorf("name must not be longer than %d characters", maxChannelNameLen)
+	}
+
+	u, err := url.Parse(ch.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("url must be a valid https url")
+	}
+
+	// hosts are resolved & checked again when
+	// delivering, this only rejects urls that can
+	// never be delivered to
+	host := u.Hostname()
+	if addr, err := netip.ParseAddr(host); strings.EqualFold(host, "localhost") || (err == nil && !notify.IsPublicAddr(addr)) {
+		return errors.New("url must point to a public host")
+	}
+
+	if ch.Type == notify.ChannelSlack && u.Hostname() != slackWebhookHost {
+		return fmt.Errorf("slack url must be an incoming webhook url on %q", slackWebhookHost)
+	}
+
+	for _, alertType := range ch.AlertTypes {
+		if !slices.Contains(alertTypes, alertType) {
+			return fmt.Errorf("unknown alert type %q", alertType)
+		}
+	}
+
+	return nil
+}
+
+// channel returns the destination notifications
+// of the channel are delivered to.
+func (ch NotificationChannel) channel() notify.Channel {
+	c := notify.Channel{
+		ID:   ch.ID,
+		Type: ch.Type,
+		URL:  ch.URL,
+	}
+
+	if ch.Secret != nil {
+		c.Secret = *ch.Secret
+	}
+
+	return c
+}
+
+// newChannelSecret generates a random secret
+// to sign webhook payloads.
+func newChannelSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// channelColumns lists the columns of
+// notification channels.
+var channelColumns = []string{
+	"id",
+	"app_id",
+	"type",
+	"name",
+	"url",
+	"secret",
+	"alert_types",
+	"created_by",
+	"created_at",
+	"updated_at",
+}
+
+// getNotificationChannels fetches the
+// notification channels of an app.
+func getNotificationChannels(ctx context.Context, appId uuid.UUID) (channels []NotificationChannel, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.notification_channels").
+		Where("app_id = ?", appId).
+		OrderBy("created_at")
+
+	defer stmt.Close()
+
+	for _, col := range channelColumns {
+		stmt.Select(col)
+	}
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[NotificationChannel])
+}
+
+// getNotificationChannel fetches a notification
+// channel of an app. Returns nil if the channel
+// does not exist.
+func getNotificationChannel(ctx context.Context, appId, id uuid.UUID) (channel *NotificationChannel, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.notification_channels").
+		Where("app_id = ?", appId).
+		Where("id = ?", id)
+
+	defer stmt.Close()
+
+	for _, col := range channelColumns {
+		stmt.Select(col)
+	}
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	ch, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[NotificationChannel])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return
+	}
+
+	return &ch, nil
+}
+
+// countNotificationChannels counts the
+// notification channels of an app.
+func countNotificationChannels(ctx context.Context, appId uuid.UUID) (count int, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.notification_channels").
+		Select("count(*)").
+		Where("app_id = ?", appId)
+
+	defer stmt.Close()
+
+	err = server.Server.PgPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&count)
+
+	return
+}
+
+// insert creates the notification channel.
+func (ch *NotificationChannel) insert(ctx context.Context) (err error) {
+	stmt := sqlf.PostgreSQL.InsertInto("public.notification_channels").
+		Set("id", ch.ID).
+		Set("app_id", ch.AppID).
+		Set("type", ch.Type).
+		Set("name", ch.Name).
+		Set("url", ch.URL).
+		Set("secret", ch.Secret).
+		Set("alert_types", ch.AlertTypes).
+		Set("created_by", ch.CreatedBy).
+		Set("created_at", time.Time(ch.CreatedAt)).
+		Set("updated_at", time.Time(ch.UpdatedAt))
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// update updates the name, url & alert
+// types of the notification channel.
+func (ch *NotificationChannel) update(ctx context.Context) (err error) {
+	stmt := sqlf.PostgreSQL.Update("public.notification_channels").
+		Set("name", ch.Name).
+		Set("url", ch.URL).
+		Set("alert_types", ch.AlertTypes).
+		Set("updated_at", time.Time(ch.UpdatedAt)).
+		Where("id = ?", ch.ID)
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// delete deletes the notification channel
+// along with its deliveries.
+func (ch *NotificationChannel) delete(ctx context.Context) (err error) {
+	stmt := sqlf.PostgreSQL.DeleteFrom("public.notification_channels").
+		Where("id = ?", ch.ID)
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// getChannelSubscriptions fetches the ids of an app's
+// notification channels subscribed to each alert type.
+func getChannelSubscriptions(ctx context.Context, appId uuid.UUID) (subscriptions map[string][]uuid.UUID, err error) {
+	channels, err := getNotificationChannels(ctx, appId)
+	if err != nil {
+		return
+	}
+
+	subscriptions = make(map[string][]uuid.UUID)
+	for _, alertType := range alertTypes {
+		subscriptions[alertType] = []uuid.UUID{}
+	}
+
+	for _, ch := range channels {
+		for _, alertType := range ch.AlertTypes {
+			subscriptions[alertType] = append(subscriptions[alertType], ch.ID)
+		}
+	}
+
+	return
+}
+
+// setChannelSubscriptions subscribes exactly the given
+// channels of an app to the alert type.
+func setChannelSubscriptions(ctx context.Context, tx *pgx.Tx, appId uuid.UUID, alertType string, channelIds []uuid.UUID) (err error) {
+	now := time.Now()
+
+	unsubscribe := sqlf.PostgreSQL.Update("public.notification_channels").
+		SetExpr("alert_types", "array_remove(alert_types, ?)", alertType).
+		Set("updated_at", now).
+		Where("app_id = ?", appId).
+		Where("? = any(alert_types)", alertType).
+		Where("not (id = any(?))", channelIds)
+
+	defer unsubscribe.Close()
+
+	if _, err = (*tx).Exec(ctx, unsubscribe.String(), unsubscribe.Args()...); err != nil {
+		return
+	}
+
+	subscribe := sqlf.PostgreSQL.Update("public.notification_channels").
+		SetExpr("alert_types", "array_append(alert_types, ?)", alertType).
+		Set("updated_at", now).
+		Where("app_id = ?", appId).
+		Where("id = any(?)", channelIds).
+		Where("not (? = any(alert_types))", alertType)
+
+	defer subscribe.Close()
+
+	_, err = (*tx).Exec(ctx, subscribe.String(), subscribe.Args()...)
+
+	return
+}
+
+func GetNotificationChannels(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAlertRead)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to read notification channels in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	channels, err := getNotificationChannels(ctx, appId)
+	if err != nil {
+		msg := `failed to fetch notification channels`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	for i := range channels {
+		channels[i].Secret = nil
+	}
+
+	c.JSON(http.StatusOK, channels)
+}
+
+func CreateNotificationChannel(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAlertAll)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to create notification channels in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var payload NotificationChannelPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		msg := `failed to parse notification channel json payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	createdBy, err := uuid.Parse(userId)
+	if err != nil {
+		msg := `user id invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if payload.Type == nil {
+		msg := `type of a notification channel is required`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	now := time.Now()
+	channel := NotificationChannel{
+		ID:         uuid.New(),
+		AppID:      appId,
+		Type:       *payload.Type,
+		AlertTypes: []string{},
+		CreatedBy:  &createdBy,
+		CreatedAt:  chrono.ISOTime(now),
+		UpdatedAt:  chrono.ISOTime(now),
+	}
+
+	payload.apply(&channel)
+
+	if err := channel.Validate(); err != nil {
+		msg := `notification channel is invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	count, err := countNotificationChannels(ctx, appId)
+	if err != nil {
+		msg := `failed to count notification channels`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if count >= maxChannelsPerApp {
+		msg := fmt.Sprintf(`app cannot have more than %d notification channels`, maxChannelsPerApp)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if channel.Type == notify.ChannelWebhook {
+		secret, err := newChannelSecret()
+		if err != nil {
+			msg := `failed to generate webhook secret`
+			fmt.Println(msg, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+		channel.Secret = &secret
+	}
+
+	if err := channel.insert(ctx); err != nil {
+		msg := `failed to create notification channel`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusCreated, &channel)
+}
+
+func UpdateNotificationChannel(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	channelId, err := uuid.Parse(c.Param("channelId"))
+	if err != nil {
+		msg := `channel id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAlertAll)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to modify notification channels in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var payload NotificationChannelPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		msg := `failed to parse notification channel json payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if payload.Type != nil {
+		msg := `type of a notification channel cannot be changed`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	channel, err := getNotificationChannel(ctx, appId, channelId)
+	if err != nil {
+		msg := `failed to fetch notification channel`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if channel == nil {
+		msg := fmt.Sprintf(`notification channel [%s] does not exist`, channelId)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	payload.apply(channel)
+	channel.UpdatedAt = chrono.ISOTime(time.Now())
+
+	if err := channel.Validate(); err != nil {
+		msg := `notification channel is invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	if err := channel.update(ctx); err != nil {
+		msg := `failed to update notification channel`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	channel.Secret = nil
+
+	c.JSON(http.StatusOK, channel)
+}
+
+func DeleteNotificationChannel(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	channelId, err := uuid.Parse(c.Param("channelId"))
+	if err != nil {
+		msg := `channel id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAlertAll)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to delete notification channels in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	channel, err := getNotificationChannel(ctx, appId, channelId)
+	if err != nil {
+		msg := `failed to fetch notification channel`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if channel == nil {
+		msg := fmt.Sprintf(`notification channel [%s] does not exist`, channelId)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	if err := channel.delete(ctx); err != nil {
+		msg := `failed to delete notification channel`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": "done"})
+}
+
+func TestNotificationChannel(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	channelId, err := uuid.Parse(c.Param("channelId"))
+	if err != nil {
+		msg := `channel id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAlertAll)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to test notification channels in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	channel, err := getNotificationChannel(ctx, appId, channelId)
+	if err != nil {
+		msg := `failed to fetch notification channel`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if channel == nil {
+		msg := fmt.Sprintf(`notification channel [%s] does not exist`, channelId)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	app.TeamId = *team.ID
+	a, err := app.getWithTeam(appId)
+	if err != nil {
+		msg := `failed to fetch app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if a == nil {
+		msg := fmt.Sprintf(`app [%s] does not exist`, appId)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	payload := map[string]any{
+		"team_id":  team.ID,
+		"app_id":   appId,
+		"app_name": a.AppName,
+		"message":  fmt.Sprintf("This is a test notification for the %q channel", channel.Name),
+	}
+
+	delivery, err := notify.Deliver(ctx, channel.channel(), notify.AlertTypeTest, payload)
+	if err != nil {
+		msg := `failed to send test notification`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	// the outcome is reported without the channel's
+	// response, so that test notifications can't be
+	// used to probe hosts
+	delivery.ResponseStatus = nil
+	delivery.LastError = nil
+
+	c.JSON(http.StatusOK, &delivery)
+}
+
+func GetNotificationDeliveries(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	channelId, err := uuid.Parse(c.Param("channelId"))
+	if err != nil {
+		msg := `channel id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId, team.ID.String(), *ScopeAlertRead)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to read notification channels in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	channel, err := getNotificationChannel(ctx, appId, channelId)
+	if err != nil {
+		msg := `failed to fetch notification channel`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if channel == nil {
+		msg := fmt.Sprintf(`notification channel [%s] does not exist`, channelId)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	deliveries, err := notify.GetDeliveries(ctx, channel.ID, maxDeliveries)
+	if err != nil {
+		msg := `failed to fetch notification deliveries`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
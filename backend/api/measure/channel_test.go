@@ -0,0 +1,87 @@
+package measure
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNotificationChannelValidate(t *testing.T) {
+	valid := NotificationChannel{
+		ID:         uuid.New(),
+		AppID:      uuid.New(),
+		Type:       "webhook",
+		Name:       "Ops",
+		URL:        "https://example.com/hooks/measure",
+		AlertTypes: []string{AlertTypeCrashRateSpike, AlertTypeNewIssue},
+	}
+
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+
+	slack := valid
+	slack.Type = "slack"
+	slack.URL = "https://hooks.slack.com/services/T000/B000/XXXX"
+	if err := slack.Validate(); err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+
+	invalid := map[string]func(ch *NotificationChannel){
+		"unknown type":       func(ch *NotificationChannel) { ch.Type = "email" },
+		"empty name":         func(ch *NotificationChannel) { ch.Name = "" },
+		"long name":          func(ch *NotificationChannel) { ch.Name = strings.Repeat("a", maxChannelNameLen+1) },
+		"http url":           func(ch *NotificationChannel) { ch.URL = "http://example.com/hooks" },
+		"relative url":       func(ch *NotificationChannel) { ch.URL = "/hooks" },
+		"localhost url":      func(ch *NotificationChannel) { ch.URL = "https://localhost/hooks" },
+		"loopback url":       func(ch *NotificationChannel) { ch.URL = "https://127.0.0.1/hooks" },
+		"metadata url":       func(ch *NotificationChannel) { ch.URL = "https://169.254.169.254/latest/meta-data" },
+		"private ipv6 url":   func(ch *NotificationChannel) { ch.URL = "https://[fd00::1]/hooks" },
+		"non slack host":     func(ch *NotificationChannel) { ch.Type = "slack" },
+		"unknown alert type": func(ch *NotificationChannel) { ch.AlertTypes = []string{"unknown"} },
+	}
+
+	for name, mutate := range invalid {
+		ch := valid
+		mutate(&ch)
+		if err := ch.Validate(); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+}
+
+func TestNotificationChannelPayloadApply(t *testing.T) {
+	name := "  Ops  "
+	alertTypes := []string{AlertTypeRegression, AlertTypeRegression, AlertTypeNewIssue}
+
+	ch := NotificationChannel{
+		Type: "webhook",
+		Name: "Old",
+		URL:  "https://example.com",
+	}
+
+	slack := "slack"
+
+	NotificationChannelPayload{
+		Type:       &slack,
+		Name:       &name,
+		AlertTypes: &alertTypes,
+	}.apply(&ch)
+
+	if ch.Type != "webhook" {
+		t.Errorf("Expected type to be unchanged, but got %q", ch.Type)
+	}
+
+	if ch.Name != "Ops" {
+		t.Errorf("Expected trimmed name %q, but got %q", "Ops", ch.Name)
+	}
+
+	if ch.URL != "https://example.com" {
+		t.Errorf("Expected url to be unchanged, but got %q", ch.URL)
+	}
+
+	if len(ch.AlertTypes) != 2 || ch.AlertTypes[0] != AlertTypeRegression || ch.AlertTypes[1] != AlertTypeNewIssue {
+		t.Errorf("Expected deduplicated alert types, but got %v", ch.AlertTypes)
+	}
+}
@@ -0,0 +1,658 @@
+package measure
+
+import (
+	"backend/api/event"
+	"backend/api/filter"
+	"backend/api/group"
+	"backend/api/metrics"
+	"backend/api/server"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+// maxAttributeCorrelations is the maximum count of
+// attribute correlations reported for an issue group.
+const maxAttributeCorrelations = 10
+
+// correlationAttributes is the array of attribute
+// name, value & user defined flag tuples of an event
+// that correlations are computed for, including all
+// user defined attributes.
+const correlationAttributes = `arrayConcat([
+	('app_version', concat(toString(attribute.app_version), ' (', toString(attribute.app_build), ')'), false),
+	('os_version', concat(toString(attribute.os_name), ' ', toString(attribute.os_version)), false),
+	('country', toStringCutToZero(inet.country_code), false),
+	('network_type', toString(attribute.network_type), false),
+	('network_generation', toString(attribute.network_generation), false),
+	('network_provider', toString(attribute.network_provider), false),
+	('locale', toString(attribute.device_locale), false),
+	('device_manufacturer', toString(attribute.device_manufacturer), false),
+	('device', concat(toString(attribute.device_manufacturer), ' - ', toString(attribute.device_name)), false)
+], arrayMap((k, v) -> (k, tupleElement(v, 2), true), mapKeys(user_defined_attribute), mapValues(user_defined_attribute)))`
+
+// AttributeCorrelation represents how over-represented
+// an attribute value is among sessions affected by an
+// issue group compared to all sessions of the app.
+type AttributeCorrelation struct {
+	Attribute   string `json:"attribute"`
+	Value       string `json:"value"`
+	UserDefined bool   `json:"user_defined"`
+	// GroupSessions is the count of sessions affected
+	// by the issue group having the attribute value.
+	GroupSessions uint64 `json:"group_sessions"`
+	// BaselineSessions is the count of all sessions
+	// having the attribute value.
+	BaselineSessions uint64 `json:"baseline_sessions"`
+	// GroupShare is the percentage of sessions affected
+	// by the issue group having the attribute value.
+	GroupShare float64 `json:"group_share"`
+	// BaselineShare is the percentage of all sessions
+	// having the attribute value.
+	BaselineShare float64 `json:"baseline_share"`
+	// Lift is the ratio of group share to
+	// baseline share.
+	Lift        float64 `json:"lift"`
+	PValue      float64 `json:"p_value"`
+	Significant bool    `json:"significant"`
+}
+
+// attributeKey identifies an attribute value.
+type attributeKey struct {
+	attribute   string
+	value       string
+	userDefined bool
+}
+
+// GetIssueAttributeCorrelations finds attribute values that
+// are over-represented among sessions affected by the issue
+// group compared to all sessions of the app while respecting
+// all applicable app filters.
+func GetIssueAttributeCorrelations(ctx context.Context, g group.IssueGroup, af *filter.AppFilter) (correlations []AttributeCorrelation, err error) {
+	var scope string
+	var scopeArgs []any
+
+	switch g := g.(type) {
+	case *group.ANRGroup:
+		scope = "type = ? and anr.fingerprint in ?"
+		scopeArgs = []any{event.TypeANR, g.GetFingerprints()}
+	case *group.ExceptionGroup:
+		scope = "type = ? and exception.handled = ? and exception.fingerprint in ?"
+		scopeArgs = []any{event.TypeException, g.Handled, g.GetFingerprints()}
+	default:
+		err = errors.New("couldn't determine correct type of issue group")
+		return
+	}
+
+	groupCounts, baselineCounts, groupTotal, baselineTotal, err := getAttributeSessions(ctx, af, scope, scopeArgs...)
+	if err != nil {
+		return
+	}
+
+	correlations = computeAttributeCorrelations(groupCounts, baselineCounts, groupTotal, baselineTotal)
+
+	if len(correlations) > maxAttributeCorrelations {
+		correlations = correlations[:maxAttributeCorrelations]
+	}
+
+	return
+}
+
+// attributeSessionsStmt builds the statement counting
+// sessions by attribute value among events matching the
+// scope & among all events, in a single pass over events.
+// The grand total row has an empty attribute.
+func attributeSessionsStmt(af *filter.AppFilter, scope string, scopeArgs ...any) *sqlf.Stmt {
+	stmt := sqlf.
+		From("events").
+		Select("kv.1 as attribute").
+		Select("kv.2 as value").
+		Select("kv.3 as user_defined").
+		Select(fmt.Sprintf("uniqIf(session_id, %s) as group_sessions", scope), scopeArgs...).
+		Select("uniq(session_id) as baseline_sessions").
+		Clause(fmt.Sprintf("array join %s as kv", correlationAttributes)).
+		Clause("prewhere app_id = toUUID(?)", af.AppID)
+
+	applyEventFilters(stmt, af)
+
+	stmt.
+		GroupBy("grouping sets ((attribute, value, user_defined), ())").
+		Having("attribute = '' or value != ''")
+
+	return stmt
+}
+
+// getAttributeSessions counts sessions by attribute value
+// among events matching the scope & among all events along
+// with the total count of sessions of each.
+func getAttributeSessions(ctx context.Context, af *filter.AppFilter, scope string, scopeArgs ...any) (groupCounts, baselineCounts map[attributeKey]uint64, groupTotal, baselineTotal uint64, err error) {
+	groupCounts = make(map[attributeKey]uint64)
+	baselineCounts = make(map[attributeKey]uint64)
+
+	stmt := attributeSessionsStmt(af, scope, scopeArgs...)
+
+	defer stmt.Close()
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		var key attributeKey
+		var groupSessions, baselineSessions uint64
+		if err = rows.Scan(&key.attribute, &key.value, &key.userDefined, &groupSessions, &baselineSessions); err != nil {
+			return
+		}
+
+		if key.attribute == "" {
+			groupTotal = groupSessions
+			baselineTotal = baselineSessions
+			continue
+		}
+
+		if groupSessions > 0 {
+			groupCounts[key] = groupSessions
+		}
+		baselineCounts[key] = baselineSessions
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// computeAttributeCorrelations computes share & lift of each
+// attribute value among sessions affected by the group over
+// all sessions. Significance is tested by comparing affected
+// sessions against the remaining sessions. Only over-represented
+// values are kept, significant ones first, ordered by lift.
+func computeAttributeCorrelations(groupCounts, baselineCounts map[attributeKey]uint64, groupTotal, baselineTotal uint64) (correlations []AttributeCorrelation) {
+	correlations = []AttributeCorrelation{}
+
+	if groupTotal == 0 || baselineTotal == 0 {
+		return
+	}
+
+	for key, groupSessions := range groupCounts {
+		baselineSessions := baselineCounts[key]
+		if baselineSessions == 0 {
+			continue
+		}
+
+		groupShare := float64(groupSessions) / float64(groupTotal)
+		baselineShare := float64(baselineSessions) / float64(baselineTotal)
+		lift := groupShare / baselineShare
+
+		if lift <= 1 {
+			continue
+		}
+
+		var restSessions, restTotal uint64
+		if baselineTotal > groupTotal && baselineSessions > groupSessions {
+			restSessions = baselineSessions - groupSessions
+		}
+		if baselineTotal > groupTotal {
+			restTotal = baselineTotal - groupTotal
+		}
+
+		pValue := metrics.ProportionsTest(groupSessions, groupTotal, restSessions, restTotal)
+
+		correlations = append(correlations, AttributeCorrelation{
+			Attribute:        key.attribute,
+			Value:            key.value,
+			UserDefined:      key.userDefined,
+			GroupSessions:    groupSessions,
+			BaselineSessions: baselineSessions,
+			GroupShare:       percentage(groupSessions, groupTotal),
+			BaselineShare:    percentage(baselineSessions, baselineTotal),
+			Lift:             math.Round(lift*100) / 100,
+			PValue:           pValue,
+			Significant:      pValue < metrics.SignificanceLevel,
+		})
+	}
+
+	sort.Slice(correlations, func(i, j int) bool {
+		a, b := correlations[i], correlations[j]
+		if a.Significant != b.Significant {
+			return a.Significant
+		}
+		if a.Lift != b.Lift {
+			return a.Lift > b.Lift
+		}
+		if a.GroupSessions != b.GroupSessions {
+			return a.GroupSessions > b.GroupSessions
+		}
+		if a.Attribute != b.Attribute {
+			return a.Attribute < b.Attribute
+		}
+		return a.Value < b.Value
+	})
+
+	return
+}
+
+func GetCrashDetailAttributeCorrelations(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	crashGroupId, err := uuid.Parse(c.Param("crashGroupId"))
+	if err != nil {
+		msg := `crash group id is invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse query parameters`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := "app filters request validation failed"
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	group, err := app.GetExceptionGroup(ctx, crashGroupId)
+	if err != nil {
+		msg := fmt.Sprintf("failed to get exception group with id %q", crashGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if group == nil || group.Handled {
+		msg := fmt.Sprintf("no crash group found with id %q", crashGroupId)
+		fmt.Println(msg)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	correlations, err := GetIssueAttributeCorrelations(ctx, group, &af)
+	if err != nil {
+		msg := `failed to compute crash attribute correlations`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, correlations)
+}
+
+func GetANRDetailAttributeCorrelations(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	anrGroupId, err := uuid.Parse(c.Param("anrGroupId"))
+	if err != nil {
+		msg := `anr group id is invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse query parameters`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := "app filters request validation failed"
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	group, err := app.GetANRGroup(ctx, anrGroupId)
+	if err != nil {
+		msg := fmt.Sprintf("failed to get anr group with id %q", anrGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if group == nil {
+		msg := fmt.Sprintf("no anr group found with id %q", anrGroupId)
+		fmt.Println(msg)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	correlations, err := GetIssueAttributeCorrelations(ctx, group, &af)
+	if err != nil {
+		msg := `failed to compute anr attribute correlations`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, correlations)
+}
+
+func GetNonFatalDetailAttributeCorrelations(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	nonFatalGroupId, err := uuid.Parse(c.Param("nonFatalGroupId"))
+	if err != nil {
+		msg := `non-fatal group id is invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse query parameters`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := "app filters request validation failed"
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	group, err := app.GetExceptionGroup(ctx, nonFatalGroupId)
+	if err != nil {
+		msg := fmt.Sprintf("failed to get exception group with id %q", nonFatalGroupId.String())
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if group == nil || !group.Handled {
+		msg := fmt.Sprintf("no non-fatal exception group found with id %q", nonFatalGroupId)
+		fmt.Println(msg)
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	correlations, err := GetIssueAttributeCorrelations(ctx, group, &af)
+	if err != nil {
+		msg := `failed to compute non-fatal attribute correlations`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, correlations)
+}
@@ -0,0 +1,90 @@
+package measure
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComputeAttributeCorrelations(t *testing.T) {
+	samsung := attributeKey{attribute: "device_manufacturer", value: "samsung"}
+	google := attributeKey{attribute: "device_manufacturer", value: "Google"}
+	wifi := attributeKey{attribute: "network_type", value: "wifi"}
+	plan := attributeKey{attribute: "plan", value: "free", userDefined: true}
+
+	groupCounts := map[attributeKey]uint64{
+		samsung: 78,
+		google:  22,
+		wifi:    50,
+		plan:    3,
+	}
+
+	baselineCounts := map[attributeKey]uint64{
+		samsung: 200,
+		google:  800,
+		wifi:    500,
+		plan:    10,
+	}
+
+	correlations := computeAttributeCorrelations(groupCounts, baselineCounts, 100, 1000)
+
+	if len(correlations) != 2 {
+		t.Fatalf("Expected %d correlations, but got %d: %+v", 2, len(correlations), correlations)
+	}
+
+	first := correlations[0]
+	if first.Value != "samsung" || !first.Significant {
+		t.Errorf("Expected %q to be the first significant correlation, but got %+v", "samsung", first)
+	}
+
+	if first.GroupShare != 78 || first.BaselineShare != 20 || first.Lift != 3.9 {
+		t.Errorf("Expected shares of %v & %v with lift %v, but got %v & %v with lift %v", 78, 20, 3.9, first.GroupShare, first.BaselineShare, first.Lift)
+	}
+
+	second := correlations[1]
+	if second.Value != "free" || !second.UserDefined || second.Lift != 3 {
+		t.Errorf("Expected %q to be a user defined correlation with lift %v, but got %+v", "free", 3, second)
+	}
+}
+
+func TestComputeAttributeCorrelationsEmpty(t *testing.T) {
+	correlations := computeAttributeCorrelations(map[attributeKey]uint64{}, map[attributeKey]uint64{}, 0, 0)
+
+	if correlations == nil || len(correlations) != 0 {
+		t.Errorf("Expected empty correlations, but got %+v", correlations)
+	}
+}
+
+func TestAttributeSessionsStmt(t *testing.T) {
+	af := newTestAppFilter()
+
+	stmt := attributeSessionsStmt(af, "type = ? and anr.fingerprint in ?", "anr", []string{"a1b2"})
+	defer stmt.Close()
+
+	sql := stmt.String()
+
+	expected := []string{
+		"uniqIf(session_id, type = ? and anr.fingerprint in ?) as group_sessions",
+		"uniq(session_id) as baseline_sessions",
+		"FROM events array join arrayConcat(",
+		"mapKeys(user_defined_attribute)",
+		"prewhere app_id = toUUID(?) WHERE",
+		"timestamp >= ? and timestamp <= ?",
+		"GROUP BY grouping sets ((attribute, value, user_defined), ())",
+		"HAVING attribute = '' or value != ''",
+	}
+
+	for _, want := range expected {
+		if !strings.Contains(sql, want) {
+			t.Errorf("Expected statement to contain %q, got %s", want, sql)
+		}
+	}
+
+	if strings.Count(sql, "FROM events") != 1 {
+		t.Errorf("Expected a single scan of events, got %s", sql)
+	}
+
+	args := stmt.Args()
+	if args[0] != "anr" {
+		t.Errorf("Expected scope args first, but got %v", args[0])
+	}
+}
@@ -0,0 +1,1069 @@
+package measure
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	// embed the timezone database so that user
+	// timezones resolve on hosts without one
+	_ "time/tzdata"
+
+	"backend/api/chrono"
+	"backend/api/email"
+	"backend/api/filter"
+	"backend/api/metrics"
+	"backend/api/notify"
+	"backend/api/server"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+const (
+	DigestFrequencyOff    = "off"
+	DigestFrequencyDaily  = "daily"
+	DigestFrequencyWeekly = "weekly"
+)
+
+// defaultDigestHour is the hour of the day
+// digests are sent at unless set.
+const defaultDigestHour = 9
+
+// maxDigestTimezoneLen is the maximum length
+// of a digest timezone.
+const maxDigestTimezoneLen = 64
+
+// maxDigestIssues is the maximum count of new
+// issues or regressions listed in a digest.
+const maxDigestIssues = 5
+
+// maxDigestIssueCandidates is the maximum count of
+// new issues or regressions ranked for a digest.
+const maxDigestIssueCandidates = 20
+
+// digestBatchSize is the maximum count of
+// digests sent at once.
+const digestBatchSize = 20
+
+// digestRetryDelay is the delay after which
+// sending a claimed digest is attempted again,
+// like when sending it failed mid-way.
+const digestRetryDelay = time.Hour
+
+// DigestPref represents a user's preferences
+// for the scheduled digest of an app.
+type DigestPref struct {
+	AppID     uuid.UUID `json:"app_id" db:"app_id"`
+	UserID    uuid.UUID `json:"user_id" db:"user_id"`
+	Frequency string    `json:"frequency" db:"frequency"`
+	// Hour is the hour of the day, in the
+	// timezone, the digest is sent at.
+	Hour int `json:"hour" db:"hour"`
+	// Weekday is the day of the week weekly
+	// digests are sent on, 0 being Sunday.
+	Weekday  int    `json:"weekday" db:"weekday"`
+	Timezone string `json:"timezone" db:"timezone"`
+	Email    bool   `json:"email" db:"email"`
+	// ChannelID is the notification channel
+	// the digest is delivered to, if any.
+	ChannelID  *uuid.UUID      `json:"channel_id" db:"channel_id"`
+	LastSentAt *chrono.ISOTime `json:"last_sent_at" db:"last_sent_at"`
+	NextSendAt *chrono.ISOTime `json:"next_send_at" db:"next_send_at"`
+	CreatedAt  chrono.ISOTime  `json:"created_at" db:"created_at"`
+	UpdatedAt  chrono.ISOTime  `json:"updated_at" db:"updated_at"`
+}
+
+// DigestPrefPayload represents the request
+// to update digest preferences. Absent fields
+// are left unchanged.
+type DigestPrefPayload struct {
+	Frequency *string `json:"frequency"`
+	Hour      *int    `json:"hour"`
+	Weekday   *int    `json:"weekday"`
+	Timezone  *string `json:"timezone"`
+	Email     *bool   `json:"email"`
+	// ChannelID is the id of the notification
+	// channel to deliver the digest to. An empty
+	// string stops delivery to channels.
+	ChannelID *string `json:"channel_id"`
+}
+
+// digestNotification is the payload of digests
+// delivered to notification channels.
+type digestNotification struct {
+	email.DigestPayload
+	Message string `json:"message"`
+}
+
+// newDigestPref creates digest preferences with
+// default values. Digests are off by default.
+func newDigestPref(appId, userId uuid.UUID) *DigestPref {
+	now := chrono.ISOTime(time.Now())
+
+	return &DigestPref{
+		AppID:     appId,
+		UserID:    userId,
+		Frequency: DigestFrequencyOff,
+		Hour:      defaultDigestHour,
+		Weekday:   int(time.Monday),
+		Timezone:  "UTC",
+		Email:     true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// apply updates the preferences with the
+// fields present in the payload.
+func (p DigestPrefPayload) apply(pref *DigestPref) error {
+	if p.Frequency != nil {
+		pref.Frequency = *p.Frequency
+	}
+	if p.Hour != nil {
+		pref.Hour = *p.Hour
+	}
+	if p.Weekday != nil {
+		pref.Weekday = *p.Weekday
+	}
+	if p.Timezone != nil {
+		pref.Timezone = strings.TrimSpace(*p.Timezone)
+	}
+	if p.Email != nil {
+		pref.Email = *p.Email
+	}
+	if p.ChannelID != nil {
+		if *p.ChannelID == "" {
+			pref.ChannelID = nil
+		} else {
+			channelId, err := uuid.Parse(*p.ChannelID)
+			if err != nil {
+				return fmt.Errorf("channel_id is invalid: %w", err)
+			}
+			pref.ChannelID = &channelId
+		}
+	}
+
+	return nil
+}
+
+// Validate validates the digest preferences.
+func (pref DigestPref) Validate() error {
+	frequencies := []string{DigestFrequencyOff, DigestFrequencyDaily, DigestFrequencyWeekly}
+	if !slices.Contains(frequencies, pref.Frequency) {
+		return fmt.Errorf("frequency must be one of %s", strings.Join(frequencies, ", "))
+	}
+
+	if pref.Hour < 0 || pref.Hour > 23 {
+		return errors.New("hour must be between 0 and 23")
+	}
+
+	if pref.Weekday < 0 || pref.Weekday > 6 {
+		return errors.New("weekday must be between 0 and 6")
+	}
+
+	if pref.Timezone == "" || len(pref.Timezone) > maxDigestTimezoneLen {
+		return errors.New("timezone must be a valid IANA timezone")
+	}
+
+	if _, err := time.LoadLocation(pref.Timezone); err != nil {
+		return errors.New("timezone must be a valid IANA timezone")
+	}
+
+	if pref.Frequency != DigestFrequencyOff && !pref.Email && pref.ChannelID == nil {
+		return errors.New("digest must be delivered by email or to a channel")
+	}
+
+	return nil
+}
+
+// location returns the timezone of the preferences,
+// falling back to UTC if it's unknown.
+func (pref DigestPref) location() *time.Location {
+	loc, err := time.LoadLocation(pref.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+
+	return loc
+}
+
+// nextSendAt computes when the next digest is due
+// after the time. Returns nil if digests are off.
+func (pref DigestPref) nextSendAt(after time.Time) *chrono.ISOTime {
+	local := after.In(pref.location())
+	next := time.Date(local.Year(), local.Month(), local.Day(), pref.Hour, 0, 0, 0, local.Location())
+
+	switch pref.Frequency {
+	case DigestFrequencyDaily:
+		if !next.After(after) {
+			next = next.AddDate(0, 0, 1)
+		}
+	case DigestFrequencyWeekly:
+		next = next.AddDate(0, 0, (pref.Weekday-int(next.Weekday())+7)%7)
+		if !next.After(after) {
+			next = next.AddDate(0, 0, 7)
+		}
+	default:
+		return nil
+	}
+
+	sendAt := chrono.ISOTime(next.UTC())
+
+	return &sendAt
+}
+
+// digestPeriod returns the length of the period
+// covered by digests of the frequency & its name.
+func digestPeriod(frequency string) (period time.Duration, name string) {
+	if frequency == DigestFrequencyDaily {
+		return 24 * time.Hour, "day"
+	}
+
+	return 7 * 24 * time.Hour, "week"
+}
+
+// describeChange describes the change of a
+// percentage from the previous period.
+func describeChange(current, previous float64, period string) string {
+	diff := current - previous
+
+	switch {
+	case math.IsNaN(previous):
+		return fmt.Sprintf("%.2f%%", current)
+	case math.Abs(diff) < 0.01:
+		return fmt.Sprintf("%.2f%%, unchanged from the previous %s", current, period)
+	case diff > 0:
+		return fmt.Sprintf("%.2f%%, up %.2f points from the previous %s", current, diff, period)
+	default:
+		return fmt.Sprintf("%.2f%%, down %.2f points from the previous %s", current, -diff, period)
+	}
+}
+
+// describeLaunch describes the p95 launch time of
+// the latest version given its ratio to the p95
+// launch time of other versions.
+func describeLaunch(kind string, p95, delta float64) string {
+	change := math.Round((delta - 1) * 100)
+
+	switch {
+	case change == 0:
+		return fmt.Sprintf("%s launch p95: %.0f ms, same as other versions", kind, p95)
+	case change > 0:
+		return fmt.Sprintf("%s launch p95: %.0f ms, %.0f%% slower than other versions", kind, p95, change)
+	default:
+		return fmt.Sprintf("%s launch p95: %.0f ms, %.0f%% faster than other versions", kind, p95, -change)
+	}
+}
+
+// describeIssue describes an issue of
+// a digest with its occurrences.
+func describeIssue(ia IssueAlert, stats IssueStats) string {
+	title := ia.Title
+	if ia.Frame != "" {
+		title = fmt.Sprintf("%s at %s", title, ia.Frame)
+	}
+
+	return fmt.Sprintf("%s in %s (%s), %d events from %d users", title, ia.AppVersion, ia.AppBuild, stats.Events, stats.Users)
+}
+
+// digestMessage renders the digest as plain
+// text for notification channels.
+func digestMessage(d email.DigestPayload) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Your %s digest, %s to %s", d.Period, d.From.Format("Jan 2"), d.To.Format("Jan 2, 2006"))
+
+	for _, section := range d.Sections {
+		fmt.Fprintf(&b, "\n\n%s", section.Title)
+		for _, item := range section.Items {
+			fmt.Fprintf(&b, "\n- %s", item)
+		}
+	}
+
+	return b.String()
+}
+
+// buildDigest builds the digest of the app for the
+// period of the frequency ending at the time. Dates
+// of the digest are in the time's location.
+func buildDigest(ctx context.Context, app *App, frequency string, to time.Time) (digest email.DigestPayload, err error) {
+	length, period := digestPeriod(frequency)
+	from := to.Add(-length)
+
+	digest = email.DigestPayload{
+		TeamID:  app.TeamId,
+		AppID:   *app.ID,
+		AppName: app.AppName,
+		Period:  frequency,
+		From:    from,
+		To:      to,
+	}
+
+	af := &filter.AppFilter{
+		AppID: *app.ID,
+		From:  from.UTC(),
+		To:    to.UTC(),
+	}
+
+	previous := &filter.AppFilter{
+		AppID: *app.ID,
+		From:  from.Add(-length).UTC(),
+		To:    from.UTC(),
+	}
+
+	crashFree, anrFree, err := app.GetIssueFreeUserMetrics(ctx, af)
+	if err != nil {
+		return
+	}
+
+	previousCrashFree, previousANRFree, err := app.GetIssueFreeUserMetrics(ctx, previous)
+	if err != nil {
+		return
+	}
+
+	stability := email.DigestSection{
+		Title: "Stability",
+	}
+
+	if crashFree.NaN {
+		stability.Items = append(stability.Items, fmt.Sprintf("No sessions this %s", period))
+	} else {
+		previousCrashFreeUsers, previousANRFreeUsers := math.NaN(), math.NaN()
+		if !previousCrashFree.NaN {
+			previousCrashFreeUsers = previousCrashFree.CrashFreeUsers
+		}
+		if !previousANRFree.NaN {
+			previousANRFreeUsers = previousANRFree.ANRFreeUsers
+		}
+
+		stability.Items = append(stability.Items,
+			"Crash free users: "+describeChange(crashFree.CrashFreeUsers, previousCrashFreeUsers, period),
+			"ANR free users: "+describeChange(anrFree.ANRFreeUsers, previousANRFreeUsers, period),
+		)
+	}
+
+	digest.Sections = append(digest.Sections, stability)
+
+	for _, issues := range []struct {
+		alertType string
+		title     string
+		empty     string
+	}{
+		{AlertTypeNewIssue, "New issues", "No new issues"},
+		{AlertTypeRegression, "Regressions", "No regressions"},
+	} {
+		section := email.DigestSection{
+			Title: issues.title,
+		}
+
+		section.Items, err = getDigestIssues(ctx, *app.ID, issues.alertType, from, to)
+		if err != nil {
+			return
+		}
+
+		if len(section.Items) == 0 {
+			section.Items = []string{issues.empty}
+		}
+
+		digest.Sections = append(digest.Sections, section)
+	}
+
+	version, build, err := getLatestVersion(ctx, *app.ID)
+	if err != nil {
+		return
+	}
+
+	if version != "" {
+		af.Versions = []string{version}
+		af.VersionCodes = []string{build}
+
+		var adoption *metrics.SessionAdoption
+		adoption, err = app.GetAdoptionMetrics(ctx, af)
+		if err != nil {
+			return
+		}
+
+		var launch *metrics.LaunchMetric
+		launch, err = app.GetLaunchMetrics(ctx, af)
+		if err != nil {
+			return
+		}
+
+		section := email.DigestSection{
+			Title: fmt.Sprintf("Latest version %s (%s)", version, build),
+		}
+
+		if adoption.NaN {
+			section.Items = append(section.Items, fmt.Sprintf("No sessions this %s", period))
+		} else {
+			section.Items = append(section.Items, fmt.Sprintf("Adoption: %.2f%% of sessions, %d of %d", adoption.Adoption, adoption.SelectedVersion, adoption.AllVersions))
+		}
+
+		if !launch.ColdNaN {
+			section.Items = append(section.Items, describeLaunch("Cold", launch.ColdLaunchP95, launch.ColdDelta))
+		}
+		if !launch.WarmNaN {
+			section.Items = append(section.Items, describeLaunch("Warm", launch.WarmLaunchP95, launch.WarmDelta))
+		}
+		if !launch.HotNaN {
+			section.Items = append(section.Items, describeLaunch("Hot", launch.HotLaunchP95, launch.HotDelta))
+		}
+
+		digest.Sections = append(digest.Sections, section)
+	}
+
+	usage, err := getAppUsage(ctx, []App{*app}, to)
+	if err != nil {
+		return
+	}
+
+	month := to.Format("Jan 2006")
+	for _, appUsage := range usage {
+		for _, monthly := range appUsage.MonthlyAppUsage {
+			if monthly.MonthName != month {
+				continue
+			}
+
+			digest.Sections = append(digest.Sections, email.DigestSection{
+				Title: fmt.Sprintf("Usage in %s", month),
+				Items: []string{
+					fmt.Sprintf("Events: %d", monthly.EventsCount),
+					fmt.Sprintf("Sessions: %d", monthly.SessionsCount),
+					fmt.Sprintf("Traces: %d", monthly.TracesCount),
+					fmt.Sprintf("Spans: %d", monthly.SpansCount),
+				},
+			})
+		}
+	}
+
+	return
+}
+
+// getDigestIssues describes the issues of the alert
+// type seen within the period, most occurring first.
+func getDigestIssues(ctx context.Context, appId uuid.UUID, alertType string, from, to time.Time) (items []string, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.issue_alerts").
+		Select("id").
+		Select("app_id").
+		Select("alert_type").
+		Select("group_type").
+		Select("group_id").
+		Select("fingerprint").
+		Select("title").
+		Select("frame").
+		Select("app_version").
+		Select("app_build").
+		Select("seen_at").
+		Where("app_id = ?", appId).
+		Where("alert_type = ?", alertType).
+		Where("status != ?", issueAlertExpired).
+		Where("seen_at >= ? and seen_at < ?", from, to).
+		OrderBy("seen_at desc").
+		Limit(maxDigestIssueCandidates)
+
+	defer stmt.Close()
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	alerts, err := pgx.CollectRows(rows, pgx.RowToStructByName[IssueAlert])
+	if err != nil {
+		return
+	}
+
+	stats := make([]IssueStats, len(alerts))
+	for i, alert := range alerts {
+		if stats[i], err = getIssueStats(ctx, alert, false); err != nil {
+			return
+		}
+	}
+
+	order := make([]int, len(alerts))
+	for i := range order {
+		order[i] = i
+	}
+
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(stats[b].Events, stats[a].Events)
+	})
+
+	for _, i := range order[:min(len(order), maxDigestIssues)] {
+		items = append(items, describeIssue(alerts[i], stats[i]))
+	}
+
+	return
+}
+
+// appVersion represents the name
+// & code of an app's version.
+type appVersion struct {
+	name string
+	code string
+}
+
+// getLatestVersion fetches the version name & code
+// of the latest version of the app. Returns empty
+// values if the app has no versions yet.
+func getLatestVersion(ctx context.Context, appId uuid.UUID) (version, build string, err error) {
+	versions, err := getRecentVersions(ctx, appId, 1)
+	if err != nil || len(versions) == 0 {
+		return
+	}
+
+	return versions[0].name, versions[0].code, nil
+}
+
+// getRecentVersions fetches up to limit of the
+// app's most recent versions, newest first, going
+// by their version codes.
+func getRecentVersions(ctx context.Context, appId uuid.UUID, limit uint64) (versions []appVersion, err error) {
+	stmt := sqlf.From("default.app_filters").
+		Select("toString(tupleElement(app_version, 1)) as name").
+		Select("toString(tupleElement(app_version, 2)) as code").
+		Where("app_id = toUUID(?)", appId).
+		GroupBy("app_version").
+		OrderBy("toUInt64OrZero(code) desc").
+		Limit(limit)
+
+	defer stmt.Close()
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var v appVersion
+		if err = rows.Scan(&v.name, &v.code); err != nil {
+			return
+		}
+		versions = append(versions, v)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// getDigestRecipient fetches the email of the user
+// if they're still a member of the app's team.
+func getDigestRecipient(ctx context.Context, appId, userId uuid.UUID) (recipient string, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.apps").
+		Select("users.email").
+		Join("public.team_membership", "team_membership.team_id = apps.team_id").
+		Join("public.users", "users.id = team_membership.user_id").
+		Where("apps.id = ?", appId).
+		Where("users.id = ?", userId)
+
+	defer stmt.Close()
+
+	err = server.Server.PgPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&recipient)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = nil
+	}
+
+	return
+}
+
+// send builds the digest & queues it for delivery
+// by email & to the notification channel.
+func (pref DigestPref) send(ctx context.Context, app *App, to time.Time) (err error) {
+	recipient, err := getDigestRecipient(ctx, pref.AppID, pref.UserID)
+	if err != nil {
+		return
+	}
+
+	// no longer a member of the team
+	if recipient == "" {
+		return
+	}
+
+	digest, err := buildDigest(ctx, app, pref.Frequency, to.In(pref.location()))
+	if err != nil {
+		return
+	}
+
+	if pref.Email {
+		if err = email.Enqueue(ctx, email.Email{
+			Kind:      email.KindDigest,
+			Recipient: recipient,
+			UserID:    &pref.UserID,
+			AppID:     &pref.AppID,
+			Payload:   digest,
+		}); err != nil {
+			return
+		}
+	}
+
+	if pref.ChannelID != nil {
+		return notify.EnqueueChannel(ctx, *pref.ChannelID, notify.AlertTypeDigest, digestNotification{
+			DigestPayload: digest,
+			Message:       digestMessage(digest),
+		})
+	}
+
+	return
+}
+
+// markSent records the digest as sent &
+// schedules the next one.
+func (pref *DigestPref) markSent(ctx context.Context, now time.Time) (err error) {
+	sentAt := chrono.ISOTime(now)
+	pref.LastSentAt = &sentAt
+	pref.NextSendAt = pref.nextSendAt(now)
+
+	stmt := sqlf.PostgreSQL.Update("public.digest_prefs").
+		Set("last_sent_at", now).
+		Set("next_send_at", (*time.Time)(pref.NextSendAt)).
+		Where("app_id = ?", pref.AppID).
+		Where("user_id = ?", pref.UserID)
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// RunDigests sends due digests at every
+// interval until the context is done.
+func RunDigests(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := sendDigests(ctx); err != nil {
+				fmt.Println("failed to send digests", err)
+			}
+		}
+	}
+}
+
+// sendDigests claims due digests & sends them.
+// Failing digests don't stop sending the rest
+// & are retried after a delay.
+func sendDigests(ctx context.Context) (err error) {
+	prefs, err := claimDigestPrefs(ctx)
+	if err != nil {
+		return
+	}
+
+	now := time.Now().Truncate(time.Minute)
+	apps := make(map[uuid.UUID]*App)
+
+	for _, pref := range prefs {
+		app, found := apps[pref.AppID]
+		if !found {
+			app = &App{
+				ID: &pref.AppID,
+			}
+			if err := app.Populate(ctx); err != nil {
+				fmt.Printf("failed to fetch app of digest for user %s: %v\n", pref.UserID, err)
+				continue
+			}
+			apps[pref.AppID] = app
+		}
+
+		if err := pref.send(ctx, app, now); err != nil {
+			fmt.Printf("failed to send digest of app %s to user %s: %v\n", pref.AppID, pref.UserID, err)
+			continue
+		}
+
+		if err := pref.markSent(ctx, now); err != nil {
+			fmt.Printf("failed to schedule digest of app %s for user %s: %v\n", pref.AppID, pref.UserID, err)
+		}
+	}
+
+	return
+}
+
+// digestPrefColumns lists the columns
+// of digest preferences.
+var digestPrefColumns = []string{
+	"app_id",
+	"user_id",
+	"frequency",
+	"hour",
+	"weekday",
+	"timezone",
+	"email",
+	"channel_id",
+	"last_sent_at",
+	"next_send_at",
+	"created_at",
+	"updated_at",
+}
+
+// claimDigestPrefs postpones due digests by the
+// retry delay & returns their preferences. Digests
+// locked by other senders are skipped.
+func claimDigestPrefs(ctx context.Context) (prefs []DigestPref, err error) {
+	now := time.Now()
+
+	stmt := sqlf.PostgreSQL.Update("public.digest_prefs").
+		Set("next_send_at", now.Add(digestRetryDelay)).
+		Where("(app_id, user_id) in (select app_id, user_id from public.digest_prefs where frequency != ? and next_send_at <= ? order by next_send_at limit ? for update skip locked)", DigestFrequencyOff, now, digestBatchSize)
+
+	defer stmt.Close()
+
+	for _, col := range digestPrefColumns {
+		stmt.Returning(col)
+	}
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[DigestPref])
+}
+
+// getDigestPref fetches the user's digest preferences
+// for the app. Returns the defaults if the user never
+// saved them.
+func getDigestPref(ctx context.Context, appId, userId uuid.UUID) (pref *DigestPref, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.digest_prefs").
+		Where("app_id = ?", appId).
+		Where("user_id = ?", userId)
+
+	defer stmt.Close()
+
+	for _, col := range digestPrefColumns {
+		stmt.Select(col)
+	}
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	found, err := pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[DigestPref])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return newDigestPref(appId, userId), nil
+	}
+	if err != nil {
+		return
+	}
+
+	return &found, nil
+}
+
+// save creates or updates the digest preferences.
+func (pref *DigestPref) save(ctx context.Context) (err error) {
+	stmt := sqlf.PostgreSQL.InsertInto("public.digest_prefs").
+		Set("app_id", pref.AppID).
+		Set("user_id", pref.UserID).
+		Set("frequency", pref.Frequency).
+		Set("hour", pref.Hour).
+		Set("weekday", pref.Weekday).
+		Set("timezone", pref.Timezone).
+		Set("email", pref.Email).
+		Set("channel_id", pref.ChannelID).
+		Set("next_send_at", (*time.Time)(pref.NextSendAt)).
+		Set("created_at", time.Time(pref.CreatedAt)).
+		Set("updated_at", time.Time(pref.UpdatedAt)).
+		Clause("on conflict (app_id, user_id) do update set frequency = excluded.frequency, hour = excluded.hour, weekday = excluded.weekday, timezone = excluded.timezone, email = excluded.email, channel_id = excluded.channel_id, next_send_at = excluded.next_send_at, updated_at = excluded.updated_at")
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// unsubscribe turns off emails of the digest. The
+// digest is turned off unless it's also delivered
+// to a channel.
+func (pref *DigestPref) unsubscribe(ctx context.Context) (err error) {
+	pref.Email = false
+	if pref.ChannelID == nil {
+		pref.Frequency = DigestFrequencyOff
+	}
+	pref.NextSendAt = pref.nextSendAt(time.Now())
+	pref.UpdatedAt = chrono.ISOTime(time.Now())
+
+	return pref.save(ctx)
+}
+
+func GetDigestPrefs(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId, err := uuid.Parse(c.GetString("userId"))
+	if err != nil {
+		msg := `user id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId.String(), team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to read apps in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	pref, err := getDigestPref(ctx, appId, userId)
+	if err != nil {
+		msg := `failed to fetch digest preferences`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, pref)
+}
+
+func UpdateDigestPrefs(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId, err := uuid.Parse(c.GetString("userId"))
+	if err != nil {
+		msg := `user id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId.String(), team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to read apps in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var payload DigestPrefPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		msg := `failed to parse digest preferences json payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	pref, err := getDigestPref(ctx, appId, userId)
+	if err != nil {
+		msg := `failed to fetch digest preferences`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if err := payload.apply(pref); err != nil {
+		msg := `digest preferences are invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	if err := pref.Validate(); err != nil {
+		msg := `digest preferences are invalid`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	if pref.ChannelID != nil {
+		channel, err := getNotificationChannel(ctx, appId, *pref.ChannelID)
+		if err != nil {
+			msg := `failed to fetch notification channel`
+			fmt.Println(msg, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+		if channel == nil {
+			msg := fmt.Sprintf(`notification channel [%s] does not exist`, pref.ChannelID)
+			c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+			return
+		}
+	}
+
+	now := time.Now()
+	pref.NextSendAt = pref.nextSendAt(now)
+	pref.UpdatedAt = chrono.ISOTime(now)
+
+	if err := pref.save(ctx); err != nil {
+		msg := `failed to update digest preferences`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, pref)
+}
+
+func GetDigestPreview(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId, err := uuid.Parse(c.GetString("userId"))
+	if err != nil {
+		msg := `user id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	ok, err := PerformAuthz(userId.String(), team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if !ok {
+		msg := fmt.Sprintf(`you don't have permissions to read apps in team [%s]`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	pref, err := getDigestPref(ctx, appId, userId)
+	if err != nil {
+		msg := `failed to fetch digest preferences`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	frequency := c.DefaultQuery("frequency", pref.Frequency)
+	if frequency == DigestFrequencyOff {
+		frequency = DigestFrequencyWeekly
+	}
+
+	if frequency != DigestFrequencyDaily && frequency != DigestFrequencyWeekly {
+		msg := fmt.Sprintf(`frequency must be one of %s, %s`, DigestFrequencyDaily, DigestFrequencyWeekly)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if err := app.Populate(ctx); err != nil {
+		msg := `failed to fetch app`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	digest, err := buildDigest(ctx, &app, frequency, time.Now().Truncate(time.Minute).In(pref.location()))
+	if err != nil {
+		msg := `failed to build digest`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	msg, err := email.Preview(email.KindDigest, digest)
+	if err != nil {
+		msg := `failed to render digest`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"subject": msg.Subject,
+		"text":    msg.Text,
+		"html":    msg.HTML,
+		"digest":  digest,
+	})
+}
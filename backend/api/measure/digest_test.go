@@ -0,0 +1,166 @@
+package measure
+
+import (
+	"testing"
+	"time"
+
+	"backend/api/email"
+
+	"github.com/google/uuid"
+)
+
+func TestDigestPrefValidate(t *testing.T) {
+	valid := *newDigestPref(uuid.New(), uuid.New())
+	valid.Frequency = DigestFrequencyWeekly
+	valid.Timezone = "Asia/Kolkata"
+
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+
+	invalid := map[string]func(p *DigestPref){
+		"unknown frequency": func(p *DigestPref) { p.Frequency = "monthly" },
+		"negative hour":     func(p *DigestPref) { p.Hour = -1 },
+		"hour past day":     func(p *DigestPref) { p.Hour = 24 },
+		"weekday past week": func(p *DigestPref) { p.Weekday = 7 },
+		"empty timezone":    func(p *DigestPref) { p.Timezone = "" },
+		"unknown timezone":  func(p *DigestPref) { p.Timezone = "Mars/Olympus" },
+		"no delivery":       func(p *DigestPref) { p.Email = false },
+	}
+
+	for name, mutate := range invalid {
+		p := valid
+		mutate(&p)
+		if err := p.Validate(); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+
+	off := valid
+	off.Frequency = DigestFrequencyOff
+	off.Email = false
+	if err := off.Validate(); err != nil {
+		t.Errorf("Expected turned off digest without delivery to be valid, but got %v", err)
+	}
+}
+
+func TestDigestPrefPayloadApply(t *testing.T) {
+	channelId := uuid.New()
+	pref := newDigestPref(uuid.New(), uuid.New())
+	pref.ChannelID = &channelId
+
+	frequency := DigestFrequencyDaily
+	timezone := " Europe/Berlin "
+	empty := ""
+
+	if err := (DigestPrefPayload{
+		Frequency: &frequency,
+		Timezone:  &timezone,
+		ChannelID: &empty,
+	}).apply(pref); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if pref.Frequency != DigestFrequencyDaily || pref.Timezone != "Europe/Berlin" {
+		t.Errorf("Expected frequency & trimmed timezone to be updated, but got %+v", pref)
+	}
+
+	if pref.ChannelID != nil {
+		t.Errorf("Expected empty channel id to remove channel")
+	}
+
+	if pref.Hour != defaultDigestHour || !pref.Email {
+		t.Errorf("Expected fields absent in payload to be unchanged")
+	}
+
+	invalid := "not-a-uuid"
+	if err := (DigestPrefPayload{ChannelID: &invalid}).apply(pref); err == nil {
+		t.Errorf("Expected error for invalid channel id")
+	}
+}
+
+func TestDigestPrefNextSendAt(t *testing.T) {
+	kolkata, _ := time.LoadLocation("Asia/Kolkata")
+
+	pref := DigestPref{
+		Frequency: DigestFrequencyDaily,
+		Hour:      9,
+		Timezone:  "Asia/Kolkata",
+	}
+
+	// Tuesday, 8 AM in Kolkata
+	now := time.Date(2024, 12, 24, 8, 0, 0, 0, kolkata)
+
+	expected := time.Date(2024, 12, 24, 9, 0, 0, 0, kolkata)
+	if next := pref.nextSendAt(now); next == nil || !time.Time(*next).Equal(expected) {
+		t.Errorf("Expected next daily digest at %v, but got %v", expected, next)
+	}
+
+	// right at the hour, the next one is due tomorrow
+	expected = time.Date(2024, 12, 25, 9, 0, 0, 0, kolkata)
+	if next := pref.nextSendAt(time.Date(2024, 12, 24, 9, 0, 0, 0, kolkata)); next == nil || !time.Time(*next).Equal(expected) {
+		t.Errorf("Expected next daily digest at %v, but got %v", expected, next)
+	}
+
+	pref.Frequency = DigestFrequencyWeekly
+	pref.Weekday = int(time.Monday)
+
+	expected = time.Date(2024, 12, 30, 9, 0, 0, 0, kolkata)
+	if next := pref.nextSendAt(now); next == nil || !time.Time(*next).Equal(expected) {
+		t.Errorf("Expected next weekly digest at %v, but got %v", expected, next)
+	}
+
+	pref.Weekday = int(time.Tuesday)
+
+	expected = time.Date(2024, 12, 24, 9, 0, 0, 0, kolkata)
+	if next := pref.nextSendAt(now); next == nil || !time.Time(*next).Equal(expected) {
+		t.Errorf("Expected next weekly digest at %v, but got %v", expected, next)
+	}
+
+	pref.Frequency = DigestFrequencyOff
+	if next := pref.nextSendAt(now); next != nil {
+		t.Errorf("Expected no next digest when turned off, but got %v", next)
+	}
+}
+
+func TestDescribeChange(t *testing.T) {
+	cases := map[string]struct {
+		current, previous float64
+	}{
+		"99.50%, up 0.30 points from the previous week":   {99.5, 99.2},
+		"99.20%, down 0.30 points from the previous week": {99.2, 99.5},
+		"99.50%, unchanged from the previous week":        {99.5, 99.5},
+	}
+
+	for expected, c := range cases {
+		if got := describeChange(c.current, c.previous, "week"); got != expected {
+			t.Errorf("Expected %q, but got %q", expected, got)
+		}
+	}
+}
+
+func TestDescribeLaunch(t *testing.T) {
+	if got := describeLaunch("Cold", 1200, 1.12); got != "Cold launch p95: 1200 ms, 12% slower than other versions" {
+		t.Errorf("Unexpected description %q", got)
+	}
+
+	if got := describeLaunch("Warm", 400, 0.8); got != "Warm launch p95: 400 ms, 20% faster than other versions" {
+		t.Errorf("Unexpected description %q", got)
+	}
+}
+
+func TestDigestMessage(t *testing.T) {
+	message := digestMessage(email.DigestPayload{
+		Period: DigestFrequencyWeekly,
+		From:   time.Date(2024, 12, 16, 9, 0, 0, 0, time.UTC),
+		To:     time.Date(2024, 12, 23, 9, 0, 0, 0, time.UTC),
+		Sections: []email.DigestSection{
+			{Title: "New issues", Items: []string{"NullPointerException at CheckoutActivity"}},
+		},
+	})
+
+	expected := "Your weekly digest, Dec 16 to Dec 23, 2024\n\nNew issues\n- NullPointerException at CheckoutActivity"
+	if message != expected {
+		t.Errorf("Expected message %q, but got %q", expected, message)
+	}
+}
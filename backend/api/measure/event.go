@@ -14,6 +14,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"mime/multipart"
 	"net"
 	"net/http"
@@ -63,6 +64,7 @@ type eventreq struct {
 	appId                  uuid.UUID
 	symbolicate            map[uuid.UUID]int
 	exceptionIds           []int
+	handledExceptionIds    []int
 	anrIds                 []int
 	size                   int64
 	symbolicationAttempted int
@@ -191,6 +193,10 @@ func (e *eventreq) read(c *gin.Context, appId uuid.UUID) error {
 			e.exceptionIds = append(e.exceptionIds, i)
 		}
 
+		if ev.IsHandledException() {
+			e.handledExceptionIds = append(e.handledExceptionIds, i)
+		}
+
 		if ev.IsANR() {
 			e.anrIds = append(e.anrIds, i)
 		}
@@ -398,6 +404,12 @@ func (e eventreq) hasUnhandledExceptions() bool {
 	return len(e.exceptionIds) > 0
 }
 
+// hasHandledExceptions returns true if event payload
+// contains handled exceptions.
+func (e eventreq) hasHandledExceptions() bool {
+	return len(e.handledExceptionIds) > 0
+}
+
 // hasANRs returns true if event payload contains
 // ANRs.
 func (e eventreq) hasANRs() bool {
@@ -436,6 +448,18 @@ func (e eventreq) getUnhandledExceptions() (events []event.EventField) {
 	return
 }
 
+// getHandledExceptions returns handled exceptions
+// from the event payload.
+func (e eventreq) getHandledExceptions() (events []event.EventField) {
+	if !e.hasHandledExceptions() {
+		return
+	}
+	for _, v := range e.handledExceptionIds {
+		events = append(events, e.events[v])
+	}
+	return
+}
+
 // getANRs returns ANRs from the event payload.
 func (e eventreq) getANRs() (events []event.EventField) {
 	if !e.hasANRs() {
@@ -463,24 +487,70 @@ func (e eventreq) bucketUnhandledExceptions(ctx context.Context, tx *pgx.Tx) (er
 			continue
 		}
 
-		matchedGroup, err := app.GetExceptionGroupByFingerprint(ctx, events[i].Exception.Fingerprint)
+		matchedGroup, err := app.GetExceptionGroupByFingerprint(ctx, events[i].Exception.Fingerprint, false)
 		if err != nil {
 			return err
 		}
 
 		if matchedGroup == nil {
-			exceptionGroup := group.NewExceptionGroup(events[i].AppID, events[i].Exception.GetType(), events[i].Exception.GetMessage(), events[i].Exception.GetMethodName(), events[i].Exception.GetFileName(), events[i].Exception.GetLineNumber(), events[i].Exception.Fingerprint, events[i].Timestamp)
+			exceptionGroup := group.NewExceptionGroup(events[i].AppID, events[i].Exception.GetType(), events[i].Exception.GetMessage(), events[i].Exception.GetMethodName(), events[i].Exception.GetFileName(), events[i].Exception.GetLineNumber(), events[i].Exception.Fingerprint, events[i].Timestamp, false)
 			if err := exceptionGroup.Insert(ctx, tx); err != nil {
 				return err
 			}
 
-			return nil
+			if err := newIssueAlert(AlertTypeNewIssue, event.TypeException, exceptionGroup.ID, &events[i]).insert(ctx, tx); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if isRegression(matchedGroup.LastEventTime, &events[i]) {
+			if err := newIssueAlert(AlertTypeRegression, event.TypeException, matchedGroup.ID, &events[i]).insert(ctx, tx); err != nil {
+				return err
+			}
+		}
+
+		if err := matchedGroup.UpdateTimeStamps(ctx, &events[i], tx); err != nil {
+			return err
+		}
+	}
+
+	return
+}
+
+// bucketHandledExceptions groups handled (non-fatal)
+// exceptions based on similarity.
+func (e eventreq) bucketHandledExceptions(ctx context.Context, tx *pgx.Tx) (err error) {
+	events := e.getHandledExceptions()
+
+	app := App{
+		ID: &e.appId,
+	}
+
+	for i := range events {
+		if events[i].Exception.Fingerprint == "" {
+			msg := fmt.Sprintf("no fingerprint found for event %q, cannot bucket handled exception", events[i].ID)
+			fmt.Println(msg)
+			continue
 		}
 
-		if !matchedGroup.EventExists(events[i].ID) {
-			if err := matchedGroup.UpdateTimeStamps(ctx, &events[i], tx); err != nil {
+		matchedGroup, err := app.GetExceptionGroupByFingerprint(ctx, events[i].Exception.Fingerprint, true)
+		if err != nil {
+			return err
+		}
+
+		if matchedGroup == nil {
+			exceptionGroup := group.NewExceptionGroup(events[i].AppID, events[i].Exception.GetType(), events[i].Exception.GetMessage(), events[i].Exception.GetMethodName(), events[i].Exception.GetFileName(), events[i].Exception.GetLineNumber(), events[i].Exception.Fingerprint, events[i].Timestamp, true)
+			if err := exceptionGroup.Insert(ctx, tx); err != nil {
 				return err
 			}
+
+			continue
+		}
+
+		if err := matchedGroup.UpdateTimeStamps(ctx, &events[i], tx); err != nil {
+			return err
 		}
 	}
 
@@ -508,19 +578,27 @@ func (e eventreq) bucketANRs(ctx context.Context, tx *pgx.Tx) (err error) {
 		}
 
 		if matchedGroup == nil {
-			anrGroup := group.NewANRGroup(events[i].AppID, events[i].ANR.GetType(), events[i].ANR.GetMessage(), events[i].ANR.GetMethodName(), events[i].ANR.GetFileName(), events[i].ANR.GetLineNumber(), events[i].ANR.Fingerprint, events[i].Timestamp)
+			anrGroup := group.NewANRGroup(events[i].AppID, events[i].ANR.GetType(), events[i].ANR.GetMessage(), events[i].ANR.GetMethodName(), events[i].ANR.GetFileName(), events[i].ANR.GetLineNumber(), events[i].ANR.Fingerprint, events[i].ANR.Cause, events[i].Timestamp)
 			if err := anrGroup.Insert(ctx, tx); err != nil {
 				return err
 			}
 
-			return nil
+			if err := newIssueAlert(AlertTypeNewIssue, event.TypeANR, anrGroup.ID, &events[i]).insert(ctx, tx); err != nil {
+				return err
+			}
+
+			continue
 		}
 
-		if !matchedGroup.EventExists(events[i].ID) {
-			if err := matchedGroup.UpdateTimeStamps(ctx, &events[i], tx); err != nil {
+		if isRegression(matchedGroup.LastEventTime, &events[i]) {
+			if err := newIssueAlert(AlertTypeRegression, event.TypeANR, matchedGroup.ID, &events[i]).insert(ctx, tx); err != nil {
 				return err
 			}
 		}
+
+		if err := matchedGroup.UpdateTimeStamps(ctx, &events[i], tx); err != nil {
+			return err
+		}
 	}
 
 	return
@@ -611,6 +689,7 @@ func (e eventreq) ingestEvents(ctx context.Context) error {
 			if err := e.events[i].ANR.ComputeANRFingerprint(); err != nil {
 				return err
 			}
+			e.events[i].ANR.Cause = e.events[i].ANR.Analyze().Cause
 		}
 		if e.events[i].IsException() {
 			marshalledExceptions, err := json.Marshal(e.events[i].Exception.Exceptions)
@@ -689,6 +768,8 @@ func (e eventreq) ingestEvents(ctx context.Context) error {
 			row.
 				Set(`anr.handled`, e.events[i].ANR.Handled).
 				Set(`anr.fingerprint`, e.events[i].ANR.Fingerprint).
+				Set(`anr.fingerprint_version`, e.events[i].ANR.FingerprintVersion).
+				Set(`anr.cause`, e.events[i].ANR.Cause).
 				Set(`anr.exceptions`, anrExceptions).
 				Set(`anr.threads`, anrThreads).
 				Set(`anr.foreground`, e.events[i].ANR.Foreground)
@@ -696,6 +777,8 @@ func (e eventreq) ingestEvents(ctx context.Context) error {
 			row.
 				Set(`anr.handled`, nil).
 				Set(`anr.fingerprint`, nil).
+				Set(`anr.fingerprint_version`, nil).
+				Set(`anr.cause`, nil).
 				Set(`anr.exceptions`, nil).
 				Set(`anr.threads`, nil).
 				Set(`anr.foreground`, nil)
@@ -706,6 +789,7 @@ func (e eventreq) ingestEvents(ctx context.Context) error {
 			row.
 				Set(`exception.handled`, e.events[i].Exception.Handled).
 				Set(`exception.fingerprint`, e.events[i].Exception.Fingerprint).
+				Set(`exception.fingerprint_version`, e.events[i].Exception.FingerprintVersion).
 				Set(`exception.exceptions`, exceptionExceptions).
 				Set(`exception.threads`, exceptionThreads).
 				Set(`exception.foreground`, e.events[i].Exception.Foreground)
@@ -713,6 +797,7 @@ func (e eventreq) ingestEvents(ctx context.Context) error {
 			row.
 				Set(`exception.handled`, nil).
 				Set(`exception.fingerprint`, nil).
+				Set(`exception.fingerprint_version`, nil).
 				Set(`exception.exceptions`, nil).
 				Set(`exception.threads`, nil).
 				Set(`exception.foreground`, nil)
@@ -969,6 +1054,7 @@ func (e eventreq) ingestEvents(ctx context.Context) error {
 		if e.events[i].IsHttp() {
 			row.
 				Set(`http.url`, e.events[i].Http.URL).
+				Set(`http.url_template`, e.events[i].Http.URLTemplate()).
 				Set(`http.method`, e.events[i].Http.Method).
 				Set(`http.status_code`, e.events[i].Http.StatusCode).
 				Set(`http.start_time`, e.events[i].Http.StartTime).
@@ -983,6 +1069,7 @@ func (e eventreq) ingestEvents(ctx context.Context) error {
 		} else {
 			row.
 				Set(`http.url`, nil).
+				Set(`http.url_template`, nil).
 				Set(`http.method`, nil).
 				Set(`http.status_code`, nil).
 				Set(`http.start_time`, nil).
@@ -1176,7 +1263,10 @@ func (e eventreq) ingestSpans(ctx context.Context) error {
 			Set(`attribute.device_manufacturer`, e.spans[i].Attributes.DeviceManufacturer).
 			Set(`attribute.device_locale`, e.spans[i].Attributes.DeviceLocale).
 			Set(`attribute.device_low_power_mode`, e.spans[i].Attributes.LowPowerModeEnabled).
-			Set(`attribute.device_thermal_throttling_enabled`, e.spans[i].Attributes.ThermalThrottlingEnabled)
+			Set(`attribute.device_thermal_throttling_enabled`, e.spans[i].Attributes.ThermalThrottlingEnabled).
+
+			// user defined attribute
+			Set(`user_defined_attribute`, e.spans[i].UserDefinedAttribute.Parameterize())
 	}
 
 	return server.Server.ChPool.AsyncInsert(ctx, stmt.String(), false, stmt.Args()...)
@@ -1237,7 +1327,7 @@ func GetExceptionsWithFilter(ctx context.Context, group *group.ExceptionGroup, a
 		keyTimestamp = af.KeyTimestamp.Format(timeformat)
 	}
 
-	prewhere := "prewhere app_id = toUUID(?) and exception.fingerprint = ?"
+	prewhere := "prewhere app_id = toUUID(?) and exception.fingerprint in ?"
 
 	substmt := sqlf.From("events").
 		Select("distinct id").
@@ -1253,11 +1343,11 @@ func GetExceptionsWithFilter(ctx context.Context, group *group.ExceptionGroup, a
 		Select("exception.threads threads").
 		Select("attachments").
 		Select(fmt.Sprintf("row_number() over (order by timestamp %s, id) as row_num", order)).
-		Clause(prewhere, af.AppID, group.Fingerprint).
+		Clause(prewhere, af.AppID, group.GetFingerprints()).
 		Where("(attribute.app_version, attribute.app_build) in (?)", selectedVersions.Parameterize()).
 		Where("(attribute.os_name, attribute.os_version) in (?)", selectedOSVersions.Parameterize()).
 		Where("type = ?", event.TypeException).
-		Where("exception.handled = false").
+		Where("exception.handled = ?", group.Handled).
 		Where("inet.country_code in ?", af.Countries).
 		Where("attribute.device_name in ?", af.DeviceNames).
 		Where("attribute.device_manufacturer in ?", af.DeviceManufacturers).
@@ -1270,8 +1360,12 @@ func GetExceptionsWithFilter(ctx context.Context, group *group.ExceptionGroup, a
 	if af.HasUDExpression() && !af.UDExpression.Empty() {
 		subQuery := sqlf.From("user_def_attrs").
 			Select("event_id id").
-			Where("app_id = toUUID(?)", af.AppID).
-			Where("exception = true")
+			Where("app_id = toUUID(?)", af.AppID)
+		if group.Handled {
+			subQuery.Where("non_fatal = true")
+		} else {
+			subQuery.Where("exception = true")
+		}
 		af.UDExpression.Augment(subQuery)
 		substmt.Clause("AND id in").SubQuery("(", ")", subQuery)
 	}
@@ -1371,19 +1465,29 @@ func GetExceptionsWithFilter(ctx context.Context, group *group.ExceptionGroup, a
 
 // GetExceptionPlotInstances queries aggregated exception
 // instances and crash free sessions by datetime and filters.
-func GetExceptionPlotInstances(ctx context.Context, af *filter.AppFilter) (issueInstances []event.IssueInstance, err error) {
+//
+// When handled is true, handled (non-fatal) exceptions are
+// considered instead of unhandled exceptions.
+func GetExceptionPlotInstances(ctx context.Context, af *filter.AppFilter, handled bool) (issueInstances []event.IssueInstance, err error) {
 	if af.Timezone == "" {
 		return nil, errors.New("missing timezone filter")
 	}
 
+	// read from pre-aggregated rollups unless
+	// user defined attributes need to be matched
+	// against individual events
+	if !af.HasUDExpression() || af.UDExpression.Empty() {
+		return getIssueMetricsPlotInstances(ctx, af, event.TypeException, handled, "")
+	}
+
 	stmt := sqlf.
 		From("events").
 		Select("formatDateTime(timestamp, '%Y-%m-%d', ?) as datetime", af.Timezone).
 		Select("concat(toString(attribute.app_version), '', '(', toString(attribute.app_build), ')') as app_version").
-		Select("uniqIf(id, type = ? and exception.handled = false) as total_exceptions", event.TypeException).
+		Select("uniqIf(id, type = ? and exception.handled = ?) as total_exceptions", event.TypeException, handled).
 		Select("round((1 - (exception_sessions / total_sessions)) * 100, 2) as crash_free_sessions").
 		Select("uniq(session_id) as total_sessions").
-		Select("uniqIf(session_id, type = ? and exception.handled = false) as exception_sessions", event.TypeException).
+		Select("uniqIf(session_id, type = ? and exception.handled = ?) as exception_sessions", event.TypeException, handled).
 		Clause("prewhere app_id = toUUID(?)", af.AppID)
 
 	defer stmt.Close()
@@ -1439,8 +1543,12 @@ func GetExceptionPlotInstances(ctx context.Context, af *filter.AppFilter) (issue
 	if af.HasUDExpression() && !af.UDExpression.Empty() {
 		subQuery := sqlf.From("user_def_attrs").
 			Select("event_id id").
-			Where("app_id = toUUID(?)", af.AppID).
-			Where("exception = true")
+			Where("app_id = toUUID(?)", af.AppID)
+		if handled {
+			subQuery.Where("non_fatal = true")
+		} else {
+			subQuery.Where("exception = true")
+		}
 		af.UDExpression.Augment(subQuery)
 		stmt.Clause("AND id in").SubQuery("(", ")", subQuery)
 	}
@@ -1455,8 +1563,8 @@ func GetExceptionPlotInstances(ctx context.Context, af *filter.AppFilter) (issue
 
 	for rows.Next() {
 		var instance event.IssueInstance
-		var ignore1, ignore2 uint64
-		if err := rows.Scan(&instance.DateTime, &instance.Version, &instance.Instances, &instance.IssueFreeSessions, &ignore1, &ignore2); err != nil {
+		var ignore uint64
+		if err := rows.Scan(&instance.DateTime, &instance.Version, &instance.Instances, &instance.IssueFreeSessions, &instance.Sessions, &ignore); err != nil {
 			return nil, err
 		}
 
@@ -1507,7 +1615,7 @@ func GetANRsWithFilter(ctx context.Context, group *group.ANRGroup, af *filter.Ap
 		keyTimestamp = af.KeyTimestamp.Format(timeformat)
 	}
 
-	prewhere := "prewhere app_id = toUUID(?) and anr.fingerprint = ?"
+	prewhere := "prewhere app_id = toUUID(?) and anr.fingerprint in ?"
 
 	substmt := sqlf.From("events").
 		Select("distinct id").
@@ -1523,7 +1631,7 @@ func GetANRsWithFilter(ctx context.Context, group *group.ANRGroup, af *filter.Ap
 		Select("anr.threads threads").
 		Select("attachments").
 		Select(fmt.Sprintf("row_number() over (order by timestamp %s, id) as row_num", order)).
-		Clause(prewhere, af.AppID, group.Fingerprint).
+		Clause(prewhere, af.AppID, group.GetFingerprints()).
 		Where("(attribute.app_version, attribute.app_build) in (?)", selectedVersions.Parameterize()).
 		Where("(attribute.os_name, attribute.os_version) in (?)", selectedOSVersions.Parameterize()).
 		Where("type = ?", event.TypeANR).
@@ -1645,6 +1753,13 @@ func GetANRPlotInstances(ctx context.Context, af *filter.AppFilter) (issueInstan
 		return nil, errors.New("missing timezone filter")
 	}
 
+	// read from pre-aggregated rollups unless
+	// user defined attributes need to be matched
+	// against individual events
+	if !af.HasUDExpression() || af.UDExpression.Empty() {
+		return getIssueMetricsPlotInstances(ctx, af, event.TypeANR, false, " ")
+	}
+
 	stmt := sqlf.
 		From("events").
 		Select("formatDateTime(timestamp, '%Y-%m-%d', ?) as datetime", af.Timezone).
@@ -1724,8 +1839,8 @@ func GetANRPlotInstances(ctx context.Context, af *filter.AppFilter) (issueInstan
 
 	for rows.Next() {
 		var instance event.IssueInstance
-		var ignore1, ignore2 uint64
-		if err := rows.Scan(&instance.DateTime, &instance.Version, &instance.Instances, &instance.IssueFreeSessions, &ignore1, &ignore2); err != nil {
+		var ignore uint64
+		if err := rows.Scan(&instance.DateTime, &instance.Version, &instance.Instances, &instance.IssueFreeSessions, &instance.Sessions, &ignore); err != nil {
 			return nil, err
 		}
 
@@ -1744,14 +1859,21 @@ func GetANRPlotInstances(ctx context.Context, af *filter.AppFilter) (issueInstan
 // GetIssuesAttributeDistribution queries distribution of attributes
 // based on datetime and filters.
 func GetIssuesAttributeDistribution(ctx context.Context, g group.IssueGroup, af *filter.AppFilter) (map[string]map[string]uint64, error) {
-	fingerprint := g.GetFingerprint()
+	fingerprints := g.GetFingerprints()
 	groupType := event.TypeException
+	udAttrColumn := "exception"
+	handled := false
 
-	switch g.(type) {
+	switch g := g.(type) {
 	case *group.ANRGroup:
 		groupType = event.TypeANR
+		udAttrColumn = "anr"
 	case *group.ExceptionGroup:
 		groupType = event.TypeException
+		handled = g.Handled
+		if handled {
+			udAttrColumn = "non_fatal"
+		}
 	default:
 		err := errors.New("couldn't determine correct type of issue group")
 		return nil, err
@@ -1766,7 +1888,7 @@ func GetIssuesAttributeDistribution(ctx context.Context, g group.IssueGroup, af
 		Select("toString(attribute.device_locale) as locale").
 		Select("concat(toString(attribute.device_manufacturer), ' - ', toString(attribute.device_name)) as device").
 		Select("uniq(id) as count").
-		Clause(fmt.Sprintf("prewhere app_id = toUUID(?) and %s.fingerprint = ?", groupType), af.AppID, fingerprint).
+		Clause(fmt.Sprintf("prewhere app_id = toUUID(?) and %s.fingerprint in ?", groupType), af.AppID, fingerprints).
 		GroupBy("app_version").
 		GroupBy("os_version").
 		GroupBy("country").
@@ -1778,6 +1900,9 @@ func GetIssuesAttributeDistribution(ctx context.Context, g group.IssueGroup, af
 
 	// Add filters as necessary
 	stmt.Where("timestamp >= ? and timestamp <= ?", af.From, af.To)
+	if groupType == event.TypeException {
+		stmt.Where("exception.handled = ?", handled)
+	}
 	if len(af.Versions) > 0 {
 		stmt.Where("attribute.app_version in ?", af.Versions)
 	}
@@ -1813,7 +1938,7 @@ func GetIssuesAttributeDistribution(ctx context.Context, g group.IssueGroup, af
 		subQuery := sqlf.From("user_def_attrs").
 			Select("event_id id").
 			Where("app_id = toUUID(?)", af.AppID).
-			Where(fmt.Sprintf("%s = true", groupType))
+			Where(fmt.Sprintf("%s = true", udAttrColumn))
 		af.UDExpression.Augment(subQuery)
 		stmt.Clause("AND id in").SubQuery("(", ")", subQuery)
 	}
@@ -1874,35 +1999,53 @@ func GetIssuesPlot(ctx context.Context, g group.IssueGroup, af *filter.AppFilter
 		return nil, errors.New("missing timezone filter")
 	}
 
-	fingerprint := g.GetFingerprint()
+	fingerprints := g.GetFingerprints()
 	groupType := event.TypeException
+	udAttrColumn := "exception"
+	handled := false
 
-	switch g.(type) {
+	switch g := g.(type) {
 	case *group.ANRGroup:
 		groupType = event.TypeANR
+		udAttrColumn = "anr"
 	case *group.ExceptionGroup:
 		groupType = event.TypeException
+		handled = g.Handled
+		if handled {
+			udAttrColumn = "non_fatal"
+		}
 	default:
 		err = errors.New("couldn't determine correct type of issue group")
 		return
 	}
 
+	// read from pre-aggregated issue metrics unless
+	// user defined attributes need to be matched
+	// against individual events
+	if !af.HasUDExpression() || af.UDExpression.Empty() {
+		return getIssueMetricsPlot(ctx, af, groupType, handled, fingerprints)
+	}
+
 	stmt := sqlf.
 		From(`events`).
 		Select("formatDateTime(timestamp, '%Y-%m-%d', ?) as datetime", af.Timezone).
 		Select("concat(toString(attribute.app_version), ' ', '(', toString(attribute.app_build),')') as version").
 		Select("uniq(id) as instances").
-		Clause(fmt.Sprintf("prewhere app_id = toUUID(?) and %s.fingerprint = ?", groupType), af.AppID, fingerprint)
+		Clause(fmt.Sprintf("prewhere app_id = toUUID(?) and %s.fingerprint in ?", groupType), af.AppID, fingerprints)
 
 	defer stmt.Close()
 
 	stmt.Where("timestamp >= ? and timestamp <= ?", af.From, af.To)
 
+	if groupType == event.TypeException {
+		stmt.Where("exception.handled = ?", handled)
+	}
+
 	if af.HasUDExpression() && !af.UDExpression.Empty() {
 		subQuery := sqlf.From("user_def_attrs").
 			Select("event_id id").
 			Where("app_id = toUUID(?)", af.AppID).
-			Where(fmt.Sprintf("%s = true", groupType))
+			Where(fmt.Sprintf("%s = true", udAttrColumn))
 		af.UDExpression.Augment(subQuery)
 		stmt.Clause("AND id in").SubQuery("(", ")", subQuery)
 	}
@@ -1970,6 +2113,520 @@ func GetIssuesPlot(ctx context.Context, g group.IssueGroup, af *filter.AppFilter
 	return
 }
 
+// getIssueMetricsPlot aggregates issue instances for plotting
+// by reading pre-aggregated issue metrics rollups.
+func getIssueMetricsPlot(ctx context.Context, af *filter.AppFilter, issueType string, handled bool, fingerprints []string) (issueInstances []event.IssueInstance, err error) {
+	stmt := sqlf.
+		From("issue_metrics").
+		Select("formatDateTime(timestamp, '%Y-%m-%d', ?) as datetime", af.Timezone).
+		Select("concat(tupleElement(app_version, 1), ' ', '(', tupleElement(app_version, 2), ')') as version").
+		Select("uniqMerge(instances) as instances").
+		Clause("prewhere app_id = toUUID(?) and type = ? and handled = ? and fingerprint in ?", af.AppID, issueType, handled, fingerprints).
+		Where("timestamp >= ? and timestamp <= ?", af.From, af.To)
+
+	defer stmt.Close()
+
+	applyIssueMetricsFilters(stmt, af)
+
+	stmt.GroupBy("version, datetime").
+		OrderBy("version, datetime")
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var instance event.IssueInstance
+		if err = rows.Scan(&instance.DateTime, &instance.Version, &instance.Instances); err != nil {
+			return
+		}
+		issueInstances = append(issueInstances, instance)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// getIssueMetricsPlotInstances aggregates issue instances &
+// issue free sessions by datetime for plotting by reading
+// pre-aggregated issue metrics & session metrics rollups.
+// Sep separates app version names from version codes.
+func getIssueMetricsPlotInstances(ctx context.Context, af *filter.AppFilter, issueType string, handled bool, sep string) (issueInstances []event.IssueInstance, err error) {
+	sessionStmt := sessionMetricsPlotStmt(af, sep)
+	defer sessionStmt.Close()
+
+	sessionRows, err := server.Server.ChPool.Query(ctx, sessionStmt.String(), sessionStmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer sessionRows.Close()
+
+	sessions := make(map[[2]string]uint64)
+	for sessionRows.Next() {
+		var datetime, version string
+		var count uint64
+		if err = sessionRows.Scan(&datetime, &version, &count); err != nil {
+			return
+		}
+		sessions[[2]string{datetime, version}] = count
+	}
+
+	if err = sessionRows.Err(); err != nil {
+		return
+	}
+
+	issueStmt := issueMetricsPlotInstancesStmt(af, issueType, handled, sep)
+	defer issueStmt.Close()
+
+	rows, err := server.Server.ChPool.Query(ctx, issueStmt.String(), issueStmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var datetime, version string
+		var instances, issueSessions uint64
+		if err = rows.Scan(&datetime, &version, &instances, &issueSessions); err != nil {
+			return
+		}
+
+		if instances == 0 {
+			continue
+		}
+
+		issueInstances = append(issueInstances, newIssueInstance(datetime, version, instances, issueSessions, sessions[[2]string{datetime, version}]))
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// issueMetricsPlotInstancesStmt builds the statement counting
+// issue instances & sessions with issues of the issue type by
+// datetime & app version from issue metrics rollups.
+func issueMetricsPlotInstancesStmt(af *filter.AppFilter, issueType string, handled bool, sep string) *sqlf.Stmt {
+	stmt := sqlf.
+		From("issue_metrics").
+		Select("formatDateTime(timestamp, '%Y-%m-%d', ?) as datetime", af.Timezone).
+		Select("concat(tupleElement(app_version, 1), ?, '(', tupleElement(app_version, 2), ')') as version", sep).
+		Select("uniqMerge(instances) as instances").
+		Select("uniqMerge(sessions) as issue_sessions").
+		Clause("prewhere app_id = toUUID(?) and type = ? and handled = ?", af.AppID, issueType, handled)
+
+	if af.HasTimeRange() {
+		stmt.Where("timestamp >= ? and timestamp <= ?", af.From, af.To)
+	}
+
+	applyIssueMetricsFilters(stmt, af)
+
+	stmt.GroupBy("version, datetime").
+		OrderBy("version, datetime")
+
+	return stmt
+}
+
+// sessionMetricsPlotStmt builds the statement counting
+// sessions by datetime & app version from session
+// metrics rollups.
+func sessionMetricsPlotStmt(af *filter.AppFilter, sep string) *sqlf.Stmt {
+	stmt := sqlf.
+		From("session_metrics").
+		Select("formatDateTime(timestamp, '%Y-%m-%d', ?) as datetime", af.Timezone).
+		Select("concat(tupleElement(app_version, 1), ?, '(', tupleElement(app_version, 2), ')') as version", sep).
+		Select("uniqMerge(sessions) as sessions").
+		Clause("prewhere app_id = toUUID(?)", af.AppID)
+
+	if af.HasTimeRange() {
+		stmt.Where("timestamp >= ? and timestamp <= ?", af.From, af.To)
+	}
+
+	applyIssueMetricsFilters(stmt, af)
+
+	stmt.GroupBy("version, datetime")
+
+	return stmt
+}
+
+// newIssueInstance computes the issue free sessions
+// percentage of a plot's datetime & version.
+func newIssueInstance(datetime, version string, instances, issueSessions, sessions uint64) (instance event.IssueInstance) {
+	instance.DateTime = datetime
+	instance.Version = version
+	instance.Instances = &instances
+	instance.Sessions = sessions
+
+	if sessions > 0 {
+		issueFree := math.Round((1-float64(issueSessions)/float64(sessions))*10000) / 100
+		instance.IssueFreeSessions = &issueFree
+	}
+
+	return
+}
+
+// GetIssueMetricsCounts counts issue events of each fingerprint
+// matching the app filter by reading pre-aggregated issue metrics
+// rollups. Fingerprints without any matching events are omitted.
+// Does not consider user defined attribute expressions.
+func GetIssueMetricsCounts(ctx context.Context, af *filter.AppFilter, issueType string, handled bool, fingerprints []string) (counts map[string]uint64, err error) {
+	counts = make(map[string]uint64)
+
+	if len(fingerprints) == 0 {
+		return
+	}
+
+	stmt := sqlf.
+		From("issue_metrics").
+		Select("toString(fingerprint) fingerprint").
+		Select("uniqMerge(instances) as instances").
+		Clause("prewhere app_id = toUUID(?) and type = ? and handled = ? and fingerprint in ?", af.AppID, issueType, handled, fingerprints)
+
+	defer stmt.Close()
+
+	if af.HasTimeRange() {
+		stmt.Where("timestamp >= ? and timestamp <= ?", af.From, af.To)
+	}
+
+	applyIssueMetricsFilters(stmt, af)
+
+	stmt.GroupBy("fingerprint")
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var fingerprint string
+		var instances uint64
+		if err = rows.Scan(&fingerprint, &instances); err != nil {
+			return
+		}
+		counts[fingerprint] = instances
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// GetIssueMetricsUsers counts unique users affected by each
+// group of fingerprints matching the app filter by reading
+// pre-aggregated issue metrics rollups. Groups maps a group
+// key to its fingerprints. Groups without any matching events
+// are omitted. Does not consider user defined attribute
+// expressions.
+func GetIssueMetricsUsers(ctx context.Context, af *filter.AppFilter, issueType string, handled bool, groups map[string][]string) (users map[string]uint64, err error) {
+	users = make(map[string]uint64)
+
+	var fingerprints, keys []string
+	for key, fps := range groups {
+		for _, fingerprint := range fps {
+			fingerprints = append(fingerprints, fingerprint)
+			keys = append(keys, key)
+		}
+	}
+
+	if len(fingerprints) == 0 {
+		return
+	}
+
+	stmt := sqlf.
+		From("issue_metrics").
+		Select("transform(toString(fingerprint), ?, ?, '') group_key", fingerprints, keys).
+		Select("uniqMerge(users) as users").
+		Clause("prewhere app_id = toUUID(?) and type = ? and handled = ? and fingerprint in ?", af.AppID, issueType, handled, fingerprints)
+
+	defer stmt.Close()
+
+	if af.HasTimeRange() {
+		stmt.Where("timestamp >= ? and timestamp <= ?", af.From, af.To)
+	}
+
+	applyIssueMetricsFilters(stmt, af)
+
+	stmt.GroupBy("group_key")
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var count uint64
+		if err = rows.Scan(&key, &count); err != nil {
+			return
+		}
+		users[key] = count
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// GetIssueEventCounts counts issue events & unique users
+// affected by each group of fingerprints matching the app
+// filter, including its user defined attribute expression,
+// by reading individual events. Groups maps a group key to
+// its fingerprints. Groups without any matching events are
+// omitted.
+func GetIssueEventCounts(ctx context.Context, af *filter.AppFilter, issueType string, handled bool, groups map[string][]string) (counts, users map[string]uint64, err error) {
+	counts = make(map[string]uint64)
+	users = make(map[string]uint64)
+
+	stmt := issueEventCountsStmt(af, issueType, handled, groups)
+	if stmt == nil {
+		return
+	}
+
+	defer stmt.Close()
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var key string
+		var instances, affected uint64
+		if err = rows.Scan(&key, &instances, &affected); err != nil {
+			return
+		}
+		counts[key] = instances
+		users[key] = affected
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// issueEventCountsStmt builds the statement counting issue
+// events & users of each group of fingerprints. Returns nil
+// if there are no fingerprints to count.
+func issueEventCountsStmt(af *filter.AppFilter, issueType string, handled bool, groups map[string][]string) *sqlf.Stmt {
+	var fingerprints, keys []string
+	for key, fps := range groups {
+		for _, fingerprint := range fps {
+			fingerprints = append(fingerprints, fingerprint)
+			keys = append(keys, key)
+		}
+	}
+
+	if len(fingerprints) == 0 {
+		return nil
+	}
+
+	stmt := sqlf.
+		From("events").
+		Select(fmt.Sprintf("transform(toString(%s.fingerprint), ?, ?, '') group_key", issueType), fingerprints, keys).
+		Select("uniq(id) as instances").
+		Select(fmt.Sprintf("uniq(%s) as users", userIdentity)).
+		Clause(fmt.Sprintf("prewhere app_id = toUUID(?) and %s.fingerprint in ?", issueType), af.AppID, fingerprints).
+		Where("type = ?", issueType)
+
+	if issueType == event.TypeException {
+		stmt.Where("exception.handled = ?", handled)
+	}
+
+	applyEventFilters(stmt, af)
+
+	if af.HasUDExpression() && !af.UDExpression.Empty() {
+		subQuery := sqlf.From("user_def_attrs").
+			Select("event_id id").
+			Where("app_id = toUUID(?)", af.AppID).
+			Where(fmt.Sprintf("%s = true", issueUDAttrColumn(issueType, handled)))
+		af.UDExpression.Augment(subQuery)
+		stmt.Clause("AND id in").SubQuery("(", ")", subQuery)
+	}
+
+	stmt.GroupBy("group_key")
+
+	return stmt
+}
+
+// issueUDAttrColumn provides the column of user defined
+// attributes marking events of the issue type.
+func issueUDAttrColumn(issueType string, handled bool) string {
+	switch {
+	case issueType == event.TypeANR:
+		return "anr"
+	case handled:
+		return "non_fatal"
+	default:
+		return "exception"
+	}
+}
+
+// userIdentity is the expression identifying an app's
+// end user by user id, falling back to installation id
+// when user id is not set.
+const userIdentity = "if(toStringCutToZero(attribute.user_id) != '', toStringCutToZero(attribute.user_id), toString(attribute.installation_id))"
+
+// applyEventFilters applies app filter's time range
+// & attribute filters to a query on events.
+func applyEventFilters(stmt *sqlf.Stmt, af *filter.AppFilter) {
+	if len(af.Versions) > 0 {
+		stmt.Where("attribute.app_version in ?", af.Versions)
+	}
+
+	if len(af.VersionCodes) > 0 {
+		stmt.Where("attribute.app_build in ?", af.VersionCodes)
+	}
+
+	if len(af.OsNames) > 0 {
+		stmt.Where("attribute.os_name in ?", af.OsNames)
+	}
+
+	if len(af.OsVersions) > 0 {
+		stmt.Where("attribute.os_version in ?", af.OsVersions)
+	}
+
+	if len(af.Countries) > 0 {
+		stmt.Where("inet.country_code in ?", af.Countries)
+	}
+
+	if len(af.NetworkProviders) > 0 {
+		stmt.Where("attribute.network_provider in ?", af.NetworkProviders)
+	}
+
+	if len(af.NetworkTypes) > 0 {
+		stmt.Where("attribute.network_type in ?", af.NetworkTypes)
+	}
+
+	if len(af.NetworkGenerations) > 0 {
+		stmt.Where("attribute.network_generation in ?", af.NetworkGenerations)
+	}
+
+	if len(af.Locales) > 0 {
+		stmt.Where("attribute.device_locale in ?", af.Locales)
+	}
+
+	if len(af.DeviceManufacturers) > 0 {
+		stmt.Where("attribute.device_manufacturer in ?", af.DeviceManufacturers)
+	}
+
+	if len(af.DeviceNames) > 0 {
+		stmt.Where("attribute.device_name in ?", af.DeviceNames)
+	}
+
+	if af.HasTimeRange() {
+		stmt.Where("timestamp >= ? and timestamp <= ?", af.From, af.To)
+	}
+}
+
+// applyIssueMetricsFilters applies app filter's
+// attribute filters to a query on issue metrics
+// or launch metrics.
+func applyIssueMetricsFilters(stmt *sqlf.Stmt, af *filter.AppFilter) {
+	if len(af.Versions) > 0 {
+		stmt.Where("tupleElement(app_version, 1) in ?", af.Versions)
+	}
+
+	if len(af.VersionCodes) > 0 {
+		stmt.Where("tupleElement(app_version, 2) in ?", af.VersionCodes)
+	}
+
+	if len(af.OsNames) > 0 {
+		stmt.Where("tupleElement(os_version, 1) in ?", af.OsNames)
+	}
+
+	if len(af.OsVersions) > 0 {
+		stmt.Where("tupleElement(os_version, 2) in ?", af.OsVersions)
+	}
+
+	if len(af.Countries) > 0 {
+		stmt.Where("country_code in ?", af.Countries)
+	}
+
+	if len(af.NetworkProviders) > 0 {
+		stmt.Where("network_provider in ?", af.NetworkProviders)
+	}
+
+	if len(af.NetworkTypes) > 0 {
+		stmt.Where("network_type in ?", af.NetworkTypes)
+	}
+
+	if len(af.NetworkGenerations) > 0 {
+		stmt.Where("network_generation in ?", af.NetworkGenerations)
+	}
+
+	if len(af.Locales) > 0 {
+		stmt.Where("device_locale in ?", af.Locales)
+	}
+
+	if len(af.DeviceManufacturers) > 0 {
+		stmt.Where("device_manufacturer in ?", af.DeviceManufacturers)
+	}
+
+	if len(af.DeviceNames) > 0 {
+		stmt.Where("device_name in ?", af.DeviceNames)
+	}
+}
+
+// GetIssueExceptionUnits fetches exception units of the most
+// recent exception or ANR event for each fingerprint. When
+// querying exceptions, handled chooses between handled and
+// unhandled exception events.
+func GetIssueExceptionUnits(ctx context.Context, appId *uuid.UUID, issueType string, handled bool, fingerprints []string) (units map[string]event.ExceptionUnits, err error) {
+	stmt := sqlf.
+		From("events").
+		Select(fmt.Sprintf("toString(%s.fingerprint) fingerprint", issueType)).
+		Select(fmt.Sprintf("argMax(%s.exceptions, timestamp) exceptions", issueType)).
+		Clause(fmt.Sprintf("prewhere app_id = toUUID(?) and %s.fingerprint in ?", issueType), appId, fingerprints).
+		Where("type = ?", issueType).
+		GroupBy("fingerprint")
+
+	defer stmt.Close()
+
+	if issueType == event.TypeException {
+		stmt.Where("exception.handled = ?", handled)
+	}
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	units = make(map[string]event.ExceptionUnits)
+
+	for rows.Next() {
+		var fingerprint string
+		var exceptions string
+		if err = rows.Scan(&fingerprint, &exceptions); err != nil {
+			return
+		}
+
+		var exceptionUnits event.ExceptionUnits
+		if err = json.Unmarshal([]byte(exceptions), &exceptionUnits); err != nil {
+			return
+		}
+
+		units[fingerprint] = exceptionUnits
+	}
+
+	err = rows.Err()
+
+	return
+}
+
 func PutEvents(c *gin.Context) {
 	appId, err := uuid.Parse(c.GetString("appId"))
 	if err != nil {
@@ -2236,6 +2893,21 @@ func PutEvents(c *gin.Context) {
 		return
 	}
 
+	// start span to trace bucketing handled exceptions
+	bucketHandledExceptionsTracer := otel.Tracer("bucket-handled-exceptions-tracer")
+	_, bucketHandledExceptionsSpan := bucketHandledExceptionsTracer.Start(ctx, "bucket-handled-exceptions")
+
+	defer bucketHandledExceptionsSpan.End()
+
+	if err := eventReq.bucketHandledExceptions(ctx, &tx); err != nil {
+		msg := `failed to bucket handled exceptions`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
 	// start span to trace bucketing ANRs
 	bucketAnrsTracer := otel.Tracer("bucket-anrs-tracer")
 	_, bucketAnrsSpan := bucketAnrsTracer.Start(ctx, "bucket-anrs-exceptions")
@@ -0,0 +1,100 @@
+package measure
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/api/event"
+	"backend/api/filter"
+
+	"github.com/google/uuid"
+)
+
+func newTestAppFilter() *filter.AppFilter {
+	return &filter.AppFilter{
+		AppID:     uuid.New(),
+		From:      time.Now().Add(-24 * time.Hour),
+		To:        time.Now(),
+		Timezone:  "UTC",
+		Countries: []string{"IN"},
+	}
+}
+
+func TestIssueMetricsPlotInstancesStmt(t *testing.T) {
+	af := newTestAppFilter()
+
+	stmt := issueMetricsPlotInstancesStmt(af, event.TypeException, true, "")
+	defer stmt.Close()
+
+	sql := stmt.String()
+
+	for _, want := range []string{"FROM issue_metrics", "prewhere app_id = toUUID(?) and type = ? and handled = ?", "uniqMerge(instances)", "uniqMerge(sessions)", "country_code in", "GROUP BY version, datetime"} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("Expected statement to contain %q, got %s", want, sql)
+		}
+	}
+
+	if strings.Contains(sql, "events") {
+		t.Errorf("Expected statement to not read events, got %s", sql)
+	}
+}
+
+func TestSessionMetricsPlotStmt(t *testing.T) {
+	af := newTestAppFilter()
+
+	stmt := sessionMetricsPlotStmt(af, " ")
+	defer stmt.Close()
+
+	sql := stmt.String()
+
+	for _, want := range []string{"FROM session_metrics", "prewhere app_id = toUUID(?)", "uniqMerge(sessions)", "timestamp >= ? and timestamp <= ?", "country_code in"} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("Expected statement to contain %q, got %s", want, sql)
+		}
+	}
+}
+
+func TestIssueEventCountsStmt(t *testing.T) {
+	af := newTestAppFilter()
+
+	if stmt := issueEventCountsStmt(af, event.TypeANR, false, nil); stmt != nil {
+		t.Errorf("Expected no statement without fingerprints, got %s", stmt.String())
+	}
+
+	af.UDExpressionRaw = `{"cmp":{"key":"premium","type":"bool","op":"eq","value":"true"}}`
+	af.UDExpression = &event.UDExpression{}
+	if err := json.Unmarshal([]byte(af.UDExpressionRaw), af.UDExpression); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	stmt := issueEventCountsStmt(af, event.TypeException, true, map[string][]string{
+		"group": {"one", "two"},
+	})
+	defer stmt.Close()
+
+	sql := stmt.String()
+
+	for _, want := range []string{"transform(toString(exception.fingerprint), ?, ?, '') group_key", "prewhere app_id = toUUID(?) and exception.fingerprint in ?", "exception.handled = ?", "non_fatal = true", "GROUP BY group_key"} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("Expected statement to contain %q, got %s", want, sql)
+		}
+	}
+}
+
+func TestNewIssueInstance(t *testing.T) {
+	instance := newIssueInstance("2024-12-01", "1.0 (1)", 12, 3, 8)
+
+	if *instance.Instances != 12 || instance.Sessions != 8 {
+		t.Errorf("Expected 12 instances over 8 sessions, but got %+v", instance)
+	}
+
+	if *instance.IssueFreeSessions != 62.5 {
+		t.Errorf("Expected 62.5 issue free sessions, but got %v", *instance.IssueFreeSessions)
+	}
+
+	if instance := newIssueInstance("2024-12-01", "1.0 (1)", 1, 1, 0); instance.IssueFreeSessions != nil {
+		t.Errorf("Expected no issue free sessions without sessions, but got %v", *instance.IssueFreeSessions)
+	}
+}
@@ -0,0 +1,1198 @@
+package measure
+
+import (
+	"backend/api/chrono"
+	"backend/api/event"
+	"backend/api/group"
+	"backend/api/server"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+// FingerprintJobPending is the status of a
+// fingerprint job that has not started yet.
+const FingerprintJobPending = "pending"
+
+// FingerprintJobRunning is the status of a
+// fingerprint job that is in progress.
+const FingerprintJobRunning = "running"
+
+// FingerprintJobCompleted is the status of a
+// fingerprint job that finished successfully.
+const FingerprintJobCompleted = "completed"
+
+// FingerprintJobFailed is the status of a
+// fingerprint job that stopped due to an
+// error.
+const FingerprintJobFailed = "failed"
+
+// fingerprintJobBatchSize is the count of events
+// fetched & processed at once before saving the
+// job's progress. Also the maximum count of event
+// ids in a single mutation.
+const fingerprintJobBatchSize = 1000
+
+// fingerprintJobStaleAfter is the duration after which
+// an unfinished fingerprint job that hasn't reported any
+// progress is no longer considered active. Jobs may stop
+// abruptly if the server restarts.
+const fingerprintJobStaleAfter = time.Hour
+
+// FingerprintJob represents a backfill job that recomputes
+// fingerprints of an app's exception & ANR events in a time
+// window using a version of the fingerprint algorithm and
+// migrates the events to matching groups.
+//
+// Previous groups are merged into the new groups, so their
+// fingerprints continue to work as aliases for events
+// outside of the window.
+type FingerprintJob struct {
+	ID                 uuid.UUID           `json:"id" db:"id"`
+	AppID              uuid.UUID           `json:"app_id" db:"app_id"`
+	FingerprintVersion uint8               `json:"fingerprint_version" db:"fingerprint_version"`
+	From               time.Time           `json:"from" db:"from_timestamp"`
+	To                 time.Time           `json:"to" db:"to_timestamp"`
+	DryRun             bool                `json:"dry_run" db:"dry_run"`
+	Status             string              `json:"status" db:"status"`
+	TotalEvents        int                 `json:"total_events" db:"total_events"`
+	ProcessedEvents    int                 `json:"processed_events" db:"processed_events"`
+	ChangedEvents      int                 `json:"changed_events" db:"changed_events"`
+	Diff               []FingerprintChange `json:"diff" db:"diff"`
+	Error              *string             `json:"error" db:"error"`
+	CreatedBy          *uuid.UUID          `json:"created_by" db:"created_by"`
+	CreatedAt          chrono.ISOTime      `json:"created_at" db:"created_at"`
+	UpdatedAt          chrono.ISOTime      `json:"updated_at" db:"updated_at"`
+	FinishedAt         *chrono.ISOTime     `json:"finished_at" db:"finished_at"`
+}
+
+// FingerprintChange represents a set of events moving
+// from one fingerprint to another fingerprint.
+type FingerprintChange struct {
+	// Type is the type of the issue, either
+	// exception or anr.
+	Type string `json:"type"`
+	// Handled is true for handled exceptions.
+	Handled bool `json:"handled"`
+	// OldFingerprint is the fingerprint the
+	// events have currently.
+	OldFingerprint string `json:"old_fingerprint"`
+	// OldGroupID is the id of the group matching
+	// the old fingerprint, if any.
+	OldGroupID *uuid.UUID `json:"old_group_id"`
+	// NewFingerprint is the recomputed fingerprint
+	// of the events.
+	NewFingerprint string `json:"new_fingerprint"`
+	// NewGroupID is the id of the group matching the
+	// new fingerprint. Empty if the group does not
+	// exist yet.
+	NewGroupID *uuid.UUID `json:"new_group_id"`
+	// Events is the count of moving events.
+	Events int `json:"events"`
+}
+
+// FingerprintJobPayload represents the request
+// payload to create a fingerprint job.
+type FingerprintJobPayload struct {
+	From               time.Time `json:"from" binding:"required"`
+	To                 time.Time `json:"to" binding:"required"`
+	FingerprintVersion uint8     `json:"fingerprint_version"`
+	DryRun             *bool     `json:"dry_run"`
+}
+
+// fingerprintKey identifies a recomputed
+// fingerprint of an issue kind.
+type fingerprintKey struct {
+	issueType   string
+	handled     bool
+	fingerprint string
+}
+
+// fingerprintSample holds the data needed
+// to create a group for a new fingerprint.
+type fingerprintSample struct {
+	units     event.ExceptionUnits
+	timestamp time.Time
+	// lastTimestamp is the timestamp of the
+	// latest event having the fingerprint.
+	lastTimestamp time.Time
+}
+
+// fingerprintRecompute holds the outcome of
+// recomputing fingerprints of events in
+// the job's window.
+type fingerprintRecompute struct {
+	// moves counts events for each pair of
+	// old & new fingerprint.
+	moves map[fingerprintKey]map[string]int
+	// samples contains the earliest event
+	// of each new fingerprint.
+	samples map[fingerprintKey]*fingerprintSample
+	// eventIds contains ids of events of the
+	// current batch to be moved to each new
+	// fingerprint.
+	eventIds map[fingerprintKey][]uuid.UUID
+}
+
+// fingerprintCursor is the position of the last
+// event processed by a fingerprint job. Events
+// are processed in timestamp & id order.
+type fingerprintCursor struct {
+	timestamp time.Time
+	id        uuid.UUID
+}
+
+// newFingerprintRecompute creates an
+// empty fingerprint recompute.
+func newFingerprintRecompute() *fingerprintRecompute {
+	return &fingerprintRecompute{
+		moves:    make(map[fingerprintKey]map[string]int),
+		samples:  make(map[fingerprintKey]*fingerprintSample),
+		eventIds: make(map[fingerprintKey][]uuid.UUID),
+	}
+}
+
+// add recomputes the fingerprint of an event using the
+// fingerprint version and records the outcome. Returns
+// true if the event's fingerprint changes.
+//
+// Events without any exception units cannot be
+// fingerprinted and remain as they are.
+func (r *fingerprintRecompute) add(id uuid.UUID, issueType string, handled bool, oldFingerprint string, units event.ExceptionUnits, timestamp time.Time, version uint8) (changed bool, err error) {
+	if len(units) == 0 {
+		return
+	}
+
+	newFingerprint, err := event.Exception{Exceptions: units}.FingerprintWithVersion(version)
+	if err != nil {
+		return
+	}
+
+	oldKey := fingerprintKey{issueType, handled, oldFingerprint}
+	newKey := fingerprintKey{issueType, handled, newFingerprint}
+
+	if r.moves[oldKey] == nil {
+		r.moves[oldKey] = make(map[string]int)
+	}
+	r.moves[oldKey][newFingerprint] += 1
+
+	if sample, ok := r.samples[newKey]; !ok {
+		r.samples[newKey] = &fingerprintSample{
+			units:         units,
+			timestamp:     timestamp,
+			lastTimestamp: timestamp,
+		}
+	} else if timestamp.Before(sample.timestamp) {
+		sample.units = units
+		sample.timestamp = timestamp
+	} else if timestamp.After(sample.lastTimestamp) {
+		sample.lastTimestamp = timestamp
+	}
+
+	if newFingerprint == oldFingerprint {
+		return
+	}
+
+	r.eventIds[newKey] = append(r.eventIds[newKey], id)
+
+	return true, nil
+}
+
+// dominantFingerprint picks the new fingerprint that
+// received most of the events of an old fingerprint.
+// Ties are broken by the smaller fingerprint.
+func dominantFingerprint(moves map[string]int) (dominant string) {
+	for newFingerprint, count := range moves {
+		if dominant == "" || count > moves[dominant] || (count == moves[dominant] && newFingerprint < dominant) {
+			dominant = newFingerprint
+		}
+	}
+
+	return
+}
+
+// newFingerprintJob creates a new pending
+// fingerprint job.
+func newFingerprintJob(appId uuid.UUID, version uint8, from, to time.Time, dryRun bool, createdBy *uuid.UUID) (job *FingerprintJob, err error) {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+
+	job = &FingerprintJob{
+		ID:                 id,
+		AppID:              appId,
+		FingerprintVersion: version,
+		From:               from,
+		To:                 to,
+		DryRun:             dryRun,
+		Status:             FingerprintJobPending,
+		Diff:               []FingerprintChange{},
+		CreatedBy:          createdBy,
+		CreatedAt:          chrono.ISOTime(now),
+		UpdatedAt:          chrono.ISOTime(now),
+	}
+
+	return
+}
+
+// errFingerprintJobActive is returned when an app
+// already has a pending or running fingerprint job.
+var errFingerprintJobActive = errors.New("a fingerprint job is already in progress")
+
+// insertStmt builds the statement to insert the
+// fingerprint job. Nothing gets inserted if the
+// app already has an active job.
+func (j *FingerprintJob) insertStmt() *sqlf.Stmt {
+	return sqlf.PostgreSQL.
+		InsertInto("public.fingerprint_jobs").
+		Set("id", j.ID).
+		Set("app_id", j.AppID).
+		Set("fingerprint_version", j.FingerprintVersion).
+		Set("from_timestamp", j.From).
+		Set("to_timestamp", j.To).
+		Set("dry_run", j.DryRun).
+		Set("status", j.Status).
+		Set("diff", j.Diff).
+		Set("created_by", j.CreatedBy).
+		Set("created_at", time.Time(j.CreatedAt)).
+		Set("updated_at", time.Time(j.UpdatedAt)).
+		Clause("on conflict (app_id) where status in ('pending', 'running') do nothing")
+}
+
+// failStaleFingerprintJobsStmt builds the statement
+// to mark unfinished fingerprint jobs of an app that
+// haven't reported progress since cutoff as failed.
+func failStaleFingerprintJobsStmt(appId uuid.UUID, cutoff time.Time) *sqlf.Stmt {
+	now := time.Now()
+	return sqlf.PostgreSQL.
+		Update("public.fingerprint_jobs").
+		Set("status", FingerprintJobFailed).
+		Set("error", "job stopped reporting progress").
+		Set("updated_at", now).
+		Set("finished_at", now).
+		Where("app_id = ?", appId).
+		Where("status in (?, ?)", FingerprintJobPending, FingerprintJobRunning).
+		Where("updated_at <= ?", cutoff)
+}
+
+// insert inserts the fingerprint job into the
+// database after failing any stale jobs of the
+// app. Returns errFingerprintJobActive if the app
+// already has an active job.
+//
+// The partial unique index on active jobs makes the
+// check & the insert atomic across concurrent
+// requests.
+func (j *FingerprintJob) insert(ctx context.Context) (err error) {
+	tx, err := server.Server.PgPool.Begin(ctx)
+	if err != nil {
+		return
+	}
+
+	defer tx.Rollback(ctx)
+
+	staleStmt := failStaleFingerprintJobsStmt(j.AppID, time.Now().Add(-fingerprintJobStaleAfter))
+	defer staleStmt.Close()
+
+	if _, err = tx.Exec(ctx, staleStmt.String(), staleStmt.Args()...); err != nil {
+		return
+	}
+
+	stmt := j.insertStmt()
+	defer stmt.Close()
+
+	tag, err := tx.Exec(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	if tag.RowsAffected() == 0 {
+		return errFingerprintJobActive
+	}
+
+	return tx.Commit(ctx)
+}
+
+// save persists status, progress & diff
+// of the fingerprint job.
+func (j *FingerprintJob) save(ctx context.Context) (err error) {
+	j.UpdatedAt = chrono.ISOTime(time.Now())
+
+	stmt := sqlf.PostgreSQL.
+		Update("public.fingerprint_jobs").
+		Set("status", j.Status).
+		Set("total_events", j.TotalEvents).
+		Set("processed_events", j.ProcessedEvents).
+		Set("changed_events", j.ChangedEvents).
+		Set("diff", j.Diff).
+		Set("error", j.Error).
+		Set("updated_at", time.Time(j.UpdatedAt)).
+		Where("id = ?", j.ID)
+
+	defer stmt.Close()
+
+	if j.FinishedAt != nil {
+		stmt.Set("finished_at", time.Time(*j.FinishedAt))
+	}
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// finish marks the fingerprint job as completed,
+// or as failed if err is not nil.
+func (j *FingerprintJob) finish(err error) {
+	finishedAt := chrono.ISOTime(time.Now())
+	j.FinishedAt = &finishedAt
+	j.Status = FingerprintJobCompleted
+
+	if err != nil {
+		msg := err.Error()
+		j.Error = &msg
+		j.Status = FingerprintJobFailed
+	}
+}
+
+// run executes the fingerprint job and records
+// the outcome. Meant to be run in the background.
+func (j *FingerprintJob) run(ctx context.Context) {
+	j.Status = FingerprintJobRunning
+	if err := j.save(ctx); err != nil {
+		fmt.Println("failed to start fingerprint job", j.ID, err)
+		return
+	}
+
+	err := j.execute(ctx)
+	if err != nil {
+		fmt.Println("fingerprint job failed", j.ID, err)
+	}
+
+	j.finish(err)
+
+	if err := j.save(ctx); err != nil {
+		fmt.Println("failed to save fingerprint job", j.ID, err)
+	}
+}
+
+// execute recomputes fingerprints of events in the
+// job's window and computes the diff. Unless a dry run,
+// migrates events & groups to the new fingerprints.
+// Events are migrated batch by batch while recomputing.
+func (j *FingerprintJob) execute(ctx context.Context) (err error) {
+	if err = j.countEvents(ctx); err != nil {
+		return
+	}
+
+	if err = j.save(ctx); err != nil {
+		return
+	}
+
+	recompute, err := j.recompute(ctx)
+	if err != nil {
+		return
+	}
+
+	if err = j.computeDiff(ctx, recompute); err != nil {
+		return
+	}
+
+	if err = j.save(ctx); err != nil {
+		return
+	}
+
+	if j.DryRun {
+		return
+	}
+
+	if err = j.stampEvents(ctx); err != nil {
+		return
+	}
+
+	if err = j.migrateGroups(ctx, recompute); err != nil {
+		return
+	}
+
+	return j.rebuildIssueMetrics(ctx)
+}
+
+// countEvents counts the exception & ANR
+// events in the job's window.
+func (j *FingerprintJob) countEvents(ctx context.Context) (err error) {
+	stmt := sqlf.
+		From("events").
+		Select("count()").
+		Clause("prewhere app_id = toUUID(?)", j.AppID).
+		Where("type in ?", []string{event.TypeException, event.TypeANR}).
+		Where("timestamp >= ? and timestamp <= ?", j.From, j.To)
+
+	defer stmt.Close()
+
+	var count uint64
+	if err = server.Server.ChPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&count); err != nil {
+		return
+	}
+
+	j.TotalEvents = int(count)
+
+	return
+}
+
+// recomputeStmt builds the statement fetching a batch
+// of exception & ANR events in the job's window after
+// the cursor. The first batch is fetched when the
+// cursor is nil.
+func (j *FingerprintJob) recomputeStmt(after *fingerprintCursor) *sqlf.Stmt {
+	stmt := sqlf.
+		From("events").
+		Select("id").
+		Select("type = ? as anr", event.TypeANR).
+		Select("timestamp").
+		Select("exception.handled").
+		Select("toString(exception.fingerprint)").
+		Select("exception.exceptions").
+		Select("toString(anr.fingerprint)").
+		Select("anr.exceptions").
+		Clause("prewhere app_id = toUUID(?)", j.AppID).
+		Where("type in ?", []string{event.TypeException, event.TypeANR}).
+		Where("timestamp >= ? and timestamp <= ?", j.From, j.To)
+
+	if after != nil {
+		stmt.Where("(timestamp, id) > (?, ?)", after.timestamp, after.id)
+	}
+
+	return stmt.
+		OrderBy("timestamp", "id").
+		Limit(fingerprintJobBatchSize)
+}
+
+// recompute iterates over exception & ANR events in
+// the job's window in batches and recomputes their
+// fingerprints using the job's fingerprint version.
+// Unless a dry run, changed events of each batch are
+// moved to their new fingerprints before the next
+// batch, so that only a batch of event ids is held
+// at a time.
+func (j *FingerprintJob) recompute(ctx context.Context) (recompute *fingerprintRecompute, err error) {
+	recompute = newFingerprintRecompute()
+
+	j.ProcessedEvents = 0
+	j.ChangedEvents = 0
+
+	var cursor *fingerprintCursor
+
+	for {
+		var count int
+		count, cursor, err = j.recomputeBatch(ctx, recompute, cursor)
+		if err != nil {
+			return
+		}
+
+		if !j.DryRun {
+			if err = j.moveEvents(ctx, recompute.eventIds); err != nil {
+				return
+			}
+		}
+
+		clear(recompute.eventIds)
+
+		if err = j.save(ctx); err != nil {
+			return
+		}
+
+		if count < fingerprintJobBatchSize {
+			return
+		}
+	}
+}
+
+// recomputeBatch recomputes fingerprints of a batch of
+// events after the cursor. Returns the count of events
+// in the batch & the cursor of its last event.
+func (j *FingerprintJob) recomputeBatch(ctx context.Context, recompute *fingerprintRecompute, after *fingerprintCursor) (count int, cursor *fingerprintCursor, err error) {
+	stmt := j.recomputeStmt(after)
+
+	defer stmt.Close()
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	defer rows.Close()
+
+	cursor = after
+
+	for rows.Next() {
+		var id uuid.UUID
+		var anr, handled bool
+		var timestamp time.Time
+		var exceptionFingerprint, exceptionExceptions, anrFingerprint, anrExceptions string
+
+		if err = rows.Scan(&id, &anr, &timestamp, &handled, &exceptionFingerprint, &exceptionExceptions, &anrFingerprint, &anrExceptions); err != nil {
+			return
+		}
+
+		count += 1
+		cursor = &fingerprintCursor{timestamp, id}
+
+		issueType := event.TypeException
+		oldFingerprint := exceptionFingerprint
+		exceptions := exceptionExceptions
+
+		if anr {
+			issueType = event.TypeANR
+			handled = false
+			oldFingerprint = anrFingerprint
+			exceptions = anrExceptions
+		}
+
+		var units event.ExceptionUnits
+		if err = json.Unmarshal([]byte(exceptions), &units); err != nil {
+			return
+		}
+
+		j.ProcessedEvents += 1
+
+		var changed bool
+		changed, err = recompute.add(id, issueType, handled, oldFingerprint, units, timestamp, j.FingerprintVersion)
+		if err != nil {
+			return
+		}
+
+		if changed {
+			j.ChangedEvents += 1
+		}
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// computeDiff computes the changes in fingerprints
+// and groups from the recomputed fingerprints.
+func (j *FingerprintJob) computeDiff(ctx context.Context, recompute *fingerprintRecompute) (err error) {
+	diff := []FingerprintChange{}
+
+	for oldKey, moves := range recompute.moves {
+		oldGroupId, _, err := j.lookupGroup(ctx, oldKey)
+		if err != nil {
+			return err
+		}
+
+		for newFingerprint, count := range moves {
+			if newFingerprint == oldKey.fingerprint {
+				continue
+			}
+
+			newKey := fingerprintKey{oldKey.issueType, oldKey.handled, newFingerprint}
+			newGroupId, _, err := j.lookupGroup(ctx, newKey)
+			if err != nil {
+				return err
+			}
+
+			diff = append(diff, FingerprintChange{
+				Type:           oldKey.issueType,
+				Handled:        oldKey.handled,
+				OldFingerprint: oldKey.fingerprint,
+				OldGroupID:     oldGroupId,
+				NewFingerprint: newFingerprint,
+				NewGroupID:     newGroupId,
+				Events:         count,
+			})
+		}
+	}
+
+	sort.SliceStable(diff, func(i, j int) bool {
+		if diff[i].Events != diff[j].Events {
+			return diff[i].Events > diff[j].Events
+		}
+		if diff[i].OldFingerprint != diff[j].OldFingerprint {
+			return diff[i].OldFingerprint < diff[j].OldFingerprint
+		}
+		return diff[i].NewFingerprint < diff[j].NewFingerprint
+	})
+
+	j.Diff = diff
+
+	return
+}
+
+// moveEventsQuery builds the mutation rewriting the
+// fingerprints of a batch of events of an issue type
+// to their new fingerprints.
+func (j *FingerprintJob) moveEventsQuery(issueType string, eventIds map[fingerprintKey][]uuid.UUID) (query string, args []any) {
+	column := fmt.Sprintf("`%s.fingerprint`", issueType)
+
+	// sort for a stable mutation
+	keys := []fingerprintKey{}
+	for key := range eventIds {
+		if key.issueType == issueType && len(eventIds[key]) > 0 {
+			keys = append(keys, key)
+		}
+	}
+
+	if len(keys) == 0 {
+		return
+	}
+
+	sort.Slice(keys, func(a, b int) bool {
+		if keys[a].fingerprint != keys[b].fingerprint {
+			return keys[a].fingerprint < keys[b].fingerprint
+		}
+		return !keys[a].handled && keys[b].handled
+	})
+
+	var branches strings.Builder
+	var ids []uuid.UUID
+
+	for _, key := range keys {
+		branches.WriteString("id in ?, toFixedString(?, 32), ")
+		args = append(args, eventIds[key], key.fingerprint)
+		ids = append(ids, eventIds[key]...)
+	}
+
+	args = append(args, j.FingerprintVersion, j.AppID, ids)
+
+	query = fmt.Sprintf("alter table events update %s = multiIf(%s%s), `%s.fingerprint_version` = ? where app_id = toUUID(?) and id in ?", column, branches.String(), column, issueType)
+
+	return
+}
+
+// moveEvents rewrites fingerprints of a batch of
+// changed events, using a single mutation for
+// each issue type.
+func (j *FingerprintJob) moveEvents(ctx context.Context, eventIds map[fingerprintKey][]uuid.UUID) (err error) {
+	ctx = clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{
+		"mutations_sync": 2,
+	}))
+
+	for _, issueType := range []string{event.TypeException, event.TypeANR} {
+		query, args := j.moveEventsQuery(issueType, eventIds)
+		if query == "" {
+			continue
+		}
+
+		if err = server.Server.ChPool.Exec(ctx, query, args...); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// stampEvents stamps every event in the job's
+// window with the job's fingerprint version.
+func (j *FingerprintJob) stampEvents(ctx context.Context) (err error) {
+	ctx = clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{
+		"mutations_sync": 2,
+	}))
+
+	for _, issueType := range []string{event.TypeException, event.TypeANR} {
+		query := fmt.Sprintf("alter table events update `%s.fingerprint_version` = ? where app_id = toUUID(?) and type = ? and timestamp >= ? and timestamp <= ?", issueType)
+		if err = server.Server.ChPool.Exec(ctx, query, j.FingerprintVersion, j.AppID, issueType, j.From, j.To); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// migrateGroups creates groups for new fingerprints and
+// merges each previous group into the group that received
+// most of its events, keeping the previous fingerprint as
+// an alias.
+func (j *FingerprintJob) migrateGroups(ctx context.Context, recompute *fingerprintRecompute) (err error) {
+	groupIds := make(map[fingerprintKey]uuid.UUID)
+
+	for key, sample := range recompute.samples {
+		groupId, mergedInto, err := j.lookupGroup(ctx, key)
+		if err != nil {
+			return err
+		}
+
+		if groupId == nil {
+			id, err := j.insertGroup(ctx, key, sample)
+			if err != nil {
+				return err
+			}
+			groupId = &id
+		} else if err := j.updateGroupVersion(ctx, key, *groupId, sample.lastTimestamp); err != nil {
+			return err
+		}
+
+		if mergedInto != nil {
+			groupId = mergedInto
+		}
+
+		groupIds[key] = *groupId
+	}
+
+	for oldKey, moves := range recompute.moves {
+		oldGroupId, _, err := j.lookupGroup(ctx, oldKey)
+		if err != nil {
+			return err
+		}
+
+		if oldGroupId == nil {
+			continue
+		}
+
+		dominant := dominantFingerprint(moves)
+		if dominant == oldKey.fingerprint {
+			continue
+		}
+
+		targetId, ok := groupIds[fingerprintKey{oldKey.issueType, oldKey.handled, dominant}]
+		if !ok || targetId == *oldGroupId {
+			continue
+		}
+
+		switch oldKey.issueType {
+		case event.TypeANR:
+			target := group.ANRGroup{
+				ID:    targetId,
+				AppID: j.AppID,
+			}
+			err = target.Merge(ctx, []uuid.UUID{*oldGroupId}, nil)
+		default:
+			target := group.ExceptionGroup{
+				ID:      targetId,
+				AppID:   j.AppID,
+				Handled: oldKey.handled,
+			}
+			err = target.Merge(ctx, []uuid.UUID{*oldGroupId}, nil)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return
+}
+
+// rebuildIssueMetrics recomputes issue metrics rollups
+// for the 15 minute windows overlapping the job's window
+// from the migrated events.
+func (j *FingerprintJob) rebuildIssueMetrics(ctx context.Context) (err error) {
+	ctx = clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{
+		"mutations_sync": 2,
+	}))
+
+	window := "timestamp >= toStartOfFifteenMinutes(?) and timestamp < toStartOfFifteenMinutes(?) + interval 15 minute"
+
+	if err = server.Server.ChPool.Exec(ctx, "alter table issue_metrics delete where app_id = toUUID(?) and "+window, j.AppID, j.From, j.To); err != nil {
+		return
+	}
+
+	query := `insert into issue_metrics
+select app_id,
+       type,
+       if(type = 'exception', exception.fingerprint, anr.fingerprint) as fingerprint,
+       if(type = 'exception', exception.handled, false)               as handled,
+       toStartOfFifteenMinutes(events.timestamp)                       as timestamp,
+       (toString(attribute.app_version),
+        toString(attribute.app_build))                                 as app_version,
+       (toString(attribute.os_name),
+        toString(attribute.os_version))                                as os_version,
+       toString(inet.country_code)                                     as country_code,
+       toString(attribute.network_provider)                            as network_provider,
+       toString(attribute.network_type)                                as network_type,
+       toString(attribute.network_generation)                          as network_generation,
+       toString(attribute.device_locale)                               as device_locale,
+       toString(attribute.device_manufacturer)                         as device_manufacturer,
+       toString(attribute.device_name)                                 as device_name,
+       uniqState(id)                                                   as instances,
+       uniqState(session_id)                                           as sessions,
+       uniqState(if(toStringCutToZero(attribute.user_id) != '',
+                    toStringCutToZero(attribute.user_id),
+                    toString(attribute.installation_id)))              as users
+from events
+where app_id = toUUID(?)
+  and type in ?
+  and events.timestamp >= toStartOfFifteenMinutes(?)
+  and events.timestamp < toStartOfFifteenMinutes(?) + interval 15 minute
+group by app_id, type, fingerprint, handled, timestamp, app_version, os_version, country_code, network_provider, network_type, network_generation, device_locale, device_manufacturer, device_name`
+
+	return server.Server.ChPool.Exec(ctx, query, j.AppID, []string{event.TypeException, event.TypeANR}, j.From, j.To)
+}
+
+// lookupGroup finds the id of the group matching
+// the fingerprint along with the id of the group
+// it is merged into, if any.
+func (j *FingerprintJob) lookupGroup(ctx context.Context, key fingerprintKey) (id, mergedInto *uuid.UUID, err error) {
+	table := "public.unhandled_exception_groups"
+	if key.issueType == event.TypeANR {
+		table = "public.anr_groups"
+	}
+
+	stmt := sqlf.PostgreSQL.
+		From(table).
+		Select("id").
+		Select("merged_into").
+		Where("app_id = ?", j.AppID).
+		Where("fingerprint = ?", key.fingerprint).
+		Limit(1)
+
+	defer stmt.Close()
+
+	if key.issueType != event.TypeANR {
+		stmt.Where("handled = ?", key.handled)
+	}
+
+	var groupId uuid.UUID
+	err = server.Server.PgPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&groupId, &mergedInto)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, nil
+	} else if err != nil {
+		return
+	}
+
+	id = &groupId
+
+	return
+}
+
+// insertGroup creates a group for a new fingerprint
+// from the earliest event having the fingerprint.
+func (j *FingerprintJob) insertGroup(ctx context.Context, key fingerprintKey, sample *fingerprintSample) (id uuid.UUID, err error) {
+	if key.issueType == event.TypeANR {
+		anr := event.ANR{Exceptions: sample.units}
+		anrGroup := group.NewANRGroup(j.AppID, anr.GetType(), anr.GetMessage(), anr.GetMethodName(), anr.GetFileName(), anr.GetLineNumber(), key.fingerprint, anr.Analyze().Cause, sample.timestamp)
+		anrGroup.FingerprintVersion = j.FingerprintVersion
+		anrGroup.LastEventTime = sample.lastTimestamp
+		if err = anrGroup.Insert(ctx, nil); err != nil {
+			return
+		}
+
+		return anrGroup.ID, nil
+	}
+
+	exception := event.Exception{Exceptions: sample.units}
+	exceptionGroup := group.NewExceptionGroup(j.AppID, exception.GetType(), exception.GetMessage(), exception.GetMethodName(), exception.GetFileName(), exception.GetLineNumber(), key.fingerprint, sample.timestamp, key.handled)
+	exceptionGroup.FingerprintVersion = j.FingerprintVersion
+	exceptionGroup.LastEventTime = sample.lastTimestamp
+	if err = exceptionGroup.Insert(ctx, nil); err != nil {
+		return
+	}
+
+	return exceptionGroup.ID, nil
+}
+
+// updateGroupVersion stamps an existing group
+// with the job's fingerprint version & moves its
+// last event timestamp forward to the latest
+// event having the fingerprint.
+func (j *FingerprintJob) updateGroupVersion(ctx context.Context, key fingerprintKey, id uuid.UUID, lastTimestamp time.Time) (err error) {
+	table := "public.unhandled_exception_groups"
+	if key.issueType == event.TypeANR {
+		table = "public.anr_groups"
+	}
+
+	stmt := sqlf.PostgreSQL.
+		Update(table).
+		Set("fingerprint_version", j.FingerprintVersion).
+		Set("updated_at", time.Now()).
+		SetExpr("last_event_timestamp", "greatest(last_event_timestamp, ?)", lastTimestamp).
+		Where("id = ?", id)
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// selectFingerprintJobs fetches fingerprint jobs
+// of an app, most recent first. When id is not
+// nil, only the matching job is fetched.
+func selectFingerprintJobs(ctx context.Context, appId uuid.UUID, id *uuid.UUID) (jobs []FingerprintJob, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.fingerprint_jobs").
+		Select("id").
+		Select("app_id").
+		Select("fingerprint_version").
+		Select("from_timestamp").
+		Select("to_timestamp").
+		Select("dry_run").
+		Select("status").
+		Select("total_events").
+		Select("processed_events").
+		Select("changed_events").
+		Select("diff").
+		Select("error").
+		Select("created_by").
+		Select("created_at").
+		Select("updated_at").
+		Select("finished_at").
+		Where("app_id = ?", appId).
+		OrderBy("created_at desc")
+
+	defer stmt.Close()
+
+	if id != nil {
+		stmt.Where("id = ?", id)
+	}
+
+	rows, _ := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	jobs, err = pgx.CollectRows(rows, pgx.RowToStructByNameLax[FingerprintJob])
+
+	return
+}
+
+func CreateFingerprintJob(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	var payload FingerprintJobPayload
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		msg := `failed to parse fingerprint job json payload`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg, "details": err.Error()})
+		return
+	}
+
+	if payload.FingerprintVersion == 0 {
+		payload.FingerprintVersion = event.LatestFingerprintVersion
+	}
+
+	if !event.IsValidFingerprintVersion(payload.FingerprintVersion) {
+		msg := fmt.Sprintf("fingerprint version %d is not supported", payload.FingerprintVersion)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if !payload.From.Before(payload.To) {
+		msg := `from must be earlier than to`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	dryRun := true
+	if payload.DryRun != nil {
+		dryRun = *payload.DryRun
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	user := &User{
+		ID: &userId,
+	}
+
+	role, err := user.getRole(team.ID.String())
+	if err != nil {
+		msg := `couldn't perform authorization checks`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if role < admin {
+		msg := fmt.Sprintf(`only owners & admins of team [%s] can start fingerprint jobs`, team.ID.String())
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	var createdBy *uuid.UUID
+	if id, err := uuid.Parse(userId); err == nil {
+		createdBy = &id
+	}
+
+	job, err := newFingerprintJob(appId, payload.FingerprintVersion, payload.From.UTC(), payload.To.UTC(), dryRun, createdBy)
+	if err != nil {
+		msg := `failed to create fingerprint job`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if err := job.insert(ctx); err != nil {
+		if errors.Is(err, errFingerprintJobActive) {
+			msg := fmt.Sprintf("a fingerprint job is already in progress for app [%s]", appId)
+			c.JSON(http.StatusConflict, gin.H{"error": msg})
+			return
+		}
+
+		msg := `failed to create fingerprint job`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+
+	go job.run(context.Background())
+}
+
+func GetFingerprintJobs(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	jobs, err := selectFingerprintJobs(ctx, appId, nil)
+	if err != nil {
+		msg := `failed to fetch fingerprint jobs`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": jobs})
+}
+
+func GetFingerprintJob(c *gin.Context) {
+	ctx := c.Request.Context()
+	userId := c.GetString("userId")
+	appId, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	jobId, err := uuid.Parse(c.Param("jobId"))
+	if err != nil {
+		msg := `fingerprint job id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	app := App{
+		ID: &appId,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	jobs, err := selectFingerprintJobs(ctx, appId, &jobId)
+	if err != nil {
+		msg := `failed to fetch fingerprint job`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if len(jobs) == 0 {
+		msg := fmt.Sprintf("no fingerprint job found with id %q", jobId.String())
+		c.JSON(http.StatusNotFound, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, jobs[0])
+}
@@ -0,0 +1,261 @@
+package measure
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/api/event"
+
+	"github.com/google/uuid"
+)
+
+func TestNewFingerprintJob(t *testing.T) {
+	appId := uuid.New()
+	from := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 12, 2, 0, 0, 0, 0, time.UTC)
+
+	job, err := newFingerprintJob(appId, 2, from, to, true, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if job.Status != FingerprintJobPending {
+		t.Errorf("Expected %q status, but got %q", FingerprintJobPending, job.Status)
+	}
+
+	if job.AppID != appId || job.FingerprintVersion != 2 || !job.DryRun {
+		t.Errorf("Expected job fields to match arguments, but got %+v", job)
+	}
+
+	if job.Diff == nil || len(job.Diff) != 0 {
+		t.Errorf("Expected empty diff, but got %v", job.Diff)
+	}
+
+	if job.FinishedAt != nil || job.Error != nil {
+		t.Errorf("Expected unfinished job, but got %+v", job)
+	}
+}
+
+func TestFingerprintJobFinish(t *testing.T) {
+	completed, _ := newFingerprintJob(uuid.New(), 2, time.Now().Add(-time.Hour), time.Now(), false, nil)
+	completed.Status = FingerprintJobRunning
+	completed.finish(nil)
+
+	if completed.Status != FingerprintJobCompleted {
+		t.Errorf("Expected %q status, but got %q", FingerprintJobCompleted, completed.Status)
+	}
+
+	if completed.FinishedAt == nil {
+		t.Errorf("Expected finished at to be set")
+	}
+
+	if completed.Error != nil {
+		t.Errorf("Expected no error, but got %q", *completed.Error)
+	}
+
+	failed, _ := newFingerprintJob(uuid.New(), 2, time.Now().Add(-time.Hour), time.Now(), false, nil)
+	failed.Status = FingerprintJobRunning
+	failed.finish(errors.New("clickhouse unavailable"))
+
+	if failed.Status != FingerprintJobFailed {
+		t.Errorf("Expected %q status, but got %q", FingerprintJobFailed, failed.Status)
+	}
+
+	if failed.FinishedAt == nil {
+		t.Errorf("Expected finished at to be set")
+	}
+
+	if failed.Error == nil || *failed.Error != "clickhouse unavailable" {
+		t.Errorf("Expected error to be recorded, but got %v", failed.Error)
+	}
+}
+
+func TestFingerprintJobInsertStmt(t *testing.T) {
+	job, _ := newFingerprintJob(uuid.New(), 2, time.Now().Add(-time.Hour), time.Now(), true, nil)
+
+	stmt := job.insertStmt()
+	defer stmt.Close()
+
+	query := stmt.String()
+
+	expected := []string{
+		"INSERT INTO public.fingerprint_jobs",
+		"on conflict (app_id) where status in ('pending', 'running') do nothing",
+	}
+
+	for _, substr := range expected {
+		if !strings.Contains(query, substr) {
+			t.Errorf("Expected query to contain %q, but got %q", substr, query)
+		}
+	}
+}
+
+func TestFailStaleFingerprintJobsStmt(t *testing.T) {
+	appId := uuid.New()
+	cutoff := time.Now().Add(-fingerprintJobStaleAfter)
+
+	stmt := failStaleFingerprintJobsStmt(appId, cutoff)
+	defer stmt.Close()
+
+	query := stmt.String()
+
+	expected := []string{
+		"UPDATE public.fingerprint_jobs",
+		"app_id = $",
+		"status in ($",
+		"updated_at <= $",
+	}
+
+	for _, substr := range expected {
+		if !strings.Contains(query, substr) {
+			t.Errorf("Expected query to contain %q, but got %q", substr, query)
+		}
+	}
+
+	args := stmt.Args()
+	if args[0] != FingerprintJobFailed {
+		t.Errorf("Expected first arg %q, but got %v", FingerprintJobFailed, args[0])
+	}
+
+	last := args[len(args)-1]
+	if last != cutoff {
+		t.Errorf("Expected last arg %v, but got %v", cutoff, last)
+	}
+}
+
+func TestFingerprintRecomputeV2(t *testing.T) {
+	// crashes from the same app call site, thrown
+	// through different platform frames
+	unitsFrom := func(platformClass string) event.ExceptionUnits {
+		return event.ExceptionUnits{
+			{
+				Type: "java.lang.IllegalStateException",
+				Frames: event.Frames{
+					{ClassName: platformClass, MethodName: "execTransact", FileName: "Binder.java"},
+					{ClassName: "sh.measure.sample.CheckoutActivity", MethodName: "onPay", FileName: "CheckoutActivity.kt"},
+				},
+			},
+		}
+	}
+
+	parcel := unitsFrom("android.os.Parcel")
+	binder := unitsFrom("android.os.Binder")
+
+	parcelV1, _ := event.Exception{Exceptions: parcel}.FingerprintWithVersion(1)
+	binderV1, _ := event.Exception{Exceptions: binder}.FingerprintWithVersion(1)
+	newFingerprint, _ := event.Exception{Exceptions: parcel}.FingerprintWithVersion(2)
+
+	recompute := newFingerprintRecompute()
+	now := time.Now()
+
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	for i, units := range []event.ExceptionUnits{parcel, parcel, binder} {
+		oldFingerprint := parcelV1
+		if i == 2 {
+			oldFingerprint = binderV1
+		}
+
+		changed, err := recompute.add(ids[i], event.TypeException, false, oldFingerprint, units, now.Add(time.Duration(i)*time.Minute), 2)
+		if err != nil {
+			t.Fatalf("Expected no error, but got %v", err)
+		}
+		if !changed {
+			t.Errorf("Expected event %d to move to the v2 fingerprint", i)
+		}
+	}
+
+	// events without exception units stay as they are
+	if changed, _ := recompute.add(uuid.New(), event.TypeException, false, parcelV1, nil, now, 2); changed {
+		t.Errorf("Expected event without exception units to be skipped")
+	}
+
+	newKey := fingerprintKey{event.TypeException, false, newFingerprint}
+
+	if moved := recompute.eventIds[newKey]; len(moved) != 3 || moved[0] != ids[0] || moved[2] != ids[2] {
+		t.Errorf("Expected all events to move to %q, but got %v", newFingerprint, recompute.eventIds)
+	}
+
+	if sample := recompute.samples[newKey]; sample == nil || !sample.timestamp.Equal(now) || !sample.lastTimestamp.Equal(now.Add(2*time.Minute)) {
+		t.Errorf("Expected sample spanning all events, but got %+v", sample)
+	}
+
+	// both v1 groups are merged into the v2 group
+	for _, oldFingerprint := range []string{parcelV1, binderV1} {
+		moves := recompute.moves[fingerprintKey{event.TypeException, false, oldFingerprint}]
+		if dominant := dominantFingerprint(moves); dominant != newFingerprint {
+			t.Errorf("Expected %q to merge into %q, but got %q", oldFingerprint, newFingerprint, dominant)
+		}
+	}
+}
+
+func TestDominantFingerprint(t *testing.T) {
+	if dominant := dominantFingerprint(map[string]int{"b": 2, "a": 1}); dominant != "b" {
+		t.Errorf("Expected %q, but got %q", "b", dominant)
+	}
+
+	if dominant := dominantFingerprint(map[string]int{"b": 2, "a": 2}); dominant != "a" {
+		t.Errorf("Expected tie to pick %q, but got %q", "a", dominant)
+	}
+}
+
+func TestFingerprintJobRecomputeStmt(t *testing.T) {
+	job, _ := newFingerprintJob(uuid.New(), 2, time.Now().Add(-time.Hour), time.Now(), false, nil)
+
+	first := job.recomputeStmt(nil)
+	defer first.Close()
+
+	if strings.Contains(first.String(), "(timestamp, id) >") {
+		t.Errorf("Expected first batch to start at the window, but got %s", first.String())
+	}
+
+	if !strings.HasSuffix(first.String(), "ORDER BY timestamp, id LIMIT ?") {
+		t.Errorf("Expected bounded batch in timestamp & id order, but got %s", first.String())
+	}
+
+	cursor := &fingerprintCursor{time.Now().Add(-time.Minute), uuid.New()}
+
+	next := job.recomputeStmt(cursor)
+	defer next.Close()
+
+	if !strings.Contains(next.String(), "AND (timestamp, id) > (?, ?) ORDER BY") {
+		t.Errorf("Expected batch after the cursor, but got %s", next.String())
+	}
+
+	args := next.Args()
+	if len(args) != 8 || args[5] != cursor.timestamp || args[6] != cursor.id || args[7] != fingerprintJobBatchSize {
+		t.Errorf("Unexpected args %v", args)
+	}
+}
+
+func TestFingerprintJobMoveEventsQuery(t *testing.T) {
+	job, _ := newFingerprintJob(uuid.New(), 2, time.Now().Add(-time.Hour), time.Now(), false, nil)
+
+	first, second, third := uuid.New(), uuid.New(), uuid.New()
+	eventIds := map[fingerprintKey][]uuid.UUID{
+		{event.TypeException, false, "b"}: {first},
+		{event.TypeException, true, "a"}:  {second, third},
+		{event.TypeANR, false, "c"}:       {},
+	}
+
+	query, args := job.moveEventsQuery(event.TypeException, eventIds)
+
+	expected := "alter table events update `exception.fingerprint` = multiIf(id in ?, toFixedString(?, 32), id in ?, toFixedString(?, 32), `exception.fingerprint`), `exception.fingerprint_version` = ? where app_id = toUUID(?) and id in ?"
+	if query != expected {
+		t.Errorf("Expected %q, but got %q", expected, query)
+	}
+
+	if len(args) != 7 || args[1] != "a" || args[3] != "b" || args[4] != job.FingerprintVersion || args[5] != job.AppID {
+		t.Errorf("Unexpected args %v", args)
+	}
+
+	if ids, ok := args[6].([]uuid.UUID); !ok || len(ids) != 3 || ids[2] != first {
+		t.Errorf("Expected all ids of the batch, but got %v", args[6])
+	}
+
+	// no mutation without changed events
+	if query, _ := job.moveEventsQuery(event.TypeANR, eventIds); query != "" {
+		t.Errorf("Expected no query, but got %q", query)
+	}
+}
@@ -0,0 +1,303 @@
+package measure
+
+import (
+	"backend/api/event"
+	"backend/api/filter"
+	"backend/api/server"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+// maxClickFrustrations is the maximum count of
+// screen & target pairs reported for frustrated
+// clicks.
+const maxClickFrustrations = 100
+
+// ClickFrustration represents rage & dead clicks
+// aggregated for a single target on a single
+// screen.
+type ClickFrustration struct {
+	Screen       string `json:"screen"`
+	Target       string `json:"target"`
+	TargetID     string `json:"target_id"`
+	RageClicks   uint64 `json:"rage_clicks"`
+	RageSessions uint64 `json:"rage_sessions"`
+	DeadClicks   uint64 `json:"dead_clicks"`
+	DeadSessions uint64 `json:"dead_sessions"`
+}
+
+// withFrustratedClicks builds a statement defining the
+// frustrated_clicks table of click gestures flagged as
+// rage clicks, dead clicks or both while respecting all
+// applicable app filters. Detection happens entirely in
+// the database following the rules of
+// event.DetectFrustratedClicks.
+//
+// Clicks on the same spot are counted over a trailing
+// window, so a click is a rage click when any window
+// ending within the rage click window after it has
+// enough clicks. A click is a dead click when the next
+// navigation, lifecycle or http event of the session
+// is missing or too late.
+func withFrustratedClicks(af *filter.AppFilter) *sqlf.Stmt {
+	rageWindow := event.RageClickWindow.Milliseconds()
+	deadWindow := event.DeadClickWindow.Milliseconds()
+
+	clickEvents := sqlf.
+		From("events").
+		Select("id").
+		Select("session_id").
+		Select("timestamp").
+		Select("toUnixTimestamp64Milli(timestamp) as ts_ms").
+		Select("type = ? as is_click", event.TypeGestureClick).
+		Select("toStringCutToZero(gesture_click.target) as target").
+		Select("toStringCutToZero(gesture_click.target_id) as target_id").
+		Select(fmt.Sprintf("if(is_click, if(target_id != '', concat(target, '#', target_id), concat(target, '@', toString(floor(gesture_click.x / %d)), ',', toString(floor(gesture_click.y / %d)))), '') as spot", event.RageClickArea, event.RageClickArea)).
+		Select("nullIf(multiIf(type = ?, toStringCutToZero(screen_view.name), type = ?, toStringCutToZero(navigation.to), type = ? and toStringCutToZero(lifecycle_activity.type) = ?, toStringCutToZero(lifecycle_activity.class_name), type = ? and toStringCutToZero(lifecycle_fragment.type) = ?, toStringCutToZero(lifecycle_fragment.class_name), type = ? and toStringCutToZero(lifecycle_view_controller.type) = ?, toStringCutToZero(lifecycle_view_controller.class_name), type = ? and toStringCutToZero(lifecycle_swift_ui.type) = ?, toStringCutToZero(lifecycle_swift_ui.class_name), ''), '') as viewed_screen",
+			event.TypeScreenView,
+			event.TypeNavigation,
+			event.TypeLifecycleActivity, event.LifecycleActivityTypeResumed,
+			event.TypeLifecycleFragment, event.LifecycleFragmentTypeResumed,
+			event.TypeLifecycleViewController, event.LifecycleViewControllerTypeViewDidAppear,
+			event.TypeLifecycleSwiftUI, event.LifecycleSwiftUITypeOnAppear).
+		Clause("prewhere app_id = toUUID(?)", af.AppID).
+		Where("type in ?", []string{
+			event.TypeGestureClick,
+			event.TypeNavigation,
+			event.TypeScreenView,
+			event.TypeHttp,
+			event.TypeLifecycleActivity,
+			event.TypeLifecycleFragment,
+			event.TypeLifecycleViewController,
+			event.TypeLifecycleSwiftUI,
+			event.TypeLifecycleApp,
+		})
+
+	applyEventFilters(clickEvents, af)
+
+	clickWindows := sqlf.
+		From("click_events").
+		Select("id").
+		Select("session_id").
+		Select("timestamp").
+		Select("ts_ms").
+		Select("is_click").
+		Select("target").
+		Select("target_id").
+		Select("spot").
+		Select("ifNull(last_value(viewed_screen) over (partition by session_id order by timestamp rows between unbounded preceding and current row), '') as screen").
+		Select("min(if(is_click, null, ts_ms)) over (partition by session_id order by ts_ms range between 1 following and unbounded following) as next_activity_ms").
+		Select(fmt.Sprintf("count() over (partition by session_id, is_click, spot order by ts_ms range between %d preceding and current row) as recent_clicks", rageWindow))
+
+	frustratedClicks := sqlf.
+		From("click_windows").
+		Select("id").
+		Select("session_id").
+		Select("timestamp").
+		Select("target").
+		Select("target_id").
+		Select("screen").
+		Select(fmt.Sprintf("max(recent_clicks) over (partition by session_id, spot order by ts_ms range between current row and %d following) >= %d as rage", rageWindow, event.RageClickThreshold)).
+		Select(fmt.Sprintf("next_activity_ms is null or next_activity_ms - ts_ms > %d as dead", deadWindow)).
+		Where("is_click")
+
+	return sqlf.
+		With("click_events", clickEvents).
+		With("click_windows", clickWindows).
+		With("frustrated_clicks", frustratedClicks)
+}
+
+// frustratedSessionsStmt builds the statement selecting
+// sessions having rage clicks, dead clicks or either of
+// them when both are requested while respecting all
+// applicable app filters.
+func frustratedSessionsStmt(af *filter.AppFilter, rage, dead bool) *sqlf.Stmt {
+	stmt := withFrustratedClicks(af).
+		From("frustrated_clicks").
+		Select("distinct session_id")
+
+	switch {
+	case rage && dead:
+		stmt.Where("rage or dead")
+	case rage:
+		stmt.Where("rage")
+	default:
+		stmt.Where("dead")
+	}
+
+	return stmt
+}
+
+// clickFrustrationsStmt builds the statement aggregating
+// rage & dead clicks by screen & target, ordered by the
+// count of frustrated clicks.
+func clickFrustrationsStmt(af *filter.AppFilter) *sqlf.Stmt {
+	return withFrustratedClicks(af).
+		From("frustrated_clicks").
+		Select("screen").
+		Select("target").
+		Select("target_id").
+		Select("countIf(rage) as rage_clicks").
+		Select("uniqExactIf(session_id, rage) as rage_sessions").
+		Select("countIf(dead) as dead_clicks").
+		Select("uniqExactIf(session_id, dead) as dead_sessions").
+		Where("rage or dead").
+		GroupBy("screen, target, target_id").
+		OrderBy("rage_clicks + dead_clicks desc, screen, target, target_id").
+		Limit(maxClickFrustrations)
+}
+
+// GetClickFrustrations aggregates rage & dead clicks
+// by screen & target while respecting all applicable
+// app filters.
+func (a App) GetClickFrustrations(ctx context.Context, af *filter.AppFilter) (frustrations []ClickFrustration, err error) {
+	frustrations = []ClickFrustration{}
+
+	stmt := clickFrustrationsStmt(af)
+
+	defer stmt.Close()
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		var f ClickFrustration
+		if err = rows.Scan(&f.Screen, &f.Target, &f.TargetID, &f.RageClicks, &f.RageSessions, &f.DeadClicks, &f.DeadSessions); err != nil {
+			return
+		}
+		frustrations = append(frustrations, f)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+func GetClickFrustrations(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse click frustrations request`
+		fmt.Println(msg, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := `click frustrations request validation failed`
+
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	frustrations, err := app.GetClickFrustrations(ctx, &af)
+	if err != nil {
+		msg := `failed to fetch click frustrations`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, frustrations)
+}
@@ -0,0 +1,63 @@
+package measure
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestClickFrustrationsStmt(t *testing.T) {
+	af := newTestAppFilter()
+
+	stmt := clickFrustrationsStmt(af)
+	defer stmt.Close()
+
+	sql := stmt.String()
+
+	expected := []string{
+		"WITH click_events AS (SELECT",
+		"toUnixTimestamp64Milli(timestamp) as ts_ms",
+		"floor(gesture_click.x / 48)",
+		"click_windows AS (SELECT",
+		"last_value(viewed_screen) over (partition by session_id order by timestamp rows between unbounded preceding and current row)",
+		"min(if(is_click, null, ts_ms)) over (partition by session_id order by ts_ms range between 1 following and unbounded following) as next_activity_ms",
+		"count() over (partition by session_id, is_click, spot order by ts_ms range between 1000 preceding and current row) as recent_clicks",
+		"frustrated_clicks AS (SELECT",
+		"max(recent_clicks) over (partition by session_id, spot order by ts_ms range between current row and 1000 following) >= 3 as rage",
+		"next_activity_ms is null or next_activity_ms - ts_ms > 1000 as dead",
+		"FROM frustrated_clicks WHERE rage or dead",
+		"GROUP BY screen, target, target_id",
+		"ORDER BY rage_clicks + dead_clicks desc",
+	}
+
+	for _, want := range expected {
+		if !strings.Contains(sql, want) {
+			t.Errorf("Expected statement to contain %q, got %s", want, sql)
+		}
+	}
+
+	if strings.Count(sql, "FROM events") != 1 {
+		t.Errorf("Expected a single scan of events, got %s", sql)
+	}
+}
+
+func TestFrustratedSessionsStmt(t *testing.T) {
+	af := newTestAppFilter()
+
+	cases := map[string]struct {
+		rage, dead bool
+	}{
+		"WHERE rage or dead": {true, true},
+		"WHERE rage":         {true, false},
+		"WHERE dead":         {false, true},
+	}
+
+	for want, c := range cases {
+		stmt := frustratedSessionsStmt(af, c.rage, c.dead)
+		sql := stmt.String()
+		stmt.Close()
+
+		if !strings.Contains(sql, "SELECT distinct session_id FROM frustrated_clicks "+want) {
+			t.Errorf("Expected statement to end with %q, got %s", want, sql)
+		}
+	}
+}
@@ -0,0 +1,541 @@
+package measure
+
+import (
+	"backend/api/event"
+	"backend/api/filter"
+	"backend/api/metrics"
+	"backend/api/server"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+// maxFunnelSteps is the maximum count of
+// steps allowed in a funnel.
+const maxFunnelSteps = 10
+
+// maxFunnelWindow is the maximum conversion
+// window allowed for user scoped funnels.
+const maxFunnelWindow = 30 * 24 * time.Hour
+
+// defaultFunnelWindow is the conversion window
+// of user scoped funnels when not set.
+const defaultFunnelWindow = 24 * time.Hour
+
+const (
+	// FunnelScopeSession requires all steps
+	// to complete within a single session.
+	FunnelScopeSession = "session"
+
+	// FunnelScopeUser requires all steps to
+	// complete by a single user within the
+	// conversion window.
+	FunnelScopeUser = "user"
+)
+
+// funnelStepNames maps the event types allowed
+// as funnel steps to their name column.
+var funnelStepNames = map[string]string{
+	event.TypeCustom:     "toStringCutToZero(`custom.name`)",
+	event.TypeScreenView: "toStringCutToZero(`screen_view.name`)",
+}
+
+// FunnelStep represents a single step of a funnel
+// matching events by type, name & optional user
+// defined attribute conditions.
+type FunnelStep struct {
+	Type       string               `json:"type" binding:"required"`
+	Name       string               `json:"name" binding:"required"`
+	Conditions []event.UDComparison `json:"conditions"`
+}
+
+// FunnelDefinition represents an ordered list of
+// funnel steps along with the scope of conversion.
+type FunnelDefinition struct {
+	Steps []FunnelStep `json:"steps" binding:"required"`
+	Scope string       `json:"scope"`
+	// Window is the conversion window of user
+	// scoped funnels in seconds.
+	Window uint32 `json:"window"`
+	// Compare is set to compute the funnel for
+	// a pair of app versions.
+	Compare *ReleaseVersions `json:"compare"`
+}
+
+// FunnelStepResult represents conversion & drop-off
+// of a single funnel step.
+type FunnelStepResult struct {
+	Type  string `json:"type"`
+	Name  string `json:"name"`
+	Count uint64 `json:"count"`
+	// Conversion is the percentage of funnel
+	// entrants reaching the step.
+	Conversion float64 `json:"conversion"`
+	// StepConversion is the percentage of the
+	// previous step reaching the step.
+	StepConversion float64 `json:"step_conversion"`
+	// DropOff is the count of the previous
+	// step not reaching the step.
+	DropOff     uint64  `json:"drop_off"`
+	DropOffRate float64 `json:"drop_off_rate"`
+}
+
+// Funnel represents the computed steps of
+// a funnel.
+type Funnel struct {
+	Scope string             `json:"scope"`
+	Steps []FunnelStepResult `json:"steps"`
+}
+
+// FunnelDiff represents the difference in
+// conversion of a funnel step between the
+// base & target versions.
+type FunnelDiff struct {
+	Step        int     `json:"step"`
+	Base        float64 `json:"base"`
+	Target      float64 `json:"target"`
+	Delta       float64 `json:"delta"`
+	PValue      float64 `json:"p_value"`
+	Significant bool    `json:"significant"`
+}
+
+// FunnelComparison represents the funnel computed
+// separately for the base & target versions.
+type FunnelComparison struct {
+	Base   Funnel       `json:"base"`
+	Target Funnel       `json:"target"`
+	Diffs  []FunnelDiff `json:"diffs"`
+}
+
+// FunnelAnalysis represents the funnel along
+// with the optional version comparison.
+type FunnelAnalysis struct {
+	Funnel     Funnel            `json:"funnel"`
+	Comparison *FunnelComparison `json:"comparison"`
+}
+
+// Validate validates the funnel definition and
+// sets defaults for missing optional values.
+func (f *FunnelDefinition) Validate() error {
+	if len(f.Steps) < 2 {
+		return errors.New("funnel must have at least 2 steps")
+	}
+
+	if len(f.Steps) > maxFunnelSteps {
+		return fmt.Errorf("funnel must not have more than %d steps", maxFunnelSteps)
+	}
+
+	if f.Scope == "" {
+		f.Scope = FunnelScopeSession
+	}
+
+	switch f.Scope {
+	case FunnelScopeSession:
+	case FunnelScopeUser:
+		if f.Window == 0 {
+			f.Window = uint32(defaultFunnelWindow.Seconds())
+		}
+
+		if time.Duration(f.Window)*time.Second > maxFunnelWindow {
+			return fmt.Errorf("window must not exceed %d seconds", uint32(maxFunnelWindow.Seconds()))
+		}
+	default:
+		return fmt.Errorf("scope must be one of %q or %q", FunnelScopeSession, FunnelScopeUser)
+	}
+
+	for i, step := range f.Steps {
+		if _, ok := funnelStepNames[step.Type]; !ok {
+			return fmt.Errorf("step %d: type must be one of %q or %q", i+1, event.TypeCustom, event.TypeScreenView)
+		}
+
+		for _, cmp := range step.Conditions {
+			if cmp.Empty() {
+				return fmt.Errorf("step %d: condition key must not be empty", i+1)
+			}
+
+			if err := cmp.Validate(); err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+
+			switch cmp.Type {
+			case event.AttrInt64, event.AttrFloat64:
+				if _, err := strconv.ParseFloat(cmp.Value, 64); err != nil {
+					return fmt.Errorf("step %d: condition value %q of %q is not a number", i+1, cmp.Value, cmp.Key)
+				}
+			}
+		}
+	}
+
+	if f.Compare != nil {
+		rv := f.Compare
+		if rv.BaseVersion == rv.TargetVersion && rv.BaseVersionCode == rv.TargetVersionCode {
+			return errors.New("base & target versions must be different")
+		}
+	}
+
+	return nil
+}
+
+// condition returns the sql expression
+// matching events of the step along with
+// its arguments.
+func (s FunnelStep) condition() (expr string, args []any) {
+	exprs := []string{"type = ?", fmt.Sprintf("%s = ?", funnelStepNames[s.Type])}
+	args = []any{s.Type, s.Name}
+
+	for _, cmp := range s.Conditions {
+		exprs = append(exprs, "mapContains(user_defined_attribute, ?)", "tupleElement(user_defined_attribute[?], 1) = ?")
+		args = append(args, cmp.Key, cmp.Key, cmp.Type.String())
+
+		switch cmp.Op {
+		case event.OpContains:
+			exprs = append(exprs, "tupleElement(user_defined_attribute[?], 2) ilike ?")
+			args = append(args, cmp.Key, "%"+cmp.EscapedValue()+"%")
+		case event.OpStartsWith:
+			exprs = append(exprs, "tupleElement(user_defined_attribute[?], 2) ilike ?")
+			args = append(args, cmp.Key, cmp.EscapedValue()+"%")
+		default:
+			switch cmp.Type {
+			case event.AttrInt64, event.AttrFloat64:
+				value, _ := strconv.ParseFloat(cmp.Value, 64)
+				exprs = append(exprs, fmt.Sprintf("toFloat64OrNull(tupleElement(user_defined_attribute[?], 2)) %s ?", cmp.Op.Sql()))
+				args = append(args, cmp.Key, value)
+			default:
+				exprs = append(exprs, fmt.Sprintf("tupleElement(user_defined_attribute[?], 2) %s ?", cmp.Op.Sql()))
+				args = append(args, cmp.Key, cmp.Value)
+			}
+		}
+	}
+
+	expr = fmt.Sprintf("(%s)", strings.Join(exprs, " and "))
+
+	return
+}
+
+// GetFunnelAnalysis computes the funnel while respecting
+// all applicable app filters. The funnel is additionally
+// computed for each version if a comparison is requested.
+func (a App) GetFunnelAnalysis(ctx context.Context, af *filter.AppFilter, fd *FunnelDefinition) (analysis *FunnelAnalysis, err error) {
+	analysis = &FunnelAnalysis{}
+
+	if analysis.Funnel, err = a.getFunnel(ctx, af, fd); err != nil {
+		return
+	}
+
+	if fd.Compare == nil {
+		return
+	}
+
+	comparison := &FunnelComparison{}
+
+	baseFilter := *af
+	baseFilter.Versions = []string{fd.Compare.BaseVersion}
+	baseFilter.VersionCodes = []string{fd.Compare.BaseVersionCode}
+
+	if comparison.Base, err = a.getFunnel(ctx, &baseFilter, fd); err != nil {
+		return
+	}
+
+	targetFilter := *af
+	targetFilter.Versions = []string{fd.Compare.TargetVersion}
+	targetFilter.VersionCodes = []string{fd.Compare.TargetVersionCode}
+
+	if comparison.Target, err = a.getFunnel(ctx, &targetFilter, fd); err != nil {
+		return
+	}
+
+	comparison.Diffs = computeFunnelDiffs(comparison.Base, comparison.Target)
+	analysis.Comparison = comparison
+
+	return
+}
+
+// getFunnel computes the funnel by finding the
+// deepest step reached in order by each session
+// or user.
+func (a App) getFunnel(ctx context.Context, af *filter.AppFilter, fd *FunnelDefinition) (funnel Funnel, err error) {
+	identity := "toString(session_id)"
+	window := af.To.Sub(af.From).Milliseconds()
+
+	if fd.Scope == FunnelScopeUser {
+		identity = userIdentity
+		window = (time.Duration(fd.Window) * time.Second).Milliseconds()
+	}
+
+	var conditions []string
+	var args []any
+	types := make(map[string]struct{})
+
+	for _, step := range fd.Steps {
+		expr, exprArgs := step.condition()
+		conditions = append(conditions, expr)
+		args = append(args, exprArgs...)
+		types[step.Type] = struct{}{}
+	}
+
+	var stepTypes []string
+	for t := range types {
+		stepTypes = append(stepTypes, t)
+	}
+
+	levelStmt := sqlf.
+		From("events").
+		Select(fmt.Sprintf("%s as identity", identity)).
+		Select(fmt.Sprintf("windowFunnel(%d, 'strict_increase')(toUInt64(toUnixTimestamp64Milli(timestamp)), %s) as level", window, strings.Join(conditions, ", ")), args...).
+		Clause("prewhere app_id = toUUID(?) and type in ?", af.AppID, stepTypes).
+		GroupBy("identity")
+
+	defer levelStmt.Close()
+
+	applyEventFilters(levelStmt, af)
+
+	stmt := sqlf.
+		From(fmt.Sprintf("(%s)", levelStmt.String()), levelStmt.Args()...).
+		Select("level").
+		Select("count() as count").
+		Where("level > 0").
+		GroupBy("level")
+
+	defer stmt.Close()
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	levels := make(map[int]uint64)
+
+	for rows.Next() {
+		var level uint8
+		var count uint64
+		if err = rows.Scan(&level, &count); err != nil {
+			return
+		}
+
+		levels[int(level)] = count
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	funnel = computeFunnel(fd, levels)
+
+	return
+}
+
+// computeFunnel computes conversion & drop-off of each
+// step from the count of sessions or users by the deepest
+// step reached.
+func computeFunnel(fd *FunnelDefinition, levels map[int]uint64) (funnel Funnel) {
+	funnel = Funnel{
+		Scope: fd.Scope,
+		Steps: []FunnelStepResult{},
+	}
+
+	counts := make([]uint64, len(fd.Steps))
+	for level, count := range levels {
+		for i := 0; i < level && i < len(counts); i++ {
+			counts[i] += count
+		}
+	}
+
+	for i, step := range fd.Steps {
+		result := FunnelStepResult{
+			Type:           step.Type,
+			Name:           step.Name,
+			Count:          counts[i],
+			Conversion:     percentage(counts[i], counts[0]),
+			StepConversion: percentage(counts[i], counts[0]),
+		}
+
+		if i > 0 {
+			result.StepConversion = percentage(counts[i], counts[i-1])
+			result.DropOff = counts[i-1] - counts[i]
+			result.DropOffRate = percentage(result.DropOff, counts[i-1])
+		}
+
+		funnel.Steps = append(funnel.Steps, result)
+	}
+
+	return
+}
+
+// computeFunnelDiffs compares conversion of each step
+// of the target funnel against the base funnel.
+func computeFunnelDiffs(base, target Funnel) (diffs []FunnelDiff) {
+	diffs = []FunnelDiff{}
+
+	if len(base.Steps) == 0 || len(base.Steps) != len(target.Steps) {
+		return
+	}
+
+	baseEntrants := base.Steps[0].Count
+	targetEntrants := target.Steps[0].Count
+
+	for i := 1; i < len(base.Steps); i++ {
+		pValue := metrics.ProportionsTest(base.Steps[i].Count, baseEntrants, target.Steps[i].Count, targetEntrants)
+
+		diffs = append(diffs, FunnelDiff{
+			Step:        i + 1,
+			Base:        base.Steps[i].Conversion,
+			Target:      target.Steps[i].Conversion,
+			Delta:       math.Round((target.Steps[i].Conversion-base.Steps[i].Conversion)*100) / 100,
+			PValue:      pValue,
+			Significant: pValue < metrics.SignificanceLevel,
+		})
+	}
+
+	return
+}
+
+func GetFunnelAnalysis(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse funnel request`
+		fmt.Println(msg, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	var fd FunnelDefinition
+
+	if err := c.ShouldBindJSON(&fd); err != nil {
+		msg := `failed to parse funnel request`
+		fmt.Println(msg, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := `funnel request validation failed`
+
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	if err := fd.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	analysis, err := app.GetFunnelAnalysis(ctx, &af, &fd)
+	if err != nil {
+		msg := `failed to compute funnel`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, analysis)
+}
@@ -0,0 +1,128 @@
+package measure
+
+import (
+	"backend/api/event"
+	"reflect"
+	"testing"
+)
+
+func TestFunnelDefinitionValidate(t *testing.T) {
+	steps := []FunnelStep{
+		{Type: event.TypeScreenView, Name: "cart"},
+		{Type: event.TypeCustom, Name: "checkout"},
+	}
+
+	fd := FunnelDefinition{Steps: steps, Scope: FunnelScopeUser}
+	if err := fd.Validate(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if fd.Window != 86400 {
+		t.Errorf("Expected default window %d, but got %d", 86400, fd.Window)
+	}
+
+	fd = FunnelDefinition{Steps: steps[:1]}
+	if err := fd.Validate(); err == nil {
+		t.Errorf("Expected error for a single step funnel, but got nil")
+	}
+
+	fd = FunnelDefinition{Steps: []FunnelStep{steps[0], {Type: event.TypeANR, Name: "anr"}}}
+	if err := fd.Validate(); err == nil {
+		t.Errorf("Expected error for invalid step type, but got nil")
+	}
+
+	fd = FunnelDefinition{Steps: []FunnelStep{steps[0], {
+		Type: event.TypeCustom,
+		Name: "checkout",
+		Conditions: []event.UDComparison{
+			{Key: "amount", Type: event.AttrInt64, Op: event.OpGt, Value: "many"},
+		},
+	}}}
+	if err := fd.Validate(); err == nil {
+		t.Errorf("Expected error for non-numeric condition value, but got nil")
+	}
+
+	fd = FunnelDefinition{Steps: steps, Compare: &ReleaseVersions{
+		BaseVersion:       "1.0",
+		BaseVersionCode:   "1",
+		TargetVersion:     "1.0",
+		TargetVersionCode: "1",
+	}}
+	if err := fd.Validate(); err == nil {
+		t.Errorf("Expected error for identical versions, but got nil")
+	}
+}
+
+func TestFunnelStepCondition(t *testing.T) {
+	step := FunnelStep{
+		Type: event.TypeCustom,
+		Name: "checkout",
+		Conditions: []event.UDComparison{
+			{Key: "plan", Type: event.AttrString, Op: event.OpStartsWith, Value: "pro"},
+		},
+	}
+
+	expr, args := step.condition()
+
+	expectedExpr := "(type = ? and toStringCutToZero(`custom.name`) = ? and mapContains(user_defined_attribute, ?) and tupleElement(user_defined_attribute[?], 1) = ? and tupleElement(user_defined_attribute[?], 2) ilike ?)"
+	if expr != expectedExpr {
+		t.Errorf("Expected %q, but got %q", expectedExpr, expr)
+	}
+
+	expectedArgs := []any{"custom", "checkout", "plan", "plan", "string", "plan", "pro%"}
+	if !reflect.DeepEqual(args, expectedArgs) {
+		t.Errorf("Expected %v, but got %v", expectedArgs, args)
+	}
+}
+
+func TestComputeFunnel(t *testing.T) {
+	fd := FunnelDefinition{
+		Scope: FunnelScopeSession,
+		Steps: []FunnelStep{
+			{Type: event.TypeScreenView, Name: "cart"},
+			{Type: event.TypeScreenView, Name: "payment"},
+			{Type: event.TypeCustom, Name: "purchase"},
+		},
+	}
+
+	funnel := computeFunnel(&fd, map[int]uint64{1: 50, 2: 30, 3: 20})
+
+	expected := []FunnelStepResult{
+		{Type: "screen_view", Name: "cart", Count: 100, Conversion: 100, StepConversion: 100},
+		{Type: "screen_view", Name: "payment", Count: 50, Conversion: 50, StepConversion: 50, DropOff: 50, DropOffRate: 50},
+		{Type: "custom", Name: "purchase", Count: 20, Conversion: 20, StepConversion: 40, DropOff: 30, DropOffRate: 60},
+	}
+
+	if !reflect.DeepEqual(funnel.Steps, expected) {
+		t.Errorf("Expected %+v, but got %+v", expected, funnel.Steps)
+	}
+
+	empty := computeFunnel(&fd, map[int]uint64{})
+	for _, step := range empty.Steps {
+		if step.Count != 0 || step.Conversion != 0 || step.DropOff != 0 {
+			t.Errorf("Expected empty step, but got %+v", step)
+		}
+	}
+}
+
+func TestComputeFunnelDiffs(t *testing.T) {
+	fd := FunnelDefinition{
+		Steps: []FunnelStep{
+			{Type: event.TypeScreenView, Name: "cart"},
+			{Type: event.TypeCustom, Name: "purchase"},
+		},
+	}
+
+	base := computeFunnel(&fd, map[int]uint64{1: 600, 2: 400})
+	target := computeFunnel(&fd, map[int]uint64{1: 800, 2: 200})
+
+	diffs := computeFunnelDiffs(base, target)
+
+	if len(diffs) != 1 {
+		t.Fatalf("Expected %d diff, but got %d", 1, len(diffs))
+	}
+
+	if diffs[0].Step != 2 || diffs[0].Base != 40 || diffs[0].Target != 20 || diffs[0].Delta != -20 || !diffs[0].Significant {
+		t.Errorf("Expected a significant drop of %v, but got %+v", -20, diffs[0])
+	}
+}
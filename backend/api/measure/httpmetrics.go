@@ -0,0 +1,758 @@
+package measure
+
+import (
+	"backend/api/event"
+	"backend/api/filter"
+	"backend/api/server"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+// maxHttpEndpoints is the maximum count of
+// endpoints reported in http analytics.
+const maxHttpEndpoints = 100
+
+// maxHttpFailureReasons is the maximum count
+// of failure reasons reported per endpoint.
+const maxHttpFailureReasons = 5
+
+// maxHttpBreakdowns is the maximum count of
+// network types, network generations or
+// countries reported in http breakdowns.
+const maxHttpBreakdowns = 10
+
+// HttpStatusClasses represents the count of http
+// calls by class of response status code. Failed
+// counts calls that did not receive any response.
+type HttpStatusClasses struct {
+	Informational uint64 `json:"1xx"`
+	Success       uint64 `json:"2xx"`
+	Redirection   uint64 `json:"3xx"`
+	ClientError   uint64 `json:"4xx"`
+	ServerError   uint64 `json:"5xx"`
+	Failed        uint64 `json:"failed"`
+}
+
+// HttpErrorRates represents the percentage of
+// http calls that resulted in client errors,
+// server errors or failures.
+type HttpErrorRates struct {
+	Total       float64 `json:"total"`
+	ClientError float64 `json:"4xx"`
+	ServerError float64 `json:"5xx"`
+	Failed      float64 `json:"failed"`
+}
+
+// HttpStats represents call count, latency
+// quantiles & error rates of http calls.
+type HttpStats struct {
+	Calls         uint64            `json:"calls"`
+	P50           float64           `json:"p50"`
+	P95           float64           `json:"p95"`
+	StatusClasses HttpStatusClasses `json:"status_classes"`
+	ErrorRates    HttpErrorRates    `json:"error_rates"`
+}
+
+// HttpFailureReason represents the count of
+// http calls that failed for a reason.
+type HttpFailureReason struct {
+	Reason string `json:"reason"`
+	Count  uint64 `json:"count"`
+}
+
+// HttpEndpoint represents the stats of http
+// calls made to a single url template using
+// a single method.
+type HttpEndpoint struct {
+	URLTemplate string `json:"url_template"`
+	Method      string `json:"method"`
+	HttpStats
+	FailureReasons []HttpFailureReason `json:"failure_reasons"`
+}
+
+// HttpBreakdown represents the stats of http
+// calls for a single value of an attribute,
+// like a network type.
+type HttpBreakdown struct {
+	Key string `json:"key"`
+	HttpStats
+}
+
+// HttpBreakdowns represents the stats of http
+// calls broken down by network type, network
+// generation & country.
+type HttpBreakdowns struct {
+	NetworkTypes       []HttpBreakdown `json:"network_types"`
+	NetworkGenerations []HttpBreakdown `json:"network_generations"`
+	Countries          []HttpBreakdown `json:"countries"`
+}
+
+// HttpPlotInstance represents the stats of
+// http calls on a single day.
+type HttpPlotInstance struct {
+	DateTime string `json:"datetime"`
+	HttpStats
+}
+
+// HttpEndpointFilter narrows down http
+// analytics to a single endpoint.
+type HttpEndpointFilter struct {
+	URLTemplate string `form:"url_template"`
+	Method      string `form:"method"`
+}
+
+// selectHttpStats adds the selects needed to
+// scan http stats to the statement.
+func selectHttpStats(stmt *sqlf.Stmt) {
+	stmt.
+		Select("count() as calls").
+		Select("ifNotFinite(round(quantileIf(0.50)(http.end_time - http.start_time, http.end_time >= http.start_time), 2), 0) as p50").
+		Select("ifNotFinite(round(quantileIf(0.95)(http.end_time - http.start_time, http.end_time >= http.start_time), 2), 0) as p95").
+		Select("countIf(http.status_code >= 100 and http.status_code < 200) as status_1xx").
+		Select("countIf(http.status_code >= 200 and http.status_code < 300) as status_2xx").
+		Select("countIf(http.status_code >= 300 and http.status_code < 400) as status_3xx").
+		Select("countIf(http.status_code >= 400 and http.status_code < 500) as status_4xx").
+		Select("countIf(http.status_code >= 500) as status_5xx").
+		Select("countIf(http.status_code = 0) as failed")
+}
+
+// httpStatsDest returns scan destinations
+// matching the selects of selectHttpStats.
+func httpStatsDest(stats *HttpStats) []any {
+	return []any{
+		&stats.Calls,
+		&stats.P50,
+		&stats.P95,
+		&stats.StatusClasses.Informational,
+		&stats.StatusClasses.Success,
+		&stats.StatusClasses.Redirection,
+		&stats.StatusClasses.ClientError,
+		&stats.StatusClasses.ServerError,
+		&stats.StatusClasses.Failed,
+	}
+}
+
+// computeErrorRates computes error rates
+// from the status class counts.
+func (s *HttpStats) computeErrorRates() {
+	sc := s.StatusClasses
+	s.ErrorRates = HttpErrorRates{
+		Total:       percentage(sc.ClientError+sc.ServerError+sc.Failed, s.Calls),
+		ClientError: percentage(sc.ClientError, s.Calls),
+		ServerError: percentage(sc.ServerError, s.Calls),
+		Failed:      percentage(sc.Failed, s.Calls),
+	}
+}
+
+// newHttpStmt creates a statement on http events
+// respecting all applicable app & endpoint filters.
+func newHttpStmt(af *filter.AppFilter, ef *HttpEndpointFilter) *sqlf.Stmt {
+	stmt := sqlf.
+		From("events").
+		Clause("prewhere app_id = toUUID(?) and type = ?", af.AppID, event.TypeHttp)
+
+	applyEventFilters(stmt, af)
+
+	if ef != nil && ef.URLTemplate != "" {
+		stmt.Where("http.url_template = ?", ef.URLTemplate)
+	}
+
+	if ef != nil && ef.Method != "" {
+		stmt.Where("toStringCutToZero(http.method) = ?", ef.Method)
+	}
+
+	return stmt
+}
+
+// GetHttpEndpoints computes call count, latency quantiles,
+// error rates & top failure reasons of http calls for each
+// endpoint while respecting all applicable app filters.
+// Only the endpoints having the most calls are kept.
+func (a App) GetHttpEndpoints(ctx context.Context, af *filter.AppFilter) (endpoints []HttpEndpoint, err error) {
+	endpoints = []HttpEndpoint{}
+
+	stmt := newHttpStmt(af, nil).
+		Select("http.url_template as url_template").
+		Select("toStringCutToZero(http.method) as method")
+
+	selectHttpStats(stmt)
+
+	stmt.
+		GroupBy("url_template, method").
+		OrderBy("calls desc, url_template, method").
+		Limit(maxHttpEndpoints)
+
+	defer stmt.Close()
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		var endpoint HttpEndpoint
+		dest := append([]any{&endpoint.URLTemplate, &endpoint.Method}, httpStatsDest(&endpoint.HttpStats)...)
+		if err = rows.Scan(dest...); err != nil {
+			return
+		}
+
+		endpoint.computeErrorRates()
+		endpoint.FailureReasons = []HttpFailureReason{}
+		endpoints = append(endpoints, endpoint)
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	reasonsStmt := newHttpStmt(af, nil).
+		Select("http.url_template as url_template").
+		Select("toStringCutToZero(http.method) as method").
+		Select("http.failure_reason as reason").
+		Select("count() as count").
+		Where("http.failure_reason != ''").
+		GroupBy("url_template, method, reason").
+		OrderBy("count desc, reason")
+
+	defer reasonsStmt.Close()
+
+	reasonRows, err := server.Server.ChPool.Query(ctx, reasonsStmt.String(), reasonsStmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	var reasons []httpFailureReasonRow
+	for reasonRows.Next() {
+		var row httpFailureReasonRow
+		if err = reasonRows.Scan(&row.urlTemplate, &row.method, &row.reason, &row.count); err != nil {
+			return
+		}
+		reasons = append(reasons, row)
+	}
+
+	if err = reasonRows.Err(); err != nil {
+		return
+	}
+
+	attachHttpFailureReasons(endpoints, reasons)
+
+	return
+}
+
+// httpFailureReasonRow represents a single row
+// of failure reason counts by endpoint.
+type httpFailureReasonRow struct {
+	urlTemplate string
+	method      string
+	reason      string
+	count       uint64
+}
+
+// attachHttpFailureReasons attaches failure reasons to
+// their endpoints. Rows must be ordered by count, only
+// the most frequent reasons of each endpoint are kept.
+func attachHttpFailureReasons(endpoints []HttpEndpoint, rows []httpFailureReasonRow) {
+	lut := make(map[[2]string]int, len(endpoints))
+	for i := range endpoints {
+		lut[[2]string{endpoints[i].URLTemplate, endpoints[i].Method}] = i
+	}
+
+	for _, row := range rows {
+		ndx, ok := lut[[2]string{row.urlTemplate, row.method}]
+		if !ok || len(endpoints[ndx].FailureReasons) >= maxHttpFailureReasons {
+			continue
+		}
+
+		endpoints[ndx].FailureReasons = append(endpoints[ndx].FailureReasons, HttpFailureReason{
+			Reason: row.reason,
+			Count:  row.count,
+		})
+	}
+}
+
+// GetHttpBreakdowns computes stats of http calls by
+// network type, network generation & country while
+// respecting all applicable app & endpoint filters.
+func (a App) GetHttpBreakdowns(ctx context.Context, af *filter.AppFilter, ef *HttpEndpointFilter) (breakdowns *HttpBreakdowns, err error) {
+	breakdowns = &HttpBreakdowns{}
+
+	if breakdowns.NetworkTypes, err = a.getHttpBreakdown(ctx, af, ef, "toString(attribute.network_type)"); err != nil {
+		return
+	}
+
+	if breakdowns.NetworkGenerations, err = a.getHttpBreakdown(ctx, af, ef, "toString(attribute.network_generation)"); err != nil {
+		return
+	}
+
+	if breakdowns.Countries, err = a.getHttpBreakdown(ctx, af, ef, "toStringCutToZero(inet.country_code)"); err != nil {
+		return
+	}
+
+	return
+}
+
+// getHttpBreakdown queries stats of http calls for each
+// value of the key expression. Only the values having
+// the most calls are kept.
+func (a App) getHttpBreakdown(ctx context.Context, af *filter.AppFilter, ef *HttpEndpointFilter, key string) (breakdown []HttpBreakdown, err error) {
+	breakdown = []HttpBreakdown{}
+
+	stmt := newHttpStmt(af, ef).
+		Select(fmt.Sprintf("%s as key", key))
+
+	selectHttpStats(stmt)
+
+	stmt.
+		GroupBy("key").
+		OrderBy("calls desc, key").
+		Limit(maxHttpBreakdowns)
+
+	defer stmt.Close()
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		var b HttpBreakdown
+		if err = rows.Scan(append([]any{&b.Key}, httpStatsDest(&b.HttpStats)...)...); err != nil {
+			return
+		}
+
+		b.computeErrorRates()
+		breakdown = append(breakdown, b)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// GetHttpPlot computes daily stats of http calls
+// while respecting all applicable app & endpoint
+// filters.
+func (a App) GetHttpPlot(ctx context.Context, af *filter.AppFilter, ef *HttpEndpointFilter) (instances []HttpPlotInstance, err error) {
+	instances = []HttpPlotInstance{}
+
+	stmt := newHttpStmt(af, ef).
+		Select("formatDateTime(timestamp, '%Y-%m-%d', ?) as datetime", af.Timezone)
+
+	selectHttpStats(stmt)
+
+	stmt.
+		GroupBy("datetime").
+		OrderBy("datetime")
+
+	defer stmt.Close()
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		var instance HttpPlotInstance
+		if err = rows.Scan(append([]any{&instance.DateTime}, httpStatsDest(&instance.HttpStats)...)...); err != nil {
+			return
+		}
+
+		instance.computeErrorRates()
+		instances = append(instances, instance)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+func GetHttpEndpoints(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse http endpoints request`
+		fmt.Println(msg, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := `http endpoints request validation failed`
+
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	endpoints, err := app.GetHttpEndpoints(ctx, &af)
+	if err != nil {
+		msg := `failed to fetch http endpoints`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, endpoints)
+}
+
+func GetHttpBreakdowns(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	var ef HttpEndpointFilter
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse http breakdowns request`
+		fmt.Println(msg, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := c.ShouldBindQuery(&ef); err != nil {
+		msg := `failed to parse http breakdowns request`
+		fmt.Println(msg, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := `http breakdowns request validation failed`
+
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	breakdowns, err := app.GetHttpBreakdowns(ctx, &af, &ef)
+	if err != nil {
+		msg := `failed to fetch http breakdowns`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, breakdowns)
+}
+
+func GetHttpPlot(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	var ef HttpEndpointFilter
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse http plot request`
+		fmt.Println(msg, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := c.ShouldBindQuery(&ef); err != nil {
+		msg := `failed to parse http plot request`
+		fmt.Println(msg, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := `http plot request validation failed`
+
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	instances, err := app.GetHttpPlot(ctx, &af, &ef)
+	if err != nil {
+		msg := `failed to fetch http plot`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, instances)
+}
@@ -0,0 +1,58 @@
+package measure
+
+import (
+	"testing"
+)
+
+func TestHttpStatsComputeErrorRates(t *testing.T) {
+	stats := HttpStats{
+		Calls: 200,
+		StatusClasses: HttpStatusClasses{
+			Success:     170,
+			ClientError: 16,
+			ServerError: 10,
+			Failed:      4,
+		},
+	}
+
+	stats.computeErrorRates()
+
+	expected := HttpErrorRates{
+		Total:       15,
+		ClientError: 8,
+		ServerError: 5,
+		Failed:      2,
+	}
+
+	if stats.ErrorRates != expected {
+		t.Errorf("Expected %+v, but got %+v", expected, stats.ErrorRates)
+	}
+}
+
+func TestAttachHttpFailureReasons(t *testing.T) {
+	endpoints := []HttpEndpoint{
+		{URLTemplate: "api.example.com/users/{id}", Method: "get", FailureReasons: []HttpFailureReason{}},
+		{URLTemplate: "api.example.com/users/{id}", Method: "post", FailureReasons: []HttpFailureReason{}},
+	}
+
+	rows := []httpFailureReasonRow{
+		{urlTemplate: "api.example.com/users/{id}", method: "post", reason: "java.net.SocketTimeoutException", count: 9},
+		{urlTemplate: "api.example.com/feed", method: "get", reason: "java.net.UnknownHostException", count: 7},
+		{urlTemplate: "api.example.com/users/{id}", method: "post", reason: "java.net.UnknownHostException", count: 3},
+	}
+
+	for i := 0; i < maxHttpFailureReasons; i++ {
+		rows = append(rows, httpFailureReasonRow{urlTemplate: "api.example.com/users/{id}", method: "get", reason: "java.io.IOException", count: 1})
+	}
+	rows = append(rows, httpFailureReasonRow{urlTemplate: "api.example.com/users/{id}", method: "get", reason: "javax.net.ssl.SSLException", count: 1})
+
+	attachHttpFailureReasons(endpoints, rows)
+
+	if len(endpoints[0].FailureReasons) != maxHttpFailureReasons {
+		t.Errorf("Expected %d failure reasons, but got %d", maxHttpFailureReasons, len(endpoints[0].FailureReasons))
+	}
+
+	if len(endpoints[1].FailureReasons) != 2 || endpoints[1].FailureReasons[0].Reason != "java.net.SocketTimeoutException" {
+		t.Errorf("Expected failure reasons ordered by count, but got %+v", endpoints[1].FailureReasons)
+	}
+}
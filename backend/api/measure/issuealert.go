@@ -0,0 +1,508 @@
+package measure
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+
+	"backend/api/email"
+	"backend/api/event"
+	"backend/api/notify"
+	"backend/api/server"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+const (
+	issueAlertPending = "pending"
+	issueAlertFired   = "fired"
+	issueAlertExpired = "expired"
+)
+
+// regressionQuietPeriod is the minimum duration an
+// issue must not occur for before occurring again
+// counts as a regression.
+const regressionQuietPeriod = 14 * 24 * time.Hour
+
+// issueAlertTTL is the duration after which pending
+// issue alerts that never met the app's alerting
+// thresholds expire.
+const issueAlertTTL = 30 * 24 * time.Hour
+
+// issueAlertEvaluationInterval is the minimum duration
+// between evaluations of the same pending issue alert.
+const issueAlertEvaluationInterval = time.Minute
+
+// issueAlertBatchSize is the maximum count of pending
+// issue alerts evaluated at once.
+const issueAlertBatchSize = 50
+
+// issueAlertEmailColumns maps issue alert types
+// to their email preference columns.
+var issueAlertEmailColumns = map[string]string{
+	AlertTypeNewIssue:   "new_issue_email",
+	AlertTypeRegression: "regression_email",
+}
+
+// IssueAlert represents an alert about an exception
+// or ANR group that is new or has regressed.
+type IssueAlert struct {
+	ID          uuid.UUID `db:"id"`
+	AppID       uuid.UUID `db:"app_id"`
+	AlertType   string    `db:"alert_type"`
+	GroupType   string    `db:"group_type"`
+	GroupID     uuid.UUID `db:"group_id"`
+	Fingerprint string    `db:"fingerprint"`
+	Title       string    `db:"title"`
+	Frame       string    `db:"frame"`
+	AppVersion  string    `db:"app_version"`
+	AppBuild    string    `db:"app_build"`
+	SeenAt      time.Time `db:"seen_at"`
+}
+
+// IssueStats contains the occurrences of an
+// issue used to evaluate alerting thresholds.
+type IssueStats struct {
+	Events uint64
+	Users  uint64
+	// LatestVersionEvents is the count of events
+	// on the latest version of the app.
+	LatestVersionEvents uint64
+}
+
+// newIssueAlert creates an issue alert for the
+// group from the event the issue was seen in.
+func newIssueAlert(alertType, groupType string, groupID uuid.UUID, ev *event.EventField) (alert IssueAlert) {
+	alert = IssueAlert{
+		ID:         uuid.New(),
+		AppID:      ev.AppID,
+		AlertType:  alertType,
+		GroupType:  groupType,
+		GroupID:    groupID,
+		AppVersion: ev.Attribute.AppVersion,
+		AppBuild:   ev.Attribute.AppBuild,
+		SeenAt:     ev.Timestamp,
+	}
+
+	switch groupType {
+	case event.TypeException:
+		alert.Fingerprint = ev.Exception.Fingerprint
+		alert.Title = ev.Exception.GetTitle()
+		alert.Frame = ev.Exception.GetTopInAppFrame()
+	case event.TypeANR:
+		alert.Fingerprint = ev.ANR.Fingerprint
+		alert.Title = ev.ANR.GetTitle()
+		alert.Frame = ev.ANR.GetTopInAppFrame()
+	}
+
+	return
+}
+
+// isRegression returns true if the event occurred after
+// the issue did not occur for the quiet period. lastSeen
+// is the timestamp of the latest event of the issue.
+func isRegression(lastSeen time.Time, ev *event.EventField) bool {
+	return ev.Timestamp.Sub(lastSeen) >= regressionQuietPeriod
+}
+
+// meetsThresholds returns true if the issue's
+// occurrences meet the app's new issue alerting
+// thresholds.
+func (s IssueStats) meetsThresholds(settings *AppSettings) bool {
+	if settings.NewIssueLatestVersionOnly && s.LatestVersionEvents == 0 {
+		return false
+	}
+
+	return s.Events >= uint64(settings.NewIssueMinEvents) && s.Users >= uint64(settings.NewIssueMinUsers)
+}
+
+// message describes the issue alert.
+func (ia IssueAlert) message(appName string) string {
+	kind := "crash"
+	if ia.GroupType == event.TypeANR {
+		kind = "ANR"
+	}
+
+	if ia.AlertType == AlertTypeRegression {
+		return fmt.Sprintf("Regressed %s in %s", kind, appName)
+	}
+
+	return fmt.Sprintf("New %s in %s", kind, appName)
+}
+
+// path returns the path of the issue's dashboard
+// page relative to the team.
+func (ia IssueAlert) path() string {
+	section := "crashes"
+	if ia.GroupType == event.TypeANR {
+		section = "anrs"
+	}
+
+	return fmt.Sprintf("%s/%s/%s/%s", section, ia.AppID, ia.GroupID, url.PathEscape(ia.Title))
+}
+
+// payload returns the data the issue alert's
+// emails & notifications are rendered from.
+func (ia IssueAlert) payload(teamID uuid.UUID, appName string) email.IssuePayload {
+	version := ia.AppVersion
+	if ia.AppBuild != "" {
+		version = fmt.Sprintf("%s (%s)", ia.AppVersion, ia.AppBuild)
+	}
+
+	return email.IssuePayload{
+		TeamID:    teamID,
+		AppID:     ia.AppID,
+		AppName:   appName,
+		Type:      ia.AlertType,
+		Message:   ia.message(appName),
+		GroupType: ia.GroupType,
+		GroupID:   ia.GroupID,
+		Title:     ia.Title,
+		Frame:     ia.Frame,
+		Version:   version,
+		SeenAt:    ia.SeenAt,
+		Path:      ia.path(),
+	}
+}
+
+// insert records the issue alert as pending. Alerts
+// for groups that already have a pending alert of
+// the same type are skipped.
+func (ia IssueAlert) insert(ctx context.Context, tx *pgx.Tx) (err error) {
+	stmt := sqlf.PostgreSQL.InsertInto("public.issue_alerts").
+		Set("id", ia.ID).
+		Set("app_id", ia.AppID).
+		Set("alert_type", ia.AlertType).
+		Set("group_type", ia.GroupType).
+		Set("group_id", ia.GroupID).
+		Set("fingerprint", ia.Fingerprint).
+		Set("title", ia.Title).
+		Set("frame", ia.Frame).
+		Set("app_version", ia.AppVersion).
+		Set("app_build", ia.AppBuild).
+		Set("seen_at", ia.SeenAt).
+		Set("status", issueAlertPending).
+		Clause("on conflict (group_id, alert_type) where status = ? do nothing", issueAlertPending)
+
+	defer stmt.Close()
+
+	_, err = (*tx).Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// RunIssueAlerts evaluates pending issue alerts
+// every interval until the context is done.
+func RunIssueAlerts(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := evaluateIssueAlerts(ctx); err != nil {
+				fmt.Println("failed to evaluate issue alerts", err)
+			}
+		}
+	}
+}
+
+// evaluateIssueAlerts fires pending issue alerts that
+// meet their app's alerting thresholds. Failing alerts
+// don't stop evaluation of the rest.
+func evaluateIssueAlerts(ctx context.Context) (err error) {
+	if err = expireIssueAlerts(ctx); err != nil {
+		return
+	}
+
+	alerts, err := claimIssueAlerts(ctx)
+	if err != nil {
+		return
+	}
+
+	apps := make(map[uuid.UUID]*App)
+	settings := make(map[uuid.UUID]*AppSettings)
+
+	for _, alert := range alerts {
+		app, found := apps[alert.AppID]
+		if !found {
+			app = &App{
+				ID: &alert.AppID,
+			}
+			if err := app.Populate(ctx); err != nil {
+				fmt.Printf("failed to fetch app %s of issue alert %s: %v\n", alert.AppID, alert.ID, err)
+				continue
+			}
+			apps[alert.AppID] = app
+		}
+
+		appSettings, found := settings[alert.AppID]
+		if !found {
+			fetched, err := getAppSettings(alert.AppID)
+			if err != nil {
+				fmt.Printf("failed to fetch settings of app %s: %v\n", alert.AppID, err)
+				continue
+			}
+			appSettings = fetched
+			settings[alert.AppID] = appSettings
+		}
+
+		if alert.AlertType == AlertTypeNewIssue && appSettings.needsIssueStats() {
+			stats, err := getIssueStats(ctx, alert, appSettings.NewIssueLatestVersionOnly)
+			if err != nil {
+				fmt.Printf("failed to compute stats of issue alert %s: %v\n", alert.ID, err)
+				continue
+			}
+
+			if !stats.meetsThresholds(appSettings) {
+				continue
+			}
+		}
+
+		if err := alert.fire(ctx, app); err != nil {
+			fmt.Printf("failed to fire issue alert %s: %v\n", alert.ID, err)
+		}
+	}
+
+	return
+}
+
+// expireIssueAlerts expires pending issue
+// alerts older than the ttl.
+func expireIssueAlerts(ctx context.Context) (err error) {
+	stmt := sqlf.PostgreSQL.Update("public.issue_alerts").
+		Set("status", issueAlertExpired).
+		Where("status = ?", issueAlertPending).
+		Where("created_at < ?", time.Now().Add(-issueAlertTTL))
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// claimIssueAlerts marks pending issue alerts due
+// for evaluation as evaluated & returns them. Alerts
+// locked by other evaluations are skipped.
+func claimIssueAlerts(ctx context.Context) (alerts []IssueAlert, err error) {
+	now := time.Now()
+
+	stmt := sqlf.PostgreSQL.Update("public.issue_alerts").
+		Set("evaluated_at", now).
+		Where("id in (select id from public.issue_alerts where status = ? and (evaluated_at is null or evaluated_at < ?) order by created_at limit ? for update skip locked)", issueAlertPending, now.Add(-issueAlertEvaluationInterval), issueAlertBatchSize).
+		Returning("id").
+		Returning("app_id").
+		Returning("alert_type").
+		Returning("group_type").
+		Returning("group_id").
+		Returning("fingerprint").
+		Returning("title").
+		Returning("frame").
+		Returning("app_version").
+		Returning("app_build").
+		Returning("seen_at")
+
+	defer stmt.Close()
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	return pgx.CollectRows(rows, pgx.RowToStructByName[IssueAlert])
+}
+
+// issueGroupTables maps issue group types
+// to their group tables.
+var issueGroupTables = map[string]string{
+	event.TypeException: "public.unhandled_exception_groups",
+	event.TypeANR:       "public.anr_groups",
+}
+
+// issueFingerprintsStmt builds the statement fetching
+// the fingerprint of the group of the alert along with
+// fingerprints of all groups merged into it.
+func issueFingerprintsStmt(alert IssueAlert) *sqlf.Stmt {
+	return sqlf.PostgreSQL.From(issueGroupTables[alert.GroupType]).
+		Select("fingerprint").
+		Where("app_id = ?", alert.AppID).
+		Where("(id = ? or merged_into = ?)", alert.GroupID, alert.GroupID)
+}
+
+// getIssueFingerprints fetches all fingerprints the
+// issue of the alert occurs with. Falls back to the
+// alert's fingerprint if the group no longer exists.
+func getIssueFingerprints(ctx context.Context, alert IssueAlert) (fingerprints []string, err error) {
+	stmt := issueFingerprintsStmt(alert)
+
+	defer stmt.Close()
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	fingerprints, err = pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return
+	}
+
+	if len(fingerprints) == 0 {
+		fingerprints = []string{alert.Fingerprint}
+	}
+
+	return
+}
+
+// issueStatsStmt builds the statement computing the
+// occurrences of the issue of the alert across the
+// issue's fingerprints from pre-aggregated issue
+// metrics.
+func issueStatsStmt(alert IssueAlert, fingerprints []string, latestVersion bool) *sqlf.Stmt {
+	stmt := sqlf.From("default.issue_metrics").
+		Select("uniqMerge(instances) as events").
+		Select("uniqMerge(users) as users")
+
+	if latestVersion {
+		stmt.Select("uniqMergeIf(instances, (tupleElement(app_version, 1), tupleElement(app_version, 2)) in (select tupleElement(app_version, 1), tupleElement(app_version, 2) from default.app_filters where app_id = toUUID(?) order by toUInt64OrZero(toString(tupleElement(app_version, 2))) desc limit 1)) as latest_version_events", alert.AppID)
+	} else {
+		stmt.Select("toUInt64(0) as latest_version_events")
+	}
+
+	// ANRs are never handled
+	return stmt.Clause("prewhere app_id = toUUID(?) and type = ? and handled = false and fingerprint in ?", alert.AppID, alert.GroupType, fingerprints)
+}
+
+// getIssueStats computes the occurrences of the
+// issue of the alert.
+func getIssueStats(ctx context.Context, alert IssueAlert, latestVersion bool) (stats IssueStats, err error) {
+	fingerprints, err := getIssueFingerprints(ctx, alert)
+	if err != nil {
+		return
+	}
+
+	stmt := issueStatsStmt(alert, fingerprints, latestVersion)
+
+	defer stmt.Close()
+
+	err = server.Server.ChPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&stats.Events, &stats.Users, &stats.LatestVersionEvents)
+
+	return
+}
+
+// fire marks the issue alert as fired & queues its
+// emails & notifications in the same transaction, so
+// that the alert stays pending & is fired again on
+// the next evaluation if any of it fails.
+func (ia IssueAlert) fire(ctx context.Context, app *App) (err error) {
+	now := time.Now()
+
+	// muted alerts are marked as fired,
+	// but no one is notified
+	muted, err := isAlertMuted(ctx, ia.AppID, ia.AlertType, nil, now)
+	if err != nil {
+		return
+	}
+
+	var recipients []alertRecipient
+	if !muted {
+		recipients, err = getAlertRecipients(ctx, ia.AppID, issueAlertEmailColumns[ia.AlertType])
+		if err != nil {
+			return
+		}
+	}
+
+	tx, err := server.Server.PgPool.Begin(ctx)
+	if err != nil {
+		return
+	}
+
+	defer tx.Rollback(ctx)
+
+	stmt := sqlf.PostgreSQL.Update("public.issue_alerts").
+		Set("status", issueAlertFired).
+		Set("fired_at", now).
+		Where("id = ?", ia.ID).
+		Where("status = ?", issueAlertPending)
+
+	defer stmt.Close()
+
+	result, err := tx.Exec(ctx, stmt.String(), stmt.Args()...)
+	if err != nil || result.RowsAffected() == 0 {
+		return
+	}
+
+	if !muted {
+		payload := ia.payload(app.TeamId, app.AppName)
+
+		var emails []email.Email
+		for _, r := range recipients {
+			emails = append(emails, email.Email{
+				Kind:      email.KindIssue,
+				Recipient: r.email,
+				UserID:    &r.userId,
+				AppID:     &ia.AppID,
+				Payload:   payload,
+			})
+		}
+
+		if err = email.EnqueueTx(ctx, &tx, emails...); err != nil {
+			return
+		}
+
+		if err = notify.EnqueueTx(ctx, &tx, ia.AppID, ia.AlertType, payload); err != nil {
+			return
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// alertRecipient is a team member who
+// receives emails of an alert.
+type alertRecipient struct {
+	userId uuid.UUID
+	email  string
+}
+
+// getAlertRecipients fetches the members of the app's
+// team whose alert preference column is turned on.
+// Members who never saved their preferences receive
+// emails of all alerts.
+func getAlertRecipients(ctx context.Context, appId uuid.UUID, column string) (recipients []alertRecipient, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.apps").
+		Select("users.id").
+		Select("users.email").
+		Join("public.team_membership", "team_membership.team_id = apps.team_id").
+		Join("public.users", "users.id = team_membership.user_id").
+		LeftJoin("public.alert_prefs", "alert_prefs.app_id = apps.id and alert_prefs.user_id = users.id").
+		Where("apps.id = ?", appId).
+		Where(fmt.Sprintf("coalesce(alert_prefs.%s, true)", column))
+
+	defer stmt.Close()
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r alertRecipient
+		if err = rows.Scan(&r.userId, &r.email); err != nil {
+			return
+		}
+		recipients = append(recipients, r)
+	}
+
+	err = rows.Err()
+
+	return
+}
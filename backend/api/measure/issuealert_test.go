@@ -0,0 +1,213 @@
+package measure
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/api/event"
+
+	"github.com/google/uuid"
+)
+
+func newExceptionEvent(timestamp time.Time) *event.EventField {
+	return &event.EventField{
+		ID:        uuid.New(),
+		AppID:     uuid.New(),
+		Type:      event.TypeException,
+		Timestamp: timestamp,
+		Attribute: event.Attribute{
+			AppVersion: "1.2.0",
+			AppBuild:   "120",
+		},
+		Exception: &event.Exception{
+			Fingerprint: "d41d8cd98f00b204e9800998ecf8427e",
+			Exceptions: event.ExceptionUnits{
+				{
+					Type:    "java.lang.NullPointerException",
+					Message: "cart is null",
+					Frames: event.Frames{
+						{ClassName: "java.util.Objects", MethodName: "requireNonNull", FileName: "Objects.java", LineNum: 203},
+						{ClassName: "com.shop.CheckoutActivity", MethodName: "onCreate", FileName: "CheckoutActivity.kt", LineNum: 42},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestNewIssueAlert(t *testing.T) {
+	seenAt := time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC)
+	ev := newExceptionEvent(seenAt)
+	groupID := uuid.New()
+
+	alert := newIssueAlert(AlertTypeNewIssue, event.TypeException, groupID, ev)
+
+	if alert.AppID != ev.AppID || alert.GroupID != groupID {
+		t.Errorf("Expected app %v & group %v, but got %v & %v", ev.AppID, groupID, alert.AppID, alert.GroupID)
+	}
+
+	if alert.Title != "java.lang.NullPointerException: cart is null" {
+		t.Errorf("Unexpected title %q", alert.Title)
+	}
+
+	if alert.Frame != "com.shop.CheckoutActivity.onCreate(CheckoutActivity.kt:42)" {
+		t.Errorf("Unexpected frame %q", alert.Frame)
+	}
+
+	if alert.Fingerprint != ev.Exception.Fingerprint || !alert.SeenAt.Equal(seenAt) {
+		t.Errorf("Unexpected fingerprint %q or seen at %v", alert.Fingerprint, alert.SeenAt)
+	}
+
+	teamID := uuid.New()
+	payload := alert.payload(teamID, "Shop")
+
+	if payload.Message != "New crash in Shop" {
+		t.Errorf("Unexpected message %q", payload.Message)
+	}
+
+	if payload.Version != "1.2.0 (120)" {
+		t.Errorf("Unexpected version %q", payload.Version)
+	}
+
+	expectedPath := "crashes/" + ev.AppID.String() + "/" + groupID.String() + "/java.lang.NullPointerException:%20cart%20is%20null"
+	if payload.Path != expectedPath {
+		t.Errorf("Expected path %q, but got %q", expectedPath, payload.Path)
+	}
+}
+
+func TestIssueAlertMessage(t *testing.T) {
+	alert := IssueAlert{
+		AlertType: AlertTypeRegression,
+		GroupType: event.TypeANR,
+	}
+
+	if message := alert.message("Shop"); message != "Regressed ANR in Shop" {
+		t.Errorf("Unexpected message %q", message)
+	}
+
+	if path := alert.path(); path[:5] != "anrs/" {
+		t.Errorf("Expected anrs path, but got %q", path)
+	}
+}
+
+func TestIsRegression(t *testing.T) {
+	lastSeen := time.Date(2024, 12, 1, 0, 0, 0, 0, time.UTC)
+
+	if isRegression(lastSeen, newExceptionEvent(lastSeen.Add(24*time.Hour))) {
+		t.Errorf("Expected no regression within the quiet period")
+	}
+
+	if !isRegression(lastSeen, newExceptionEvent(lastSeen.Add(regressionQuietPeriod))) {
+		t.Errorf("Expected regression after the quiet period")
+	}
+}
+
+func TestIssueStatsMeetsThresholds(t *testing.T) {
+	settings := newAppSettings(uuid.New())
+
+	if settings.needsIssueStats() {
+		t.Errorf("Expected default settings to not need issue stats")
+	}
+
+	if !(IssueStats{}).meetsThresholds(settings) {
+		t.Errorf("Expected default settings to alert right away")
+	}
+
+	settings.NewIssueMinEvents = 10
+	settings.NewIssueMinUsers = 3
+
+	if (IssueStats{Events: 10, Users: 2}).meetsThresholds(settings) {
+		t.Errorf("Expected min users threshold to hold off alert")
+	}
+
+	if (IssueStats{Events: 9, Users: 3}).meetsThresholds(settings) {
+		t.Errorf("Expected min events threshold to hold off alert")
+	}
+
+	if !(IssueStats{Events: 10, Users: 3}).meetsThresholds(settings) {
+		t.Errorf("Expected alert once thresholds are met")
+	}
+
+	settings.NewIssueLatestVersionOnly = true
+
+	if (IssueStats{Events: 10, Users: 3}).meetsThresholds(settings) {
+		t.Errorf("Expected latest version threshold to hold off alert")
+	}
+
+	if !(IssueStats{Events: 10, Users: 3, LatestVersionEvents: 1}).meetsThresholds(settings) {
+		t.Errorf("Expected alert once issue occurs on latest version")
+	}
+}
+
+func TestIssueStatsStmt(t *testing.T) {
+	alert := IssueAlert{
+		AppID:       uuid.New(),
+		GroupType:   event.TypeANR,
+		Fingerprint: "d41d8cd98f00b204e9800998ecf8427e",
+	}
+
+	fingerprints := []string{alert.Fingerprint, "9e107d9d372bb6826bd81d3542a419d6"}
+
+	stmt := issueStatsStmt(alert, fingerprints, false)
+	defer stmt.Close()
+
+	query := stmt.String()
+
+	for _, expected := range []string{"FROM default.issue_metrics", "uniqMerge(instances) as events", "uniqMerge(users) as users", "toUInt64(0) as latest_version_events", "prewhere app_id = toUUID(?) and type = ? and handled = false and fingerprint in ?"} {
+		if !strings.Contains(query, expected) {
+			t.Errorf("Expected query to contain %q, but got %s", expected, query)
+		}
+	}
+
+	if strings.Contains(query, "default.events") {
+		t.Errorf("Expected stats to be computed from issue metrics, but got %s", query)
+	}
+
+	args := stmt.Args()
+	if len(args) != 3 || args[0] != alert.AppID || args[1] != event.TypeANR || !reflect.DeepEqual(args[2], fingerprints) {
+		t.Errorf("Unexpected args %v", args)
+	}
+
+	latest := issueStatsStmt(alert, fingerprints, true)
+	defer latest.Close()
+
+	if !strings.Contains(latest.String(), "uniqMergeIf(instances, ") {
+		t.Errorf("Expected latest version events to be counted, but got %s", latest.String())
+	}
+
+	// app id of the latest version lookup precedes the prewhere args
+	if args := latest.Args(); len(args) != 4 || args[0] != alert.AppID || !reflect.DeepEqual(args[3], fingerprints) {
+		t.Errorf("Unexpected args %v", args)
+	}
+}
+
+func TestIssueFingerprintsStmt(t *testing.T) {
+	alert := IssueAlert{
+		AppID:     uuid.New(),
+		GroupType: event.TypeException,
+		GroupID:   uuid.New(),
+	}
+
+	stmt := issueFingerprintsStmt(alert)
+	defer stmt.Close()
+
+	expected := "SELECT fingerprint FROM public.unhandled_exception_groups WHERE app_id = $1 AND (id = $2 or merged_into = $3)"
+	if stmt.String() != expected {
+		t.Errorf("Expected %q, but got %q", expected, stmt.String())
+	}
+
+	if args := stmt.Args(); len(args) != 3 || args[0] != alert.AppID || args[1] != alert.GroupID || args[2] != alert.GroupID {
+		t.Errorf("Unexpected args %v", args)
+	}
+
+	alert.GroupType = event.TypeANR
+
+	anr := issueFingerprintsStmt(alert)
+	defer anr.Close()
+
+	if !strings.Contains(anr.String(), "FROM public.anr_groups") {
+		t.Errorf("Expected ANR groups to be queried, but got %s", anr.String())
+	}
+}
@@ -0,0 +1,329 @@
+package measure
+
+import (
+	"backend/api/event"
+	"backend/api/filter"
+	"backend/api/metrics"
+	"backend/api/server"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+// maxLaunchBreakdowns is the maximum count of
+// breakdown values reported in a launch plot.
+const maxLaunchBreakdowns = 10
+
+// launchTypes maps the launch type query
+// parameter to the launch event type.
+var launchTypes = map[string]string{
+	"cold": event.TypeColdLaunch,
+	"warm": event.TypeWarmLaunch,
+	"hot":  event.TypeHotLaunch,
+}
+
+// launchBreakdowns maps the launch breakdown
+// query parameter to the key expression on
+// launch metrics.
+var launchBreakdowns = map[string]string{
+	"version":           "concat(tupleElement(app_version, 1), ' (', tupleElement(app_version, 2), ')')",
+	"launched_activity": "launched_activity",
+	"device":            "concat(device_manufacturer, ' ', device_name)",
+	"os_version":        "concat(tupleElement(os_version, 1), ' ', tupleElement(os_version, 2))",
+	"lukewarm":          "if(lukewarm, 'lukewarm', 'warm')",
+}
+
+// LaunchPlotInstance represents launch duration
+// quantiles for a single breakdown value on a
+// single day.
+type LaunchPlotInstance struct {
+	Key      string  `json:"-"`
+	DateTime string  `json:"datetime"`
+	Launches uint64  `json:"launches"`
+	P50      float64 `json:"p50"`
+	P75      float64 `json:"p75"`
+	P90      float64 `json:"p90"`
+	P95      float64 `json:"p95"`
+	P99      float64 `json:"p99"`
+}
+
+// LaunchPlot represents the daily time series
+// of launch duration quantiles for a single
+// breakdown value.
+type LaunchPlot struct {
+	ID   string               `json:"id"`
+	Data []LaunchPlotInstance `json:"data"`
+}
+
+// GetLaunchDistribution computes quantiles and the
+// histogram of launch durations of a launch type
+// while respecting all applicable app filters.
+func (a App) GetLaunchDistribution(ctx context.Context, af *filter.AppFilter, launchType string) (distribution *metrics.LaunchDistribution, err error) {
+	distribution = &metrics.LaunchDistribution{}
+
+	stmt := sqlf.From("launch_metrics").
+		Select("sum(launches) as launches").
+		Select("round(quantileMerge(0.50)(p50), 2) as p50").
+		Select("round(quantileMerge(0.75)(p75), 2) as p75").
+		Select("round(quantileMerge(0.90)(p90), 2) as p90").
+		Select("round(quantileMerge(0.95)(p95), 2) as p95").
+		Select("round(quantileMerge(0.99)(p99), 2) as p99").
+		Select("tupleElement(sumMapMerge(histogram), 1) as bounds").
+		Select("tupleElement(sumMapMerge(histogram), 2) as counts").
+		Clause("prewhere app_id = toUUID(?) and type = ? and timestamp >= ? and timestamp <= ?", af.AppID, launchType, af.From, af.To)
+
+	defer stmt.Close()
+
+	applyIssueMetricsFilters(stmt, af)
+
+	var bounds []uint32
+	var counts []uint64
+
+	if err = server.Server.ChPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(
+		&distribution.Launches,
+		&distribution.P50,
+		&distribution.P75,
+		&distribution.P90,
+		&distribution.P95,
+		&distribution.P99,
+		&bounds,
+		&counts,
+	); err != nil {
+		return
+	}
+
+	distribution.Histogram = metrics.NewLaunchHistogram(bounds, counts)
+	distribution.SetNaNs()
+
+	return
+}
+
+// GetLaunchPlot computes daily launch duration quantiles
+// of a launch type for each value of the breakdown while
+// respecting all applicable app filters. Only the values
+// having the most launches are kept.
+func (a App) GetLaunchPlot(ctx context.Context, af *filter.AppFilter, launchType, breakdown string) (plots []LaunchPlot, err error) {
+	plots = []LaunchPlot{}
+
+	stmt := sqlf.From("launch_metrics").
+		Select(fmt.Sprintf("%s as key", launchBreakdowns[breakdown])).
+		Select("formatDateTime(timestamp, '%Y-%m-%d', ?) as datetime", af.Timezone).
+		Select("sum(launches) as launches").
+		Select("round(quantileMerge(0.50)(p50), 2) as p50").
+		Select("round(quantileMerge(0.75)(p75), 2) as p75").
+		Select("round(quantileMerge(0.90)(p90), 2) as p90").
+		Select("round(quantileMerge(0.95)(p95), 2) as p95").
+		Select("round(quantileMerge(0.99)(p99), 2) as p99").
+		Clause("prewhere app_id = toUUID(?) and type = ? and timestamp >= ? and timestamp <= ?", af.AppID, launchType, af.From, af.To).
+		GroupBy("key, datetime").
+		OrderBy("datetime")
+
+	defer stmt.Close()
+
+	applyIssueMetricsFilters(stmt, af)
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	var instances []LaunchPlotInstance
+
+	for rows.Next() {
+		var instance LaunchPlotInstance
+		if err = rows.Scan(&instance.Key, &instance.DateTime, &instance.Launches, &instance.P50, &instance.P75, &instance.P90, &instance.P95, &instance.P99); err != nil {
+			return
+		}
+
+		instances = append(instances, instance)
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	plots = computeLaunchPlots(instances, maxLaunchBreakdowns)
+
+	return
+}
+
+// computeLaunchPlots groups plot instances by their
+// breakdown value. Only the limit values having the
+// most launches are kept, ordered by launches.
+func computeLaunchPlots(instances []LaunchPlotInstance, limit int) (plots []LaunchPlot) {
+	plots = []LaunchPlot{}
+	lut := make(map[string]int)
+	totals := make(map[string]uint64)
+
+	for _, instance := range instances {
+		ndx, ok := lut[instance.Key]
+		if !ok {
+			plots = append(plots, LaunchPlot{
+				ID:   instance.Key,
+				Data: []LaunchPlotInstance{},
+			})
+			ndx = len(plots) - 1
+			lut[instance.Key] = ndx
+		}
+
+		plots[ndx].Data = append(plots[ndx].Data, instance)
+		totals[instance.Key] += instance.Launches
+	}
+
+	sort.SliceStable(plots, func(i, j int) bool {
+		return totals[plots[i].ID] > totals[plots[j].ID]
+	})
+
+	if len(plots) > limit {
+		plots = plots[:limit]
+	}
+
+	return
+}
+
+func GetLaunchPlot(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	launchType, ok := launchTypes[c.Query("launch_type")]
+	if !ok {
+		msg := `launch_type must be one of "cold", "warm" or "hot"`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	breakdown := c.DefaultQuery("breakdown", "version")
+	if _, ok := launchBreakdowns[breakdown]; !ok {
+		msg := `breakdown must be one of "version", "launched_activity", "device", "os_version" or "lukewarm"`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	if breakdown == "lukewarm" && launchType != event.TypeWarmLaunch {
+		msg := `lukewarm breakdown is only applicable to warm launches`
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse launch plot request`
+		fmt.Println(msg, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := `launch plot request validation failed`
+
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	plots, err := app.GetLaunchPlot(ctx, &af, launchType, breakdown)
+	if err != nil {
+		msg := `failed to fetch launch plot`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, plots)
+}
@@ -0,0 +1,40 @@
+package measure
+
+import (
+	"testing"
+)
+
+func TestComputeLaunchPlots(t *testing.T) {
+	instances := []LaunchPlotInstance{
+		{Key: "1.0 (1)", DateTime: "2024-12-01", Launches: 4, P95: 900},
+		{Key: "1.1 (2)", DateTime: "2024-12-01", Launches: 3, P95: 700},
+		{Key: "1.2 (3)", DateTime: "2024-12-01", Launches: 1, P95: 600},
+		{Key: "1.1 (2)", DateTime: "2024-12-02", Launches: 5, P95: 650},
+	}
+
+	plots := computeLaunchPlots(instances, 2)
+
+	if len(plots) != 2 {
+		t.Fatalf("Expected %d plots, but got %d", 2, len(plots))
+	}
+
+	if plots[0].ID != "1.1 (2)" || len(plots[0].Data) != 2 {
+		t.Errorf("Expected %q with %d instances first, but got %+v", "1.1 (2)", 2, plots[0])
+	}
+
+	if plots[0].Data[1].DateTime != "2024-12-02" {
+		t.Errorf("Expected instances to stay ordered by datetime, but got %+v", plots[0].Data)
+	}
+
+	if plots[1].ID != "1.0 (1)" {
+		t.Errorf("Expected %q second, but got %q", "1.0 (1)", plots[1].ID)
+	}
+}
+
+func TestComputeLaunchPlotsEmpty(t *testing.T) {
+	plots := computeLaunchPlots(nil, maxLaunchBreakdowns)
+
+	if plots == nil || len(plots) != 0 {
+		t.Errorf("Expected empty plots, but got %v", plots)
+	}
+}
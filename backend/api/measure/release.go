@@ -0,0 +1,559 @@
+package measure
+
+import (
+	"backend/api/event"
+	"backend/api/filter"
+	"backend/api/metrics"
+	"backend/api/pairs"
+	"backend/api/server"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+// ReleaseVersions represents the pair of app
+// versions to compare.
+type ReleaseVersions struct {
+	BaseVersion       string `form:"base_version" json:"base_version" binding:"required"`
+	BaseVersionCode   string `form:"base_version_code" json:"base_version_code" binding:"required"`
+	TargetVersion     string `form:"target_version" json:"target_version" binding:"required"`
+	TargetVersionCode string `form:"target_version_code" json:"target_version_code" binding:"required"`
+}
+
+// ReleaseMetrics represents health metrics
+// of a single app version.
+type ReleaseMetrics struct {
+	Version                    string                      `json:"version"`
+	VersionCode                string                      `json:"version_code"`
+	Sessions                   uint64                      `json:"sessions"`
+	CrashFreeSessions          float64                     `json:"crash_free_sessions"`
+	PerceivedCrashFreeSessions float64                     `json:"perceived_crash_free_sessions"`
+	ANRFreeSessions            float64                     `json:"anr_free_sessions"`
+	PerceivedANRFreeSessions   float64                     `json:"perceived_anr_free_sessions"`
+	Adoption                   *metrics.SessionAdoption    `json:"adoption"`
+	Size                       *metrics.SizeMetric         `json:"size"`
+	ColdLaunch                 *metrics.LaunchDistribution `json:"cold_launch"`
+	WarmLaunch                 *metrics.LaunchDistribution `json:"warm_launch"`
+	HotLaunch                  *metrics.LaunchDistribution `json:"hot_launch"`
+	// NaN is true if the version has
+	// no sessions in the time range.
+	NaN bool `json:"nan"`
+
+	crashSessions          uint64
+	perceivedCrashSessions uint64
+	anrSessions            uint64
+	perceivedANRSessions   uint64
+}
+
+// ReleaseDiff represents the difference of a
+// metric between the base & target versions.
+type ReleaseDiff struct {
+	Metric      string  `json:"metric"`
+	Base        float64 `json:"base"`
+	Target      float64 `json:"target"`
+	Delta       float64 `json:"delta"`
+	PValue      float64 `json:"p_value"`
+	Significant bool    `json:"significant"`
+}
+
+// ReleaseIssueGroup represents a crash or ANR
+// group along with the sessions it affected in
+// the base & target versions.
+type ReleaseIssueGroup struct {
+	ID             uuid.UUID `json:"id"`
+	IssueType      string    `json:"issue_type"`
+	Type           string    `json:"type"`
+	Message        string    `json:"message"`
+	MethodName     string    `json:"method_name"`
+	FileName       string    `json:"file_name"`
+	LineNumber     int       `json:"line_number"`
+	BaseSessions   uint64    `json:"base_sessions"`
+	TargetSessions uint64    `json:"target_sessions"`
+	BaseRate       float64   `json:"base_rate"`
+	TargetRate     float64   `json:"target_rate"`
+	PValue         float64   `json:"p_value"`
+	Significant    bool      `json:"significant"`
+}
+
+// ReleaseComparison represents side by side health
+// metrics of two app versions along with differences
+// and the issue groups that are new, regressed or
+// fixed in the target version.
+type ReleaseComparison struct {
+	Base      ReleaseMetrics      `json:"base"`
+	Target    ReleaseMetrics      `json:"target"`
+	Diffs     []ReleaseDiff       `json:"diffs"`
+	New       []ReleaseIssueGroup `json:"new"`
+	Regressed []ReleaseIssueGroup `json:"regressed"`
+	Fixed     []ReleaseIssueGroup `json:"fixed"`
+}
+
+// GetReleaseComparison compares health metrics & issue
+// groups of the target version against the base version
+// in the selected time range.
+func (a App) GetReleaseComparison(ctx context.Context, af *filter.AppFilter, rv *ReleaseVersions) (comparison *ReleaseComparison, err error) {
+	comparison = &ReleaseComparison{}
+
+	if comparison.Base, err = a.getReleaseMetrics(ctx, af, rv.BaseVersion, rv.BaseVersionCode); err != nil {
+		return
+	}
+
+	if comparison.Target, err = a.getReleaseMetrics(ctx, af, rv.TargetVersion, rv.TargetVersionCode); err != nil {
+		return
+	}
+
+	comparison.Diffs = computeReleaseDiffs(&comparison.Base, &comparison.Target)
+
+	groups, err := a.getReleaseIssueGroups(ctx, af, rv)
+	if err != nil {
+		return
+	}
+
+	comparison.New, comparison.Regressed, comparison.Fixed = classifyReleaseIssueGroups(groups, comparison.Base.Sessions, comparison.Target.Sessions)
+
+	return
+}
+
+// getReleaseMetrics computes health metrics of
+// a single app version.
+func (a App) getReleaseMetrics(ctx context.Context, af *filter.AppFilter, version, code string) (rm ReleaseMetrics, err error) {
+	rm = ReleaseMetrics{
+		Version:     version,
+		VersionCode: code,
+	}
+
+	vf := *af
+	vf.Versions = []string{version}
+	vf.VersionCodes = []string{code}
+
+	stmt := sqlf.From("app_metrics").
+		Select("uniqMerge(unique_sessions) as sessions").
+		Select("uniqMerge(crash_sessions) as crash_sessions").
+		Select("uniqMerge(perceived_crash_sessions) as perceived_crash_sessions").
+		Select("uniqMerge(anr_sessions) as anr_sessions").
+		Select("uniqMerge(perceived_anr_sessions) as perceived_anr_sessions").
+		Where("app_id = toUUID(?)", af.AppID).
+		Where("timestamp >= ? and timestamp <= ?", af.From, af.To).
+		Where("app_version = (?, ?)", version, code)
+
+	defer stmt.Close()
+
+	if err = server.Server.ChPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(
+		&rm.Sessions,
+		&rm.crashSessions,
+		&rm.perceivedCrashSessions,
+		&rm.anrSessions,
+		&rm.perceivedANRSessions,
+	); err != nil {
+		return
+	}
+
+	if rm.Sessions == 0 {
+		rm.NaN = true
+	} else {
+		rm.CrashFreeSessions = metrics.IssueFree(rm.Sessions, rm.crashSessions)
+		rm.PerceivedCrashFreeSessions = metrics.IssueFree(rm.Sessions, rm.perceivedCrashSessions)
+		rm.ANRFreeSessions = metrics.IssueFree(rm.Sessions, rm.anrSessions)
+		rm.PerceivedANRFreeSessions = metrics.IssueFree(rm.Sessions, rm.perceivedANRSessions)
+	}
+
+	if rm.Adoption, err = a.GetAdoptionMetrics(ctx, &vf); err != nil {
+		return
+	}
+
+	excludedVersions, err := vf.GetExcludedVersions(ctx)
+	if err != nil {
+		return
+	}
+
+	if rm.Size, err = a.GetSizeMetrics(ctx, &vf, excludedVersions); err != nil {
+		return
+	}
+
+	if rm.ColdLaunch, err = a.GetLaunchDistribution(ctx, &vf, event.TypeColdLaunch); err != nil {
+		return
+	}
+
+	if rm.WarmLaunch, err = a.GetLaunchDistribution(ctx, &vf, event.TypeWarmLaunch); err != nil {
+		return
+	}
+
+	if rm.HotLaunch, err = a.GetLaunchDistribution(ctx, &vf, event.TypeHotLaunch); err != nil {
+		return
+	}
+
+	return
+}
+
+// computeReleaseDiffs computes differences of issue free
+// sessions & launch times between the base & target
+// versions. Issue free sessions are tested with a two
+// proportion z-test and launch times by comparing their
+// histograms.
+func computeReleaseDiffs(base, target *ReleaseMetrics) (diffs []ReleaseDiff) {
+	proportions := []struct {
+		metric       string
+		base, target float64
+		x1, x2       uint64
+	}{
+		{"crash_free_sessions", base.CrashFreeSessions, target.CrashFreeSessions, base.crashSessions, target.crashSessions},
+		{"perceived_crash_free_sessions", base.PerceivedCrashFreeSessions, target.PerceivedCrashFreeSessions, base.perceivedCrashSessions, target.perceivedCrashSessions},
+		{"anr_free_sessions", base.ANRFreeSessions, target.ANRFreeSessions, base.anrSessions, target.anrSessions},
+		{"perceived_anr_free_sessions", base.PerceivedANRFreeSessions, target.PerceivedANRFreeSessions, base.perceivedANRSessions, target.perceivedANRSessions},
+	}
+
+	for _, p := range proportions {
+		pValue := metrics.ProportionsTest(p.x1, base.Sessions, p.x2, target.Sessions)
+		diffs = append(diffs, ReleaseDiff{
+			Metric:      p.metric,
+			Base:        p.base,
+			Target:      p.target,
+			Delta:       math.Round((p.target-p.base)*100) / 100,
+			PValue:      pValue,
+			Significant: pValue < metrics.SignificanceLevel,
+		})
+	}
+
+	launches := []struct {
+		metric       string
+		base, target *metrics.LaunchDistribution
+	}{
+		{"cold_launch_p95", base.ColdLaunch, target.ColdLaunch},
+		{"warm_launch_p95", base.WarmLaunch, target.WarmLaunch},
+		{"hot_launch_p95", base.HotLaunch, target.HotLaunch},
+	}
+
+	for _, l := range launches {
+		if l.base == nil || l.target == nil {
+			continue
+		}
+
+		pValue := metrics.HistogramsTest(metrics.HistogramCounts(l.base.Histogram), metrics.HistogramCounts(l.target.Histogram))
+		diffs = append(diffs, ReleaseDiff{
+			Metric:      l.metric,
+			Base:        l.base.P95,
+			Target:      l.target.P95,
+			Delta:       math.Round((l.target.P95-l.base.P95)*100) / 100,
+			PValue:      pValue,
+			Significant: pValue < metrics.SignificanceLevel,
+		})
+	}
+
+	return
+}
+
+// releaseIssueFingerprintsStmt builds the statement
+// selecting fingerprints of crashes & ANRs seen in the
+// base or target versions.
+func releaseIssueFingerprintsStmt(af *filter.AppFilter, versions *pairs.Pairs[string, string]) *sqlf.Stmt {
+	return sqlf.From("issue_metrics").
+		Select("toString(type) as type").
+		Select("toString(fingerprint) as fingerprint").
+		Clause("prewhere app_id = toUUID(?) and timestamp >= ? and timestamp <= ?", af.AppID, af.From, af.To).
+		Where("handled = false").
+		Where("app_version in (?)", versions.Parameterize()).
+		GroupBy("type, fingerprint")
+}
+
+// releaseIssueSessionsStmt builds the statement counting
+// unique sessions of each group in the base & target
+// versions. Keys of groups map to the list of type &
+// fingerprint pairs of the group, so sessions affected
+// by more than one fingerprint of a merged group are
+// counted once.
+func releaseIssueSessionsStmt(af *filter.AppFilter, rv *ReleaseVersions, versions *pairs.Pairs[string, string], groups map[string][]string) *sqlf.Stmt {
+	var issues, keys []string
+	for key, groupIssues := range groups {
+		for _, issue := range groupIssues {
+			issues = append(issues, issue)
+			keys = append(keys, key)
+		}
+	}
+
+	return sqlf.From("issue_metrics").
+		Select("transform(concat(toString(type), ':', toString(fingerprint)), ?, ?, '') as group_key", issues, keys).
+		Select("app_version = (?, ?) as is_target", rv.TargetVersion, rv.TargetVersionCode).
+		Select("uniqMerge(sessions) as sessions").
+		Clause("prewhere app_id = toUUID(?) and timestamp >= ? and timestamp <= ?", af.AppID, af.From, af.To).
+		Where("handled = false").
+		Where("app_version in (?)", versions.Parameterize()).
+		Where("concat(toString(type), ':', toString(fingerprint)) in ?", issues).
+		GroupBy("group_key, is_target")
+}
+
+// getReleaseIssueGroups queries sessions affected by each
+// crash & ANR group in the base & target versions. Merged
+// groups are resolved to the group they were merged into.
+func (a App) getReleaseIssueGroups(ctx context.Context, af *filter.AppFilter, rv *ReleaseVersions) (groups []ReleaseIssueGroup, err error) {
+	versions, err := pairs.NewPairs([]string{rv.BaseVersion, rv.TargetVersion}, []string{rv.BaseVersionCode, rv.TargetVersionCode})
+	if err != nil {
+		return
+	}
+
+	stmt := releaseIssueFingerprintsStmt(af, versions)
+
+	defer stmt.Close()
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	fingerprints := make(map[string][]string)
+
+	for rows.Next() {
+		var issueType, fingerprint string
+		if err = rows.Scan(&issueType, &fingerprint); err != nil {
+			return
+		}
+
+		fingerprints[issueType] = append(fingerprints[issueType], fingerprint)
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	lut := make(map[string]int)
+	groupIssues := make(map[string][]string)
+
+	for issueType, fps := range fingerprints {
+		matched, err := a.getAppExitGroups(ctx, issueType, fps)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, g := range matched {
+			key := g.ID.String()
+			if _, ok := lut[key]; !ok {
+				groups = append(groups, ReleaseIssueGroup{
+					ID:         g.ID,
+					IssueType:  issueType,
+					Type:       g.Type,
+					Message:    g.Message,
+					MethodName: g.MethodName,
+					FileName:   g.FileName,
+					LineNumber: g.LineNumber,
+				})
+				lut[key] = len(groups) - 1
+			}
+
+			groupIssues[key] = append(groupIssues[key], issueType+":"+g.Fingerprint)
+		}
+	}
+
+	if len(groupIssues) == 0 {
+		return
+	}
+
+	sessionsStmt := releaseIssueSessionsStmt(af, rv, versions, groupIssues)
+
+	defer sessionsStmt.Close()
+
+	sessionRows, err := server.Server.ChPool.Query(ctx, sessionsStmt.String(), sessionsStmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	for sessionRows.Next() {
+		var key string
+		var isTarget bool
+		var sessions uint64
+		if err = sessionRows.Scan(&key, &isTarget, &sessions); err != nil {
+			return
+		}
+
+		ndx, ok := lut[key]
+		if !ok {
+			continue
+		}
+
+		if isTarget {
+			groups[ndx].TargetSessions = sessions
+		} else {
+			groups[ndx].BaseSessions = sessions
+		}
+	}
+
+	err = sessionRows.Err()
+
+	return
+}
+
+// classifyReleaseIssueGroups classifies issue groups as
+// new when they only affect the target version, fixed
+// when they only affect the base version and regressed
+// when they affect a significantly higher rate of
+// sessions in the target version.
+func classifyReleaseIssueGroups(groups []ReleaseIssueGroup, baseSessions, targetSessions uint64) (newGroups, regressed, fixed []ReleaseIssueGroup) {
+	newGroups = []ReleaseIssueGroup{}
+	regressed = []ReleaseIssueGroup{}
+	fixed = []ReleaseIssueGroup{}
+
+	for _, g := range groups {
+		g.BaseRate = percentage(g.BaseSessions, baseSessions)
+		g.TargetRate = percentage(g.TargetSessions, targetSessions)
+		g.PValue = metrics.ProportionsTest(g.BaseSessions, baseSessions, g.TargetSessions, targetSessions)
+		g.Significant = g.PValue < metrics.SignificanceLevel
+
+		switch {
+		case g.BaseSessions == 0 && g.TargetSessions > 0:
+			newGroups = append(newGroups, g)
+		case g.BaseSessions > 0 && g.TargetSessions == 0:
+			fixed = append(fixed, g)
+		case g.TargetRate > g.BaseRate && g.Significant:
+			regressed = append(regressed, g)
+		}
+	}
+
+	sort.SliceStable(newGroups, func(i, j int) bool {
+		return newGroups[i].TargetSessions > newGroups[j].TargetSessions
+	})
+
+	sort.SliceStable(regressed, func(i, j int) bool {
+		return regressed[i].TargetRate-regressed[i].BaseRate > regressed[j].TargetRate-regressed[j].BaseRate
+	})
+
+	sort.SliceStable(fixed, func(i, j int) bool {
+		return fixed[i].BaseSessions > fixed[j].BaseSessions
+	})
+
+	return
+}
+
+func GetReleaseComparison(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	var rv ReleaseVersions
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse release comparison request`
+		fmt.Println(msg, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := c.ShouldBindQuery(&rv); err != nil {
+		msg := `failed to parse release comparison request`
+		fmt.Println(msg, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := `release comparison request validation failed`
+
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if rv.BaseVersion == rv.TargetVersion && rv.BaseVersionCode == rv.TargetVersionCode {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": "base & target versions must be different",
+		})
+		return
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	comparison, err := app.GetReleaseComparison(ctx, &af, &rv)
+	if err != nil {
+		msg := `failed to compare releases`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, comparison)
+}
@@ -0,0 +1,125 @@
+package measure
+
+import (
+	"backend/api/metrics"
+	"backend/api/pairs"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestClassifyReleaseIssueGroups(t *testing.T) {
+	groups := []ReleaseIssueGroup{
+		{ID: uuid.New(), Type: "new", BaseSessions: 0, TargetSessions: 12},
+		{ID: uuid.New(), Type: "fixed", BaseSessions: 9, TargetSessions: 0},
+		{ID: uuid.New(), Type: "regressed", BaseSessions: 10, TargetSessions: 60},
+		{ID: uuid.New(), Type: "steady", BaseSessions: 10, TargetSessions: 11},
+	}
+
+	newGroups, regressed, fixed := classifyReleaseIssueGroups(groups, 1000, 1000)
+
+	if len(newGroups) != 1 || newGroups[0].Type != "new" {
+		t.Errorf("Expected only %q to be new, but got %+v", "new", newGroups)
+	}
+
+	if len(fixed) != 1 || fixed[0].Type != "fixed" {
+		t.Errorf("Expected only %q to be fixed, but got %+v", "fixed", fixed)
+	}
+
+	if len(regressed) != 1 || regressed[0].Type != "regressed" || !regressed[0].Significant {
+		t.Errorf("Expected only %q to be regressed, but got %+v", "regressed", regressed)
+	}
+
+	if regressed[0].BaseRate != 1 || regressed[0].TargetRate != 6 {
+		t.Errorf("Expected rates of %v & %v, but got %v & %v", 1, 6, regressed[0].BaseRate, regressed[0].TargetRate)
+	}
+}
+
+func TestComputeReleaseDiffs(t *testing.T) {
+	base := ReleaseMetrics{
+		Sessions:          1000,
+		CrashFreeSessions: 99,
+		ANRFreeSessions:   99.5,
+		crashSessions:     10,
+		anrSessions:       5,
+	}
+	target := ReleaseMetrics{
+		Sessions:          1000,
+		CrashFreeSessions: 96,
+		ANRFreeSessions:   99.4,
+		crashSessions:     40,
+		anrSessions:       6,
+		ColdLaunch:        &metrics.LaunchDistribution{},
+	}
+
+	diffs := computeReleaseDiffs(&base, &target)
+
+	lut := make(map[string]ReleaseDiff)
+	for _, diff := range diffs {
+		lut[diff.Metric] = diff
+	}
+
+	if crash := lut["crash_free_sessions"]; crash.Delta != -3 || !crash.Significant {
+		t.Errorf("Expected significant delta of %v, but got %+v", -3, crash)
+	}
+
+	if anr := lut["anr_free_sessions"]; anr.Significant {
+		t.Errorf("Expected insignificant delta, but got %+v", anr)
+	}
+
+	if _, ok := lut["cold_launch_p95"]; ok {
+		t.Errorf("Expected no launch diff when base has no launch distribution")
+	}
+}
+
+func TestReleaseIssueSessionsStmt(t *testing.T) {
+	af := newTestAppFilter()
+	rv := &ReleaseVersions{
+		BaseVersion:       "1.0.0",
+		BaseVersionCode:   "100",
+		TargetVersion:     "1.1.0",
+		TargetVersionCode: "110",
+	}
+
+	versions, err := pairs.NewPairs([]string{rv.BaseVersion, rv.TargetVersion}, []string{rv.BaseVersionCode, rv.TargetVersionCode})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groupID := uuid.New().String()
+	groups := map[string][]string{
+		groupID: {"exception:a1b2", "exception:c3d4"},
+	}
+
+	stmt := releaseIssueSessionsStmt(af, rv, versions, groups)
+	defer stmt.Close()
+
+	sql := stmt.String()
+
+	expected := []string{
+		"transform(concat(toString(type), ':', toString(fingerprint)), ?, ?, '') as group_key",
+		"uniqMerge(sessions) as sessions",
+		"GROUP BY group_key, is_target",
+	}
+
+	for _, want := range expected {
+		if !strings.Contains(sql, want) {
+			t.Errorf("Expected statement to contain %q, got %s", want, sql)
+		}
+	}
+
+	// fingerprints of a merged group must map to the
+	// same key so their sessions are merged before
+	// counting
+	args := stmt.Args()
+	issues, ok := args[0].([]string)
+	if !ok || len(issues) != 2 {
+		t.Fatalf("Expected 2 issues, but got %v", args[0])
+	}
+
+	keys, ok := args[1].([]string)
+	if !ok || len(keys) != 2 || keys[0] != groupID || keys[1] != groupID {
+		t.Errorf("Expected both fingerprints to map to %q, but got %v", groupID, args[1])
+	}
+}
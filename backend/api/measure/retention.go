@@ -0,0 +1,476 @@
+package measure
+
+import (
+	"backend/api/filter"
+	"backend/api/server"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+// maxRetentionPeriods is the maximum count
+// of periods in a retention matrix.
+const maxRetentionPeriods = 90
+
+// defaultRetentionPeriods is the count of
+// periods in a retention matrix when not
+// set.
+const defaultRetentionPeriods = 7
+
+// maxRetentionVersionCohorts is the maximum
+// count of version cohorts reported in a
+// retention matrix.
+const maxRetentionVersionCohorts = 10
+
+// retentionPeriodStarts maps the retention
+// period to the expression truncating a date
+// to the start of the period.
+var retentionPeriodStarts = map[string]string{
+	"day":  "toDate",
+	"week": "toMonday",
+}
+
+// RetentionParams represents the shape of
+// a retention matrix.
+type RetentionParams struct {
+	// Kind is either "installation" or "user".
+	Kind string `form:"kind"`
+	// Cohort is either "day", "week" or
+	// "version".
+	Cohort string `form:"cohort"`
+	// Period is either "day" or "week".
+	Period  string `form:"period"`
+	Periods int    `form:"periods"`
+}
+
+// RetentionPeriod represents the retention of
+// a cohort for a single period.
+type RetentionPeriod struct {
+	Period   int    `json:"period"`
+	Retained uint64 `json:"retained"`
+	// Eligible is the count of the cohort that
+	// could have returned in the period.
+	Eligible uint64 `json:"eligible"`
+	// Retention is the percentage of eligible
+	// that returned in the period. It is nil
+	// if none of the cohort was eligible.
+	Retention *float64 `json:"retention"`
+}
+
+// RetentionCohort represents a single row
+// of a retention matrix.
+type RetentionCohort struct {
+	Cohort  string            `json:"cohort"`
+	Size    uint64            `json:"size"`
+	Periods []RetentionPeriod `json:"periods"`
+}
+
+// Retention represents a retention matrix.
+type Retention struct {
+	Kind    string            `json:"kind"`
+	Cohort  string            `json:"cohort"`
+	Period  string            `json:"period"`
+	Cohorts []RetentionCohort `json:"cohorts"`
+}
+
+// retentionCount represents the count of a
+// cohort first seen in a period, active in a
+// later period.
+type retentionCount struct {
+	cohort      string
+	firstPeriod time.Time
+	period      int
+	identities  uint64
+}
+
+// Validate validates the retention parameters
+// and sets defaults for missing values.
+func (rp *RetentionParams) Validate() error {
+	if rp.Kind == "" {
+		rp.Kind = "installation"
+	}
+
+	if rp.Kind != "installation" && rp.Kind != "user" {
+		return errors.New(`kind must be one of "installation" or "user"`)
+	}
+
+	if rp.Cohort == "" {
+		rp.Cohort = "day"
+	}
+
+	switch rp.Cohort {
+	case "day", "week":
+		if rp.Period == "" {
+			rp.Period = rp.Cohort
+		}
+
+		if rp.Period != rp.Cohort {
+			return errors.New("period must match cohort for day & week cohorts")
+		}
+	case "version":
+		if rp.Period == "" {
+			rp.Period = "day"
+		}
+	default:
+		return errors.New(`cohort must be one of "day", "week" or "version"`)
+	}
+
+	if _, ok := retentionPeriodStarts[rp.Period]; !ok {
+		return errors.New(`period must be one of "day" or "week"`)
+	}
+
+	if rp.Periods == 0 {
+		rp.Periods = defaultRetentionPeriods
+	}
+
+	if rp.Periods < 1 || rp.Periods > maxRetentionPeriods {
+		return fmt.Errorf("periods must be between 1 and %d", maxRetentionPeriods)
+	}
+
+	return nil
+}
+
+// periodStart truncates the time to the
+// start of the UTC day or ISO week.
+func (rp RetentionParams) periodStart(t time.Time) time.Time {
+	t = t.UTC().Truncate(24 * time.Hour)
+
+	if rp.Period == "week" {
+		offset := (int(t.Weekday()) + 6) % 7
+		t = t.AddDate(0, 0, -offset)
+	}
+
+	return t
+}
+
+// addPeriods adds count periods to the time.
+func (rp RetentionParams) addPeriods(t time.Time, count int) time.Time {
+	if rp.Period == "week" {
+		return t.AddDate(0, 0, count*7)
+	}
+
+	return t.AddDate(0, 0, count)
+}
+
+// GetRetention computes the retention matrix of
+// installations or users first seen in the time
+// range while respecting all applicable app
+// filters. Activity after the time range is not
+// considered.
+func (a App) GetRetention(ctx context.Context, af *filter.AppFilter, rp *RetentionParams) (retention *Retention, err error) {
+	periodStart := retentionPeriodStarts[rp.Period]
+
+	cohort := fmt.Sprintf("toString(%s(toDate(min(first_seen))))", periodStart)
+	if rp.Cohort == "version" {
+		cohort = "concat(tupleElement(argMinMerge(first_version), 1), ' (', tupleElement(argMinMerge(first_version), 2), ')')"
+	}
+
+	firstSeenStmt := sqlf.
+		From("user_first_seen").
+		Select("identity").
+		Select(fmt.Sprintf("%s as cohort", cohort)).
+		Select(fmt.Sprintf("%s(toDate(min(first_seen))) as first_period", periodStart)).
+		Where("app_id = toUUID(?) and kind = ?", af.AppID, rp.Kind).
+		GroupBy("identity").
+		Having("min(first_seen) >= ? and min(first_seen) <= ?", af.From, af.To)
+
+	defer firstSeenStmt.Close()
+
+	activityStmt := sqlf.
+		From(fmt.Sprintf("user_activity a inner join (%s) f using identity", firstSeenStmt.String()), firstSeenStmt.Args()...).
+		Select("identity").
+		Select("any(f.cohort) as cohort").
+		Select("any(f.first_period) as first_period").
+		Select(fmt.Sprintf("groupUniqArray(toInt32(dateDiff('%s', f.first_period, %s(a.date)))) as periods", rp.Period, periodStart)).
+		Where("a.app_id = toUUID(?) and a.kind = ?", af.AppID, rp.Kind).
+		Where("a.date >= toDate(?) and a.date <= toDate(?)", af.From, af.To).
+		GroupBy("identity")
+
+	defer activityStmt.Close()
+
+	applyIssueMetricsFilters(activityStmt, af)
+
+	stmt := sqlf.
+		From(fmt.Sprintf("(%s)", activityStmt.String()), activityStmt.Args()...).
+		Select("cohort").
+		Select("first_period").
+		Select("period").
+		Select("count() as identities").
+		Clause("array join periods as period").
+		Where("has(periods, 0) and period >= 0 and period <= ?", rp.Periods).
+		GroupBy("cohort, first_period, period")
+
+	defer stmt.Close()
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	var counts []retentionCount
+
+	for rows.Next() {
+		var count retentionCount
+		var period int32
+		if err = rows.Scan(&count.cohort, &count.firstPeriod, &period, &count.identities); err != nil {
+			return
+		}
+
+		count.period = int(period)
+
+		counts = append(counts, count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	retention = &Retention{
+		Kind:    rp.Kind,
+		Cohort:  rp.Cohort,
+		Period:  rp.Period,
+		Cohorts: computeRetention(counts, rp, rp.periodStart(af.To)),
+	}
+
+	return
+}
+
+// computeRetention builds the retention matrix from
+// counts of each cohort by the period first seen in &
+// the period returned in. Only parts of a cohort first
+// seen early enough for a period to have elapsed by the
+// last period are eligible for that period.
+func computeRetention(counts []retentionCount, rp *RetentionParams, lastPeriod time.Time) (cohorts []RetentionCohort) {
+	cohorts = []RetentionCohort{}
+
+	type cohortCounts struct {
+		// sizes is the count of the cohort by
+		// the period first seen in.
+		sizes    map[time.Time]uint64
+		retained []uint64
+	}
+
+	lut := make(map[string]*cohortCounts)
+	var keys []string
+
+	for _, count := range counts {
+		if count.period < 0 || count.period > rp.Periods {
+			continue
+		}
+
+		cc, ok := lut[count.cohort]
+		if !ok {
+			cc = &cohortCounts{
+				sizes:    make(map[time.Time]uint64),
+				retained: make([]uint64, rp.Periods+1),
+			}
+			lut[count.cohort] = cc
+			keys = append(keys, count.cohort)
+		}
+
+		if count.period == 0 {
+			cc.sizes[count.firstPeriod.UTC()] += count.identities
+		}
+
+		cc.retained[count.period] += count.identities
+	}
+
+	for _, key := range keys {
+		cc := lut[key]
+		cohort := RetentionCohort{
+			Cohort:  key,
+			Periods: []RetentionPeriod{},
+		}
+
+		for _, size := range cc.sizes {
+			cohort.Size += size
+		}
+
+		for period := 0; period <= rp.Periods; period++ {
+			rpd := RetentionPeriod{
+				Period:   period,
+				Retained: cc.retained[period],
+			}
+
+			for firstPeriod, size := range cc.sizes {
+				if !rp.addPeriods(firstPeriod, period).After(lastPeriod) {
+					rpd.Eligible += size
+				}
+			}
+
+			if rpd.Eligible > 0 {
+				retention := percentage(rpd.Retained, rpd.Eligible)
+				rpd.Retention = &retention
+			}
+
+			cohort.Periods = append(cohort.Periods, rpd)
+		}
+
+		cohorts = append(cohorts, cohort)
+	}
+
+	if rp.Cohort == "version" {
+		sort.SliceStable(cohorts, func(i, j int) bool {
+			return cohorts[i].Size > cohorts[j].Size
+		})
+
+		if len(cohorts) > maxRetentionVersionCohorts {
+			cohorts = cohorts[:maxRetentionVersionCohorts]
+		}
+	} else {
+		sort.SliceStable(cohorts, func(i, j int) bool {
+			return cohorts[i].Cohort < cohorts[j].Cohort
+		})
+	}
+
+	return
+}
+
+func GetRetention(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	var rp RetentionParams
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse retention request`
+		fmt.Println(msg, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := c.ShouldBindQuery(&rp); err != nil {
+		msg := `failed to parse retention request`
+		fmt.Println(msg, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := `retention request validation failed`
+
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	if err := rp.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	retention, err := app.GetRetention(ctx, &af, &rp)
+	if err != nil {
+		msg := `failed to compute retention`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, retention)
+}
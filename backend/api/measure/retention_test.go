@@ -0,0 +1,102 @@
+package measure
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetentionParamsValidate(t *testing.T) {
+	rp := RetentionParams{Cohort: "week"}
+	if err := rp.Validate(); err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if rp.Kind != "installation" || rp.Period != "week" || rp.Periods != 7 {
+		t.Errorf("Expected defaults %q, %q & %d, but got %q, %q & %d", "installation", "week", 7, rp.Kind, rp.Period, rp.Periods)
+	}
+
+	invalid := []RetentionParams{
+		{Kind: "device"},
+		{Cohort: "month"},
+		{Cohort: "day", Period: "week"},
+		{Cohort: "version", Period: "month"},
+		{Periods: 91},
+	}
+
+	for _, rp := range invalid {
+		if err := rp.Validate(); err == nil {
+			t.Errorf("Expected error for %+v, but got nil", rp)
+		}
+	}
+}
+
+func TestRetentionParamsPeriodStart(t *testing.T) {
+	rp := RetentionParams{Period: "week"}
+
+	// Sunday
+	sunday := time.Date(2024, 12, 22, 18, 30, 0, 0, time.UTC)
+	monday := time.Date(2024, 12, 16, 0, 0, 0, 0, time.UTC)
+
+	if got := rp.periodStart(sunday); !got.Equal(monday) {
+		t.Errorf("Expected %v, but got %v", monday, got)
+	}
+
+	rp.Period = "day"
+	day := time.Date(2024, 12, 22, 0, 0, 0, 0, time.UTC)
+
+	if got := rp.periodStart(sunday); !got.Equal(day) {
+		t.Errorf("Expected %v, but got %v", day, got)
+	}
+}
+
+func TestComputeRetention(t *testing.T) {
+	rp := RetentionParams{Kind: "installation", Cohort: "day", Period: "day", Periods: 2}
+	first := time.Date(2024, 12, 20, 0, 0, 0, 0, time.UTC)
+	second := time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC)
+
+	counts := []retentionCount{
+		{cohort: "2024-12-21", firstPeriod: second, period: 0, identities: 50},
+		{cohort: "2024-12-21", firstPeriod: second, period: 1, identities: 10},
+		{cohort: "2024-12-20", firstPeriod: first, period: 0, identities: 100},
+		{cohort: "2024-12-20", firstPeriod: first, period: 1, identities: 40},
+		{cohort: "2024-12-20", firstPeriod: first, period: 2, identities: 25},
+	}
+
+	cohorts := computeRetention(counts, &rp, second.AddDate(0, 0, 1))
+
+	if len(cohorts) != 2 || cohorts[0].Cohort != "2024-12-20" {
+		t.Fatalf("Expected 2 cohorts ordered by date, but got %+v", cohorts)
+	}
+
+	if cohorts[0].Size != 100 || *cohorts[0].Periods[1].Retention != 40 || *cohorts[0].Periods[2].Retention != 25 {
+		t.Errorf("Expected retention of %v & %v, but got %+v", 40, 25, cohorts[0].Periods)
+	}
+
+	if cohorts[1].Periods[2].Retention != nil || cohorts[1].Periods[2].Eligible != 0 {
+		t.Errorf("Expected period %d of %q to not have elapsed, but got %+v", 2, cohorts[1].Cohort, cohorts[1].Periods[2])
+	}
+}
+
+func TestComputeRetentionVersions(t *testing.T) {
+	rp := RetentionParams{Kind: "user", Cohort: "version", Period: "day", Periods: 1}
+	first := time.Date(2024, 12, 20, 0, 0, 0, 0, time.UTC)
+	second := time.Date(2024, 12, 21, 0, 0, 0, 0, time.UTC)
+
+	counts := []retentionCount{
+		{cohort: "2.3 (230)", firstPeriod: first, period: 0, identities: 60},
+		{cohort: "2.3 (230)", firstPeriod: second, period: 0, identities: 40},
+		{cohort: "2.3 (230)", firstPeriod: first, period: 1, identities: 30},
+		{cohort: "2.2 (220)", firstPeriod: first, period: 0, identities: 10},
+	}
+
+	cohorts := computeRetention(counts, &rp, second)
+
+	if len(cohorts) != 2 || cohorts[0].Cohort != "2.3 (230)" || cohorts[0].Size != 100 {
+		t.Fatalf("Expected largest version cohort first, but got %+v", cohorts)
+	}
+
+	period := cohorts[0].Periods[1]
+	if period.Eligible != 60 || *period.Retention != 50 {
+		t.Errorf("Expected %d eligible with retention %v, but got %+v", 60, 50, period)
+	}
+}
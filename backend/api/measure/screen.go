@@ -0,0 +1,431 @@
+package measure
+
+import (
+	"backend/api/event"
+	"backend/api/filter"
+	"backend/api/server"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+// defaultScreensLimit is the default count
+// of screens reported in screen analytics.
+const defaultScreensLimit = 100
+
+// ScreenKindActivity is the kind of
+// screen backed by an Android activity.
+const ScreenKindActivity = "activity"
+
+// ScreenKindFragment is the kind of
+// screen backed by an Android fragment.
+const ScreenKindFragment = "fragment"
+
+// ScreenKindViewController is the kind of
+// screen backed by an iOS view controller.
+const ScreenKindViewController = "view_controller"
+
+// ScreenKindSwiftUI is the kind of screen
+// backed by a SwiftUI view.
+const ScreenKindSwiftUI = "swift_ui"
+
+// ScreenKindNavigation is the kind of screen
+// reported as the destination of a navigation.
+const ScreenKindNavigation = "navigation"
+
+// ScreenKindScreenView is the kind of screen
+// reported by a screen view.
+const ScreenKindScreenView = "screen_view"
+
+// screenKinds maps event types to the kind of
+// screen they describe.
+var screenKinds = map[string]string{
+	event.TypeLifecycleActivity:       ScreenKindActivity,
+	event.TypeLifecycleFragment:       ScreenKindFragment,
+	event.TypeLifecycleViewController: ScreenKindViewController,
+	event.TypeLifecycleSwiftUI:        ScreenKindSwiftUI,
+	event.TypeNavigation:              ScreenKindNavigation,
+	event.TypeScreenView:              ScreenKindScreenView,
+}
+
+// screenLoadActions maps screen kinds to the
+// lifecycle action from which the screen starts
+// loading.
+var screenLoadActions = map[string]string{
+	ScreenKindActivity:       event.LifecycleActivityTypeCreated,
+	ScreenKindFragment:       event.LifecycleFragmentTypeAttached,
+	ScreenKindViewController: event.LifecycleViewControllerTypeViewDidLoad,
+}
+
+// screenEnterActions maps screen kinds to the
+// lifecycle action at which the screen becomes
+// visible.
+var screenEnterActions = map[string]string{
+	ScreenKindActivity:       event.LifecycleActivityTypeResumed,
+	ScreenKindFragment:       event.LifecycleFragmentTypeResumed,
+	ScreenKindViewController: event.LifecycleViewControllerTypeViewDidAppear,
+	ScreenKindSwiftUI:        event.LifecycleSwiftUITypeOnAppear,
+}
+
+// screenLeaveActions maps screen kinds to the
+// lifecycle action at which the screen stops
+// being visible.
+var screenLeaveActions = map[string]string{
+	ScreenKindActivity:       event.LifecycleActivityTypePaused,
+	ScreenKindFragment:       event.LifecycleFragmentTypePaused,
+	ScreenKindViewController: event.LifecycleViewControllerTypeViewDidDisappear,
+	ScreenKindSwiftUI:        event.LifecycleSwiftUITypeOnDisappear,
+}
+
+// Screen represents engagement & performance
+// metrics of a single screen.
+type Screen struct {
+	Name     string `json:"name"`
+	Kind     string `json:"kind"`
+	Views    uint64 `json:"views"`
+	Sessions uint64 `json:"sessions"`
+	// TimeOnScreen is the median time in milliseconds
+	// the screen stayed visible. Nil when no view of
+	// the screen was seen ending.
+	TimeOnScreen *float64 `json:"time_on_screen"`
+	// LoadTime is the median time in milliseconds
+	// the screen took to become visible. Nil when
+	// the kind of screen has no load lifecycle or
+	// no load was seen.
+	LoadTime *float64 `json:"load_time"`
+	Crashes  uint64   `json:"crashes"`
+	ANRs     uint64   `json:"anrs"`
+	Exits    uint64   `json:"exits"`
+	ExitRate float64  `json:"exit_rate"`
+}
+
+// screenTypes is the list of event types
+// describing screens, in the order their
+// kinds & names are resolved.
+var screenTypes = []string{
+	event.TypeLifecycleActivity,
+	event.TypeLifecycleFragment,
+	event.TypeLifecycleViewController,
+	event.TypeLifecycleSwiftUI,
+	event.TypeNavigation,
+	event.TypeScreenView,
+}
+
+// screenActionPairs builds a list of (kind, action)
+// tuples from a map of screen kinds to lifecycle
+// actions, ordered by kind.
+func screenActionPairs(actions map[string]string) string {
+	var kinds []string
+	for kind := range actions {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+
+	var pairs []string
+	for _, kind := range kinds {
+		pairs = append(pairs, fmt.Sprintf("('%s', '%s')", kind, actions[kind]))
+	}
+
+	return "(" + strings.Join(pairs, ", ") + ")"
+}
+
+// screenEventsStmt builds the statement selecting the
+// kind, name & lifecycle action of screen events and
+// unhandled exception & ANR events matching the app
+// filters.
+func screenEventsStmt(af *filter.AppFilter) *sqlf.Stmt {
+	var kinds, actions, names []string
+	var kindArgs, actionArgs, nameArgs []any
+
+	for _, t := range screenTypes {
+		kinds = append(kinds, "type = ?, ?")
+		kindArgs = append(kindArgs, t, screenKinds[t])
+	}
+
+	lifecycles := map[string]string{
+		event.TypeLifecycleActivity:       "lifecycle_activity",
+		event.TypeLifecycleFragment:       "lifecycle_fragment",
+		event.TypeLifecycleViewController: "lifecycle_view_controller",
+		event.TypeLifecycleSwiftUI:        "lifecycle_swift_ui",
+	}
+
+	for _, t := range screenTypes {
+		column, ok := lifecycles[t]
+		if !ok {
+			continue
+		}
+		actions = append(actions, fmt.Sprintf("type = ?, toStringCutToZero(%s.type)", column))
+		actionArgs = append(actionArgs, t)
+		names = append(names, fmt.Sprintf("type = ?, toStringCutToZero(%s.class_name)", column))
+		nameArgs = append(nameArgs, t)
+	}
+
+	names = append(names, "type = ?, toStringCutToZero(navigation.to)", "type = ?, toStringCutToZero(screen_view.name)")
+	nameArgs = append(nameArgs, event.TypeNavigation, event.TypeScreenView)
+
+	stmt := sqlf.
+		From("events").
+		Select("session_id").
+		Select("timestamp").
+		Select("toString(type) as event_type").
+		Select(fmt.Sprintf("multiIf(%s, '') as kind", strings.Join(kinds, ", ")), kindArgs...).
+		Select(fmt.Sprintf("multiIf(%s, '') as action", strings.Join(actions, ", ")), actionArgs...).
+		Select(fmt.Sprintf("multiIf(%s, '') as name", strings.Join(names, ", ")), nameArgs...).
+		Clause("prewhere app_id = toUUID(?)", af.AppID).
+		Where("(type in ? or (type = ? and exception.handled = false) or type = ?)", screenTypes, event.TypeException, event.TypeANR)
+
+	applyEventFilters(stmt, af)
+
+	return stmt
+}
+
+// screenViewsStmt builds the statement reconstructing
+// screen views from screen events. Each view carries
+// its duration & load time, while each exception &
+// ANR carries the screen viewed at the time.
+//
+// A screen is viewed when it becomes visible and the view
+// ends at its next view or when it stops being visible.
+// Navigations & screen views end at the next navigation
+// or screen view. A screen loads from its load action
+// until it becomes visible.
+func screenViewsStmt() *sqlf.Stmt {
+	isView := fmt.Sprintf("kind in ('%s', '%s') or (kind, action) in %s", ScreenKindNavigation, ScreenKindScreenView, screenActionPairs(screenEnterActions))
+	isLoad := fmt.Sprintf("(kind, action) in %s", screenActionPairs(screenLoadActions))
+	isLeave := fmt.Sprintf("(kind, action) in %s", screenActionPairs(screenLeaveActions))
+	viewGroup := fmt.Sprintf("if(kind in ('%s', '%s'), '', name)", ScreenKindNavigation, ScreenKindScreenView)
+
+	return sqlf.
+		From("screen_events").
+		Select("session_id").
+		Select("event_type").
+		Select("kind").
+		Select("name").
+		Select(fmt.Sprintf("%s as is_view", isView)).
+		Select(fmt.Sprintf("%s as is_load", isLoad)).
+		Select(fmt.Sprintf("%s as is_leave", isLeave)).
+		Select(fmt.Sprintf("if(is_view, dateDiff('millisecond', timestamp, leadInFrame(toNullable(timestamp)) over (partition by session_id, kind, %s, is_load order by timestamp rows between unbounded preceding and unbounded following)), null) as duration", viewGroup)).
+		Select("if(is_view and lagInFrame(is_load) over w_load, dateDiff('millisecond', lagInFrame(timestamp) over w_load, timestamp), null) as load_time").
+		Select("last_value(if(is_view, kind, null)) over w_current as screen_kind").
+		Select("last_value(if(is_view, name, null)) over w_current as screen_name").
+		Select("last_value(if(is_view, kind, null)) over w_session as exit_kind").
+		Select("last_value(if(is_view, name, null)) over w_session as exit_name").
+		Where("(name != '' and (is_view or is_load or is_leave)) or event_type in (?, ?)", event.TypeException, event.TypeANR).
+		Clause("WINDOW w_load as (partition by session_id, kind, name, is_leave order by timestamp rows between unbounded preceding and unbounded following), w_current as (partition by session_id order by timestamp rows between unbounded preceding and current row), w_session as (partition by session_id order by timestamp rows between unbounded preceding and unbounded following)")
+}
+
+// screensStmt builds the statement computing metrics
+// of each screen from screen views, ordered by views.
+// Exceptions & ANRs are attributed to the most recently
+// viewed screen. The most recently viewed screen of
+// each session counts as an exit.
+func screensStmt(af *filter.AppFilter) *sqlf.Stmt {
+	stmt := sqlf.
+		With("screen_events", screenEventsStmt(af)).
+		With("screen_views", screenViewsStmt()).
+		From("screen_views").
+		Select("screen_name").
+		Select("screen_kind").
+		Select("countIf(is_view) as views").
+		Select("uniqExactIf(session_id, is_view) as sessions").
+		Select("quantileExactInclusive(0.5)(duration) as median_duration").
+		Select("quantileExactInclusive(0.5)(load_time) as median_load_time").
+		Select("countIf(event_type = ?) as crashes", event.TypeException).
+		Select("countIf(event_type = ?) as anrs", event.TypeANR).
+		Select("uniqExactIf(session_id, is_view and kind = exit_kind and name = exit_name) as exits").
+		Where("screen_kind is not null").
+		Where("(is_view or event_type in (?, ?))", event.TypeException, event.TypeANR).
+		GroupBy("screen_kind, screen_name").
+		Having("views > 0").
+		OrderBy("views desc, screen_kind, screen_name")
+
+	if af.Limit > 0 {
+		stmt.Limit(uint64(af.Limit) + 1)
+	}
+
+	if af.Offset > 0 {
+		stmt.Offset(uint64(af.Offset))
+	}
+
+	return stmt
+}
+
+// GetScreens computes engagement & performance metrics
+// of an app's screens while respecting all applicable
+// app filters, ordered by views.
+func (a App) GetScreens(ctx context.Context, af *filter.AppFilter) (screens []Screen, next, previous bool, err error) {
+	screens = []Screen{}
+
+	stmt := screensStmt(af)
+
+	defer stmt.Close()
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	for rows.Next() {
+		var screen Screen
+		if err = rows.Scan(&screen.Name, &screen.Kind, &screen.Views, &screen.Sessions, &screen.TimeOnScreen, &screen.LoadTime, &screen.Crashes, &screen.ANRs, &screen.Exits); err != nil {
+			return
+		}
+		screen.ExitRate = percentage(screen.Exits, screen.Views)
+		screens = append(screens, screen)
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	if af.Limit > 0 && len(screens) > af.Limit {
+		screens = screens[:af.Limit]
+		next = true
+	}
+
+	previous = af.Offset > 0
+
+	return
+}
+
+func GetScreens(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: defaultScreensLimit,
+	}
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse screens request`
+		fmt.Println(msg, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := `screens request validation failed`
+
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if af.Limit < 0 || af.Offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": "`limit` & `offset` cannot be negative",
+		})
+		return
+	}
+
+	if len(af.Versions) > 0 || len(af.VersionCodes) > 0 {
+		if err := af.ValidateVersions(); err != nil {
+			fmt.Println(msg, err)
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	screens, next, previous, err := app.GetScreens(ctx, &af)
+	if err != nil {
+		msg := `failed to fetch screens`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": screens,
+		"meta": gin.H{
+			"next":     next,
+			"previous": previous,
+		},
+	})
+}
@@ -0,0 +1,54 @@
+package measure
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScreenActionPairs(t *testing.T) {
+	expected := "(('activity', 'resumed'), ('fragment', 'resumed'), ('swift_ui', 'on_appear'), ('view_controller', 'viewDidAppear'))"
+	if got := screenActionPairs(screenEnterActions); got != expected {
+		t.Errorf("Expected %q, but got %q", expected, got)
+	}
+}
+
+func TestScreensStmt(t *testing.T) {
+	af := newTestAppFilter()
+	af.Limit = 20
+	af.Offset = 40
+
+	stmt := screensStmt(af)
+	defer stmt.Close()
+
+	sql := stmt.String()
+
+	expected := []string{
+		"WITH screen_events AS (SELECT",
+		"prewhere app_id = toUUID(?) WHERE (type in ? or (type = ? and exception.handled = false) or type = ?) AND inet.country_code in ?",
+		"screen_views AS (SELECT",
+		"leadInFrame(toNullable(timestamp)) over (partition by session_id, kind, if(kind in ('navigation', 'screen_view'), '', name), is_load order by timestamp",
+		"lagInFrame(is_load) over w_load",
+		"last_value(if(is_view, kind, null)) over w_current as screen_kind",
+		"last_value(if(is_view, kind, null)) over w_session as exit_kind",
+		"FROM screen_views WHERE screen_kind is not null AND (is_view or event_type in (?, ?))",
+		"GROUP BY screen_kind, screen_name",
+		"ORDER BY views desc, screen_kind, screen_name",
+		"LIMIT ?",
+		"OFFSET ?",
+	}
+
+	for _, want := range expected {
+		if !strings.Contains(sql, want) {
+			t.Errorf("Expected statement to contain %q, got %s", want, sql)
+		}
+	}
+
+	args := stmt.Args()
+	if limit := args[len(args)-2]; limit != uint64(21) {
+		t.Errorf("Expected limit of %d to detect next page, but got %v", 21, limit)
+	}
+
+	if offset := args[len(args)-1]; offset != uint64(40) {
+		t.Errorf("Expected offset of %d, but got %v", 40, offset)
+	}
+}
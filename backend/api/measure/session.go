@@ -310,6 +310,12 @@ func GetSessionsWithFilter(ctx context.Context, af *filter.AppFilter) (sessions
 		stmt.Having("uniqMerge(anr_count) >= 1")
 	}
 
+	if af.RageClick || af.DeadClick {
+		subQuery := frustratedSessionsStmt(af, af.RageClick, af.DeadClick)
+		stmt.Where(fmt.Sprintf("session_id in (%s)", subQuery.String()), subQuery.Args()...)
+		subQuery.Close()
+	}
+
 	if af.HasVersions() {
 		selectedVersions, err := af.VersionPairs()
 		if err != nil {
@@ -0,0 +1,687 @@
+package measure
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"backend/api/email"
+	"backend/api/filter"
+	"backend/api/metrics"
+	"backend/api/notify"
+	"backend/api/pairs"
+	"backend/api/server"
+	"backend/api/span"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+// spanRegressionSampleSize is the maximum count of
+// durations sampled per root span & version for
+// comparing latency distributions.
+const spanRegressionSampleSize = 2000
+
+// minSpanRegressionSamples is the minimum count of
+// durations of a root span in each version for the
+// span to be compared.
+const minSpanRegressionSamples = 30
+
+// minSpanRegressionDelta is the minimum percentage
+// a root span's p50 must grow by in the newer
+// version to count as a regression.
+const minSpanRegressionDelta = 5
+
+// spanRegressionCheckPeriod is the duration of root
+// spans compared by scheduled regression checks.
+const spanRegressionCheckPeriod = 7 * 24 * time.Hour
+
+// minSpanRegressionCheckSpans is the minimum count of
+// root spans of the newest version before scheduled
+// regression checks compare it.
+const minSpanRegressionCheckSpans = 1000
+
+// SpanLatency represents the latency distribution
+// of a root span in a version.
+type SpanLatency struct {
+	Spans uint64  `json:"spans"`
+	P50   float64 `json:"p50"`
+	P95   float64 `json:"p95"`
+
+	// durations is a sample of
+	// durations in milliseconds.
+	durations []float64
+}
+
+// SpanRegression represents a root span that
+// got significantly slower in the target version.
+type SpanRegression struct {
+	SpanName string      `json:"span_name"`
+	Base     SpanLatency `json:"base"`
+	Target   SpanLatency `json:"target"`
+	// P50Delta & P95Delta are the percentages the
+	// percentiles grew by in the target version.
+	P50Delta float64 `json:"p50_delta"`
+	P95Delta float64 `json:"p95_delta"`
+	// Superiority is the probability that a span of
+	// the target version is slower than a span of the
+	// base version.
+	Superiority float64 `json:"superiority"`
+	// PValue is adjusted for the count of root spans
+	// compared.
+	PValue float64 `json:"p_value"`
+}
+
+// SpanRegressionReport represents the root spans
+// that regressed between two versions.
+type SpanRegressionReport struct {
+	ReleaseVersions
+	// Compared is the count of root spans with
+	// enough samples in both versions.
+	Compared    int              `json:"compared"`
+	Regressions []SpanRegression `json:"regressions"`
+
+	// targetSpans is the count of root
+	// spans of the target version.
+	targetSpans uint64
+}
+
+// spanLatencies represents the latency distributions
+// of a root span in the base & target versions.
+type spanLatencies struct {
+	base, target *SpanLatency
+}
+
+// compareSpanLatencies compares latency distributions of
+// root spans present in both versions with a Mann-Whitney
+// U test, adjusting p-values for the count of spans
+// compared. Returns the spans whose durations got
+// significantly longer & whose p50 grew by at least the
+// minimum delta, the largest regressions first.
+func compareSpanLatencies(spans map[string]spanLatencies) (compared int, regressions []SpanRegression) {
+	var candidates []SpanRegression
+
+	for name, s := range spans {
+		if s.base == nil || s.target == nil {
+			continue
+		}
+
+		if len(s.base.durations) < minSpanRegressionSamples || len(s.target.durations) < minSpanRegressionSamples {
+			continue
+		}
+
+		compared++
+
+		// spans taking no time in the base version
+		// have no relative growth to go by
+		if s.base.P50 <= 0 {
+			continue
+		}
+
+		p, superiority := metrics.MannWhitneyTest(s.base.durations, s.target.durations)
+
+		candidates = append(candidates, SpanRegression{
+			SpanName:    name,
+			Base:        *s.base,
+			Target:      *s.target,
+			P50Delta:    percentChange(s.base.P50, s.target.P50),
+			P95Delta:    percentChange(s.base.P95, s.target.P95),
+			Superiority: superiority,
+			PValue:      p,
+		})
+	}
+
+	for _, c := range candidates {
+		c.PValue = math.Min(c.PValue*float64(compared), 1)
+
+		if c.PValue >= metrics.SignificanceLevel || c.P50Delta < minSpanRegressionDelta {
+			continue
+		}
+
+		regressions = append(regressions, c)
+	}
+
+	sort.Slice(regressions, func(i, j int) bool {
+		if regressions[i].P50Delta == regressions[j].P50Delta {
+			return regressions[i].SpanName < regressions[j].SpanName
+		}
+		return regressions[i].P50Delta > regressions[j].P50Delta
+	})
+
+	return
+}
+
+// percentChange computes the percentage the value
+// changed by from the base value. Returns 0 if the
+// base value is 0.
+func percentChange(base, value float64) float64 {
+	if base == 0 {
+		return 0
+	}
+
+	return (value - base) / base * 100
+}
+
+// spanRegressionMessage describes the
+// regressions of the report.
+func spanRegressionMessage(appName string, report SpanRegressionReport) string {
+	version := fmt.Sprintf("%s (%s)", report.TargetVersion, report.TargetVersionCode)
+
+	if len(report.Regressions) == 1 {
+		r := report.Regressions[0]
+		return fmt.Sprintf("%s got %.0f%% slower in %s %s", r.SpanName, r.P50Delta, appName, version)
+	}
+
+	return fmt.Sprintf("%d spans got slower in %s %s", len(report.Regressions), appName, version)
+}
+
+// getSpanRegressions compares latencies of root spans
+// between the base & target versions over the filter's
+// time range. Version filters are ignored in favour of
+// the versions compared.
+func getSpanRegressions(ctx context.Context, af *filter.AppFilter, rv ReleaseVersions) (report SpanRegressionReport, err error) {
+	report.ReleaseVersions = rv
+	report.Regressions = []SpanRegression{}
+
+	versions, err := pairs.NewPairs([]string{rv.BaseVersion, rv.TargetVersion}, []string{rv.BaseVersionCode, rv.TargetVersionCode})
+	if err != nil {
+		return
+	}
+
+	duration := "dateDiff('millisecond', start_time, end_time)"
+
+	stmt := sqlf.From("spans").
+		Select("toString(span_name) as name").
+		Select("attribute.app_version = (?, ?) as is_target", rv.TargetVersion, rv.TargetVersionCode).
+		Select("count() as spans").
+		Select(fmt.Sprintf("quantile(0.50)(%s) as p50", duration)).
+		Select(fmt.Sprintf("quantile(0.95)(%s) as p95", duration)).
+		Select(fmt.Sprintf("groupArraySample(%d)(toFloat64(%s)) as durations", spanRegressionSampleSize, duration)).
+		Clause("prewhere app_id = toUUID(?) and start_time >= ? and end_time <= ?", af.AppID, af.From, af.To).
+		Where("parent_id = ''").
+		Where("attribute.app_version in (?)", versions.Parameterize()).
+		GroupBy("name, is_target")
+
+	defer stmt.Close()
+
+	if len(af.SpanStatuses) > 0 {
+		stmt.Where("status").In(af.SpanStatuses)
+	}
+
+	if af.HasOSVersions() {
+		selectedOSVersions, err := af.OSVersionPairs()
+		if err != nil {
+			return report, err
+		}
+		stmt.Where("attribute.os_version in (?)", selectedOSVersions.Parameterize())
+	}
+
+	if af.HasCountries() {
+		stmt.Where("attribute.country_code in ?", af.Countries)
+	}
+
+	if af.HasNetworkProviders() {
+		stmt.Where("attribute.network_provider in ?", af.NetworkProviders)
+	}
+
+	if af.HasNetworkTypes() {
+		stmt.Where("attribute.network_type in ?", af.NetworkTypes)
+	}
+
+	if af.HasNetworkGenerations() {
+		stmt.Where("attribute.network_generation in ?", af.NetworkGenerations)
+	}
+
+	if af.HasDeviceLocales() {
+		stmt.Where("attribute.device_locale in ?", af.Locales)
+	}
+
+	if af.HasDeviceManufacturers() {
+		stmt.Where("attribute.device_manufacturer in ?", af.DeviceManufacturers)
+	}
+
+	if af.HasDeviceNames() {
+		stmt.Where("attribute.device_name in ?", af.DeviceNames)
+	}
+
+	span.FilterUDExpression(stmt, af)
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	spans := make(map[string]spanLatencies)
+
+	for rows.Next() {
+		var name string
+		var isTarget bool
+		var latency SpanLatency
+		if err = rows.Scan(&name, &isTarget, &latency.Spans, &latency.P50, &latency.P95, &latency.durations); err != nil {
+			return
+		}
+
+		s := spans[name]
+		if isTarget {
+			s.target = &latency
+			report.targetSpans += latency.Spans
+		} else {
+			s.base = &latency
+		}
+		spans[name] = s
+	}
+
+	if err = rows.Err(); err != nil {
+		return
+	}
+
+	compared, regressions := compareSpanLatencies(spans)
+	report.Compared = compared
+	if len(regressions) > 0 {
+		report.Regressions = regressions
+	}
+
+	return
+}
+
+// isSpanRegressionChecked checks if the app's
+// target version was already checked for
+// span regressions.
+func isSpanRegressionChecked(ctx context.Context, appId uuid.UUID, rv ReleaseVersions) (checked bool, err error) {
+	stmt := sqlf.PostgreSQL.
+		Select("exists (select 1 from public.span_regression_checks where app_id = ? and target_version = ? and target_version_code = ?)", appId, rv.TargetVersion, rv.TargetVersionCode)
+
+	defer stmt.Close()
+
+	err = server.Server.PgPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&checked)
+
+	return
+}
+
+// getOnboardedApps fetches ids of
+// apps that have been onboarded.
+func getOnboardedApps(ctx context.Context) (ids []uuid.UUID, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.apps").
+		Select("id").
+		Where("onboarded = ?", true)
+
+	defer stmt.Close()
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	return pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+}
+
+// RunSpanRegressions checks the newest version of
+// each app for span regressions at every interval
+// until the context is done.
+func RunSpanRegressions(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := checkSpanRegressions(ctx); err != nil {
+				fmt.Println("failed to check span regressions", err)
+			}
+		}
+	}
+}
+
+// checkSpanRegressions checks the newest version of
+// each onboarded app against its predecessor. Failing
+// apps don't stop checking the rest.
+func checkSpanRegressions(ctx context.Context) (err error) {
+	ids, err := getOnboardedApps(ctx)
+	if err != nil {
+		return
+	}
+
+	now := time.Now().UTC().Truncate(time.Minute)
+
+	for _, id := range ids {
+		if err := checkAppSpanRegressions(ctx, id, now); err != nil {
+			fmt.Printf("failed to check span regressions of app %s: %v\n", id, err)
+		}
+	}
+
+	return
+}
+
+// checkAppSpanRegressions compares root spans of the
+// app's newest version & its predecessor once the newest
+// version has enough spans, alerting on regressions.
+// Each version is checked only once.
+func checkAppSpanRegressions(ctx context.Context, appId uuid.UUID, now time.Time) (err error) {
+	versions, err := getRecentVersions(ctx, appId, 2)
+	if err != nil || len(versions) < 2 {
+		return
+	}
+
+	rv := ReleaseVersions{
+		BaseVersion:       versions[1].name,
+		BaseVersionCode:   versions[1].code,
+		TargetVersion:     versions[0].name,
+		TargetVersionCode: versions[0].code,
+	}
+
+	checked, err := isSpanRegressionChecked(ctx, appId, rv)
+	if err != nil || checked {
+		return
+	}
+
+	af := &filter.AppFilter{
+		AppID: appId,
+		From:  now.Add(-spanRegressionCheckPeriod),
+		To:    now,
+	}
+
+	report, err := getSpanRegressions(ctx, af, rv)
+	if err != nil {
+		return
+	}
+
+	// wait for the newest version
+	// to gather enough spans
+	if report.targetSpans < minSpanRegressionCheckSpans {
+		return
+	}
+
+	app := &App{
+		ID: &appId,
+	}
+	if err = app.Populate(ctx); err != nil {
+		return
+	}
+
+	return fireSpanRegressions(ctx, app, report, af.From, af.To)
+}
+
+// fireSpanRegressions records the check of the report's
+// target version & alerts on its regressions, queueing
+// emails & notifications unless span regressions are
+// muted. Nothing is recorded if another check of the
+// version was recorded first.
+func fireSpanRegressions(ctx context.Context, app *App, report SpanRegressionReport, windowStart, windowEnd time.Time) (err error) {
+	appId := *app.ID
+	message := spanRegressionMessage(app.AppName, report)
+	now := time.Now()
+
+	muted, err := isAlertMuted(ctx, appId, AlertTypeSpanRegression, nil, now)
+	if err != nil {
+		return
+	}
+
+	tx, err := server.Server.PgPool.Begin(ctx)
+	if err != nil {
+		return
+	}
+
+	defer tx.Rollback(ctx)
+
+	check := sqlf.PostgreSQL.InsertInto("public.span_regression_checks").
+		Set("app_id", appId).
+		Set("target_version", report.TargetVersion).
+		Set("target_version_code", report.TargetVersionCode).
+		Set("base_version", report.BaseVersion).
+		Set("base_version_code", report.BaseVersionCode).
+		Set("compared", report.Compared).
+		Set("regressions", len(report.Regressions)).
+		Set("checked_at", now).
+		Clause("on conflict (app_id, target_version, target_version_code) do nothing")
+
+	defer check.Close()
+
+	result, err := tx.Exec(ctx, check.String(), check.Args()...)
+	if err != nil || result.RowsAffected() == 0 {
+		return
+	}
+
+	if len(report.Regressions) > 0 {
+		alert := sqlf.PostgreSQL.InsertInto("public.alerts").
+			Set("id", uuid.New()).
+			Set("app_id", appId).
+			Set("type", AlertTypeSpanRegression).
+			Set("message", message).
+			Set("evidence", report).
+			Set("window_start", windowStart).
+			Set("window_end", windowEnd).
+			Set("created_at", now)
+
+		defer alert.Close()
+
+		if _, err = tx.Exec(ctx, alert.String(), alert.Args()...); err != nil {
+			return
+		}
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return
+	}
+
+	if muted || len(report.Regressions) == 0 {
+		return
+	}
+
+	payload := email.AlertPayload{
+		TeamID:      app.TeamId,
+		AppID:       appId,
+		AppName:     app.AppName,
+		Type:        AlertTypeSpanRegression,
+		Message:     message,
+		WindowStart: windowStart,
+		WindowEnd:   windowEnd,
+	}
+
+	recipients, err := getAlertRecipients(ctx, appId, "span_regression_email")
+	if err != nil {
+		return
+	}
+
+	var emails []email.Email
+	for _, recipient := range recipients {
+		emails = append(emails, email.Email{
+			Kind:      email.KindAlert,
+			Recipient: recipient.email,
+			UserID:    &recipient.userId,
+			AppID:     &appId,
+			Payload:   payload,
+		})
+	}
+
+	if len(emails) > 0 {
+		if err = email.Enqueue(ctx, emails...); err != nil {
+			return
+		}
+	}
+
+	return notify.Enqueue(ctx, appId, AlertTypeSpanRegression, payload)
+}
+
+// spanRegressionVersionParams lists the query
+// params of explicitly compared versions.
+var spanRegressionVersionParams = []string{
+	"base_version",
+	"base_version_code",
+	"target_version",
+	"target_version_code",
+}
+
+func GetSpanRegressions(c *gin.Context) {
+	ctx := c.Request.Context()
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		msg := `app id invalid or missing`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	af := filter.AppFilter{
+		AppID: id,
+		Limit: filter.DefaultPaginationLimit,
+	}
+
+	if err := c.ShouldBindQuery(&af); err != nil {
+		msg := `failed to parse span regressions request`
+		fmt.Println(msg, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	// versions are compared only when asked
+	// for, otherwise the newest version is
+	// compared against its predecessor
+	var rv *ReleaseVersions
+	for _, param := range spanRegressionVersionParams {
+		if _, ok := c.GetQuery(param); ok {
+			rv = &ReleaseVersions{}
+			break
+		}
+	}
+
+	if rv != nil {
+		if err := c.ShouldBindQuery(rv); err != nil {
+			msg := `failed to parse span regressions request`
+			fmt.Println(msg, err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	if err := af.Expand(ctx); err != nil {
+		msg := `failed to expand filters`
+		fmt.Println(msg, err)
+		status := http.StatusInternalServerError
+		if errors.Is(err, pgx.ErrNoRows) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	msg := `span regressions request validation failed`
+
+	if err := af.Validate(); err != nil {
+		fmt.Println(msg, err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if rv != nil && rv.BaseVersion == rv.TargetVersion && rv.BaseVersionCode == rv.TargetVersionCode {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   msg,
+			"details": "base & target versions must be different",
+		})
+		return
+	}
+
+	if !af.HasTimeRange() {
+		af.SetDefaultTimeRange()
+	}
+
+	app := App{
+		ID: &id,
+	}
+
+	team, err := app.getTeam(ctx)
+	if err != nil {
+		msg := "failed to get team from app id"
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+	if team == nil {
+		msg := fmt.Sprintf("no team exists for app [%s]", app.ID)
+		c.JSON(http.StatusBadRequest, gin.H{"error": msg})
+		return
+	}
+
+	userId := c.GetString("userId")
+	okTeam, err := PerformAuthz(userId, team.ID.String(), *ScopeTeamRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	okApp, err := PerformAuthz(userId, team.ID.String(), *ScopeAppRead)
+	if err != nil {
+		msg := `failed to perform authorization`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	if !okTeam || !okApp {
+		msg := `you are not authorized to access this app`
+		c.JSON(http.StatusForbidden, gin.H{"error": msg})
+		return
+	}
+
+	if rv == nil {
+		versions, err := getRecentVersions(ctx, id, 2)
+		if err != nil {
+			msg := `failed to fetch app versions`
+			fmt.Println(msg, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			return
+		}
+
+		if len(versions) < 2 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   msg,
+				"details": "app needs at least 2 versions to compare",
+			})
+			return
+		}
+
+		rv = &ReleaseVersions{
+			BaseVersion:       versions[1].name,
+			BaseVersionCode:   versions[1].code,
+			TargetVersion:     versions[0].name,
+			TargetVersionCode: versions[0].code,
+		}
+	}
+
+	report, err := getSpanRegressions(ctx, &af, *rv)
+	if err != nil {
+		msg := `failed to compare span latencies`
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": msg,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,122 @@
+package measure
+
+import (
+	"testing"
+)
+
+// latency builds a span latency from
+// evenly spread durations.
+func latency(from, to float64, n int) *SpanLatency {
+	l := &SpanLatency{
+		Spans: uint64(n),
+		P50:   (from + to) / 2,
+		P95:   from + (to-from)*0.95,
+	}
+
+	for i := 0; i < n; i++ {
+		l.durations = append(l.durations, from+(to-from)*float64(i)/float64(n-1))
+	}
+
+	return l
+}
+
+func TestCompareSpanLatencies(t *testing.T) {
+	spans := map[string]spanLatencies{
+		"checkout": {
+			base:   latency(100, 200, 200),
+			target: latency(150, 250, 200),
+		},
+		"login": {
+			base:   latency(100, 200, 200),
+			target: latency(110, 210, 200),
+		},
+		"search": {
+			base:   latency(100, 200, 200),
+			target: latency(100, 200, 200),
+		},
+		"faster": {
+			base:   latency(100, 200, 200),
+			target: latency(50, 150, 200),
+		},
+		"sparse": {
+			base:   latency(100, 200, 10),
+			target: latency(300, 400, 10),
+		},
+		"new": {
+			target: latency(300, 400, 200),
+		},
+	}
+
+	compared, regressions := compareSpanLatencies(spans)
+
+	if compared != 4 {
+		t.Errorf("Expected 4 spans compared, but got %d", compared)
+	}
+
+	if len(regressions) != 2 {
+		t.Fatalf("Expected 2 regressions, but got %+v", regressions)
+	}
+
+	if regressions[0].SpanName != "checkout" || regressions[1].SpanName != "login" {
+		t.Errorf("Expected checkout & login regressions, largest first, but got %s & %s", regressions[0].SpanName, regressions[1].SpanName)
+	}
+
+	if got := regressions[0].P50Delta; got < 33.3 || got > 33.4 {
+		t.Errorf("Expected checkout p50 delta of 33.33%%, but got %v", got)
+	}
+
+	if got := regressions[0].Superiority; got <= 0.5 {
+		t.Errorf("Expected target spans to be likely slower, but got superiority %v", got)
+	}
+
+	for _, r := range regressions {
+		if r.PValue >= 0.05 || r.PValue <= 0 {
+			t.Errorf("Expected significant adjusted p-value for %s, but got %v", r.SpanName, r.PValue)
+		}
+	}
+}
+
+func TestCompareSpanLatenciesMinDelta(t *testing.T) {
+	// a large sample makes a tiny slowdown
+	// significant, but not a regression
+	spans := map[string]spanLatencies{
+		"checkout": {
+			base:   latency(100, 200, 2000),
+			target: latency(103, 203, 2000),
+		},
+	}
+
+	compared, regressions := compareSpanLatencies(spans)
+
+	if compared != 1 {
+		t.Errorf("Expected 1 span compared, but got %d", compared)
+	}
+
+	if len(regressions) != 0 {
+		t.Errorf("Expected no regressions, but got %+v", regressions)
+	}
+}
+
+func TestSpanRegressionMessage(t *testing.T) {
+	report := SpanRegressionReport{
+		ReleaseVersions: ReleaseVersions{
+			BaseVersion:       "1.0.0",
+			BaseVersionCode:   "100",
+			TargetVersion:     "1.1.0",
+			TargetVersionCode: "110",
+		},
+		Regressions: []SpanRegression{
+			{SpanName: "checkout", P50Delta: 33.33},
+		},
+	}
+
+	if got := spanRegressionMessage("Shop", report); got != "checkout got 33% slower in Shop 1.1.0 (110)" {
+		t.Errorf("Unexpected message %q", got)
+	}
+
+	report.Regressions = append(report.Regressions, SpanRegression{SpanName: "login", P50Delta: 10})
+
+	if got := spanRegressionMessage("Shop", report); got != "2 spans got slower in Shop 1.1.0 (110)" {
+		t.Errorf("Unexpected message %q", got)
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"backend/api/chrono"
+	"backend/api/email"
 	"backend/api/server"
 
 	"github.com/gin-gonic/gin"
@@ -209,6 +210,69 @@ func (t *Team) addMembers(invitees []Invitee) error {
 	return nil
 }
 
+// inviteNewUsers records invites for invitees who
+// haven't signed up yet & emails them the invite.
+// Invites are accepted when the invitee signs up.
+func (t *Team) inviteNewUsers(ctx context.Context, invitees []Invitee, invitedBy string) (err error) {
+	var teamName, inviterName string
+
+	teamStmt := sqlf.PostgreSQL.
+		From("public.teams").
+		Select("name").
+		Where("id = ?", t.ID)
+
+	defer teamStmt.Close()
+
+	if err = server.Server.PgPool.QueryRow(ctx, teamStmt.String(), teamStmt.Args()...).Scan(&teamName); err != nil {
+		return
+	}
+
+	inviterStmt := sqlf.PostgreSQL.
+		From("public.users").
+		Select("coalesce(nullif(name, ''), email)").
+		Where("id = ?", invitedBy)
+
+	defer inviterStmt.Close()
+
+	if err = server.Server.PgPool.QueryRow(ctx, inviterStmt.String(), inviterStmt.Args()...).Scan(&inviterName); err != nil {
+		return
+	}
+
+	now := time.Now()
+	var emails []email.Email
+
+	for _, invitee := range invitees {
+		stmt := sqlf.PostgreSQL.
+			InsertInto("public.team_invites").
+			Set("team_id", t.ID).
+			Set("email", strings.ToLower(invitee.Email)).
+			Set("role", invitee.Role.String()).
+			Set("invited_by_user_id", invitedBy).
+			Set("created_at", now).
+			Set("updated_at", now).
+			Clause("on conflict (team_id, email) do update set role = excluded.role, invited_by_user_id = excluded.invited_by_user_id, updated_at = excluded.updated_at")
+
+		_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+		stmt.Close()
+		if err != nil {
+			return
+		}
+
+		emails = append(emails, email.Email{
+			Kind:      email.KindInvite,
+			Recipient: invitee.Email,
+			Payload: email.InvitePayload{
+				TeamID:    *t.ID,
+				TeamName:  teamName,
+				InvitedBy: inviterName,
+				Role:      invitee.Role.String(),
+			},
+		})
+	}
+
+	return email.Enqueue(ctx, emails...)
+}
+
 func (t *Team) removeMember(memberId *uuid.UUID) error {
 	stmt := sqlf.PostgreSQL.DeleteFrom("team_membership").
 		Where("team_id = ?", nil).
@@ -642,8 +706,7 @@ func InviteMembers(c *gin.Context) {
 		return
 	}
 
-	// ignoring new invitees for now
-	existingUsers, _, err := GetUsersByInvitees(invitees)
+	existingUsers, newInvitees, err := GetUsersByInvitees(invitees)
 	if err != nil {
 		msg := `failed to invite`
 		fmt.Println(msg, err)
@@ -653,31 +716,31 @@ func InviteMembers(c *gin.Context) {
 		return
 	}
 
-	if len(existingUsers) < 1 {
-		inviteeEmails := []string{}
-		for i := range invitees {
-			inviteeEmails = append(inviteeEmails, invitees[i].Email)
+	if len(existingUsers) > 0 {
+		if err := team.addMembers(existingUsers); err != nil {
+			msg := `failed to invite existing users`
+			fmt.Println(msg, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": msg,
+			})
+			return
 		}
-		emails := strings.Join(inviteeEmails, ", ")
-		msg := fmt.Sprintf("no matching users found for %s", emails)
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": msg,
-		})
-		return
 	}
 
-	if err := team.addMembers(existingUsers); err != nil {
-		msg := `failed to invite existing users`
-		fmt.Println(msg, err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": msg,
-		})
-		return
+	if len(newInvitees) > 0 {
+		if err := team.inviteNewUsers(c.Request.Context(), newInvitees, userId); err != nil {
+			msg := `failed to invite new users`
+			fmt.Println(msg, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": msg,
+			})
+			return
+		}
 	}
 
 	invitedEmails := []string{}
-	for i := range existingUsers {
-		invitedEmails = append(invitedEmails, existingUsers[i].Email)
+	for i := range invitees {
+		invitedEmails = append(invitedEmails, invitees[i].Email)
 	}
 	emails := strings.Join(invitedEmails, ", ")
 
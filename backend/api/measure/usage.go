@@ -2,6 +2,7 @@ package measure
 
 import (
 	"backend/api/server"
+	"context"
 	"fmt"
 	"net/http"
 	"time"
@@ -75,16 +76,29 @@ func GetUsage(c *gin.Context) {
 		return
 	}
 
-	var appIds []uuid.UUID
-	for _, app := range apps {
-		appIds = append(appIds, *app.ID)
-	}
-
 	// we want the API server to be the source-of-truth and
 	// arbiter of providing time. makes dealing with system
 	// clock skews easier, which is a horrendous problem to
 	// deal with honestly.
-	now := time.Now()
+	result, err := getAppUsage(ctx, apps, time.Now())
+	if err != nil {
+		msg := fmt.Sprintf("error occurred while querying usage for team: %s", teamId)
+		fmt.Println(msg, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// getAppUsage computes the monthly event, session, trace
+// & span counts of the apps for the month of now & the
+// two months before it.
+func getAppUsage(ctx context.Context, apps []App, now time.Time) (result []AppUsage, err error) {
+	var appIds []uuid.UUID
+	for _, app := range apps {
+		appIds = append(appIds, *app.ID)
+	}
 
 	// Query events and session counts for all apps in team
 	eventsStmt := sqlf.
@@ -102,32 +116,19 @@ func GetUsage(c *gin.Context) {
 
 	eventRows, err := server.Server.ChPool.Query(ctx, eventsStmt.String(), eventsStmt.Args()...)
 	if err != nil {
-		msg := fmt.Sprintf("error occurred while querying event usage for team: %s", teamId)
-		fmt.Println(msg, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 		return
 	}
+	defer eventRows.Close()
 
-	spansStmt := sqlf.
-		From(`spans`).
-		Select("app_id").
-		Select("formatDateTime(toStartOfMonth(start_time), '%b %Y') AS month_year").
-		Select("COUNT(DISTINCT trace_id) AS trace_count").
-		Select("COUNT(DISTINCT span_id) AS span_count").
-		Where("`app_id` in ?", appIds).
-		Where("start_time >= addMonths(toStartOfMonth(?), -2) AND start_time < toStartOfMonth(addMonths(?, 1))", now, now).
-		GroupBy("app_id, toStartOfMonth(start_time)").
-		OrderBy("app_id, toStartOfMonth(start_time) DESC")
+	spansStmt := spanUsageStmt(appIds, now)
 
 	defer spansStmt.Close()
 
 	spanRows, err := server.Server.ChPool.Query(ctx, spansStmt.String(), spansStmt.Args()...)
 	if err != nil {
-		msg := fmt.Sprintf("error occurred while querying span usage for team: %s", teamId)
-		fmt.Println(msg, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
 		return
 	}
+	defer spanRows.Close()
 
 	appUsageMap := make(map[string]*AppUsage)
 
@@ -153,10 +154,7 @@ func GetUsage(c *gin.Context) {
 		var appId, monthYear string
 		var eventCount, sessionCount uint64
 
-		if err := eventRows.Scan(&appId, &monthYear, &eventCount, &sessionCount); err != nil {
-			msg := fmt.Sprintf("error occurred while scanning event usage row for team: %s", teamId)
-			fmt.Println(msg, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+		if err = eventRows.Scan(&appId, &monthYear, &eventCount, &sessionCount); err != nil {
 			return
 		}
 
@@ -169,23 +167,15 @@ func GetUsage(c *gin.Context) {
 		}
 	}
 
-	if err := eventRows.Err(); err != nil {
-		msg := fmt.Sprintf("error occurred while iterating event usage rows for team: %s", teamId)
-		fmt.Println(msg, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+	if err = eventRows.Err(); err != nil {
 		return
 	}
 
 	// Populate appUsageMap with span rows from DB
 	for spanRows.Next() {
-		var appId, monthYear string
-		var traceCount, spanCount uint64
-
-		if err := spanRows.Scan(&appId, &monthYear, &spanCount, &traceCount); err != nil {
-			msg := fmt.Sprintf("error occurred while scanning span usage row for team: %s", teamId)
-			fmt.Println(msg, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-			return
+		appId, monthYear, traceCount, spanCount, err := scanSpanUsage(spanRows)
+		if err != nil {
+			return nil, err
 		}
 
 		if appUsage, exists := appUsageMap[appId]; exists {
@@ -212,10 +202,7 @@ func GetUsage(c *gin.Context) {
 		}
 	}
 
-	if err := spanRows.Err(); err != nil {
-		msg := fmt.Sprintf("error occurred while iterating span usage rows for team: %s", teamId)
-		fmt.Println(msg, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+	if err = spanRows.Err(); err != nil {
 		return
 	}
 
@@ -243,11 +230,34 @@ func GetUsage(c *gin.Context) {
 		appUsage.MonthlyAppUsage = newMonthlyAppUsage
 	}
 
-	// Convert map to slice for JSON response
-	var result []AppUsage
+	// Convert map to slice
 	for _, appUsage := range appUsageMap {
 		result = append(result, *appUsage)
 	}
 
-	c.JSON(http.StatusOK, result)
+	return
+}
+
+// spanUsageStmt builds the statement counting
+// traces & spans of the apps by month for the
+// month of now & the two months before it.
+func spanUsageStmt(appIds []uuid.UUID, now time.Time) *sqlf.Stmt {
+	return sqlf.
+		From(`spans`).
+		Select("app_id").
+		Select("formatDateTime(toStartOfMonth(start_time), '%b %Y') AS month_year").
+		Select("COUNT(DISTINCT trace_id) AS trace_count").
+		Select("COUNT(DISTINCT span_id) AS span_count").
+		Where("`app_id` in ?", appIds).
+		Where("start_time >= addMonths(toStartOfMonth(?), -2) AND start_time < toStartOfMonth(addMonths(?, 1))", now, now).
+		GroupBy("app_id, toStartOfMonth(start_time)").
+		OrderBy("app_id, toStartOfMonth(start_time) DESC")
+}
+
+// scanSpanUsage scans a row of span usage
+// in the order of spanUsageStmt's columns.
+func scanSpanUsage(row interface{ Scan(dest ...any) error }) (appId, monthYear string, traceCount, spanCount uint64, err error) {
+	err = row.Scan(&appId, &monthYear, &traceCount, &spanCount)
+
+	return
 }
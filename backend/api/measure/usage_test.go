@@ -0,0 +1,49 @@
+package measure
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// usageRow is a row of usage scanned
+// in the order of its values.
+type usageRow []any
+
+func (r usageRow) Scan(dest ...any) error {
+	for i := range dest {
+		switch d := dest[i].(type) {
+		case *string:
+			*d = r[i].(string)
+		case *uint64:
+			*d = r[i].(uint64)
+		}
+	}
+
+	return nil
+}
+
+func TestScanSpanUsage(t *testing.T) {
+	stmt := spanUsageStmt([]uuid.UUID{uuid.New()}, time.Now())
+	defer stmt.Close()
+
+	query := stmt.String()
+	if strings.Index(query, "AS trace_count") > strings.Index(query, "AS span_count") {
+		t.Fatalf("Expected trace count to be selected before span count, but got %s", query)
+	}
+
+	appId, monthYear, traceCount, spanCount, err := scanSpanUsage(usageRow{"app", "Dec 2024", uint64(3), uint64(42)})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if appId != "app" || monthYear != "Dec 2024" {
+		t.Errorf("Unexpected app %q or month %q", appId, monthYear)
+	}
+
+	if traceCount != 3 || spanCount != 42 {
+		t.Errorf("Expected 3 traces & 42 spans, but got %d traces & %d spans", traceCount, spanCount)
+	}
+}
@@ -158,6 +158,32 @@ func (u *User) save(ctx context.Context, tx *pgx.Tx) (err error) {
 	return
 }
 
+// acceptInvites makes a newly signed up user member
+// of the teams they were invited to before signing up.
+func (u *User) acceptInvites(ctx context.Context, tx *pgx.Tx) (err error) {
+	now := time.Now()
+
+	stmt := sqlf.PostgreSQL.
+		New("insert into public.team_membership (team_id, user_id, role, role_updated_at, created_at) select team_id, ?, role, ?, ? from public.team_invites", u.ID, now, now).
+		Where("email = lower(?)", u.Email)
+
+	defer stmt.Close()
+
+	if _, err = (*tx).Exec(ctx, stmt.String(), stmt.Args()...); err != nil {
+		return
+	}
+
+	deleteStmt := sqlf.PostgreSQL.
+		DeleteFrom("public.team_invites").
+		Where("email = lower(?)", u.Email)
+
+	defer deleteStmt.Close()
+
+	_, err = (*tx).Exec(ctx, deleteStmt.String(), deleteStmt.Args()...)
+
+	return
+}
+
 // firstName returns the first name of the
 // user.
 func (u *User) firstName() (firstName string) {
@@ -0,0 +1,259 @@
+package metrics
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+)
+
+const (
+	// AnomalyMethodMAD scores points by how many median
+	// absolute deviations they are away from the median
+	// of earlier points.
+	AnomalyMethodMAD = "mad"
+	// AnomalyMethodEWMA scores points by how many standard
+	// deviations they are away from the exponentially
+	// weighted moving average of earlier points.
+	AnomalyMethodEWMA = "ewma"
+)
+
+// madScale scales the median absolute deviation to be
+// consistent with the standard deviation of normally
+// distributed values.
+const madScale = 1.4826
+
+// minRelativeSpread is the minimum spread of a baseline
+// relative to its center, so that flat baselines don't
+// turn negligible changes into anomalies.
+const minRelativeSpread = 0.001
+
+// maxAnomalyHistory is the maximum count of earlier
+// points a point is compared against.
+const maxAnomalyHistory = 28
+
+// SeriesPoint represents a value of a
+// metric's time series.
+type SeriesPoint struct {
+	Value float64
+	// Volume is the count of samples
+	// the value is computed from.
+	Volume uint64
+	// Phase is the seasonal phase of the point,
+	// like the day of the week of daily points.
+	Phase int
+}
+
+// AnomalyOptions configures the detection
+// of anomalies in a time series.
+type AnomalyOptions struct {
+	Method string
+	// Threshold is the minimum absolute
+	// score of anomalous points.
+	Threshold float64
+	// MinHistory is the minimum count of
+	// earlier points needed to score a point.
+	MinHistory int
+	// MinVolume is the minimum volume of points
+	// to be scored or be part of a baseline.
+	MinVolume uint64
+	// Seasonal compares points against earlier
+	// points of the same phase, once there are
+	// enough of them. Only applies to MAD.
+	Seasonal bool
+	// Alpha is the smoothing factor of EWMA,
+	// higher values forgetting history faster.
+	Alpha float64
+}
+
+// Anomaly represents a point of a time
+// series deviating from its baseline.
+type Anomaly struct {
+	// Index is the position of the
+	// point in the series.
+	Index int     `json:"-"`
+	Value float64 `json:"value"`
+	// Expected is the center of the
+	// baseline the point deviates from.
+	Expected float64 `json:"expected"`
+	// Score is the count of baseline spreads the
+	// value is away from the expected value. Positive
+	// above & negative below the expected value.
+	Score float64 `json:"score"`
+}
+
+// Validate validates the anomaly options.
+func (o AnomalyOptions) Validate() error {
+	switch o.Method {
+	case AnomalyMethodMAD:
+	case AnomalyMethodEWMA:
+		if o.Alpha <= 0 || o.Alpha >= 1 {
+			return errors.New("alpha must be between 0 and 1")
+		}
+	default:
+		return fmt.Errorf("method must be one of %q or %q", AnomalyMethodMAD, AnomalyMethodEWMA)
+	}
+
+	if o.Threshold <= 0 {
+		return errors.New("threshold must be greater than 0")
+	}
+
+	if o.MinHistory < 1 {
+		return errors.New("min history must be at least 1")
+	}
+
+	return nil
+}
+
+// RobustBaseline computes the median of the values &
+// their median absolute deviation, scaled to match the
+// standard deviation & floored to a small fraction of
+// the median. Returns NaNs if there are no values.
+func RobustBaseline(values []float64) (center, spread float64) {
+	if len(values) == 0 {
+		return math.NaN(), math.NaN()
+	}
+
+	center = median(values)
+
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - center)
+	}
+
+	spread = max(madScale*median(deviations), minRelativeSpread*math.Abs(center))
+
+	return
+}
+
+// Score computes how many spreads the value is away
+// from the center. Returns 0 if the spread is 0, as
+// a baseline of zeroes can't tell anomalies apart.
+func Score(value, center, spread float64) float64 {
+	if spread == 0 || math.IsNaN(spread) {
+		return 0
+	}
+
+	return (value - center) / spread
+}
+
+// DetectAnomalies scores each point of the series against
+// the points before it & returns the points scoring at
+// least the threshold. Points lacking volume or history
+// are never anomalous.
+func DetectAnomalies(series []SeriesPoint, opts AnomalyOptions) (anomalies []Anomaly) {
+	switch opts.Method {
+	case AnomalyMethodMAD:
+		return detectMAD(series, opts)
+	case AnomalyMethodEWMA:
+		return detectEWMA(series, opts)
+	}
+
+	return
+}
+
+// detectMAD detects anomalies using robust
+// scores over a trailing window of history.
+func detectMAD(series []SeriesPoint, opts AnomalyOptions) (anomalies []Anomaly) {
+	for i, p := range series {
+		if !p.usable(opts.MinVolume) {
+			continue
+		}
+
+		var history, seasonal []float64
+		for j := i - 1; j >= 0 && len(history) < maxAnomalyHistory; j-- {
+			if !series[j].usable(opts.MinVolume) {
+				continue
+			}
+			history = append(history, series[j].Value)
+			if series[j].Phase == p.Phase {
+				seasonal = append(seasonal, series[j].Value)
+			}
+		}
+
+		if opts.Seasonal && len(seasonal) >= opts.MinHistory {
+			history = seasonal
+		}
+
+		if len(history) < opts.MinHistory {
+			continue
+		}
+
+		center, spread := RobustBaseline(history)
+		score := Score(p.Value, center, spread)
+
+		if math.Abs(score) >= opts.Threshold {
+			anomalies = append(anomalies, Anomaly{
+				Index:    i,
+				Value:    p.Value,
+				Expected: center,
+				Score:    score,
+			})
+		}
+	}
+
+	return
+}
+
+// detectEWMA detects anomalies using exponentially
+// weighted moving averages & variances. Anomalous
+// points are kept out of the average so that they
+// don't mask the ones after them.
+func detectEWMA(series []SeriesPoint, opts AnomalyOptions) (anomalies []Anomaly) {
+	var mean, variance float64
+	var n int
+
+	for i, p := range series {
+		if !p.usable(opts.MinVolume) {
+			continue
+		}
+
+		if n >= opts.MinHistory {
+			spread := max(math.Sqrt(variance), minRelativeSpread*math.Abs(mean))
+			score := Score(p.Value, mean, spread)
+
+			if math.Abs(score) >= opts.Threshold {
+				anomalies = append(anomalies, Anomaly{
+					Index:    i,
+					Value:    p.Value,
+					Expected: mean,
+					Score:    score,
+				})
+				continue
+			}
+		}
+
+		if n == 0 {
+			mean = p.Value
+		} else {
+			diff := p.Value - mean
+			incr := opts.Alpha * diff
+			mean += incr
+			variance = (1 - opts.Alpha) * (variance + diff*incr)
+		}
+
+		n++
+	}
+
+	return
+}
+
+// usable checks if the point has a finite
+// value & at least the minimum volume.
+func (p SeriesPoint) usable(minVolume uint64) bool {
+	return !math.IsNaN(p.Value) && !math.IsInf(p.Value, 0) && p.Volume >= minVolume
+}
+
+// median computes the median of
+// a non-empty slice of values.
+func median(values []float64) float64 {
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[mid-1] + sorted[mid]) / 2
+	}
+
+	return sorted[mid]
+}
@@ -52,6 +52,22 @@ type PerceivedANRFreeSession struct {
 	NaN             bool    `json:"nan"`
 }
 
+// CrashFreeUser represents compute result of an app's
+// crash free users.
+type CrashFreeUser struct {
+	CrashFreeUsers float64 `json:"crash_free_users"`
+	Delta          float64 `json:"delta"`
+	NaN            bool    `json:"nan"`
+}
+
+// ANRFreeUser represents compute result of an app's
+// ANR free users.
+type ANRFreeUser struct {
+	ANRFreeUsers float64 `json:"anr_free_users"`
+	Delta        float64 `json:"delta"`
+	NaN          bool    `json:"nan"`
+}
+
 // LaunchMetric represents compute result of an app's cold,
 // warm and hot launch timings.
 type LaunchMetric struct {
@@ -66,6 +82,77 @@ type LaunchMetric struct {
 	HotNaN        bool    `json:"hot_nan"`
 }
 
+// LaunchHistogramBounds are the lower bounds of launch
+// duration histogram buckets in milliseconds. These must
+// stay in sync with the buckets of the launch_metrics
+// materialized view.
+var LaunchHistogramBounds = []uint32{0, 100, 200, 300, 400, 500, 600, 800, 1000, 1250, 1500, 2000, 2500, 3000, 4000, 5000, 7500, 10000, 15000, 20000, 30000}
+
+// HistogramBucket represents the count of launches
+// whose duration falls within [Start, End). End is
+// nil for the last, unbounded bucket.
+type HistogramBucket struct {
+	Start uint32  `json:"start"`
+	End   *uint32 `json:"end"`
+	Count uint64  `json:"count"`
+}
+
+// LaunchDistribution represents compute result of
+// the quantiles and histogram of an app's launch
+// durations.
+type LaunchDistribution struct {
+	P50       float64           `json:"p50"`
+	P75       float64           `json:"p75"`
+	P90       float64           `json:"p90"`
+	P95       float64           `json:"p95"`
+	P99       float64           `json:"p99"`
+	Launches  uint64            `json:"launches"`
+	Histogram []HistogramBucket `json:"histogram"`
+	NaN       bool              `json:"nan"`
+}
+
+// NewLaunchHistogram creates histogram buckets from
+// bucket lower bounds and their counts. Empty buckets
+// are filled in up to the highest non-empty bucket, so
+// that the histogram is contiguous.
+func NewLaunchHistogram(bounds []uint32, counts []uint64) (histogram []HistogramBucket) {
+	histogram = []HistogramBucket{}
+	lut := make(map[uint32]uint64, len(bounds))
+	var highest uint32
+
+	for i := range bounds {
+		if i >= len(counts) || counts[i] == 0 {
+			continue
+		}
+		lut[bounds[i]] += counts[i]
+		highest = max(highest, bounds[i])
+	}
+
+	if len(lut) == 0 {
+		return
+	}
+
+	for i, start := range LaunchHistogramBounds {
+		if start > highest {
+			break
+		}
+
+		bucket := HistogramBucket{
+			Start: start,
+			Count: lut[start],
+		}
+
+		if i+1 < len(LaunchHistogramBounds) {
+			end := LaunchHistogramBounds[i+1]
+			bucket.End = &end
+		}
+
+		histogram = append(histogram, bucket)
+	}
+
+	return
+}
+
 // SetNaNs sets the NaN bit if adoption
 // value is NaN.
 func (sa *SessionAdoption) SetNaNs() {
@@ -123,6 +210,26 @@ func (pafs *PerceivedANRFreeSession) SetNaNs() {
 	}
 }
 
+// SetNaNs sets the NaN bit if crash
+// free users value(s) are NaN.
+func (cfu *CrashFreeUser) SetNaNs() {
+	if math.IsNaN(cfu.CrashFreeUsers) || math.IsNaN(cfu.Delta) {
+		cfu.NaN = true
+		cfu.CrashFreeUsers = 0
+		cfu.Delta = 0
+	}
+}
+
+// SetNaNs sets the NaN bit if ANR
+// free users value(s) are NaN.
+func (afu *ANRFreeUser) SetNaNs() {
+	if math.IsNaN(afu.ANRFreeUsers) || math.IsNaN(afu.Delta) {
+		afu.NaN = true
+		afu.ANRFreeUsers = 0
+		afu.Delta = 0
+	}
+}
+
 // SetNaNs sets the NaN bits if any cold,
 // warm or hot values are NaN.
 func (lm *LaunchMetric) SetNaNs() {
@@ -151,3 +258,44 @@ func (lm *LaunchMetric) SetNaNs() {
 		lm.HotDelta = 0
 	}
 }
+
+// IssueFree computes the percentage of total not
+// affected by an issue rounded to 2 decimal places.
+// Returns NaN if total is zero.
+func IssueFree(total, affected uint64) float64 {
+	if total == 0 {
+		return math.NaN()
+	}
+
+	return math.Round((1-float64(affected)/float64(total))*10000) / 100
+}
+
+// Delta computes the ratio of current to previous
+// rounded to 2 decimal places. Returns 1 if previous
+// is zero or NaN, as there is nothing to compare
+// against.
+func Delta(current, previous float64) float64 {
+	if math.IsNaN(current) {
+		return math.NaN()
+	}
+
+	if previous == 0 || math.IsNaN(previous) {
+		return 1
+	}
+
+	return math.Round(current/previous*100) / 100
+}
+
+// SetNaNs sets the NaN bit if there
+// are no launches or quantile values
+// are NaN.
+func (ld *LaunchDistribution) SetNaNs() {
+	if ld.Launches == 0 || math.IsNaN(ld.P50) || math.IsNaN(ld.P75) || math.IsNaN(ld.P90) || math.IsNaN(ld.P95) || math.IsNaN(ld.P99) {
+		ld.NaN = true
+		ld.P50 = 0
+		ld.P75 = 0
+		ld.P90 = 0
+		ld.P95 = 0
+		ld.P99 = 0
+	}
+}
@@ -0,0 +1,292 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestIssueFree(t *testing.T) {
+	if got := IssueFree(200, 3); got != 98.5 {
+		t.Errorf("Expected %v, but got %v", 98.5, got)
+	}
+
+	if got := IssueFree(3, 1); got != 66.67 {
+		t.Errorf("Expected %v, but got %v", 66.67, got)
+	}
+
+	if got := IssueFree(0, 0); !math.IsNaN(got) {
+		t.Errorf("Expected NaN, but got %v", got)
+	}
+}
+
+func TestDelta(t *testing.T) {
+	if got := Delta(99, 90); got != 1.1 {
+		t.Errorf("Expected %v, but got %v", 1.1, got)
+	}
+
+	if got := Delta(99, math.NaN()); got != 1 {
+		t.Errorf("Expected %v, but got %v", 1, got)
+	}
+
+	if got := Delta(99, 0); got != 1 {
+		t.Errorf("Expected %v, but got %v", 1, got)
+	}
+
+	if got := Delta(math.NaN(), 90); !math.IsNaN(got) {
+		t.Errorf("Expected NaN, but got %v", got)
+	}
+}
+
+func TestCrashFreeUserSetNaNs(t *testing.T) {
+	cfu := CrashFreeUser{
+		CrashFreeUsers: math.NaN(),
+		Delta:          math.NaN(),
+	}
+
+	cfu.SetNaNs()
+
+	if !cfu.NaN || cfu.CrashFreeUsers != 0 || cfu.Delta != 0 {
+		t.Errorf("Expected NaN bit to be set and values to be zeroed, but got %+v", cfu)
+	}
+}
+
+func TestNewLaunchHistogram(t *testing.T) {
+	histogram := NewLaunchHistogram([]uint32{0, 200}, []uint64{3, 5})
+
+	if len(histogram) != 3 {
+		t.Fatalf("Expected %d buckets, but got %d", 3, len(histogram))
+	}
+
+	if histogram[1].Start != 100 || histogram[1].Count != 0 || *histogram[1].End != 200 {
+		t.Errorf("Expected empty bucket [100, 200), but got %+v", histogram[1])
+	}
+
+	if histogram[2].Start != 200 || histogram[2].Count != 5 {
+		t.Errorf("Expected bucket starting at %d with count %d, but got %+v", 200, 5, histogram[2])
+	}
+}
+
+func TestNewLaunchHistogramLastBucket(t *testing.T) {
+	histogram := NewLaunchHistogram([]uint32{30000}, []uint64{1})
+	last := histogram[len(histogram)-1]
+
+	if len(histogram) != len(LaunchHistogramBounds) || last.End != nil || last.Count != 1 {
+		t.Errorf("Expected unbounded last bucket with count %d, but got %+v", 1, last)
+	}
+}
+
+func TestNewLaunchHistogramEmpty(t *testing.T) {
+	histogram := NewLaunchHistogram(nil, nil)
+
+	if histogram == nil || len(histogram) != 0 {
+		t.Errorf("Expected empty histogram, but got %v", histogram)
+	}
+}
+
+func TestLaunchDistributionSetNaNs(t *testing.T) {
+	ld := LaunchDistribution{
+		P50: math.NaN(),
+		P99: math.NaN(),
+	}
+
+	ld.SetNaNs()
+
+	if !ld.NaN || ld.P50 != 0 || ld.P99 != 0 {
+		t.Errorf("Expected NaN bit to be set and values to be zeroed, but got %+v", ld)
+	}
+}
+
+func TestProportionsTest(t *testing.T) {
+	if got := ProportionsTest(50, 1000, 80, 1000); got >= SignificanceLevel {
+		t.Errorf("Expected significant p-value, but got %v", got)
+	}
+
+	if got := ProportionsTest(5, 100, 6, 100); got < SignificanceLevel {
+		t.Errorf("Expected insignificant p-value, but got %v", got)
+	}
+
+	if got := ProportionsTest(0, 100, 0, 100); got != 1 {
+		t.Errorf("Expected %v, but got %v", 1, got)
+	}
+
+	if got := ProportionsTest(1, 0, 1, 100); got != 1 {
+		t.Errorf("Expected %v, but got %v", 1, got)
+	}
+}
+
+func TestHistogramsTest(t *testing.T) {
+	fast := []uint64{0, 50, 300, 100, 40, 10}
+	slow := []uint64{0, 10, 60, 120, 200, 110}
+
+	if got := HistogramsTest(fast, slow); got >= SignificanceLevel {
+		t.Errorf("Expected significant p-value, but got %v", got)
+	}
+
+	if got := HistogramsTest(fast, fast); got != 1 {
+		t.Errorf("Expected %v, but got %v", 1, got)
+	}
+
+	if got := HistogramsTest(fast, nil); got != 1 {
+		t.Errorf("Expected %v, but got %v", 1, got)
+	}
+}
+
+func TestHistogramCounts(t *testing.T) {
+	counts := HistogramCounts(NewLaunchHistogram([]uint32{100, 500}, []uint64{2, 7}))
+
+	if len(counts) != len(LaunchHistogramBounds) || counts[1] != 2 || counts[5] != 7 {
+		t.Errorf("Expected counts aligned to bounds, but got %v", counts)
+	}
+}
+
+func TestRobustBaseline(t *testing.T) {
+	center, spread := RobustBaseline([]float64{10, 12, 11, 13, 100})
+	if center != 12 {
+		t.Errorf("Expected median %v, but got %v", 12, center)
+	}
+
+	if math.Abs(spread-1.4826) > 1e-9 {
+		t.Errorf("Expected spread %v, but got %v", 1.4826, spread)
+	}
+
+	// flat baselines get a spread of 0.1% of the median
+	if _, spread := RobustBaseline([]float64{1000, 1000, 1000}); math.Abs(spread-1) > 1e-9 {
+		t.Errorf("Expected spread %v, but got %v", 1, spread)
+	}
+
+	if center, _ := RobustBaseline(nil); !math.IsNaN(center) {
+		t.Errorf("Expected NaN, but got %v", center)
+	}
+}
+
+func TestDetectAnomaliesMAD(t *testing.T) {
+	values := []float64{99.6, 99.5, 99.7, 99.6, 99.5, 99.6, 97.1, 99.6}
+	var series []SeriesPoint
+	for _, v := range values {
+		series = append(series, SeriesPoint{Value: v, Volume: 1000})
+	}
+
+	opts := AnomalyOptions{Method: AnomalyMethodMAD, Threshold: 3, MinHistory: 5, MinVolume: 100}
+
+	anomalies := DetectAnomalies(series, opts)
+	if len(anomalies) != 1 || anomalies[0].Index != 6 || anomalies[0].Score >= 0 {
+		t.Fatalf("Expected drop at index 6 to be anomalous, but got %+v", anomalies)
+	}
+
+	if anomalies[0].Expected != 99.6 {
+		t.Errorf("Expected baseline of %v, but got %v", 99.6, anomalies[0].Expected)
+	}
+
+	// too little volume to trust the drop
+	series[6].Volume = 10
+	if anomalies := DetectAnomalies(series, opts); len(anomalies) != 0 {
+		t.Errorf("Expected no anomalies, but got %+v", anomalies)
+	}
+}
+
+func TestDetectAnomaliesSeasonal(t *testing.T) {
+	// weekends see twice the sessions of weekdays
+	var series []SeriesPoint
+	for week := 0; week < 3; week++ {
+		for day := 0; day < 7; day++ {
+			value := 1000.0 + float64(day*5)
+			if day >= 5 {
+				value = 2000 + float64(week*10)
+			}
+			series = append(series, SeriesPoint{Value: value, Volume: uint64(value), Phase: day})
+		}
+	}
+
+	opts := AnomalyOptions{Method: AnomalyMethodMAD, Threshold: 3, MinHistory: 2, MinVolume: 100}
+
+	// the third week has enough history of each weekday
+	thirdWeek := func(anomalies []Anomaly) (count int) {
+		for _, a := range anomalies {
+			if a.Index >= 14 {
+				count++
+			}
+		}
+		return
+	}
+
+	if count := thirdWeek(DetectAnomalies(series, opts)); count == 0 {
+		t.Errorf("Expected weekends to be anomalous against all earlier days")
+	}
+
+	opts.Seasonal = true
+	if count := thirdWeek(DetectAnomalies(series, opts)); count != 0 {
+		t.Errorf("Expected no anomalies against seasonal baselines, but got %d", count)
+	}
+}
+
+func TestDetectAnomaliesEWMA(t *testing.T) {
+	values := []float64{200, 210, 190, 205, 195, 200, 420, 430, 205}
+	var series []SeriesPoint
+	for _, v := range values {
+		series = append(series, SeriesPoint{Value: v, Volume: 500})
+	}
+
+	anomalies := DetectAnomalies(series, AnomalyOptions{Method: AnomalyMethodEWMA, Threshold: 3, MinHistory: 5, Alpha: 0.3})
+
+	// anomalies stay out of the average, so
+	// the second spike is caught as well
+	if len(anomalies) != 2 || anomalies[0].Index != 6 || anomalies[1].Index != 7 || anomalies[0].Score <= 0 {
+		t.Errorf("Expected spikes at index 6 & 7 to be anomalous, but got %+v", anomalies)
+	}
+}
+
+func TestAnomalyOptionsValidate(t *testing.T) {
+	if err := (AnomalyOptions{Method: AnomalyMethodEWMA, Threshold: 3, MinHistory: 5, Alpha: 0.3}).Validate(); err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+
+	invalid := map[string]AnomalyOptions{
+		"unknown method": {Method: "stddev", Threshold: 3, MinHistory: 5},
+		"no threshold":   {Method: AnomalyMethodMAD, MinHistory: 5},
+		"no history":     {Method: AnomalyMethodMAD, Threshold: 3},
+		"alpha of 1":     {Method: AnomalyMethodEWMA, Threshold: 3, MinHistory: 5, Alpha: 1},
+	}
+
+	for name, o := range invalid {
+		if err := o.Validate(); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+}
+
+func TestMannWhitneyTest(t *testing.T) {
+	base := []float64{110, 120, 95, 130, 105, 115, 125, 100, 118, 108, 112, 122}
+	slower := []float64{150, 160, 140, 170, 155, 145, 165, 138, 158, 149, 152, 162}
+
+	p, superiority := MannWhitneyTest(base, slower)
+	if p >= SignificanceLevel {
+		t.Errorf("Expected significant p-value, but got %v", p)
+	}
+
+	if superiority != 1 {
+		t.Errorf("Expected all target values to be greater, but got %v", superiority)
+	}
+
+	// the test is one-sided, faster
+	// targets are not significant
+	if p, _ := MannWhitneyTest(slower, base); p < 0.5 {
+		t.Errorf("Expected insignificant p-value, but got %v", p)
+	}
+
+	p, superiority = MannWhitneyTest(base, base)
+	if p < SignificanceLevel {
+		t.Errorf("Expected insignificant p-value, but got %v", p)
+	}
+
+	if superiority != 0.5 {
+		t.Errorf("Expected %v, but got %v", 0.5, superiority)
+	}
+
+	if p, _ := MannWhitneyTest([]float64{100, 100}, []float64{100, 100}); p != 1 {
+		t.Errorf("Expected %v for all ties, but got %v", 1, p)
+	}
+
+	if p, superiority := MannWhitneyTest(nil, slower); p != 1 || superiority != 0.5 {
+		t.Errorf("Expected 1 & 0.5, but got %v & %v", p, superiority)
+	}
+}
@@ -0,0 +1,170 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+)
+
+// SignificanceLevel is the p-value below which
+// a difference is considered statistically
+// significant.
+const SignificanceLevel = 0.05
+
+// ProportionsTest computes the two-sided p-value of
+// a pooled two-proportion z-test between x1 out of n1
+// and x2 out of n2. Returns 1 if either sample is empty
+// or the pooled proportion leaves no variance, as there
+// is no evidence of a difference.
+func ProportionsTest(x1, n1, x2, n2 uint64) float64 {
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+
+	p1 := float64(x1) / float64(n1)
+	p2 := float64(x2) / float64(n2)
+	pooled := float64(x1+x2) / float64(n1+n2)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(n1) + 1/float64(n2)))
+
+	if se == 0 {
+		return 1
+	}
+
+	z := (p1 - p2) / se
+
+	return math.Erfc(math.Abs(z) / math.Sqrt2)
+}
+
+// HistogramsTest computes the p-value of a two-sample
+// Kolmogorov-Smirnov test between two histograms sharing
+// the same buckets, using the asymptotic distribution of
+// the statistic. Returns 1 if either histogram is empty.
+func HistogramsTest(c1, c2 []uint64) float64 {
+	var n1, n2 uint64
+	for _, c := range c1 {
+		n1 += c
+	}
+	for _, c := range c2 {
+		n2 += c
+	}
+
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+
+	var cum1, cum2 uint64
+	var d float64
+	for i := 0; i < max(len(c1), len(c2)); i++ {
+		if i < len(c1) {
+			cum1 += c1[i]
+		}
+		if i < len(c2) {
+			cum2 += c2[i]
+		}
+		d = max(d, math.Abs(float64(cum1)/float64(n1)-float64(cum2)/float64(n2)))
+	}
+
+	ne := float64(n1) * float64(n2) / float64(n1+n2)
+	lambda := (math.Sqrt(ne) + 0.12 + 0.11/math.Sqrt(ne)) * d
+
+	if lambda == 0 {
+		return 1
+	}
+
+	var p float64
+	sign := 1.0
+	for k := 1; k <= 100; k++ {
+		term := sign * 2 * math.Exp(-2*float64(k*k)*lambda*lambda)
+		p += term
+		if math.Abs(term) < 1e-10 {
+			break
+		}
+		sign = -sign
+	}
+
+	return math.Min(math.Max(p, 0), 1)
+}
+
+// MannWhitneyTest computes the one-sided p-value of a
+// Mann-Whitney U test that values of the target sample
+// tend to be greater than values of the base sample,
+// using the normal approximation corrected for ties &
+// continuity. Also returns the probability that a target
+// value is greater than a base value, counting ties as
+// half. Returns a p-value of 1 & a probability of 0.5 if
+// either sample is empty.
+func MannWhitneyTest(base, target []float64) (p, superiority float64) {
+	n1, n2 := float64(len(base)), float64(len(target))
+	if n1 == 0 || n2 == 0 {
+		return 1, 0.5
+	}
+
+	type value struct {
+		v      float64
+		target bool
+	}
+
+	values := make([]value, 0, len(base)+len(target))
+	for _, v := range base {
+		values = append(values, value{v: v})
+	}
+	for _, v := range target {
+		values = append(values, value{v: v, target: true})
+	}
+
+	sort.Slice(values, func(i, j int) bool {
+		return values[i].v < values[j].v
+	})
+
+	// tied values share the average
+	// of the ranks they span
+	var rankSum, ties float64
+	for i := 0; i < len(values); {
+		j := i
+		for j < len(values) && values[j].v == values[i].v {
+			j++
+		}
+
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if values[k].target {
+				rankSum += rank
+			}
+		}
+
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	u := rankSum - n2*(n2+1)/2
+	superiority = u / (n1 * n2)
+
+	n := n1 + n2
+	variance := n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		return 1, superiority
+	}
+
+	z := (u - n1*n2/2 - 0.5) / math.Sqrt(variance)
+	p = 0.5 * math.Erfc(z/math.Sqrt2)
+
+	return
+}
+
+// HistogramCounts aligns a histogram to all launch
+// histogram bounds, returning the count of each bucket.
+func HistogramCounts(histogram []HistogramBucket) (counts []uint64) {
+	counts = make([]uint64, len(LaunchHistogramBounds))
+	lut := make(map[uint32]int, len(LaunchHistogramBounds))
+	for i, bound := range LaunchHistogramBounds {
+		lut[bound] = i
+	}
+
+	for _, bucket := range histogram {
+		if i, ok := lut[bucket.Start]; ok {
+			counts[i] += bucket.Count
+		}
+	}
+
+	return
+}
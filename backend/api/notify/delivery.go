@@ -0,0 +1,384 @@
+package notify
+
+import (
+	"backend/api/chrono"
+	"backend/api/server"
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/leporo/sqlf"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusSending   = "sending"
+	StatusDelivered = "delivered"
+	StatusFailed    = "failed"
+)
+
+// maxAttempts is the maximum count of delivery
+// attempts after which a delivery is marked failed.
+const maxAttempts = 6
+
+// maxBackoff is the maximum delay between
+// delivery attempts.
+const maxBackoff = time.Hour
+
+// dispatchBatchSize is the maximum count of
+// notifications delivered in a single dispatch.
+const dispatchBatchSize = 50
+
+// sendingTimeout is the duration after which
+// deliveries stuck sending are claimed again. It
+// is twice the longest a dispatch can take, so
+// that deliveries still waiting on a slow batch
+// are never claimed & sent twice.
+const sendingTimeout = 2 * dispatchBatchSize * timeout
+
+// Delivery represents an attempt to deliver
+// a notification to a channel.
+type Delivery struct {
+	ID             uuid.UUID       `json:"id" db:"id"`
+	ChannelID      uuid.UUID       `json:"channel_id" db:"channel_id"`
+	AlertType      string          `json:"alert_type" db:"alert_type"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	ResponseStatus *int            `json:"response_status" db:"response_status"`
+	LastError      *string         `json:"last_error" db:"last_error"`
+	NextAttemptAt  chrono.ISOTime  `json:"next_attempt_at" db:"next_attempt_at"`
+	DeliveredAt    *chrono.ISOTime `json:"delivered_at" db:"delivered_at"`
+	CreatedAt      chrono.ISOTime  `json:"created_at" db:"created_at"`
+}
+
+// Enqueue queues delivery of an alert to every
+// channel of the app subscribed to the alert type.
+func Enqueue(ctx context.Context, appID uuid.UUID, alertType string, payload any) (err error) {
+	stmt, err := enqueueStmt(appID, alertType, payload)
+	if err != nil {
+		return
+	}
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// EnqueueTx queues delivery of an alert to every
+// channel of the app subscribed to the alert type
+// within the transaction, so that it is only
+// delivered if the transaction commits.
+func EnqueueTx(ctx context.Context, tx *pgx.Tx, appID uuid.UUID, alertType string, payload any) (err error) {
+	stmt, err := enqueueStmt(appID, alertType, payload)
+	if err != nil {
+		return
+	}
+
+	defer stmt.Close()
+
+	_, err = (*tx).Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// enqueueStmt builds the statement queueing delivery
+// of an alert to every channel of the app subscribed
+// to the alert type.
+func enqueueStmt(appID uuid.UUID, alertType string, payload any) (stmt *sqlf.Stmt, err error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	stmt = sqlf.PostgreSQL.
+		New("insert into public.notification_deliveries (id, channel_id, alert_type, payload, next_attempt_at, created_at, updated_at) select gen_random_uuid(), id, ?, ?::jsonb, now(), now(), now() from public.notification_channels", alertType, string(data)).
+		Where("app_id = ?", appID).
+		Where("? = any(alert_types)", alertType)
+
+	return
+}
+
+// EnqueueChannel queues delivery of an alert
+// to a single channel.
+func EnqueueChannel(ctx context.Context, channelID uuid.UUID, alertType string, payload any) (err error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+
+	stmt := sqlf.PostgreSQL.InsertInto("public.notification_deliveries").
+		Set("id", uuid.New()).
+		Set("channel_id", channelID).
+		Set("alert_type", alertType).
+		Set("payload", string(data)).
+		Set("next_attempt_at", now).
+		Set("created_at", now).
+		Set("updated_at", now)
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// Deliver delivers a notification to the channel right
+// away & records the delivery. Failed deliveries are not
+// retried.
+func Deliver(ctx context.Context, ch Channel, alertType string, payload any) (delivery Delivery, err error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return
+	}
+
+	n := Notification{
+		ID:        uuid.New(),
+		AlertType: alertType,
+		Payload:   data,
+		CreatedAt: time.Now(),
+	}
+
+	stmt := sqlf.PostgreSQL.InsertInto("public.notification_deliveries").
+		Set("id", n.ID).
+		Set("channel_id", ch.ID).
+		Set("alert_type", alertType).
+		Set("payload", string(data)).
+		Set("status", StatusSending).
+		Set("next_attempt_at", n.CreatedAt).
+		Set("created_at", n.CreatedAt).
+		Set("updated_at", n.CreatedAt)
+
+	defer stmt.Close()
+
+	if _, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...); err != nil {
+		return
+	}
+
+	status, sendErr := Send(ctx, ch, n, server.Server.Config.SiteOrigin)
+	if sendErr != nil {
+		err = fail(ctx, n.ID, maxAttempts-1, status, sendErr)
+	} else {
+		err = markDelivered(ctx, n.ID, status)
+	}
+
+	if err != nil {
+		return
+	}
+
+	return getDelivery(ctx, n.ID)
+}
+
+// GetDeliveries fetches the most recent
+// deliveries to a channel.
+func GetDeliveries(ctx context.Context, channelID uuid.UUID, limit int) (deliveries []Delivery, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.notification_deliveries").
+		Select("id").
+		Select("channel_id").
+		Select("alert_type").
+		Select("status").
+		Select("attempts").
+		Select("response_status").
+		Select("last_error").
+		Select("next_attempt_at").
+		Select("delivered_at").
+		Select("created_at").
+		Where("channel_id = ?", channelID).
+		OrderBy("created_at desc").
+		Limit(limit)
+
+	defer stmt.Close()
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	deliveries, err = pgx.CollectRows(rows, pgx.RowToStructByName[Delivery])
+
+	return
+}
+
+// getDelivery fetches a delivery by id.
+func getDelivery(ctx context.Context, id uuid.UUID) (delivery Delivery, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.notification_deliveries").
+		Select("id").
+		Select("channel_id").
+		Select("alert_type").
+		Select("status").
+		Select("attempts").
+		Select("response_status").
+		Select("last_error").
+		Select("next_attempt_at").
+		Select("delivered_at").
+		Select("created_at").
+		Where("id = ?", id)
+
+	defer stmt.Close()
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+
+	return pgx.CollectExactlyOneRow(rows, pgx.RowToStructByName[Delivery])
+}
+
+// RunDispatcher delivers queued notifications
+// every interval until the context is done.
+func RunDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := dispatch(ctx); err != nil {
+				fmt.Println("failed to dispatch notifications", err)
+			}
+		}
+	}
+}
+
+// claimed is a delivery claimed for
+// dispatch with its channel.
+type claimed struct {
+	channel      Channel
+	notification Notification
+	attempts     int
+}
+
+// dispatch claims due deliveries & delivers them.
+func dispatch(ctx context.Context) (err error) {
+	deliveries, err := claim(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, d := range deliveries {
+		status, sendErr := Send(ctx, d.channel, d.notification, server.Server.Config.SiteOrigin)
+		if sendErr != nil {
+			if err = fail(ctx, d.notification.ID, d.attempts, status, sendErr); err != nil {
+				return
+			}
+			continue
+		}
+
+		if err = markDelivered(ctx, d.notification.ID, status); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// claim marks due deliveries as sending & returns
+// them. Rows locked by other dispatchers are skipped.
+func claim(ctx context.Context) (deliveries []claimed, err error) {
+	now := time.Now()
+
+	stmt := sqlf.PostgreSQL.
+		New("update public.notification_deliveries as d set status = ?, updated_at = ? from public.notification_channels as c", StatusSending, now).
+		Where("c.id = d.channel_id").
+		Where("d.id in (select id from public.notification_deliveries where (status = ? and next_attempt_at <= ?) or (status = ? and updated_at < ?) order by next_attempt_at limit ? for update skip locked)", StatusPending, now, StatusSending, now.Add(-sendingTimeout), dispatchBatchSize).
+		Returning("d.id").
+		Returning("d.alert_type").
+		Returning("d.payload").
+		Returning("d.attempts").
+		Returning("d.created_at").
+		Returning("c.id").
+		Returning("c.type").
+		Returning("c.url").
+		Returning("coalesce(c.secret, '')")
+
+	defer stmt.Close()
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var d claimed
+		if err = rows.Scan(&d.notification.ID, &d.notification.AlertType, &d.notification.Payload, &d.attempts, &d.notification.CreatedAt, &d.channel.ID, &d.channel.Type, &d.channel.URL, &d.channel.Secret); err != nil {
+			return
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// backoff computes the delay before the next
+// delivery attempt, doubling with every attempt.
+func backoff(attempts int) time.Duration {
+	delay := 30 * time.Second
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+
+	return min(delay, maxBackoff)
+}
+
+// fail records a failed delivery attempt. The delivery
+// is retried with backoff until it runs out of attempts.
+func fail(ctx context.Context, id uuid.UUID, attempts, status int, cause error) (err error) {
+	attempts++
+	next := StatusPending
+	if attempts >= maxAttempts {
+		next = StatusFailed
+	}
+
+	now := time.Now()
+
+	var responseStatus *int
+	if status != 0 {
+		responseStatus = &status
+	}
+
+	stmt := sqlf.PostgreSQL.Update("public.notification_deliveries").
+		Set("status", next).
+		Set("attempts", attempts).
+		Set("response_status", responseStatus).
+		Set("last_error", cause.Error()).
+		Set("next_attempt_at", now.Add(backoff(attempts))).
+		Set("updated_at", now).
+		Where("id = ?", id)
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// markDelivered records a successful delivery.
+func markDelivered(ctx context.Context, id uuid.UUID, status int) (err error) {
+	now := time.Now()
+
+	stmt := sqlf.PostgreSQL.Update("public.notification_deliveries").
+		Set("status", StatusDelivered).
+		Set("response_status", status).
+		Set("delivered_at", now).
+		Set("updated_at", now).
+		Where("id = ?", id)
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestBackoff(t *testing.T) {
+	expected := map[int]time.Duration{
+		1:  30 * time.Second,
+		2:  time.Minute,
+		5:  8 * time.Minute,
+		20: maxBackoff,
+	}
+
+	for attempts, delay := range expected {
+		if actual := backoff(attempts); actual != delay {
+			t.Errorf("Expected backoff of %v after %d attempts, but got %v", delay, attempts, actual)
+		}
+	}
+}
+
+func TestSendingTimeout(t *testing.T) {
+	// a claimed batch must be done sending before
+	// its deliveries can be claimed again
+	if longest := time.Duration(dispatchBatchSize) * timeout; sendingTimeout <= longest {
+		t.Errorf("Expected sending timeout to exceed %v, but got %v", longest, sendingTimeout)
+	}
+}
+
+func TestEnqueueStmt(t *testing.T) {
+	appID := uuid.New()
+
+	stmt, err := enqueueStmt(appID, AlertTypeTest, map[string]string{"message": "hello"})
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+	defer stmt.Close()
+
+	if !strings.HasPrefix(stmt.String(), "insert into public.notification_deliveries") {
+		t.Errorf("Unexpected statement %s", stmt.String())
+	}
+
+	args := stmt.Args()
+	if len(args) != 4 || args[0] != AlertTypeTest || args[1] != `{"message":"hello"}` || args[2] != appID || args[3] != AlertTypeTest {
+		t.Errorf("Unexpected args %v", args)
+	}
+
+	if _, err := enqueueStmt(appID, AlertTypeTest, make(chan int)); err == nil {
+		t.Errorf("Expected error for payload that can't be encoded")
+	}
+}
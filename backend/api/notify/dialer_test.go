@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	expected := map[string]bool{
+		"93.184.215.14":          true,
+		"2606:2800:21f:cb07::1":  true,
+		"127.0.0.1":              false,
+		"10.1.2.3":               false,
+		"172.16.0.1":             false,
+		"192.168.1.10":           false,
+		"169.254.169.254":        false,
+		"100.100.100.200":        false,
+		"0.0.0.0":                false,
+		"::1":                    false,
+		"fe80::1":                false,
+		"fd00:ec2::254":          false,
+		"::ffff:127.0.0.1":       false,
+		"::ffff:169.254.169.254": false,
+		"64:ff9b::a00:1":         false,
+	}
+
+	for ip, public := range expected {
+		if actual := IsPublicAddr(netip.MustParseAddr(ip)); actual != public {
+			t.Errorf("Expected %q to be public %v, but got %v", ip, public, actual)
+		}
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	if err := dialControl("tcp4", "93.184.215.14:443", nil); err != nil {
+		t.Errorf("Expected no error, but got %v", err)
+	}
+
+	for _, address := range []string{"127.0.0.1:443", "[::1]:443", "169.254.169.254:80"} {
+		if err := dialControl("tcp", address, nil); err != ErrNonPublicAddress {
+			t.Errorf("Expected %v for %q, but got %v", ErrNonPublicAddress, address, err)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ChannelWebhook = "webhook"
+	ChannelSlack   = "slack"
+)
+
+// AlertTypeTest is the alert type of
+// test notifications.
+const AlertTypeTest = "test"
+
+// AlertTypeDigest is the alert type of
+// scheduled digests.
+const AlertTypeDigest = "digest"
+
+// timeout is the maximum duration to wait
+// for a channel to respond.
+const timeout = 10 * time.Second
+
+var client = &http.Client{
+	Timeout:   timeout,
+	Transport: newTransport(),
+}
+
+// Channel represents the destination
+// of a notification.
+type Channel struct {
+	ID     uuid.UUID
+	Type   string
+	URL    string
+	Secret string
+}
+
+// Notification represents an alert
+// delivered to a channel.
+type Notification struct {
+	ID        uuid.UUID
+	AlertType string
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+// summary contains the fields common to
+// all alert payloads.
+type summary struct {
+	TeamID  uuid.UUID `json:"team_id"`
+	AppID   uuid.UUID `json:"app_id"`
+	AppName string    `json:"app_name"`
+	Message string    `json:"message"`
+	// Path is the path of the dashboard page
+	// relevant to the alert, relative to the
+	// team. Defaults to the overview.
+	Path string `json:"path"`
+}
+
+// webhookBody is the JSON body posted
+// to webhook channels.
+type webhookBody struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	Message   string          `json:"message"`
+	URL       string          `json:"url"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Sign computes the signature of a webhook body sent
+// at the timestamp. Receivers verify webhooks by comparing
+// it with the X-Measure-Signature header.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send delivers the notification to the channel & returns
+// the http status code the channel responded with.
+func Send(ctx context.Context, ch Channel, n Notification, siteOrigin string) (status int, err error) {
+	body, err := format(ch.Type, n, siteOrigin)
+	if err != nil {
+		return
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ch.URL, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Measure-Notifications/1.0")
+
+	if ch.Type == ChannelWebhook {
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set("X-Measure-Delivery", n.ID.String())
+		req.Header.Set("X-Measure-Event", n.AlertType)
+		req.Header.Set("X-Measure-Timestamp", timestamp)
+		req.Header.Set("X-Measure-Signature", Sign(ch.Secret, timestamp, body))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	// drain to reuse the connection
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	status = resp.StatusCode
+
+	if status < 200 || status > 299 {
+		err = fmt.Errorf("channel responded with status %d", status)
+	}
+
+	return
+}
+
+// format renders the body of the notification
+// for the channel type.
+func format(channelType string, n Notification, siteOrigin string) (body []byte, err error) {
+	var s summary
+	if err = json.Unmarshal(n.Payload, &s); err != nil {
+		return
+	}
+
+	path := s.Path
+	if path == "" {
+		path = "overview"
+	}
+
+	url := fmt.Sprintf("%s/%s/%s", siteOrigin, s.TeamID, path)
+
+	switch channelType {
+	case ChannelWebhook:
+		return json.Marshal(webhookBody{
+			ID:        n.ID,
+			Type:      n.AlertType,
+			Message:   s.Message,
+			URL:       url,
+			CreatedAt: n.CreatedAt,
+			Data:      n.Payload,
+		})
+	case ChannelSlack:
+		return json.Marshal(slackMessage(s, url))
+	}
+
+	return nil, fmt.Errorf("unknown channel type %q", channelType)
+}
+
+// slackMessage formats the notification for
+// Slack incoming webhooks.
+func slackMessage(s summary, url string) map[string]any {
+	text := fmt.Sprintf("*%s*\n%s", slackEscape(s.AppName), slackEscape(s.Message))
+
+	return map[string]any{
+		"text": fmt.Sprintf("%s: %s", s.AppName, s.Message),
+		"blocks": []map[string]any{
+			{
+				"type": "section",
+				"text": map[string]any{
+					"type": "mrkdwn",
+					"text": text,
+				},
+			},
+			{
+				"type": "actions",
+				"elements": []map[string]any{
+					{
+						"type": "button",
+						"text": map[string]any{
+							"type": "plain_text",
+							"text": "Open dashboard",
+						},
+						"url": url,
+					},
+				},
+			},
+		},
+	}
+}
+
+// slackEscape escapes control characters
+// of Slack's mrkdwn.
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
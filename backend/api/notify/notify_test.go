@@ -0,0 +1,215 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func newNotification(t *testing.T, teamID uuid.UUID) Notification {
+	payload, err := json.Marshal(map[string]any{
+		"team_id":  teamID,
+		"app_id":   uuid.New(),
+		"app_name": "Shop <Android>",
+		"message":  "Crash rate spiked to 3.00% from a baseline of 1.00%",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Notification{
+		ID:        uuid.New(),
+		AlertType: "crash_rate_spike",
+		Payload:   payload,
+		CreatedAt: time.Date(2024, 12, 23, 10, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+
+	expected := "sha256=086f6aff7bd084c98679825129c5a64dbad88c760016d6d2c0fb123f27951d54"
+	actual := Sign("secret", "1700000000", body)
+
+	if actual != expected {
+		t.Errorf("Expected signature %q, but got %q", expected, actual)
+	}
+
+	if actual == Sign("other", "1700000000", body) {
+		t.Errorf("Expected signature to depend on the secret")
+	}
+
+	if actual == Sign("secret", "1700000001", body) {
+		t.Errorf("Expected signature to depend on the timestamp")
+	}
+}
+
+func TestFormatWebhook(t *testing.T) {
+	teamID := uuid.New()
+	n := newNotification(t, teamID)
+
+	body, err := format(ChannelWebhook, n, "https://measure.sh")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	var actual webhookBody
+	if err := json.Unmarshal(body, &actual); err != nil {
+		t.Fatalf("Expected JSON body, but got %v", err)
+	}
+
+	if actual.ID != n.ID || actual.Type != n.AlertType {
+		t.Errorf("Expected id %v & type %q, but got %v & %q", n.ID, n.AlertType, actual.ID, actual.Type)
+	}
+
+	if expected := "https://measure.sh/" + teamID.String() + "/overview"; actual.URL != expected {
+		t.Errorf("Expected url %q, but got %q", expected, actual.URL)
+	}
+
+	if actual.Message != "Crash rate spiked to 3.00% from a baseline of 1.00%" {
+		t.Errorf("Unexpected message %q", actual.Message)
+	}
+}
+
+func TestFormatSlack(t *testing.T) {
+	n := newNotification(t, uuid.New())
+
+	body, err := format(ChannelSlack, n, "https://measure.sh")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	var actual struct {
+		Text   string `json:"text"`
+		Blocks []struct {
+			Type string `json:"type"`
+			Text struct {
+				Text string `json:"text"`
+			} `json:"text"`
+		} `json:"blocks"`
+	}
+	if err := json.Unmarshal(body, &actual); err != nil {
+		t.Fatalf("Expected JSON body, but got %v", err)
+	}
+
+	if len(actual.Blocks) != 2 || actual.Blocks[0].Type != "section" {
+		t.Fatalf("Expected section & actions blocks, but got %s", body)
+	}
+
+	if !strings.HasPrefix(actual.Blocks[0].Text.Text, "*Shop &lt;Android&gt;*") {
+		t.Errorf("Expected escaped app name, but got %q", actual.Blocks[0].Text.Text)
+	}
+
+	if _, err := format("email", n, "https://measure.sh"); err == nil {
+		t.Errorf("Expected error for unknown channel type")
+	}
+}
+
+// allowLoopback lets notifications reach test
+// servers listening on loopback.
+func allowLoopback(t *testing.T) {
+	original := client
+	client = &http.Client{Timeout: timeout}
+	t.Cleanup(func() {
+		client = original
+	})
+}
+
+func TestSend(t *testing.T) {
+	allowLoopback(t)
+
+	var header http.Header
+	var body []byte
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	ch := Channel{
+		ID:     uuid.New(),
+		Type:   ChannelWebhook,
+		URL:    srv.URL,
+		Secret: "secret",
+	}
+	n := newNotification(t, uuid.New())
+
+	status, err := Send(context.Background(), ch, n, "https://measure.sh")
+	if err != nil {
+		t.Fatalf("Expected no error, but got %v", err)
+	}
+
+	if status != http.StatusNoContent {
+		t.Errorf("Expected status %d, but got %d", http.StatusNoContent, status)
+	}
+
+	if header.Get("X-Measure-Delivery") != n.ID.String() {
+		t.Errorf("Unexpected delivery header %q", header.Get("X-Measure-Delivery"))
+	}
+
+	if header.Get("X-Measure-Event") != n.AlertType {
+		t.Errorf("Unexpected event header %q", header.Get("X-Measure-Event"))
+	}
+
+	expected := Sign(ch.Secret, header.Get("X-Measure-Timestamp"), body)
+	if header.Get("X-Measure-Signature") != expected {
+		t.Errorf("Expected signature %q, but got %q", expected, header.Get("X-Measure-Signature"))
+	}
+}
+
+func TestSendFailure(t *testing.T) {
+	allowLoopback(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	ch := Channel{
+		ID:   uuid.New(),
+		Type: ChannelSlack,
+		URL:  srv.URL,
+	}
+
+	status, err := Send(context.Background(), ch, newNotification(t, uuid.New()), "https://measure.sh")
+	if err == nil {
+		t.Errorf("Expected error for non 2xx status")
+	}
+
+	if status != http.StatusBadGateway {
+		t.Errorf("Expected status %d, but got %d", http.StatusBadGateway, status)
+	}
+}
+
+func TestSendNonPublic(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	ch := Channel{
+		ID:   uuid.New(),
+		Type: ChannelWebhook,
+		URL:  srv.URL,
+	}
+
+	_, err := Send(context.Background(), ch, newNotification(t, uuid.New()), "https://measure.sh")
+	if !errors.Is(err, ErrNonPublicAddress) {
+		t.Errorf("Expected %v, but got %v", ErrNonPublicAddress, err)
+	}
+
+	if called {
+		t.Errorf("Expected loopback server to not be reached")
+	}
+}
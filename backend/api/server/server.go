@@ -60,6 +60,11 @@ type ServerConfig struct {
 	OAuthGoogleKey             string
 	AccessTokenSecret          []byte
 	RefreshTokenSecret         []byte
+	SMTPHost                   string
+	SMTPPort                   int
+	SMTPUsername               string
+	SMTPPassword               string
+	EmailFrom                  string
 	OtelServiceName            string
 }
 
@@ -150,6 +155,25 @@ func NewConfig() *ServerConfig {
 		log.Println("SESSION_REFRESH_SECRET env var is not set, dashboard authn won't work")
 	}
 
+	smtpHost := os.Getenv("SMTP_HOST")
+	if smtpHost == "" {
+		log.Println("SMTP_HOST env var is not set, emails won't be sent")
+	}
+
+	smtpPort, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+	if err != nil {
+		log.Println("using default value of SMTP_PORT")
+		smtpPort = 587
+	}
+
+	smtpUsername := os.Getenv("SMTP_USERNAME")
+	smtpPassword := os.Getenv("SMTP_PASSWORD")
+
+	emailFrom := os.Getenv("EMAIL_FROM")
+	if emailFrom == "" {
+		log.Println("EMAIL_FROM env var is not set, emails won't be sent")
+	}
+
 	postgresDSN := os.Getenv("POSTGRES_DSN")
 	if postgresDSN == "" {
 		log.Fatal("POSTGRES_DSN env var is not set, cannot start server")
@@ -192,6 +216,11 @@ func NewConfig() *ServerConfig {
 		OAuthGoogleKey:             oauthGoogleKey,
 		AccessTokenSecret:          []byte(atSecret),
 		RefreshTokenSecret:         []byte(rtSecret),
+		SMTPHost:                   smtpHost,
+		SMTPPort:                   smtpPort,
+		SMTPUsername:               smtpUsername,
+		SMTPPassword:               smtpPassword,
+		EmailFrom:                  emailFrom,
 		OtelServiceName:            otelServiceName,
 	}
 }
@@ -1,6 +1,7 @@
 package span
 
 import (
+	"backend/api/event"
 	"backend/api/filter"
 	"backend/api/platform"
 	"backend/api/server"
@@ -68,6 +69,11 @@ const (
 	maxDeviceLocaleChars       = 64
 )
 
+// maxMetricsBreakdownValues is the maximum count of
+// user defined attribute values span metrics are
+// broken down by.
+const maxMetricsBreakdownValues = 50
+
 type CheckPointField struct {
 	Name      string    `json:"name" binding:"required"`
 	Timestamp time.Time `json:"timestamp" binding:"required"`
@@ -108,6 +114,9 @@ type SpanField struct {
 	EndTime     time.Time         `json:"end_time" binding:"required"`
 	CheckPoints []CheckPointField `json:"checkpoints"`
 	Attributes  SpanAttributes    `json:"attributes"`
+	// UserDefinedAttribute is optional, as
+	// older SDKs never send it.
+	UserDefinedAttribute event.UDAttribute `json:"user_defined_attribute"`
 }
 
 type RootSpanDisplay struct {
@@ -128,17 +137,18 @@ type RootSpanDisplay struct {
 }
 
 type SpanDisplay struct {
-	SpanName                 string            `json:"span_name" binding:"required"`
-	SpanID                   string            `json:"span_id" binding:"required"`
-	ParentID                 string            `json:"parent_id" binding:"required"`
-	Status                   uint8             `json:"status" binding:"required"`
-	StartTime                time.Time         `json:"start_time" binding:"required"`
-	EndTime                  time.Time         `json:"end_time" binding:"required"`
-	Duration                 time.Duration     `json:"duration" binding:"required"`
-	ThreadName               string            `json:"thread_name"`
-	LowPowerModeEnabled      bool              `json:"device_low_power_mode"`
-	ThermalThrottlingEnabled bool              `json:"device_thermal_throttling_enabled"`
-	CheckPoints              []CheckPointField `json:"checkpoints"`
+	SpanName                 string             `json:"span_name" binding:"required"`
+	SpanID                   string             `json:"span_id" binding:"required"`
+	ParentID                 string             `json:"parent_id" binding:"required"`
+	Status                   uint8              `json:"status" binding:"required"`
+	StartTime                time.Time          `json:"start_time" binding:"required"`
+	EndTime                  time.Time          `json:"end_time" binding:"required"`
+	Duration                 time.Duration      `json:"duration" binding:"required"`
+	ThreadName               string             `json:"thread_name"`
+	LowPowerModeEnabled      bool               `json:"device_low_power_mode"`
+	ThermalThrottlingEnabled bool               `json:"device_thermal_throttling_enabled"`
+	CheckPoints              []CheckPointField  `json:"checkpoints"`
+	UDAttribute              *event.UDAttribute `json:"user_defined_attribute"`
 }
 
 type TraceDisplay struct {
@@ -157,6 +167,19 @@ type TraceDisplay struct {
 	Spans              []SpanDisplay `json:"spans"  binding:"required"`
 }
 
+// SpanMetricsBreakdown represents duration metrics
+// of a span having a user defined attribute value.
+type SpanMetricsBreakdown struct {
+	Value string  `json:"value"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+	// Spans is the count of spans the
+	// quantiles are computed from.
+	Spans uint64 `json:"spans"`
+}
+
 type SpanMetricsPlotInstance struct {
 	Version  string   `json:"version"`
 	DateTime string   `json:"datetime"`
@@ -164,6 +187,9 @@ type SpanMetricsPlotInstance struct {
 	P90      *float64 `json:"p90"`
 	P95      *float64 `json:"p95"`
 	P99      *float64 `json:"p99"`
+	// Spans is the count of spans the
+	// quantiles are computed from.
+	Spans uint64 `json:"spans"`
 }
 
 // Validate validates the span for data
@@ -320,6 +346,14 @@ func (s *SpanField) Validate() error {
 		return fmt.Errorf(`%q exceeds maximum allowed characters of %d`, `device_locale`, maxDeviceLocaleChars)
 	}
 
+	// only process user defined attributes
+	// if the span contains any.
+	if !s.UserDefinedAttribute.Empty() {
+		if err := s.UserDefinedAttribute.Validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -435,6 +469,8 @@ func GetSpanInstancesWithFilter(ctx context.Context, spanName string, af *filter
 		stmt.Where("attribute.device_name in ?", af.DeviceNames)
 	}
 
+	FilterUDExpression(stmt, af)
+
 	defer stmt.Close()
 
 	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
@@ -485,6 +521,7 @@ func GetSpanMetricsPlotWithFilter(ctx context.Context, spanName string, af *filt
 		Select("round(quantileMerge(0.90)(p90), 2) as p90").
 		Select("round(quantileMerge(0.95)(p95), 2) as p95").
 		Select("round(quantileMerge(0.99)(p99), 2) as p99").
+		Select("count() as spans").
 		Clause("prewhere app_id = toUUID(?) and span_name = ? and timestamp >= ? and timestamp <= ?", af.AppID, spanName, af.From, af.To).
 		Where("status").In(af.SpanStatuses)
 
@@ -534,6 +571,8 @@ func GetSpanMetricsPlotWithFilter(ctx context.Context, spanName string, af *filt
 		stmt.Where("device_name in ?", af.DeviceNames)
 	}
 
+	FilterUDExpression(stmt, af)
+
 	stmt.GroupBy("app_version, datetime")
 	stmt.OrderBy("datetime, tupleElement(app_version, 2) desc")
 
@@ -546,7 +585,7 @@ func GetSpanMetricsPlotWithFilter(ctx context.Context, spanName string, af *filt
 
 	for rows.Next() {
 		var spanMetricsPlotInstance SpanMetricsPlotInstance
-		if err = rows.Scan(&spanMetricsPlotInstance.Version, &spanMetricsPlotInstance.DateTime, &spanMetricsPlotInstance.P50, &spanMetricsPlotInstance.P90, &spanMetricsPlotInstance.P95, &spanMetricsPlotInstance.P99); err != nil {
+		if err = rows.Scan(&spanMetricsPlotInstance.Version, &spanMetricsPlotInstance.DateTime, &spanMetricsPlotInstance.P50, &spanMetricsPlotInstance.P90, &spanMetricsPlotInstance.P95, &spanMetricsPlotInstance.P99, &spanMetricsPlotInstance.Spans); err != nil {
 			return
 		}
 
@@ -558,6 +597,118 @@ func GetSpanMetricsPlotWithFilter(ctx context.Context, spanName string, af *filt
 	return
 }
 
+// GetSpanMetricsBreakdownWithFilter provides p50, p90, p95 and p99 duration
+// metrics for the given span broken down by values of a user defined attribute
+// key, with the applied filtering criteria. Only the most frequent values are
+// kept.
+func GetSpanMetricsBreakdownWithFilter(ctx context.Context, spanName, key string, af *filter.AppFilter) (breakdowns []SpanMetricsBreakdown, err error) {
+	duration := "dateDiff('millisecond', start_time, end_time)"
+
+	stmt := sqlf.From("spans").
+		Select("tupleElement(user_defined_attribute[?], 2) as value", key).
+		Select(fmt.Sprintf("round(quantile(0.50)(%s), 2) as p50", duration)).
+		Select(fmt.Sprintf("round(quantile(0.90)(%s), 2) as p90", duration)).
+		Select(fmt.Sprintf("round(quantile(0.95)(%s), 2) as p95", duration)).
+		Select(fmt.Sprintf("round(quantile(0.99)(%s), 2) as p99", duration)).
+		Select("count() as spans").
+		Clause("prewhere app_id = toUUID(?) and span_name = ? and start_time >= ? and end_time <= ?", af.AppID, spanName, af.From, af.To).
+		Where("mapContains(user_defined_attribute, ?)", key)
+
+	defer stmt.Close()
+
+	if len(af.SpanStatuses) > 0 {
+		stmt.Where("status").In(af.SpanStatuses)
+	}
+
+	if af.HasVersions() {
+		selectedVersions, err := af.VersionPairs()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Where("attribute.app_version in (?)", selectedVersions.Parameterize())
+	}
+
+	if af.HasOSVersions() {
+		selectedOSVersions, err := af.OSVersionPairs()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Where("attribute.os_version in (?)", selectedOSVersions.Parameterize())
+	}
+
+	if af.HasCountries() {
+		stmt.Where("attribute.country_code in ?", af.Countries)
+	}
+
+	if af.HasNetworkProviders() {
+		stmt.Where("attribute.network_provider in ?", af.NetworkProviders)
+	}
+
+	if af.HasNetworkTypes() {
+		stmt.Where("attribute.network_type in ?", af.NetworkTypes)
+	}
+
+	if af.HasNetworkGenerations() {
+		stmt.Where("attribute.network_generation in ?", af.NetworkGenerations)
+	}
+
+	if af.HasDeviceLocales() {
+		stmt.Where("attribute.device_locale in ?", af.Locales)
+	}
+
+	if af.HasDeviceManufacturers() {
+		stmt.Where("attribute.device_manufacturer in ?", af.DeviceManufacturers)
+	}
+
+	if af.HasDeviceNames() {
+		stmt.Where("attribute.device_name in ?", af.DeviceNames)
+	}
+
+	FilterUDExpression(stmt, af)
+
+	stmt.GroupBy("value")
+	stmt.OrderBy("spans desc, value")
+	stmt.Limit(maxMetricsBreakdownValues)
+
+	rows, err := server.Server.ChPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var breakdown SpanMetricsBreakdown
+		if err = rows.Scan(&breakdown.Value, &breakdown.P50, &breakdown.P90, &breakdown.P95, &breakdown.P99, &breakdown.Spans); err != nil {
+			return
+		}
+
+		breakdowns = append(breakdowns, breakdown)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// FilterUDExpression narrows down the statement to spans
+// matching the filter's user defined attribute expression,
+// if any. The statement must select from a table having
+// a `span_id` column.
+func FilterUDExpression(stmt *sqlf.Stmt, af *filter.AppFilter) {
+	if !af.HasUDExpression() || af.UDExpression.Empty() {
+		return
+	}
+
+	subQuery := sqlf.From("span_user_def_attrs").
+		Select("span_id").
+		Where("app_id = toUUID(?)", af.AppID)
+	af.UDExpression.Augment(subQuery)
+
+	defer subQuery.Close()
+
+	stmt.Where(fmt.Sprintf("span_id in (%s)", subQuery.String()), subQuery.Args()...)
+}
+
 // GetTrace constructs and returns a trace for
 // a given traceId
 func GetTrace(ctx context.Context, traceId string) (trace TraceDisplay, err error) {
@@ -583,6 +734,7 @@ func GetTrace(ctx context.Context, traceId string) (trace TraceDisplay, err erro
 		Select("toString(attribute.thread_name)").
 		Select("attribute.device_low_power_mode").
 		Select("attribute.device_thermal_throttling_enabled").
+		Select("user_defined_attribute").
 		From("spans").
 		Where("trace_id = ?", traceId).
 		OrderBy("start_time desc")
@@ -598,9 +750,10 @@ func GetTrace(ctx context.Context, traceId string) (trace TraceDisplay, err erro
 
 	for rows.Next() {
 		var rawCheckpoints [][]interface{}
+		var userDefAttr map[string][]any
 		span := SpanField{}
 
-		if err = rows.Scan(&span.AppID, &span.TraceID, &span.SessionID, &span.Attributes.UserID, &span.SpanID, &span.SpanName, &span.ParentID, &span.StartTime, &span.EndTime, &span.Status, &rawCheckpoints, &span.Attributes.AppVersion, &span.Attributes.AppBuild, &span.Attributes.OSName, &span.Attributes.OSVersion, &span.Attributes.DeviceManufacturer, &span.Attributes.DeviceModel, &span.Attributes.NetworkType, &span.Attributes.ThreadName, &span.Attributes.LowPowerModeEnabled, &span.Attributes.ThermalThrottlingEnabled); err != nil {
+		if err = rows.Scan(&span.AppID, &span.TraceID, &span.SessionID, &span.Attributes.UserID, &span.SpanID, &span.SpanName, &span.ParentID, &span.StartTime, &span.EndTime, &span.Status, &rawCheckpoints, &span.Attributes.AppVersion, &span.Attributes.AppBuild, &span.Attributes.OSName, &span.Attributes.OSVersion, &span.Attributes.DeviceManufacturer, &span.Attributes.DeviceModel, &span.Attributes.NetworkType, &span.Attributes.ThreadName, &span.Attributes.LowPowerModeEnabled, &span.Attributes.ThermalThrottlingEnabled, &userDefAttr); err != nil {
 			fmt.Println(err)
 			return
 		}
@@ -620,6 +773,11 @@ func GetTrace(ctx context.Context, traceId string) (trace TraceDisplay, err erro
 			})
 		}
 
+		// populate user defined attribute
+		if len(userDefAttr) > 0 {
+			span.UserDefinedAttribute.Scan(userDefAttr)
+		}
+
 		spans = append(spans, span)
 	}
 
@@ -644,6 +802,11 @@ func GetTrace(ctx context.Context, traceId string) (trace TraceDisplay, err erro
 			span.Attributes.LowPowerModeEnabled,
 			span.Attributes.ThermalThrottlingEnabled,
 			span.CheckPoints,
+			nil,
+		}
+
+		if !span.UserDefinedAttribute.Empty() {
+			spanDisplay.UDAttribute = &span.UserDefinedAttribute
 		}
 
 		spanDisplays = append(spanDisplays, spanDisplay)
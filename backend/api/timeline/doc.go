@@ -28,6 +28,8 @@ import {
 	"backend/api/event"
 	"backend/api/timeline"
 	"fmt"
+
+	"github.com/google/uuid"
 }
 
 func main() {
@@ -43,8 +45,14 @@ func main() {
 		clickEvents = append(clickEvents, click)
 	}
 
+	// detect rage & dead clicks
+	frustrated := make(map[uuid.UUID]event.FrustratedClick)
+	for _, click := range event.DetectFrustratedClicks(clickEvents) {
+		frustrated[click.ID] = click
+	}
+
 	// perform compute
-	gestureClicks := timeline.ComputeGestureClicks(clickEvents)
+	gestureClicks := timeline.ComputeGestureClicks(clickEvents, frustrated)
 
 	// organize events by thread
 	gcThreads := timeline.GroupByThreads(gestureClicks)
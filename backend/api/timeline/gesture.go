@@ -3,6 +3,8 @@ package timeline
 import (
 	"backend/api/event"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // GestureClick represents click events suitable
@@ -19,6 +21,8 @@ type GestureClick struct {
 	Y           float32            `json:"y"`
 	Timestamp   time.Time          `json:"timestamp"`
 	Attachments []event.Attachment `json:"attachments"`
+	Rage        bool               `json:"rage"`
+	Dead        bool               `json:"dead"`
 }
 
 // GetThreadName provides the name of the thread
@@ -89,9 +93,11 @@ func (gs GestureScroll) GetTimestamp() time.Time {
 }
 
 // ComputeGestureClicks computes click gestures
-// for session timeline.
-func ComputeGestureClicks(events []event.EventField) (result []ThreadGrouper) {
+// for session timeline marking the frustrated
+// clicks.
+func ComputeGestureClicks(events []event.EventField, frustrated map[uuid.UUID]event.FrustratedClick) (result []ThreadGrouper) {
 	for _, event := range events {
+		frustration := frustrated[event.ID]
 		gestureClicks := GestureClick{
 			event.Type,
 			&event.UserDefinedAttribute,
@@ -104,6 +110,8 @@ func ComputeGestureClicks(events []event.EventField) (result []ThreadGrouper) {
 			event.GestureClick.Y,
 			event.Timestamp,
 			event.Attachments,
+			frustration.Rage,
+			frustration.Dead,
 		}
 		result = append(result, gestureClicks)
 	}
@@ -0,0 +1,612 @@
+package alerts
+
+import (
+	"backend/cleanup/server"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/leporo/sqlf"
+)
+
+const (
+	TypeCrashRateSpike  = "crash_rate_spike"
+	TypeAnrRateSpike    = "anr_rate_spike"
+	TypeLaunchTimeSpike = "launch_time_spike"
+)
+
+const (
+	incidentOpen         = "open"
+	incidentAcknowledged = "acknowledged"
+	incidentResolved     = "resolved"
+)
+
+// evaluationWindow is the sliding window of recent
+// activity that is compared against the baseline.
+const evaluationWindow = time.Hour
+
+// baselineWindow is the window of activity right
+// before the evaluation window that serves as the
+// baseline.
+const baselineWindow = 7 * 24 * time.Hour
+
+// rollupInterval is the interval app & launch
+// metrics are pre-aggregated to. Windows are
+// aligned to it.
+const rollupInterval = 15 * time.Minute
+
+// cooldown is the duration after an alert within
+// which the same alert is not fired again for the
+// same app.
+const cooldown = 24 * time.Hour
+
+// spikeFactor is the minimum ratio of a metric's
+// value over its baseline to be considered a spike.
+const spikeFactor = 1.5
+
+// minSessions is the minimum count of sessions in
+// the evaluation window for crash & ANR rates to be
+// evaluated.
+const minSessions = 100
+
+// minLaunches is the minimum count of cold launches
+// in the evaluation window for launch time to be
+// evaluated.
+const minLaunches = 50
+
+// minRateDelta is the minimum increase in percentage
+// points of crash & ANR rates to be considered a spike.
+const minRateDelta = 0.5
+
+// minLaunchDelta is the minimum increase in milliseconds
+// of p95 cold launch time to be considered a spike.
+const minLaunchDelta = 200
+
+// Metrics represents the metrics of an app evaluated
+// for spikes over a window of time.
+type Metrics struct {
+	Sessions      uint64  `json:"sessions"`
+	CrashSessions uint64  `json:"crash_sessions"`
+	ANRSessions   uint64  `json:"anr_sessions"`
+	Launches      uint64  `json:"launches"`
+	LaunchP95     float64 `json:"launch_p95"`
+}
+
+// CrashRate computes the percentage of sessions
+// having at least one crash.
+func (m Metrics) CrashRate() float64 {
+	if m.Sessions == 0 {
+		return 0
+	}
+	return float64(m.CrashSessions) / float64(m.Sessions) * 100
+}
+
+// ANRRate computes the percentage of sessions
+// having at least one ANR.
+func (m Metrics) ANRRate() float64 {
+	if m.Sessions == 0 {
+		return 0
+	}
+	return float64(m.ANRSessions) / float64(m.Sessions) * 100
+}
+
+// Evidence represents the computed values that
+// fired an alert.
+type Evidence struct {
+	Value         float64   `json:"value"`
+	Baseline      float64   `json:"baseline"`
+	Factor        float64   `json:"factor"`
+	Current       Metrics   `json:"current"`
+	Previous      Metrics   `json:"previous"`
+	BaselineStart time.Time `json:"baseline_start"`
+	BaselineEnd   time.Time `json:"baseline_end"`
+}
+
+// Alert represents a fired alert for an app.
+type Alert struct {
+	ID          uuid.UUID
+	AppID       uuid.UUID
+	IncidentID  uuid.UUID
+	Type        string
+	Message     string
+	Evidence    Evidence
+	WindowStart time.Time
+	WindowEnd   time.Time
+	CreatedAt   time.Time
+}
+
+// alertPrefColumns maps alert types to the
+// alert preference of emailing them.
+var alertPrefColumns = map[string]string{
+	TypeCrashRateSpike:  "crash_rate_spike_email",
+	TypeAnrRateSpike:    "anr_rate_spike_email",
+	TypeLaunchTimeSpike: "launch_time_spike_email",
+}
+
+// alertPayload is the data alert emails &
+// notifications are rendered from by the
+// dispatchers.
+type alertPayload struct {
+	TeamID      uuid.UUID `json:"team_id"`
+	AppID       uuid.UUID `json:"app_id"`
+	AppName     string    `json:"app_name"`
+	Type        string    `json:"type"`
+	Message     string    `json:"message"`
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+}
+
+type app struct {
+	id     uuid.UUID
+	teamID uuid.UUID
+	name   string
+}
+
+type recipient struct {
+	userID uuid.UUID
+	email  string
+}
+
+// EvaluateAlerts evaluates crash rate, ANR rate &
+// launch time of every onboarded app against their
+// baselines and records alerts for spikes.
+func EvaluateAlerts(ctx context.Context) {
+	apps, err := getApps(ctx)
+	if err != nil {
+		fmt.Printf("Failed to fetch apps for alert evaluation: %v\n", err)
+		return
+	}
+
+	windowEnd := time.Now().UTC().Truncate(rollupInterval)
+	windowStart := windowEnd.Add(-evaluationWindow)
+	baselineStart := windowStart.Add(-baselineWindow)
+
+	for _, a := range apps {
+		current, err := getMetrics(ctx, a.id, windowStart, windowEnd)
+		if err != nil {
+			fmt.Printf("Failed to compute metrics for app_id: %v, err: %v\n", a.id, err)
+			continue
+		}
+
+		previous, err := getMetrics(ctx, a.id, baselineStart, windowStart)
+		if err != nil {
+			fmt.Printf("Failed to compute baseline metrics for app_id: %v, err: %v\n", a.id, err)
+			continue
+		}
+
+		alerts := detectSpikes(current, previous)
+
+		for _, alertType := range recovered(current, alerts) {
+			if err := resolveIncidents(ctx, a.id, alertType, windowEnd); err != nil {
+				fmt.Printf("Failed to resolve %v incidents for app_id: %v, err: %v\n", alertType, a.id, err)
+			}
+		}
+
+		for _, alert := range alerts {
+			alert.ID = uuid.New()
+			alert.IncidentID = uuid.New()
+			alert.AppID = a.id
+			alert.Message = fmt.Sprintf("%s for %s", alert.Message, a.name)
+			alert.Evidence.BaselineStart = baselineStart
+			alert.Evidence.BaselineEnd = windowStart
+			alert.WindowStart = windowStart
+			alert.WindowEnd = windowEnd
+			alert.CreatedAt = time.Now().UTC()
+
+			// skip alerts still cooling down
+			recent, err := hasRecentAlert(ctx, a.id, alert.Type, alert.CreatedAt.Add(-cooldown))
+			if err != nil {
+				fmt.Printf("Failed to check recent %v alerts for app_id: %v, err: %v\n", alert.Type, a.id, err)
+				continue
+			}
+
+			if recent {
+				continue
+			}
+
+			muted, err := isMuted(ctx, a.id, alert.Type, alert.CreatedAt)
+			if err != nil {
+				fmt.Printf("Failed to check mutes of %v alerts for app_id: %v, err: %v\n", alert.Type, a.id, err)
+				continue
+			}
+
+			opened, err := alert.open(ctx, muted)
+			if err != nil {
+				fmt.Printf("Failed to record %v alert for app_id: %v, err: %v\n", alert.Type, a.id, err)
+				continue
+			}
+
+			// an incident of the alert type
+			// is still open or acknowledged
+			if !opened {
+				continue
+			}
+
+			fmt.Printf("Fired %v alert for app_id: %v\n", alert.Type, a.id)
+
+			if muted {
+				continue
+			}
+
+			if err := alert.enqueueEmails(ctx, a); err != nil {
+				fmt.Printf("Failed to queue emails of %v alert for app_id: %v, err: %v\n", alert.Type, a.id, err)
+			}
+
+			if err := alert.enqueueNotifications(ctx, a); err != nil {
+				fmt.Printf("Failed to queue notifications of %v alert for app_id: %v, err: %v\n", alert.Type, a.id, err)
+			}
+		}
+	}
+}
+
+// detectSpikes compares metrics of the evaluation window
+// against the baseline and returns alerts for metrics
+// that spiked.
+func detectSpikes(current, previous Metrics) (alerts []Alert) {
+	if current.Sessions >= minSessions {
+		if evidence, ok := spike(current.CrashRate(), previous.CrashRate(), minRateDelta); ok {
+			alerts = append(alerts, Alert{
+				Type:     TypeCrashRateSpike,
+				Message:  fmt.Sprintf("Crash rate spiked to %.2f%% from a baseline of %.2f%%", evidence.Value, evidence.Baseline),
+				Evidence: evidence,
+			})
+		}
+
+		if evidence, ok := spike(current.ANRRate(), previous.ANRRate(), minRateDelta); ok {
+			alerts = append(alerts, Alert{
+				Type:     TypeAnrRateSpike,
+				Message:  fmt.Sprintf("ANR rate spiked to %.2f%% from a baseline of %.2f%%", evidence.Value, evidence.Baseline),
+				Evidence: evidence,
+			})
+		}
+	}
+
+	if current.Launches >= minLaunches {
+		if evidence, ok := spike(current.LaunchP95, previous.LaunchP95, minLaunchDelta); ok {
+			alerts = append(alerts, Alert{
+				Type:     TypeLaunchTimeSpike,
+				Message:  fmt.Sprintf("p95 cold launch time spiked to %.0fms from a baseline of %.0fms", evidence.Value, evidence.Baseline),
+				Evidence: evidence,
+			})
+		}
+	}
+
+	for i := range alerts {
+		alerts[i].Evidence.Current = current
+		alerts[i].Evidence.Previous = previous
+	}
+
+	return
+}
+
+// recovered returns the alert types whose metrics
+// had enough activity in the evaluation window to be
+// evaluated & did not spike.
+func recovered(current Metrics, alerts []Alert) (types []string) {
+	var evaluated []string
+
+	if current.Sessions >= minSessions {
+		evaluated = append(evaluated, TypeCrashRateSpike, TypeAnrRateSpike)
+	}
+
+	if current.Launches >= minLaunches {
+		evaluated = append(evaluated, TypeLaunchTimeSpike)
+	}
+
+	for _, alertType := range evaluated {
+		spiked := false
+		for _, alert := range alerts {
+			if alert.Type == alertType {
+				spiked = true
+				break
+			}
+		}
+
+		if !spiked {
+			types = append(types, alertType)
+		}
+	}
+
+	return
+}
+
+// spike checks if value spiked over the baseline
+// by at least spikeFactor & minDelta.
+func spike(value, baseline, minDelta float64) (evidence Evidence, ok bool) {
+	if value-baseline < minDelta {
+		return
+	}
+
+	// a zero baseline has no ratio, so the
+	// absolute delta alone decides
+	if baseline > 0 && value/baseline < spikeFactor {
+		return
+	}
+
+	evidence.Value = value
+	evidence.Baseline = baseline
+	if baseline > 0 {
+		evidence.Factor = value / baseline
+	}
+
+	return evidence, true
+}
+
+// getApps fetches all onboarded apps.
+func getApps(ctx context.Context) (apps []app, err error) {
+	stmt := sqlf.PostgreSQL.
+		From("public.apps").
+		Select("id").
+		Select("team_id").
+		Select("coalesce(app_name, '')").
+		Where("onboarded = ?", true)
+
+	defer stmt.Close()
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a app
+		if err = rows.Scan(&a.id, &a.teamID, &a.name); err != nil {
+			return
+		}
+		apps = append(apps, a)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// sessionMetricsStmt builds the statement computing
+// session, crash & ANR counts of an app within a window
+// of time from pre-aggregated app metrics.
+func sessionMetricsStmt(appID uuid.UUID, from, to time.Time) *sqlf.Stmt {
+	return sqlf.
+		From("default.app_metrics").
+		Select("uniqMerge(unique_sessions)").
+		Select("uniqMerge(crash_sessions)").
+		Select("uniqMerge(anr_sessions)").
+		Clause("prewhere app_id = toUUID(?) and timestamp >= ? and timestamp < ?", appID, from, to)
+}
+
+// launchMetricsStmt builds the statement computing
+// count & p95 duration of cold launches of an app
+// within a window of time from pre-aggregated launch
+// metrics.
+func launchMetricsStmt(appID uuid.UUID, from, to time.Time) *sqlf.Stmt {
+	return sqlf.
+		From("default.launch_metrics").
+		Select("sum(launches)").
+		Select("ifNotFinite(quantileMerge(0.95)(p95), 0)").
+		Clause("prewhere app_id = toUUID(?) and type = 'cold_launch' and timestamp >= ? and timestamp < ?", appID, from, to)
+}
+
+// getMetrics computes the metrics of an app
+// within a window of time. Bounds of the window
+// must be aligned to the rollup interval.
+func getMetrics(ctx context.Context, appID uuid.UUID, from, to time.Time) (metrics Metrics, err error) {
+	sessionStmt := sessionMetricsStmt(appID, from, to)
+
+	defer sessionStmt.Close()
+
+	if err = server.Server.ChPool.QueryRow(ctx, sessionStmt.String(), sessionStmt.Args()...).Scan(&metrics.Sessions, &metrics.CrashSessions, &metrics.ANRSessions); err != nil {
+		return
+	}
+
+	launchStmt := launchMetricsStmt(appID, from, to)
+
+	defer launchStmt.Close()
+
+	err = server.Server.ChPool.QueryRow(ctx, launchStmt.String(), launchStmt.Args()...).Scan(&metrics.Launches, &metrics.LaunchP95)
+
+	return
+}
+
+// hasRecentAlert checks if an alert of the type was
+// recorded for the app since the given time.
+func hasRecentAlert(ctx context.Context, appID uuid.UUID, alertType string, since time.Time) (recent bool, err error) {
+	stmt := sqlf.PostgreSQL.
+		Select("exists(select 1 from public.alerts where app_id = ? and type = ? and created_at >= ?)", appID, alertType, since)
+
+	defer stmt.Close()
+
+	err = server.Server.PgPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&recent)
+
+	return
+}
+
+// isMuted checks if alerts of the type are
+// muted for the app at the given time.
+func isMuted(ctx context.Context, appID uuid.UUID, alertType string, at time.Time) (muted bool, err error) {
+	stmt := sqlf.PostgreSQL.
+		Select("exists(select 1 from public.alert_mutes where app_id = ? and muted_until > ? and rule_id is null and (alert_type is null or alert_type = ?))", appID, at, alertType)
+
+	defer stmt.Close()
+
+	err = server.Server.PgPool.QueryRow(ctx, stmt.String(), stmt.Args()...).Scan(&muted)
+
+	return
+}
+
+// open records the alert along with the incident it
+// opens. Nothing is recorded if an incident of the
+// alert type is not resolved yet.
+func (a *Alert) open(ctx context.Context, muted bool) (opened bool, err error) {
+	tx, err := server.Server.PgPool.Begin(ctx)
+	if err != nil {
+		return
+	}
+
+	defer tx.Rollback(ctx)
+
+	incident := sqlf.PostgreSQL.InsertInto("public.alert_incidents").
+		Set("id", a.IncidentID).
+		Set("app_id", a.AppID).
+		Set("type", a.Type).
+		Set("status", incidentOpen).
+		Set("message", a.Message).
+		Set("snapshot", a.Evidence).
+		Set("muted", muted).
+		Set("opened_at", a.CreatedAt).
+		Set("updated_at", a.CreatedAt).
+		Clause("on conflict do nothing")
+
+	defer incident.Close()
+
+	result, err := tx.Exec(ctx, incident.String(), incident.Args()...)
+	if err != nil || result.RowsAffected() == 0 {
+		return
+	}
+
+	stmt := sqlf.PostgreSQL.InsertInto("public.alerts").
+		Set("id", a.ID).
+		Set("app_id", a.AppID).
+		Set("incident_id", a.IncidentID).
+		Set("type", a.Type).
+		Set("message", a.Message).
+		Set("evidence", a.Evidence).
+		Set("window_start", a.WindowStart).
+		Set("window_end", a.WindowEnd).
+		Set("created_at", a.CreatedAt)
+
+	defer stmt.Close()
+
+	if _, err = tx.Exec(ctx, stmt.String(), stmt.Args()...); err != nil {
+		return
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return
+	}
+
+	return true, nil
+}
+
+// resolveIncidents resolves the app's open &
+// acknowledged incidents of the alert type
+// after its metric recovered.
+func resolveIncidents(ctx context.Context, appID uuid.UUID, alertType string, at time.Time) (err error) {
+	stmt := sqlf.PostgreSQL.Update("public.alert_incidents").
+		Set("status", incidentResolved).
+		Set("resolved_at", at).
+		Set("updated_at", at).
+		Where("app_id = ?", appID).
+		Where("type = ?", alertType).
+		Where("rule_id is null").
+		Where("status in (?, ?)", incidentOpen, incidentAcknowledged)
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
+
+// getRecipients fetches members of the app's team
+// who haven't turned off emails of the alert type.
+func getRecipients(ctx context.Context, appID uuid.UUID, alertType string) (recipients []recipient, err error) {
+	column, ok := alertPrefColumns[alertType]
+	if !ok {
+		err = fmt.Errorf("unknown alert type %q", alertType)
+		return
+	}
+
+	stmt := sqlf.PostgreSQL.
+		From("public.apps").
+		Select("users.id").
+		Select("users.email").
+		Join("public.team_membership", "team_membership.team_id = apps.team_id").
+		Join("public.users", "users.id = team_membership.user_id").
+		LeftJoin("public.alert_prefs", "alert_prefs.app_id = apps.id and alert_prefs.user_id = users.id").
+		Where("apps.id = ?", appID).
+		Where(fmt.Sprintf("coalesce(alert_prefs.%s, true)", column))
+
+	defer stmt.Close()
+
+	rows, err := server.Server.PgPool.Query(ctx, stmt.String(), stmt.Args()...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r recipient
+		if err = rows.Scan(&r.userID, &r.email); err != nil {
+			return
+		}
+		recipients = append(recipients, r)
+	}
+
+	err = rows.Err()
+
+	return
+}
+
+// payload returns the data the alert's emails
+// & notifications are rendered from.
+func (a *Alert) payload(target app) alertPayload {
+	return alertPayload{
+		TeamID:      target.teamID,
+		AppID:       a.AppID,
+		AppName:     target.name,
+		Type:        a.Type,
+		Message:     a.Message,
+		WindowStart: a.WindowStart,
+		WindowEnd:   a.WindowEnd,
+	}
+}
+
+// enqueueEmails queues emails of the alert in the
+// outbox for every recipient.
+func (a *Alert) enqueueEmails(ctx context.Context, target app) (err error) {
+	recipients, err := getRecipients(ctx, a.AppID, a.Type)
+	if err != nil {
+		return
+	}
+
+	payload := a.payload(target)
+
+	now := time.Now()
+
+	for _, r := range recipients {
+		stmt := sqlf.PostgreSQL.InsertInto("public.email_outbox").
+			Set("id", uuid.New()).
+			Set("kind", "alert").
+			Set("recipient", r.email).
+			Set("user_id", r.userID).
+			Set("app_id", a.AppID).
+			Set("payload", payload).
+			Set("next_attempt_at", now).
+			Set("created_at", now).
+			Set("updated_at", now)
+
+		_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+		stmt.Close()
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// enqueueNotifications queues delivery of the alert to
+// every notification channel of the app subscribed to
+// the alert type.
+func (a *Alert) enqueueNotifications(ctx context.Context, target app) (err error) {
+	stmt := sqlf.PostgreSQL.New("insert into public.notification_deliveries (id, channel_id, alert_type, payload, next_attempt_at, created_at, updated_at) select gen_random_uuid(), id, ?, ?::jsonb, now(), now(), now() from public.notification_channels", a.Type, a.payload(target)).
+		Where("app_id = ?", a.AppID).
+		Where("? = any(alert_types)", a.Type)
+
+	defer stmt.Close()
+
+	_, err = server.Server.PgPool.Exec(ctx, stmt.String(), stmt.Args()...)
+
+	return
+}
@@ -0,0 +1,137 @@
+package alerts
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestDetectSpikes(t *testing.T) {
+	previous := Metrics{
+		Sessions:      10000,
+		CrashSessions: 100,
+		ANRSessions:   50,
+		Launches:      5000,
+		LaunchP95:     1200,
+	}
+
+	current := Metrics{
+		Sessions:      200,
+		CrashSessions: 6,
+		ANRSessions:   1,
+		Launches:      100,
+		LaunchP95:     2000,
+	}
+
+	alerts := detectSpikes(current, previous)
+
+	if len(alerts) != 2 {
+		t.Fatalf("Expected %d alerts, but got %d", 2, len(alerts))
+	}
+
+	if alerts[0].Type != TypeCrashRateSpike {
+		t.Errorf("Expected %q, but got %q", TypeCrashRateSpike, alerts[0].Type)
+	}
+
+	if alerts[0].Evidence.Value != 3 || alerts[0].Evidence.Baseline != 1 || alerts[0].Evidence.Factor != 3 {
+		t.Errorf("Expected value 3, baseline 1 & factor 3, but got %+v", alerts[0].Evidence)
+	}
+
+	if alerts[0].Evidence.Current != current || alerts[0].Evidence.Previous != previous {
+		t.Errorf("Expected evidence to carry window & baseline metrics, but got %+v", alerts[0].Evidence)
+	}
+
+	if alerts[1].Type != TypeLaunchTimeSpike {
+		t.Errorf("Expected %q, but got %q", TypeLaunchTimeSpike, alerts[1].Type)
+	}
+}
+
+func TestDetectSpikesMinimumVolume(t *testing.T) {
+	previous := Metrics{
+		Sessions:      10000,
+		CrashSessions: 100,
+		Launches:      5000,
+		LaunchP95:     1200,
+	}
+
+	current := Metrics{
+		Sessions:      minSessions - 1,
+		CrashSessions: 50,
+		Launches:      minLaunches - 1,
+		LaunchP95:     5000,
+	}
+
+	if alerts := detectSpikes(current, previous); len(alerts) != 0 {
+		t.Errorf("Expected no alerts below minimum volume, but got %d", len(alerts))
+	}
+}
+
+func TestRecovered(t *testing.T) {
+	current := Metrics{
+		Sessions: minSessions,
+		Launches: minLaunches - 1,
+	}
+
+	alerts := []Alert{{Type: TypeCrashRateSpike}}
+
+	types := recovered(current, alerts)
+
+	if len(types) != 1 || types[0] != TypeAnrRateSpike {
+		t.Errorf("Expected only %q to recover, but got %v", TypeAnrRateSpike, types)
+	}
+
+	if types := recovered(Metrics{}, nil); len(types) != 0 {
+		t.Errorf("Expected nothing to recover without activity, but got %v", types)
+	}
+}
+
+func TestSpike(t *testing.T) {
+	if _, ok := spike(1.4, 1, minRateDelta); ok {
+		t.Errorf("Expected no spike below minimum delta")
+	}
+
+	if _, ok := spike(10.5, 8, minRateDelta); ok {
+		t.Errorf("Expected no spike below spike factor")
+	}
+
+	evidence, ok := spike(0.8, 0, minRateDelta)
+	if !ok {
+		t.Fatalf("Expected spike over zero baseline")
+	}
+
+	if evidence.Factor != 0 {
+		t.Errorf("Expected factor 0 for zero baseline, but got %v", evidence.Factor)
+	}
+}
+
+func TestMetricsStmts(t *testing.T) {
+	appID := uuid.New()
+	to := time.Date(2024, 12, 20, 10, 0, 0, 0, time.UTC)
+	from := to.Add(-baselineWindow)
+
+	sessionStmt := sessionMetricsStmt(appID, from, to)
+	defer sessionStmt.Close()
+
+	for _, want := range []string{"FROM default.app_metrics", "uniqMerge(unique_sessions)", "uniqMerge(crash_sessions)", "uniqMerge(anr_sessions)"} {
+		if !strings.Contains(sessionStmt.String(), want) {
+			t.Errorf("Expected statement to contain %q, got %s", want, sessionStmt.String())
+		}
+	}
+
+	launchStmt := launchMetricsStmt(appID, from, to)
+	defer launchStmt.Close()
+
+	for _, want := range []string{"FROM default.launch_metrics", "sum(launches)", "quantileMerge(0.95)(p95)", "type = 'cold_launch'"} {
+		if !strings.Contains(launchStmt.String(), want) {
+			t.Errorf("Expected statement to contain %q, got %s", want, launchStmt.String())
+		}
+	}
+
+	for _, stmt := range []string{sessionStmt.String(), launchStmt.String()} {
+		if strings.Contains(stmt, "default.events") {
+			t.Errorf("Expected statement to read rollups only, got %s", stmt)
+		}
+	}
+}
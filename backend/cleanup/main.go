@@ -7,6 +7,7 @@ import (
 	"os"
 	"strings"
 
+	"backend/cleanup/alerts"
 	"backend/cleanup/cleanup"
 	"backend/cleanup/server"
 
@@ -66,6 +67,7 @@ func main() {
 func initCron(ctx context.Context) *cron.Cron {
 	cron := cron.New()
 	cron.AddFunc("@hourly", func() { cleanup.DeleteStaleData(ctx) })
+	cron.AddFunc("@every 15m", func() { alerts.EvaluateAlerts(ctx) })
 	cron.Start()
 	return cron
 }
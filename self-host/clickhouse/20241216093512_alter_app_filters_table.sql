@@ -0,0 +1,10 @@
+-- migrate:up
+alter table app_filters
+    add column if not exists `non_fatal` Bool not null default false comment 'true if source is handled exception event' codec(ZSTD(3)) after `anr`,
+    modify order by (app_id, end_of_month, app_version, os_version, country_code, network_provider, network_type, network_generation, device_locale, device_manufacturer, device_name, exception, anr, non_fatal);
+
+
+-- migrate:down
+alter table app_filters
+    modify order by (app_id, end_of_month, app_version, os_version, country_code, network_provider, network_type, network_generation, device_locale, device_manufacturer, device_name, exception, anr),
+    drop column if exists `non_fatal`;
@@ -0,0 +1,14 @@
+-- migrate:up
+alter table user_def_attrs
+    add column if not exists `non_fatal` Bool default false comment 'true if source is handled exception event' codec (ZSTD(3)) after `anr`,
+    add index if not exists non_fatal_bloom_idx non_fatal type bloom_filter granularity 2,
+    modify order by (app_id, end_of_month, app_version, os_version, exception, anr,
+            key, type, value, event_id, session_id, non_fatal);
+
+
+-- migrate:down
+alter table user_def_attrs
+    modify order by (app_id, end_of_month, app_version, os_version, exception, anr,
+            key, type, value, event_id, session_id),
+    drop index if exists non_fatal_bloom_idx,
+    drop column if exists `non_fatal`;
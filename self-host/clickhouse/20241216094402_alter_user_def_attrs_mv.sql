@@ -0,0 +1,52 @@
+-- migrate:up
+alter table user_def_attrs_mv modify query
+select distinct app_id,
+                id                                   as event_id,
+                session_id,
+                toLastDayOfMonth(timestamp)          as end_of_month,
+                (toString(attribute.app_version),
+                 toString(attribute.app_build))      as app_version,
+                (toString(attribute.os_name),
+                 toString(attribute.os_version))     as os_version,
+                if(events.type = 'exception' and exception.handled = false,
+                   true, false)                      as exception,
+                if(events.type = 'anr', true, false) as anr,
+                if(events.type = 'exception' and exception.handled = true,
+                   true, false)                      as non_fatal,
+                arr_key                              as key,
+                tupleElement(arr_val, 1)             as type,
+                tupleElement(arr_val, 2)             as value
+from events
+    array join
+     mapKeys(user_defined_attribute) as arr_key,
+     mapValues(user_defined_attribute) as arr_val
+where length(user_defined_attribute) > 0
+group by app_id, end_of_month, app_version, os_version, events.type,
+         exception.handled, key, type, value, event_id, session_id
+order by app_id;
+
+
+-- migrate:down
+alter table user_def_attrs_mv modify query
+select distinct app_id,
+                id                                   as event_id,
+                session_id,
+                toLastDayOfMonth(timestamp)          as end_of_month,
+                (toString(attribute.app_version),
+                 toString(attribute.app_build))      as app_version,
+                (toString(attribute.os_name),
+                 toString(attribute.os_version))     as os_version,
+                if(events.type = 'exception' and exception.handled = false,
+                   true, false)                      as exception,
+                if(events.type = 'anr', true, false) as anr,
+                arr_key                              as key,
+                tupleElement(arr_val, 1)             as type,
+                tupleElement(arr_val, 2)             as value
+from events
+    array join
+     mapKeys(user_defined_attribute) as arr_key,
+     mapValues(user_defined_attribute) as arr_val
+where length(user_defined_attribute) > 0
+group by app_id, end_of_month, app_version, os_version, events.type,
+         exception.handled, key, type, value, event_id, session_id
+order by app_id;
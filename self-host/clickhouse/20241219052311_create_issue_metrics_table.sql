@@ -0,0 +1,28 @@
+-- migrate:up
+create table if not exists issue_metrics
+(
+    `app_id`              UUID not null comment 'associated app id' codec(ZSTD(3)),
+    `type`                LowCardinality(FixedString(32)) not null comment 'type of the issue, either exception or anr' codec(ZSTD(3)),
+    `fingerprint`         FixedString(32) not null comment 'fingerprint of the issue' codec(ZSTD(3)),
+    `handled`             Bool not null comment 'true if the exception was handled by application code' codec(ZSTD(3)),
+    `timestamp`           DateTime64(3, 'UTC') not null comment 'interval metrics will be aggregated to' codec(DoubleDelta, ZSTD(3)),
+    `app_version`         Tuple(LowCardinality(String), LowCardinality(String)) not null comment 'composite app version' codec(ZSTD(3)),
+    `os_version`          Tuple(LowCardinality(String), LowCardinality(String)) comment 'composite os version' codec(ZSTD(3)),
+    `country_code`        LowCardinality(String) comment 'country code' codec(ZSTD(3)),
+    `network_provider`    LowCardinality(String) comment 'network provider' codec(ZSTD(3)),
+    `network_type`        LowCardinality(String) comment 'network type' codec(ZSTD(3)),
+    `network_generation`  LowCardinality(String) comment 'network generation' codec(ZSTD(3)),
+    `device_locale`       LowCardinality(String) comment 'device locale' codec(ZSTD(3)),
+    `device_manufacturer` LowCardinality(String) comment 'device manufacturer' codec(ZSTD(3)),
+    `device_name`         LowCardinality(String) comment 'device name' codec(ZSTD(3)),
+    `instances`           AggregateFunction(uniq, UUID) comment 'unique issue event ids' codec(ZSTD(3)),
+    `sessions`            AggregateFunction(uniq, UUID) comment 'unique session ids with the issue' codec(ZSTD(3))
+)
+engine = AggregatingMergeTree
+order by (app_id, type, handled, fingerprint, timestamp, app_version, os_version, country_code, network_provider, network_type, network_generation, device_locale, device_manufacturer, device_name)
+settings index_granularity = 8192
+comment 'aggregated issue metrics by fingerprint & a fixed time window';
+
+
+-- migrate:down
+drop table if exists issue_metrics;
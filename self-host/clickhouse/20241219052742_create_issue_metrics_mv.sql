@@ -0,0 +1,28 @@
+-- migrate:up
+create materialized view if not exists issue_metrics_mv to issue_metrics as
+select app_id,
+       type,
+       if(type = 'exception', exception.fingerprint, anr.fingerprint) as fingerprint,
+       if(type = 'exception', exception.handled, false)               as handled,
+       toStartOfFifteenMinutes(timestamp)                              as timestamp,
+       (toString(attribute.app_version),
+        toString(attribute.app_build))                                 as app_version,
+       (toString(attribute.os_name),
+        toString(attribute.os_version))                                as os_version,
+       toString(inet.country_code)                                     as country_code,
+       toString(attribute.network_provider)                            as network_provider,
+       toString(attribute.network_type)                                as network_type,
+       toString(attribute.network_generation)                          as network_generation,
+       toString(attribute.device_locale)                               as device_locale,
+       toString(attribute.device_manufacturer)                         as device_manufacturer,
+       toString(attribute.device_name)                                 as device_name,
+       uniqState(id)                                                   as instances,
+       uniqState(session_id)                                           as sessions
+from events
+where type in ('exception', 'anr')
+group by app_id, type, fingerprint, handled, timestamp, app_version, os_version, country_code, network_provider, network_type, network_generation, device_locale, device_manufacturer, device_name
+order by app_id, type, handled, fingerprint, timestamp;
+
+
+-- migrate:down
+drop view if exists issue_metrics_mv;
@@ -0,0 +1,12 @@
+-- migrate:up
+alter table events
+    add column if not exists `anr.fingerprint_version` UInt8 default 1 after `anr.fingerprint`,
+    add column if not exists `exception.fingerprint_version` UInt8 default 1 after `exception.fingerprint`,
+    comment column `anr.fingerprint_version` 'version of the fingerprint algorithm used to compute anr fingerprint',
+    comment column `exception.fingerprint_version` 'version of the fingerprint algorithm used to compute exception fingerprint';
+
+
+-- migrate:down
+alter table events
+  drop column if exists `anr.fingerprint_version`,
+  drop column if exists `exception.fingerprint_version`;
@@ -0,0 +1,9 @@
+-- migrate:up
+alter table events
+    add column if not exists `anr.cause` LowCardinality(FixedString(32)) after `anr.fingerprint_version`,
+    comment column `anr.cause` 'likely cause of anr classified from thread dump';
+
+
+-- migrate:down
+alter table events
+  drop column if exists `anr.cause`;
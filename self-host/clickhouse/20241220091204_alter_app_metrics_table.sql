@@ -0,0 +1,16 @@
+-- migrate:up
+alter table app_metrics
+    add column if not exists `unique_users` AggregateFunction(uniq, String) comment 'unique users in interval window' codec(ZSTD(3)) after `perceived_anr_sessions`,
+    add column if not exists `crash_users` AggregateFunction(uniq, String) comment 'crash users in interval window' codec(ZSTD(3)) after `unique_users`,
+    add column if not exists `perceived_crash_users` AggregateFunction(uniq, String) comment 'perceived crash users in interval window' codec(ZSTD(3)) after `crash_users`,
+    add column if not exists `anr_users` AggregateFunction(uniq, String) comment 'anr users in interval window' codec(ZSTD(3)) after `perceived_crash_users`,
+    add column if not exists `perceived_anr_users` AggregateFunction(uniq, String) comment 'perceived anr users in interval window' codec(ZSTD(3)) after `anr_users`;
+
+
+-- migrate:down
+alter table app_metrics
+    drop column if exists `unique_users`,
+    drop column if exists `crash_users`,
+    drop column if exists `perceived_crash_users`,
+    drop column if exists `anr_users`,
+    drop column if exists `perceived_anr_users`;
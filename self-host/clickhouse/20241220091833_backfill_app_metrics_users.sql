@@ -0,0 +1,41 @@
+-- migrate:up
+-- only user states are inserted, other aggregate
+-- states default to empty states & uniq states merge
+-- to the same result when events are aggregated more
+-- than once, so re-running this backfill is safe.
+insert into app_metrics (app_id, timestamp, app_version, unique_users, crash_users, perceived_crash_users, anr_users, perceived_anr_users)
+select app_id,
+       toStartOfFifteenMinutes(timestamp)                          as timestamp,
+       tuple(toString(attribute.app_version),
+             toString(attribute.app_build))                        as app_version,
+       uniqState(if(toStringCutToZero(attribute.user_id) != '',
+                    toStringCutToZero(attribute.user_id),
+                    toString(attribute.installation_id)))          as unique_users,
+       uniqStateIf(if(toStringCutToZero(attribute.user_id) != '',
+                      toStringCutToZero(attribute.user_id),
+                      toString(attribute.installation_id)),
+                   type = 'exception' and exception.handled =
+                                          false)                   as crash_users,
+       uniqStateIf(if(toStringCutToZero(attribute.user_id) != '',
+                      toStringCutToZero(attribute.user_id),
+                      toString(attribute.installation_id)),
+                   type = 'exception' and exception.handled = false and
+                   exception.foreground =
+                   true)                                           as perceived_crash_users,
+       uniqStateIf(if(toStringCutToZero(attribute.user_id) != '',
+                      toStringCutToZero(attribute.user_id),
+                      toString(attribute.installation_id)),
+                   type = 'anr')                                   as anr_users,
+       uniqStateIf(if(toStringCutToZero(attribute.user_id) != '',
+                      toStringCutToZero(attribute.user_id),
+                      toString(attribute.installation_id)),
+                   type = 'anr' and anr.foreground =
+                                    true)                          as perceived_anr_users
+from events
+group by app_id, timestamp, app_version;
+
+
+-- migrate:down
+-- user states cannot be separated from the rest
+-- of the aggregated states, so nothing to undo.
+select 1;
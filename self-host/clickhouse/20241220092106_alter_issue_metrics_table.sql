@@ -0,0 +1,8 @@
+-- migrate:up
+alter table issue_metrics
+    add column if not exists `users` AggregateFunction(uniq, String) comment 'unique users, or installations when user id is not set, with the issue' codec(ZSTD(3)) after `sessions`;
+
+
+-- migrate:down
+alter table issue_metrics
+    drop column if exists `users`;
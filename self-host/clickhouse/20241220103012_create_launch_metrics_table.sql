@@ -0,0 +1,33 @@
+-- migrate:up
+create table if not exists launch_metrics
+(
+    `app_id`              UUID not null comment 'associated app id' codec(ZSTD(3)),
+    `type`                LowCardinality(FixedString(32)) not null comment 'type of the launch, either cold_launch, warm_launch or hot_launch' codec(ZSTD(3)),
+    `lukewarm`            Bool not null comment 'true if warm launch was a lukewarm launch' codec(ZSTD(3)),
+    `timestamp`           DateTime64(3, 'UTC') not null comment 'interval metrics will be aggregated to' codec(DoubleDelta, ZSTD(3)),
+    `app_version`         Tuple(LowCardinality(String), LowCardinality(String)) not null comment 'composite app version' codec(ZSTD(3)),
+    `os_version`          Tuple(LowCardinality(String), LowCardinality(String)) comment 'composite os version' codec(ZSTD(3)),
+    `country_code`        LowCardinality(String) comment 'country code' codec(ZSTD(3)),
+    `network_provider`    LowCardinality(String) comment 'network provider' codec(ZSTD(3)),
+    `network_type`        LowCardinality(String) comment 'network type' codec(ZSTD(3)),
+    `network_generation`  LowCardinality(String) comment 'network generation' codec(ZSTD(3)),
+    `device_locale`       LowCardinality(String) comment 'device locale' codec(ZSTD(3)),
+    `device_manufacturer` LowCardinality(String) comment 'device manufacturer' codec(ZSTD(3)),
+    `device_name`         LowCardinality(String) comment 'device name' codec(ZSTD(3)),
+    `launched_activity`   LowCardinality(String) comment 'activity which drew the first frame during launch' codec(ZSTD(3)),
+    `launches`            SimpleAggregateFunction(sum, UInt64) comment 'count of launches' codec(ZSTD(3)),
+    `p50`                 AggregateFunction(quantile(0.50), UInt32) comment 'p50 quantile of launch duration' codec(ZSTD(3)),
+    `p75`                 AggregateFunction(quantile(0.75), UInt32) comment 'p75 quantile of launch duration' codec(ZSTD(3)),
+    `p90`                 AggregateFunction(quantile(0.90), UInt32) comment 'p90 quantile of launch duration' codec(ZSTD(3)),
+    `p95`                 AggregateFunction(quantile(0.95), UInt32) comment 'p95 quantile of launch duration' codec(ZSTD(3)),
+    `p99`                 AggregateFunction(quantile(0.99), UInt32) comment 'p99 quantile of launch duration' codec(ZSTD(3)),
+    `histogram`           AggregateFunction(sumMap, Array(UInt32), Array(UInt64)) comment 'count of launches by lower bound of duration bucket' codec(ZSTD(3))
+)
+engine = AggregatingMergeTree
+order by (app_id, type, lukewarm, timestamp, app_version, os_version, country_code, network_provider, network_type, network_generation, device_locale, device_manufacturer, device_name, launched_activity)
+settings index_granularity = 8192
+comment 'aggregated launch metrics by a fixed time window';
+
+
+-- migrate:down
+drop table if exists launch_metrics;
@@ -0,0 +1,43 @@
+-- migrate:up
+-- launch_metrics only tracks launches ingested after the
+-- view was created, so populate it from existing events.
+insert into launch_metrics
+with multiIf(type = 'cold_launch', cold_launch.duration,
+             type = 'warm_launch', warm_launch.duration,
+             hot_launch.duration) as duration
+select app_id,
+       type,
+       if(type = 'warm_launch', warm_launch.is_lukewarm, false)         as lukewarm,
+       toStartOfFifteenMinutes(timestamp)                               as timestamp,
+       (toString(attribute.app_version),
+        toString(attribute.app_build))                                  as app_version,
+       (toString(attribute.os_name),
+        toString(attribute.os_version))                                 as os_version,
+       toString(inet.country_code)                                      as country_code,
+       toString(attribute.network_provider)                             as network_provider,
+       toString(attribute.network_type)                                 as network_type,
+       toString(attribute.network_generation)                           as network_generation,
+       toString(attribute.device_locale)                                as device_locale,
+       toString(attribute.device_manufacturer)                          as device_manufacturer,
+       toString(attribute.device_name)                                  as device_name,
+       multiIf(type = 'cold_launch', toStringCutToZero(cold_launch.launched_activity),
+               type = 'warm_launch', toStringCutToZero(warm_launch.launched_activity),
+               toStringCutToZero(hot_launch.launched_activity))         as launched_activity,
+       toUInt64(count())                                                as launches,
+       quantileState(0.50)(duration)                                    as p50,
+       quantileState(0.75)(duration)                                    as p75,
+       quantileState(0.90)(duration)                                    as p90,
+       quantileState(0.95)(duration)                                    as p95,
+       quantileState(0.99)(duration)                                    as p99,
+       sumMapState([toUInt32(arrayLast(b -> b <= duration,
+                                       [0, 100, 200, 300, 400, 500, 600, 800, 1000, 1250, 1500, 2000, 2500, 3000, 4000, 5000, 7500, 10000, 15000, 20000, 30000]))],
+                   [toUInt64(1)])                                       as histogram
+from events
+where (type = 'cold_launch' and cold_launch.duration > 0 and cold_launch.duration <= 30000)
+   or (type = 'warm_launch' and warm_launch.duration > 0 and warm_launch.duration <= 10000)
+   or (type = 'hot_launch' and hot_launch.duration > 0)
+group by app_id, type, lukewarm, timestamp, app_version, os_version, country_code, network_provider, network_type, network_generation, device_locale, device_manufacturer, device_name, launched_activity;
+
+
+-- migrate:down
+truncate table if exists launch_metrics;
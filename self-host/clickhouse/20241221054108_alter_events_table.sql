@@ -0,0 +1,12 @@
+-- migrate:up
+-- default expression computes templates of http
+-- events ingested before the column existed. it
+-- must be kept in sync with `Http.URLTemplate`.
+alter table events
+    add column if not exists `http.url_template` String default concat(domain(`http.url`), arrayStringConcat(arrayMap(s -> multiIf(match(s, '^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$'), '{uuid}', match(s, '^[0-9]+$'), '{id}', length(s) >= 16 and match(s, '^[0-9a-fA-F]*[0-9][0-9a-fA-F]*$'), '{id}', s), splitByChar('/', path(`http.url`))), '/')) codec(ZSTD(3)) after `http.url`,
+    comment column `http.url_template` 'url normalized to a template identifying the endpoint';
+
+
+-- migrate:down
+alter table events
+  drop column if exists `http.url_template`;
@@ -0,0 +1,17 @@
+-- migrate:up
+create table if not exists user_first_seen
+(
+    `app_id`        UUID not null comment 'associated app id' codec(ZSTD(3)),
+    `kind`          LowCardinality(String) not null comment 'kind of identity, either installation or user' codec(ZSTD(3)),
+    `identity`      String not null comment 'installation id or user id falling back to installation id' codec(ZSTD(3)),
+    `first_seen`    SimpleAggregateFunction(min, DateTime64(3, 'UTC')) comment 'timestamp of the earliest event of the identity' codec(DoubleDelta, ZSTD(3)),
+    `first_version` AggregateFunction(argMin, Tuple(LowCardinality(String), LowCardinality(String)), DateTime64(3, 'UTC')) comment 'composite app version of the earliest event of the identity' codec(ZSTD(3))
+)
+engine = AggregatingMergeTree
+order by (app_id, kind, identity)
+settings index_granularity = 8192
+comment 'first seen timestamp & app version of installations & users';
+
+
+-- migrate:down
+drop table if exists user_first_seen;
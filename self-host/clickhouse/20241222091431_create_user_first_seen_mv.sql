@@ -0,0 +1,18 @@
+-- migrate:up
+create materialized view if not exists user_first_seen_mv to user_first_seen as
+select app_id,
+       kv.1                                                             as kind,
+       kv.2                                                             as identity,
+       min(timestamp)                                                   as first_seen,
+       argMinState((toString(attribute.app_version),
+                    toString(attribute.app_build)), timestamp)          as first_version
+from events
+array join [('installation', toString(attribute.installation_id)),
+            ('user', if(toStringCutToZero(attribute.user_id) != '',
+                        toStringCutToZero(attribute.user_id),
+                        toString(attribute.installation_id)))] as kv
+group by app_id, kind, identity;
+
+
+-- migrate:down
+drop view if exists user_first_seen_mv;
@@ -0,0 +1,21 @@
+-- migrate:up
+-- user_first_seen only tracks events ingested after
+-- the view was created, so populate it from existing
+-- events.
+insert into user_first_seen
+select app_id,
+       kv.1                                                             as kind,
+       kv.2                                                             as identity,
+       min(timestamp)                                                   as first_seen,
+       argMinState((toString(attribute.app_version),
+                    toString(attribute.app_build)), timestamp)          as first_version
+from events
+array join [('installation', toString(attribute.installation_id)),
+            ('user', if(toStringCutToZero(attribute.user_id) != '',
+                        toStringCutToZero(attribute.user_id),
+                        toString(attribute.installation_id)))] as kv
+group by app_id, kind, identity;
+
+
+-- migrate:down
+truncate table if exists user_first_seen;
@@ -0,0 +1,26 @@
+-- migrate:up
+create table if not exists user_activity
+(
+    `app_id`              UUID not null comment 'associated app id' codec(ZSTD(3)),
+    `kind`                LowCardinality(String) not null comment 'kind of identity, either installation or user' codec(ZSTD(3)),
+    `date`                Date not null comment 'day of activity' codec(DoubleDelta, ZSTD(3)),
+    `identity`            String not null comment 'installation id or user id falling back to installation id' codec(ZSTD(3)),
+    `app_version`         Tuple(LowCardinality(String), LowCardinality(String)) not null comment 'composite app version' codec(ZSTD(3)),
+    `os_version`          Tuple(LowCardinality(String), LowCardinality(String)) comment 'composite os version' codec(ZSTD(3)),
+    `country_code`        LowCardinality(String) comment 'country code' codec(ZSTD(3)),
+    `network_provider`    LowCardinality(String) comment 'network provider' codec(ZSTD(3)),
+    `network_type`        LowCardinality(String) comment 'network type' codec(ZSTD(3)),
+    `network_generation`  LowCardinality(String) comment 'network generation' codec(ZSTD(3)),
+    `device_locale`       LowCardinality(String) comment 'device locale' codec(ZSTD(3)),
+    `device_manufacturer` LowCardinality(String) comment 'device manufacturer' codec(ZSTD(3)),
+    `device_name`         LowCardinality(String) comment 'device name' codec(ZSTD(3)),
+    `events`              SimpleAggregateFunction(sum, UInt64) comment 'count of events' codec(ZSTD(3))
+)
+engine = AggregatingMergeTree
+order by (app_id, kind, date, identity, app_version, os_version, country_code, network_provider, network_type, network_generation, device_locale, device_manufacturer, device_name)
+settings index_granularity = 8192
+comment 'daily activity of installations & users';
+
+
+-- migrate:down
+drop table if exists user_activity;
@@ -0,0 +1,28 @@
+-- migrate:up
+create materialized view if not exists user_activity_mv to user_activity as
+select app_id,
+       kv.1                                                             as kind,
+       toDate(timestamp)                                                as date,
+       kv.2                                                             as identity,
+       (toString(attribute.app_version),
+        toString(attribute.app_build))                                  as app_version,
+       (toString(attribute.os_name),
+        toString(attribute.os_version))                                 as os_version,
+       toString(inet.country_code)                                      as country_code,
+       toString(attribute.network_provider)                             as network_provider,
+       toString(attribute.network_type)                                 as network_type,
+       toString(attribute.network_generation)                           as network_generation,
+       toString(attribute.device_locale)                                as device_locale,
+       toString(attribute.device_manufacturer)                          as device_manufacturer,
+       toString(attribute.device_name)                                  as device_name,
+       toUInt64(count())                                                as events
+from events
+array join [('installation', toString(attribute.installation_id)),
+            ('user', if(toStringCutToZero(attribute.user_id) != '',
+                        toStringCutToZero(attribute.user_id),
+                        toString(attribute.installation_id)))] as kv
+group by app_id, kind, date, identity, app_version, os_version, country_code, network_provider, network_type, network_generation, device_locale, device_manufacturer, device_name;
+
+
+-- migrate:down
+drop view if exists user_activity_mv;
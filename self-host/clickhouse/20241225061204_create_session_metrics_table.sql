@@ -0,0 +1,24 @@
+-- migrate:up
+create table if not exists session_metrics
+(
+    `app_id`              UUID not null comment 'associated app id' codec(ZSTD(3)),
+    `timestamp`           DateTime64(3, 'UTC') not null comment 'interval metrics will be aggregated to' codec(DoubleDelta, ZSTD(3)),
+    `app_version`         Tuple(LowCardinality(String), LowCardinality(String)) not null comment 'composite app version' codec(ZSTD(3)),
+    `os_version`          Tuple(LowCardinality(String), LowCardinality(String)) comment 'composite os version' codec(ZSTD(3)),
+    `country_code`        LowCardinality(String) comment 'country code' codec(ZSTD(3)),
+    `network_provider`    LowCardinality(String) comment 'network provider' codec(ZSTD(3)),
+    `network_type`        LowCardinality(String) comment 'network type' codec(ZSTD(3)),
+    `network_generation`  LowCardinality(String) comment 'network generation' codec(ZSTD(3)),
+    `device_locale`       LowCardinality(String) comment 'device locale' codec(ZSTD(3)),
+    `device_manufacturer` LowCardinality(String) comment 'device manufacturer' codec(ZSTD(3)),
+    `device_name`         LowCardinality(String) comment 'device name' codec(ZSTD(3)),
+    `sessions`            AggregateFunction(uniq, UUID) comment 'unique session ids with any event' codec(ZSTD(3))
+)
+engine = AggregatingMergeTree
+order by (app_id, timestamp, app_version, os_version, country_code, network_provider, network_type, network_generation, device_locale, device_manufacturer, device_name)
+settings index_granularity = 8192
+comment 'aggregated session counts by a fixed time window';
+
+
+-- migrate:down
+drop table if exists session_metrics;
@@ -0,0 +1,23 @@
+-- migrate:up
+create materialized view if not exists session_metrics_mv to session_metrics as
+select app_id,
+       toStartOfFifteenMinutes(timestamp)      as timestamp,
+       (toString(attribute.app_version),
+        toString(attribute.app_build))         as app_version,
+       (toString(attribute.os_name),
+        toString(attribute.os_version))        as os_version,
+       toString(inet.country_code)             as country_code,
+       toString(attribute.network_provider)    as network_provider,
+       toString(attribute.network_type)        as network_type,
+       toString(attribute.network_generation)  as network_generation,
+       toString(attribute.device_locale)       as device_locale,
+       toString(attribute.device_manufacturer) as device_manufacturer,
+       toString(attribute.device_name)         as device_name,
+       uniqState(session_id)                   as sessions
+from events
+group by app_id, timestamp, app_version, os_version, country_code, network_provider, network_type, network_generation, device_locale, device_manufacturer, device_name
+order by app_id, timestamp;
+
+
+-- migrate:down
+drop view if exists session_metrics_mv;
@@ -0,0 +1,25 @@
+-- migrate:up
+-- uniq states merge to the same result when events
+-- are aggregated more than once, so re-running this
+-- backfill does not inflate any counts.
+insert into session_metrics
+select app_id,
+       toStartOfFifteenMinutes(timestamp)      as timestamp,
+       (toString(attribute.app_version),
+        toString(attribute.app_build))         as app_version,
+       (toString(attribute.os_name),
+        toString(attribute.os_version))        as os_version,
+       toString(inet.country_code)             as country_code,
+       toString(attribute.network_provider)    as network_provider,
+       toString(attribute.network_type)        as network_type,
+       toString(attribute.network_generation)  as network_generation,
+       toString(attribute.device_locale)       as device_locale,
+       toString(attribute.device_manufacturer) as device_manufacturer,
+       toString(attribute.device_name)         as device_name,
+       uniqState(session_id)                   as sessions
+from events
+group by app_id, timestamp, app_version, os_version, country_code, network_provider, network_type, network_generation, device_locale, device_manufacturer, device_name;
+
+
+-- migrate:down
+truncate table if exists session_metrics;
@@ -218,6 +218,17 @@ OAUTH_GITHUB_SECRET=$OAUTH_GITHUB_SECRET
 SESSION_ACCESS_SECRET=super-secret-for-jwt-token-with-at-least-32-characters
 SESSION_REFRESH_SECRET=super-secret-for-jwt-token-with-at-least-32-characters
 
+#########
+# Email #
+#########
+
+# Emails won't be sent without these
+SMTP_HOST=
+SMTP_PORT=587
+SMTP_USERNAME=
+SMTP_PASSWORD=
+EMAIL_FROM=
+
 ########
 # OTEL #
 ########
@@ -332,6 +343,17 @@ OAUTH_GITHUB_SECRET=$OAUTH_GITHUB_SECRET
 SESSION_ACCESS_SECRET=$SESSION_ACCESS_SECRET
 SESSION_REFRESH_SECRET=$SESSION_REFRESH_SECRET
 
+#########
+# Email #
+#########
+
+# Emails won't be sent without these
+SMTP_HOST=
+SMTP_PORT=587
+SMTP_USERNAME=
+SMTP_PASSWORD=
+EMAIL_FROM=
+
 ########
 # OTEL #
 ########
@@ -0,0 +1,9 @@
+-- migrate:up
+alter table if exists public.unhandled_exception_groups
+  add column if not exists handled boolean not null default false;
+
+comment on column public.unhandled_exception_groups.handled is 'true if the group contains handled (non-fatal) exceptions';
+
+-- migrate:down
+alter table if exists public.unhandled_exception_groups
+  drop column if exists handled;
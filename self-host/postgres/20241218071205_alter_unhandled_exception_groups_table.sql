@@ -0,0 +1,9 @@
+-- migrate:up
+alter table if exists public.unhandled_exception_groups
+  add column if not exists merged_into uuid references public.unhandled_exception_groups(id) on delete set null;
+
+comment on column public.unhandled_exception_groups.merged_into is 'id of the exception group this group was merged into';
+
+-- migrate:down
+alter table if exists public.unhandled_exception_groups
+  drop column if exists merged_into;
@@ -0,0 +1,9 @@
+-- migrate:up
+alter table if exists public.anr_groups
+  add column if not exists merged_into uuid references public.anr_groups(id) on delete set null;
+
+comment on column public.anr_groups.merged_into is 'id of the ANR group this group was merged into';
+
+-- migrate:down
+alter table if exists public.anr_groups
+  drop column if exists merged_into;
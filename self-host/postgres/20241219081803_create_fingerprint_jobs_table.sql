@@ -0,0 +1,39 @@
+-- migrate:up
+create table if not exists public.fingerprint_jobs (
+    id uuid primary key not null,
+    app_id uuid not null references public.apps(id) on delete cascade,
+    fingerprint_version smallint not null,
+    from_timestamp timestamptz not null,
+    to_timestamp timestamptz not null,
+    dry_run boolean not null default true,
+    status varchar(16) not null default 'pending',
+    total_events int not null default 0,
+    processed_events int not null default 0,
+    changed_events int not null default 0,
+    diff jsonb,
+    error text,
+    created_by uuid references public.users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    finished_at timestamptz
+);
+
+comment on column public.fingerprint_jobs.id is 'unique id of the fingerprint backfill job';
+comment on column public.fingerprint_jobs.app_id is 'linked app id';
+comment on column public.fingerprint_jobs.fingerprint_version is 'version of the fingerprint algorithm to recompute fingerprints with';
+comment on column public.fingerprint_jobs.from_timestamp is 'utc timestamp of the start of the window of events to recompute';
+comment on column public.fingerprint_jobs.to_timestamp is 'utc timestamp of the end of the window of events to recompute';
+comment on column public.fingerprint_jobs.dry_run is 'true if the job only computes the diff without migrating any events or groups';
+comment on column public.fingerprint_jobs.status is 'status of the job, either pending, running, completed or failed';
+comment on column public.fingerprint_jobs.total_events is 'number of issue events in the window';
+comment on column public.fingerprint_jobs.processed_events is 'number of issue events processed so far';
+comment on column public.fingerprint_jobs.changed_events is 'number of issue events whose fingerprint changed';
+comment on column public.fingerprint_jobs.diff is 'changes in fingerprints and groups computed by the job';
+comment on column public.fingerprint_jobs.error is 'error message if the job failed';
+comment on column public.fingerprint_jobs.created_by is 'id of the user who triggered the job';
+comment on column public.fingerprint_jobs.created_at is 'utc timestamp at the time of record creation';
+comment on column public.fingerprint_jobs.updated_at is 'utc timestamp at the time of record update';
+comment on column public.fingerprint_jobs.finished_at is 'utc timestamp at the time of job completion or failure';
+
+-- migrate:down
+drop table if exists public.fingerprint_jobs;
@@ -0,0 +1,9 @@
+-- migrate:up
+alter table if exists public.anr_groups
+  add column if not exists cause varchar(32) not null default 'unknown';
+
+comment on column public.anr_groups.cause is 'likely cause of the ANR group classified from thread dump of its first ANR';
+
+-- migrate:down
+alter table if exists public.anr_groups
+  drop column if exists cause;
@@ -0,0 +1,26 @@
+-- migrate:up
+create table if not exists public.alerts (
+    id uuid primary key not null,
+    app_id uuid not null references public.apps(id) on delete cascade,
+    type varchar(64) not null,
+    message text not null,
+    evidence jsonb not null,
+    window_start timestamptz not null,
+    window_end timestamptz not null,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists alerts_app_id_type_created_at_idx on public.alerts (app_id, type, created_at desc);
+
+comment on column public.alerts.id is 'unique id of the alert';
+comment on column public.alerts.app_id is 'linked app id';
+comment on column public.alerts.type is 'type of the alert, like crash_rate_spike, anr_rate_spike or launch_time_spike';
+comment on column public.alerts.message is 'human readable summary of the alert';
+comment on column public.alerts.evidence is 'metric values of the evaluation window and the baseline that fired the alert';
+comment on column public.alerts.window_start is 'utc timestamp of the start of the evaluation window';
+comment on column public.alerts.window_end is 'utc timestamp of the end of the evaluation window';
+comment on column public.alerts.created_at is 'utc timestamp at the time of record creation';
+
+-- migrate:down
+drop index if exists alerts_app_id_type_created_at_idx;
+drop table if exists public.alerts;
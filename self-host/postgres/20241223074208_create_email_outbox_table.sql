@@ -0,0 +1,39 @@
+-- migrate:up
+create table if not exists public.email_outbox (
+    id uuid primary key not null,
+    kind varchar(32) not null,
+    recipient varchar(256) not null,
+    user_id uuid references public.users(id) on delete cascade,
+    app_id uuid references public.apps(id) on delete cascade,
+    payload jsonb not null,
+    status varchar(16) not null default 'pending',
+    attempts int not null default 0,
+    last_error text,
+    next_attempt_at timestamptz not null default now(),
+    sent_at timestamptz,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+
+create index if not exists email_outbox_status_next_attempt_at_idx on public.email_outbox (status, next_attempt_at);
+
+create index if not exists email_outbox_recipient_sent_at_idx on public.email_outbox (recipient, sent_at);
+
+comment on column public.email_outbox.id is 'unique id of the email';
+comment on column public.email_outbox.kind is 'kind of the email, like alert, invite or digest';
+comment on column public.email_outbox.recipient is 'email address of the recipient';
+comment on column public.email_outbox.user_id is 'linked user id of the recipient, if the recipient is a user';
+comment on column public.email_outbox.app_id is 'linked app id, if the email is about an app';
+comment on column public.email_outbox.payload is 'data the email is rendered from';
+comment on column public.email_outbox.status is 'status of the email, either pending, sending, sent or failed';
+comment on column public.email_outbox.attempts is 'number of failed delivery attempts';
+comment on column public.email_outbox.last_error is 'error message of the last failed delivery attempt';
+comment on column public.email_outbox.next_attempt_at is 'utc timestamp after which delivery is attempted next';
+comment on column public.email_outbox.sent_at is 'utc timestamp at the time of delivery';
+comment on column public.email_outbox.created_at is 'utc timestamp at the time of record creation';
+comment on column public.email_outbox.updated_at is 'utc timestamp at the time of record update';
+
+-- migrate:down
+drop index if exists email_outbox_recipient_sent_at_idx;
+drop index if exists email_outbox_status_next_attempt_at_idx;
+drop table if exists public.email_outbox;
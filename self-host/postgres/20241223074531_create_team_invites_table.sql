@@ -0,0 +1,22 @@
+-- migrate:up
+create table if not exists public.team_invites (
+    id uuid primary key not null default gen_random_uuid(),
+    team_id uuid not null references public.teams(id) on delete cascade,
+    email varchar(256) not null,
+    role varchar(256) not null,
+    invited_by_user_id uuid references public.users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    unique (team_id, email)
+);
+
+comment on column public.team_invites.id is 'unique id of the invite';
+comment on column public.team_invites.team_id is 'team id the invitee is invited to';
+comment on column public.team_invites.email is 'lowercased email address of the invitee who has not signed up yet';
+comment on column public.team_invites.role is 'role the invitee joins the team as';
+comment on column public.team_invites.invited_by_user_id is 'id of the user who invited';
+comment on column public.team_invites.created_at is 'utc timestamp at the time of record creation';
+comment on column public.team_invites.updated_at is 'utc timestamp at the time of record update';
+
+-- migrate:down
+drop table if exists public.team_invites;
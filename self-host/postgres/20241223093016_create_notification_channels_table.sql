@@ -0,0 +1,30 @@
+-- migrate:up
+create table if not exists public.notification_channels (
+    id uuid primary key not null,
+    app_id uuid not null references public.apps(id) on delete cascade,
+    type varchar(16) not null,
+    name varchar(256) not null,
+    url text not null,
+    secret varchar(64),
+    alert_types varchar(64)[] not null default '{}',
+    created_by uuid references public.users(id) on delete set null,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+
+create index if not exists notification_channels_app_id_idx on public.notification_channels (app_id);
+
+comment on column public.notification_channels.id is 'unique id of the notification channel';
+comment on column public.notification_channels.app_id is 'linked app id';
+comment on column public.notification_channels.type is 'type of the channel, either webhook or slack';
+comment on column public.notification_channels.name is 'display name of the channel';
+comment on column public.notification_channels.url is 'https url notifications are posted to';
+comment on column public.notification_channels.secret is 'secret used to sign webhook payloads';
+comment on column public.notification_channels.alert_types is 'alert types the channel is subscribed to';
+comment on column public.notification_channels.created_by is 'id of the user who created the channel';
+comment on column public.notification_channels.created_at is 'utc timestamp at the time of record creation';
+comment on column public.notification_channels.updated_at is 'utc timestamp at the time of record update';
+
+-- migrate:down
+drop index if exists notification_channels_app_id_idx;
+drop table if exists public.notification_channels;
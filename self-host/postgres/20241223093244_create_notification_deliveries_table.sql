@@ -0,0 +1,37 @@
+-- migrate:up
+create table if not exists public.notification_deliveries (
+    id uuid primary key not null,
+    channel_id uuid not null references public.notification_channels(id) on delete cascade,
+    alert_type varchar(64) not null,
+    payload jsonb not null,
+    status varchar(16) not null default 'pending',
+    attempts int not null default 0,
+    response_status int,
+    last_error text,
+    next_attempt_at timestamptz not null default now(),
+    delivered_at timestamptz,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+
+create index if not exists notification_deliveries_status_next_attempt_at_idx on public.notification_deliveries (status, next_attempt_at);
+
+create index if not exists notification_deliveries_channel_id_created_at_idx on public.notification_deliveries (channel_id, created_at desc);
+
+comment on column public.notification_deliveries.id is 'unique id of the delivery';
+comment on column public.notification_deliveries.channel_id is 'linked notification channel id';
+comment on column public.notification_deliveries.alert_type is 'type of the alert delivered, or test for test notifications';
+comment on column public.notification_deliveries.payload is 'data the notification is rendered from';
+comment on column public.notification_deliveries.status is 'status of the delivery, either pending, sending, delivered or failed';
+comment on column public.notification_deliveries.attempts is 'number of failed delivery attempts';
+comment on column public.notification_deliveries.response_status is 'http status code of the last delivery attempt';
+comment on column public.notification_deliveries.last_error is 'error message of the last failed delivery attempt';
+comment on column public.notification_deliveries.next_attempt_at is 'utc timestamp after which delivery is attempted next';
+comment on column public.notification_deliveries.delivered_at is 'utc timestamp at the time of delivery';
+comment on column public.notification_deliveries.created_at is 'utc timestamp at the time of record creation';
+comment on column public.notification_deliveries.updated_at is 'utc timestamp at the time of record update';
+
+-- migrate:down
+drop index if exists notification_deliveries_channel_id_created_at_idx;
+drop index if exists notification_deliveries_status_next_attempt_at_idx;
+drop table if exists public.notification_deliveries;
@@ -0,0 +1,12 @@
+-- migrate:up
+alter table if exists public.alert_prefs
+  add column if not exists new_issue_email boolean not null default true,
+  add column if not exists regression_email boolean not null default true;
+
+comment on column public.alert_prefs.new_issue_email is 'user set pref for enabling email on new crash or ANR issues';
+comment on column public.alert_prefs.regression_email is 'user set pref for enabling email on regressed crash or ANR issues';
+
+-- migrate:down
+alter table if exists public.alert_prefs
+  drop column if exists new_issue_email,
+  drop column if exists regression_email;
@@ -0,0 +1,15 @@
+-- migrate:up
+alter table if exists public.app_settings
+  add column if not exists new_issue_latest_version_only boolean not null default false,
+  add column if not exists new_issue_min_events int not null default 0,
+  add column if not exists new_issue_min_users int not null default 0;
+
+comment on column public.app_settings.new_issue_latest_version_only is 'alert on new issues only once they occur on the latest app version';
+comment on column public.app_settings.new_issue_min_events is 'minimum count of events of a new issue before alerting';
+comment on column public.app_settings.new_issue_min_users is 'minimum count of users affected by a new issue before alerting';
+
+-- migrate:down
+alter table if exists public.app_settings
+  drop column if exists new_issue_latest_version_only,
+  drop column if exists new_issue_min_events,
+  drop column if exists new_issue_min_users;
@@ -0,0 +1,43 @@
+-- migrate:up
+create table if not exists public.issue_alerts (
+    id uuid primary key not null,
+    app_id uuid not null references public.apps(id) on delete cascade,
+    alert_type varchar(16) not null,
+    group_type varchar(16) not null,
+    group_id uuid not null,
+    fingerprint varchar(32) not null,
+    title text not null,
+    frame text not null default '',
+    app_version varchar(256) not null,
+    app_build varchar(256) not null,
+    seen_at timestamptz not null,
+    status varchar(16) not null default 'pending',
+    evaluated_at timestamptz,
+    fired_at timestamptz,
+    created_at timestamptz not null default now()
+);
+
+create unique index if not exists issue_alerts_pending_group_idx on public.issue_alerts (group_id, alert_type) where status = 'pending';
+
+create index if not exists issue_alerts_app_id_status_idx on public.issue_alerts (app_id, status);
+
+comment on column public.issue_alerts.id is 'unique id of the issue alert';
+comment on column public.issue_alerts.app_id is 'linked app id';
+comment on column public.issue_alerts.alert_type is 'type of the alert, either new_issue or regression';
+comment on column public.issue_alerts.group_type is 'type of the issue group, either exception or anr';
+comment on column public.issue_alerts.group_id is 'id of the exception or anr group';
+comment on column public.issue_alerts.fingerprint is 'fingerprint of the issue group';
+comment on column public.issue_alerts.title is 'title of the issue';
+comment on column public.issue_alerts.frame is 'top in-app frame of the issue';
+comment on column public.issue_alerts.app_version is 'app version the issue was seen in';
+comment on column public.issue_alerts.app_build is 'app build the issue was seen in';
+comment on column public.issue_alerts.seen_at is 'utc timestamp of the event the issue was first seen or seen again in';
+comment on column public.issue_alerts.status is 'status of the alert, either pending, fired or expired';
+comment on column public.issue_alerts.evaluated_at is 'utc timestamp at the time of last evaluation of alerting thresholds';
+comment on column public.issue_alerts.fired_at is 'utc timestamp at the time the alert fired';
+comment on column public.issue_alerts.created_at is 'utc timestamp at the time of record creation';
+
+-- migrate:down
+drop index if exists issue_alerts_app_id_status_idx;
+drop index if exists issue_alerts_pending_group_idx;
+drop table if exists public.issue_alerts;
@@ -0,0 +1,49 @@
+-- migrate:up
+create table if not exists public.alert_rules (
+    id uuid primary key not null,
+    app_id uuid not null references public.apps(id) on delete cascade,
+    name varchar(256) not null,
+    metric varchar(64) not null,
+    span_name varchar(64) not null default '',
+    event_type varchar(64) not null default '',
+    http_url text not null default '',
+    filters jsonb not null default '{}',
+    condition varchar(16) not null,
+    threshold double precision not null,
+    window_minutes integer not null,
+    min_samples integer not null default 0,
+    enabled boolean not null default true,
+    created_by uuid references public.users(id) on delete set null,
+    last_evaluated_at timestamptz,
+    last_fired_at timestamptz,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now()
+);
+
+create index if not exists alert_rules_app_id_idx on public.alert_rules (app_id);
+
+create index if not exists alert_rules_enabled_last_evaluated_at_idx on public.alert_rules (last_evaluated_at nulls first) where enabled;
+
+comment on column public.alert_rules.id is 'unique id of the alert rule';
+comment on column public.alert_rules.app_id is 'linked app id';
+comment on column public.alert_rules.name is 'display name of the rule';
+comment on column public.alert_rules.metric is 'metric the rule evaluates, like crash_free_sessions or span_p95';
+comment on column public.alert_rules.span_name is 'name of the span for span metrics';
+comment on column public.alert_rules.event_type is 'type of the event for event count metrics';
+comment on column public.alert_rules.http_url is 'part of the url of http events for http metrics';
+comment on column public.alert_rules.filters is 'app filters the metric is computed over, including user defined attribute expressions';
+comment on column public.alert_rules.condition is 'condition firing the rule, either above, below or anomaly';
+comment on column public.alert_rules.threshold is 'threshold of the metric, or minimum percentage deviation from the baseline for anomaly conditions';
+comment on column public.alert_rules.window_minutes is 'length of the evaluation window in minutes';
+comment on column public.alert_rules.min_samples is 'minimum count of samples in the evaluation window for the rule to be evaluated';
+comment on column public.alert_rules.enabled is 'true if the rule is evaluated';
+comment on column public.alert_rules.created_by is 'id of the user who created the rule';
+comment on column public.alert_rules.last_evaluated_at is 'utc timestamp at the time of last evaluation';
+comment on column public.alert_rules.last_fired_at is 'utc timestamp at the time the rule last fired';
+comment on column public.alert_rules.created_at is 'utc timestamp at the time of record creation';
+comment on column public.alert_rules.updated_at is 'utc timestamp at the time of record update';
+
+-- migrate:down
+drop index if exists alert_rules_enabled_last_evaluated_at_idx;
+drop index if exists alert_rules_app_id_idx;
+drop table if exists public.alert_rules;
@@ -0,0 +1,9 @@
+-- migrate:up
+alter table if exists public.alert_prefs
+  add column if not exists alert_rule_email boolean not null default true;
+
+comment on column public.alert_prefs.alert_rule_email is 'user set pref for enabling email on custom alert rules';
+
+-- migrate:down
+alter table if exists public.alert_prefs
+  drop column if exists alert_rule_email;
@@ -0,0 +1,9 @@
+-- migrate:up
+alter table if exists public.alerts
+  add column if not exists rule_id uuid references public.alert_rules(id) on delete set null;
+
+comment on column public.alerts.rule_id is 'id of the alert rule that fired the alert, if any';
+
+-- migrate:down
+alter table if exists public.alerts
+  drop column if exists rule_id;
@@ -0,0 +1,35 @@
+-- migrate:up
+create table if not exists public.digest_prefs (
+    app_id uuid not null references public.apps(id) on delete cascade,
+    user_id uuid not null references public.users(id) on delete cascade,
+    frequency varchar(16) not null default 'off',
+    hour smallint not null default 9,
+    weekday smallint not null default 1,
+    timezone varchar(64) not null default 'UTC',
+    email boolean not null default true,
+    channel_id uuid references public.notification_channels(id) on delete set null,
+    last_sent_at timestamptz,
+    next_send_at timestamptz,
+    created_at timestamptz not null default now(),
+    updated_at timestamptz not null default now(),
+    primary key (app_id, user_id)
+);
+
+create index if not exists digest_prefs_next_send_at_idx on public.digest_prefs (next_send_at) where frequency != 'off';
+
+comment on column public.digest_prefs.app_id is 'linked app id';
+comment on column public.digest_prefs.user_id is 'linked user id';
+comment on column public.digest_prefs.frequency is 'how often the digest is sent, either off, daily or weekly';
+comment on column public.digest_prefs.hour is 'hour of the day, in the user''s timezone, the digest is sent at';
+comment on column public.digest_prefs.weekday is 'day of the week weekly digests are sent on, 0 being sunday';
+comment on column public.digest_prefs.timezone is 'iana timezone of the user';
+comment on column public.digest_prefs.email is 'whether the digest is emailed to the user';
+comment on column public.digest_prefs.channel_id is 'notification channel the digest is delivered to, if any';
+comment on column public.digest_prefs.last_sent_at is 'utc timestamp at the time the last digest was sent';
+comment on column public.digest_prefs.next_send_at is 'utc timestamp at the time the next digest is due';
+comment on column public.digest_prefs.created_at is 'utc timestamp at the time of record creation';
+comment on column public.digest_prefs.updated_at is 'utc timestamp at the time of record update';
+
+-- migrate:down
+drop index if exists digest_prefs_next_send_at_idx;
+drop table if exists public.digest_prefs;
@@ -0,0 +1,43 @@
+-- migrate:up
+create table if not exists public.alert_incidents (
+    id uuid primary key not null,
+    app_id uuid not null references public.apps(id) on delete cascade,
+    rule_id uuid references public.alert_rules(id) on delete set null,
+    type varchar(64) not null,
+    status varchar(16) not null default 'open',
+    message text not null,
+    filters jsonb,
+    snapshot jsonb not null,
+    muted boolean not null default false,
+    opened_at timestamptz not null default now(),
+    acknowledged_at timestamptz,
+    acknowledged_by uuid references public.users(id) on delete set null,
+    resolved_at timestamptz,
+    resolved_by uuid references public.users(id) on delete set null,
+    updated_at timestamptz not null default now()
+);
+
+create unique index if not exists alert_incidents_unresolved_idx on public.alert_incidents (app_id, type, coalesce(rule_id, '00000000-0000-0000-0000-000000000000'::uuid)) where status != 'resolved';
+
+create index if not exists alert_incidents_app_id_opened_at_idx on public.alert_incidents (app_id, opened_at desc);
+
+comment on column public.alert_incidents.id is 'unique id of the incident';
+comment on column public.alert_incidents.app_id is 'linked app id';
+comment on column public.alert_incidents.rule_id is 'id of the alert rule that opened the incident, if any';
+comment on column public.alert_incidents.type is 'type of the alert that opened the incident';
+comment on column public.alert_incidents.status is 'status of the incident, either open, acknowledged or resolved';
+comment on column public.alert_incidents.message is 'human readable summary of the alert that opened the incident';
+comment on column public.alert_incidents.filters is 'snapshot of the filters the metric was computed with, if any';
+comment on column public.alert_incidents.snapshot is 'snapshot of the metric values that opened the incident';
+comment on column public.alert_incidents.muted is 'whether the incident opened while its alerts were muted';
+comment on column public.alert_incidents.opened_at is 'utc timestamp at the time the incident opened';
+comment on column public.alert_incidents.acknowledged_at is 'utc timestamp at the time the incident was acknowledged';
+comment on column public.alert_incidents.acknowledged_by is 'id of the user who acknowledged the incident';
+comment on column public.alert_incidents.resolved_at is 'utc timestamp at the time the incident was resolved';
+comment on column public.alert_incidents.resolved_by is 'id of the user who resolved the incident, null if it resolved on its own';
+comment on column public.alert_incidents.updated_at is 'utc timestamp at the time of record update';
+
+-- migrate:down
+drop index if exists alert_incidents_app_id_opened_at_idx;
+drop index if exists alert_incidents_unresolved_idx;
+drop table if exists public.alert_incidents;
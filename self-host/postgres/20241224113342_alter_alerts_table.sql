@@ -0,0 +1,9 @@
+-- migrate:up
+alter table if exists public.alerts
+  add column if not exists incident_id uuid references public.alert_incidents(id) on delete set null;
+
+comment on column public.alerts.incident_id is 'id of the incident the alert opened, if any';
+
+-- migrate:down
+alter table if exists public.alerts
+  drop column if exists incident_id;
@@ -0,0 +1,24 @@
+-- migrate:up
+create table if not exists public.alert_mutes (
+    id uuid primary key not null,
+    app_id uuid not null references public.apps(id) on delete cascade,
+    alert_type varchar(64),
+    rule_id uuid references public.alert_rules(id) on delete cascade,
+    muted_until timestamptz not null,
+    created_by uuid references public.users(id) on delete set null,
+    created_at timestamptz not null default now()
+);
+
+create index if not exists alert_mutes_app_id_muted_until_idx on public.alert_mutes (app_id, muted_until);
+
+comment on column public.alert_mutes.id is 'unique id of the mute';
+comment on column public.alert_mutes.app_id is 'linked app id';
+comment on column public.alert_mutes.alert_type is 'type of alerts muted, null to mute alerts of all types';
+comment on column public.alert_mutes.rule_id is 'id of the alert rule muted, null to mute alerts of all rules';
+comment on column public.alert_mutes.muted_until is 'utc timestamp until which alerts stay muted';
+comment on column public.alert_mutes.created_by is 'id of the user who muted the alerts';
+comment on column public.alert_mutes.created_at is 'utc timestamp at the time of record creation';
+
+-- migrate:down
+drop index if exists alert_mutes_app_id_muted_until_idx;
+drop table if exists public.alert_mutes;
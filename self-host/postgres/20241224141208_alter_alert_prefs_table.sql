@@ -0,0 +1,9 @@
+-- migrate:up
+alter table if exists public.alert_prefs
+  add column if not exists span_regression_email boolean not null default true;
+
+comment on column public.alert_prefs.span_regression_email is 'user set pref for enabling email on span latency regressions of new versions';
+
+-- migrate:down
+alter table if exists public.alert_prefs
+  drop column if exists span_regression_email;
@@ -0,0 +1,24 @@
+-- migrate:up
+create table if not exists public.span_regression_checks (
+    app_id uuid not null references public.apps(id) on delete cascade,
+    target_version varchar(128) not null,
+    target_version_code varchar(128) not null,
+    base_version varchar(128) not null,
+    base_version_code varchar(128) not null,
+    compared integer not null default 0,
+    regressions integer not null default 0,
+    checked_at timestamptz not null default now(),
+    primary key (app_id, target_version, target_version_code)
+);
+
+comment on column public.span_regression_checks.app_id is 'linked app id';
+comment on column public.span_regression_checks.target_version is 'version name of the newer version that was checked';
+comment on column public.span_regression_checks.target_version_code is 'version code of the newer version that was checked';
+comment on column public.span_regression_checks.base_version is 'version name of the version the newer version was compared against';
+comment on column public.span_regression_checks.base_version_code is 'version code of the version the newer version was compared against';
+comment on column public.span_regression_checks.compared is 'count of root spans compared between the versions';
+comment on column public.span_regression_checks.regressions is 'count of root spans that regressed significantly';
+comment on column public.span_regression_checks.checked_at is 'utc timestamp at the time of the check';
+
+-- migrate:down
+drop table if exists public.span_regression_checks;
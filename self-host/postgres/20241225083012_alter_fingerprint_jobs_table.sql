@@ -0,0 +1,15 @@
+-- migrate:up
+update public.fingerprint_jobs
+set status = 'failed', error = 'superseded by a newer job', updated_at = now(), finished_at = now()
+where status in ('pending', 'running')
+and id not in (
+    select distinct on (app_id) id
+    from public.fingerprint_jobs
+    where status in ('pending', 'running')
+    order by app_id, created_at desc
+);
+
+create unique index if not exists fingerprint_jobs_active_app_idx on public.fingerprint_jobs (app_id) where status in ('pending', 'running');
+
+-- migrate:down
+drop index if exists public.fingerprint_jobs_active_app_idx;
@@ -0,0 +1,20 @@
+-- migrate:up
+alter table if exists public.anr_groups
+  add column if not exists last_event_timestamp timestamptz;
+
+-- updated_at is also bumped by merges & fingerprint
+-- jobs, so it only approximates when existing groups
+-- were last seen
+update public.anr_groups
+set last_event_timestamp = greatest(first_event_timestamp, updated_at)
+where last_event_timestamp is null;
+
+alter table if exists public.anr_groups
+  alter column last_event_timestamp set not null,
+  alter column last_event_timestamp set default now();
+
+comment on column public.anr_groups.last_event_timestamp is 'utc timestamp of the latest event of the group';
+
+-- migrate:down
+alter table if exists public.anr_groups
+  drop column if exists last_event_timestamp;